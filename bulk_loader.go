@@ -0,0 +1,583 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"bytes"
+	"io"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/errors/oserror"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/keyspan"
+	"github.com/cockroachdb/pebble/objstorage/objstorageprovider"
+	"github.com/cockroachdb/pebble/sstable"
+)
+
+const (
+	// bulkLoaderDefaultMemoryLimit is the default value of
+	// BulkLoaderOptions.MemoryLimit.
+	bulkLoaderDefaultMemoryLimit = 64 << 20 // 64 MB
+	// bulkLoaderChunkSize is the size of the chunks of memory that buffer the
+	// keys and values added to a BulkLoader.
+	bulkLoaderChunkSize = 1 << 20 // 1 MB
+	// bulkEntryOverhead approximates the memory used by a bulkEntry, besides
+	// its key and value.
+	bulkEntryOverhead = 48
+	// bulkSpanOverhead approximates the memory used by a bulkSpan, besides its
+	// bounds, suffix and value.
+	bulkSpanOverhead = 104
+)
+
+// BulkLoaderOptions configures a BulkLoader.
+type BulkLoaderOptions struct {
+	// MemoryLimit is the amount of memory used to buffer the added key-value
+	// pairs. When it's reached, the buffered pairs are sorted and spilled to a
+	// temporary sstable.
+	//
+	// The default value is 64 MB.
+	MemoryLimit uint64
+	// TargetFileSize is the target size of the sstables that are ingested.
+	//
+	// The default value is the target file size of the bottommost level.
+	TargetFileSize int64
+	// OnProgress, if set, is called after each sorted run is spilled to a
+	// temporary sstable, and after each sstable to be ingested is written.
+	OnProgress func(BulkLoadProgress)
+}
+
+// BulkLoadProgress describes the progress of a BulkLoader.
+type BulkLoadProgress struct {
+	// KeysAdded and BytesAdded are the number of key-value pairs added, and
+	// their total size.
+	KeysAdded  uint64
+	BytesAdded uint64
+	// Runs is the number of sorted runs spilled to temporary sstables.
+	Runs int
+	// TablesWritten and BytesWritten are the number of sstables to be
+	// ingested that were written, and their total size.
+	TablesWritten int
+	BytesWritten  uint64
+}
+
+// bulkEntry is a key-value pair buffered by a BulkLoader.
+type bulkEntry struct {
+	key, value []byte
+}
+
+// bulkSpan is a range deletion or range key set buffered by a BulkLoader.
+type bulkSpan struct {
+	kind                      InternalKeyKind
+	start, end, suffix, value []byte
+}
+
+// BulkLoader loads key-value pairs added in any order into a DB. The pairs are
+// buffered in memory, and spilled to temporary sstables as sorted runs. Finish
+// merges the runs into sstables that partition the keys, and ingests them
+// atomically.
+//
+// A key added multiple times is loaded with the value that results from merging
+// its values with the DB's Merger, in the order they were added: the first
+// value is the base value, and the later ones are merged as newer operands.
+// The loaded value replaces any value of the key in the DB, as with
+// DB.Ingest.
+//
+// The temporary sstables are created in the DB's directory. If the process
+// exits before they're removed, they're removed when the DB is next opened.
+//
+// A BulkLoader is not safe for concurrent use.
+type BulkLoader struct {
+	d          *DB
+	opts       BulkLoaderOptions
+	writerOpts sstable.WriterOptions
+
+	// entries and spans are the buffered key-value pairs and spans, whose
+	// bytes are stored in chunks. memSize is the memory used by the buffered
+	// pairs and spans.
+	entries []bulkEntry
+	spans   []bulkSpan
+	chunk   []byte
+	memSize uint64
+
+	// runs are the paths of the sorted runs, and outputs the paths of the
+	// sstables to ingest. They're removed by Close.
+	runs    []string
+	outputs []string
+
+	progress BulkLoadProgress
+	finished bool
+}
+
+// NewBulkLoader returns a BulkLoader that loads key-value pairs into the DB.
+// The BulkLoader must be closed after use.
+func (d *DB) NewBulkLoader(opts BulkLoaderOptions) (*BulkLoader, error) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if d.opts.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	if opts.MemoryLimit == 0 {
+		opts.MemoryLimit = bulkLoaderDefaultMemoryLimit
+	}
+	if opts.TargetFileSize <= 0 {
+		opts.TargetFileSize = d.opts.Level(numLevels - 1).TargetFileSize
+	}
+	// The ingested sstables may be placed at any level, so they're written
+	// with the options of L0.
+	writerOpts := d.opts.MakeWriterOptions(0, d.FormatMajorVersion().MaxTableFormat())
+	return &BulkLoader{d: d, opts: opts, writerOpts: writerOpts}, nil
+}
+
+// Add adds a key-value pair to load. The BulkLoader doesn't retain key or
+// value.
+func (b *BulkLoader) Add(key, value []byte) error {
+	if b.finished {
+		return errors.New("pebble: bulk loader already finished")
+	}
+	n := len(key) + len(value)
+	buf := b.alloc(n)
+	copy(buf, key)
+	copy(buf[len(key):], value)
+	b.entries = append(b.entries, bulkEntry{
+		key:   buf[:len(key):len(key)],
+		value: buf[len(key):],
+	})
+	b.memSize += bulkEntryOverhead
+	b.progress.KeysAdded++
+	b.progress.BytesAdded += uint64(n)
+	if b.memSize >= b.opts.MemoryLimit {
+		return b.spill()
+	}
+	return nil
+}
+
+// addSpan adds a range deletion (InternalKeyKindRangeDelete) or range key set
+// (InternalKeyKindRangeKeySet) over [start, end) to load. The spans are
+// written to the ingested sstables, so they apply to the keys of the DB, and
+// not to the key-value pairs loaded with them. The range keys set with the
+// same suffix must not overlap. The BulkLoader doesn't retain the arguments.
+func (b *BulkLoader) addSpan(kind InternalKeyKind, start, end, suffix, value []byte) error {
+	if b.finished {
+		return errors.New("pebble: bulk loader already finished")
+	}
+	if b.d.cmp(start, end) >= 0 {
+		return errors.Errorf("pebble: invalid span [%s, %s)",
+			b.d.opts.Comparer.FormatKey(start), b.d.opts.Comparer.FormatKey(end))
+	}
+	buf := b.alloc(len(start) + len(end) + len(suffix) + len(value))
+	take := func(src []byte) []byte {
+		n := copy(buf, src)
+		dst := buf[:n:n]
+		buf = buf[n:]
+		return dst
+	}
+	b.spans = append(b.spans, bulkSpan{
+		kind:   kind,
+		start:  take(start),
+		end:    take(end),
+		suffix: take(suffix),
+		value:  take(value),
+	})
+	b.memSize += bulkSpanOverhead
+	if b.memSize >= b.opts.MemoryLimit {
+		return b.spill()
+	}
+	return nil
+}
+
+// alloc returns n bytes of the current chunk, allocating a new chunk if it's
+// full.
+func (b *BulkLoader) alloc(n int) []byte {
+	if len(b.chunk)+n > cap(b.chunk) {
+		b.chunk = make([]byte, 0, max(bulkLoaderChunkSize, n))
+		b.memSize += uint64(cap(b.chunk))
+	}
+	start := len(b.chunk)
+	b.chunk = b.chunk[:start+n]
+	return b.chunk[start : start+n : start+n]
+}
+
+// Finish spills the buffered key-value pairs, merges the sorted runs into
+// sstables, and ingests them. No pairs can be added afterwards.
+func (b *BulkLoader) Finish() error {
+	if b.finished {
+		return errors.New("pebble: bulk loader already finished")
+	}
+	if err := b.spill(); err != nil {
+		return err
+	}
+	b.finished = true
+	if len(b.runs) == 0 {
+		return nil
+	}
+	if err := b.merge(); err != nil {
+		return err
+	}
+	return b.d.Ingest(b.outputs)
+}
+
+// Close removes the temporary sstables of the BulkLoader. If Finish wasn't
+// called, or failed, none of the added key-value pairs are loaded.
+func (b *BulkLoader) Close() error {
+	b.finished = true
+	b.entries, b.spans, b.chunk = nil, nil, nil
+	var err error
+	for _, path := range append(b.runs, b.outputs...) {
+		if rmErr := b.d.opts.FS.Remove(path); rmErr != nil && !oserror.IsNotExist(rmErr) {
+			err = firstError(err, rmErr)
+		}
+	}
+	b.runs, b.outputs = nil, nil
+	return err
+}
+
+// createTemp creates a temporary sstable in the DB's directory, and adds its
+// path to paths.
+func (b *BulkLoader) createTemp(paths *[]string) (*sstable.Writer, error) {
+	d := b.d
+	d.mu.Lock()
+	fileNum := d.mu.versions.getNextFileNum()
+	d.mu.Unlock()
+	path := base.MakeFilepath(d.opts.FS, d.dirname, fileTypeTemp, fileNum.DiskFileNum())
+	f, err := d.opts.FS.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	*paths = append(*paths, path)
+	return sstable.NewWriter(objstorageprovider.NewFileWritable(f), b.writerOpts), nil
+}
+
+// spill sorts the buffered key-value pairs and spans, and writes them to a
+// temporary sstable as a sorted run. The duplicate keys within the run are
+// merged.
+func (b *BulkLoader) spill() error {
+	if len(b.entries) == 0 && len(b.spans) == 0 {
+		return nil
+	}
+	d := b.d
+	slices.SortStableFunc(b.entries, func(a, b bulkEntry) int {
+		return d.cmp(a.key, b.key)
+	})
+	w, err := b.createTemp(&b.runs)
+	if err != nil {
+		return err
+	}
+	// The keys of each run have a sequence number that's greater than those of
+	// the runs spilled before, so that the merging iterator returns the values
+	// of a key from newest to oldest.
+	seqNum := uint64(len(b.runs))
+	for i := 0; i < len(b.entries); {
+		e := b.entries[i]
+		value := e.value
+		var closer io.Closer
+		j := i + 1
+		if j < len(b.entries) && d.equal(b.entries[j].key, e.key) {
+			vm, err := d.merge(e.key, e.value)
+			if err != nil {
+				return firstError(err, w.Close())
+			}
+			for ; j < len(b.entries) && d.equal(b.entries[j].key, e.key); j++ {
+				if err := vm.MergeNewer(b.entries[j].value); err != nil {
+					return firstError(err, w.Close())
+				}
+			}
+			if value, closer, err = vm.Finish(true /* includesBase */); err != nil {
+				return firstError(err, w.Close())
+			}
+		}
+		err := w.Add(base.MakeInternalKey(e.key, seqNum, InternalKeyKindSet), value)
+		if closer != nil {
+			err = firstError(err, closer.Close())
+		}
+		if err != nil {
+			return firstError(err, w.Close())
+		}
+		i = j
+	}
+	if err := b.spillSpans(w); err != nil {
+		return firstError(err, w.Close())
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	b.entries = b.entries[:0]
+	b.spans = b.spans[:0]
+	b.chunk = nil
+	b.memSize = 0
+	b.progress.Runs++
+	b.reportProgress()
+	return nil
+}
+
+// spillSpans sorts the buffered spans, and writes them to the sorted run
+// written by w. The range deletions are fragmented, as sstables require.
+func (b *BulkLoader) spillSpans(w *sstable.Writer) error {
+	d := b.d
+	slices.SortStableFunc(b.spans, func(a, b bulkSpan) int {
+		return d.cmp(a.start, b.start)
+	})
+	var err error
+	frag := keyspan.Fragmenter{
+		Cmp:    d.cmp,
+		Format: d.opts.Comparer.FormatKey,
+		Emit: func(s keyspan.Span) {
+			if err == nil {
+				err = w.DeleteRange(s.Start, s.End)
+			}
+		},
+	}
+	for _, s := range b.spans {
+		switch s.kind {
+		case InternalKeyKindRangeDelete:
+			frag.Add(keyspan.Span{
+				Start: s.start,
+				End:   s.end,
+				Keys:  []keyspan.Key{{Trailer: base.MakeTrailer(0, InternalKeyKindRangeDelete)}},
+			})
+		case InternalKeyKindRangeKeySet:
+			if err == nil {
+				err = w.RangeKeySet(s.start, s.end, s.suffix, s.value)
+			}
+		}
+	}
+	frag.Finish()
+	return err
+}
+
+// merge merges the sorted runs into the sstables to ingest, splitting them at
+// the target file size. The spans of the runs are merged and written to the
+// sstables whose key range they overlap, truncated to it.
+func (b *BulkLoader) merge() (err error) {
+	d := b.d
+	iters := make([]internalIterator, 0, len(b.runs))
+	var spanIters []keyspan.FragmentIterator
+	var readers []*sstable.Reader
+	defer func() {
+		// The iterators are closed by the merging iterators once they're
+		// created.
+		for _, iter := range iters {
+			err = firstError(err, iter.Close())
+		}
+		for _, iter := range spanIters {
+			err = firstError(err, iter.Close())
+		}
+		for _, r := range readers {
+			err = firstError(err, r.Close())
+		}
+	}()
+	for _, path := range b.runs {
+		f, err := d.opts.FS.Open(path)
+		if err != nil {
+			return err
+		}
+		readable, err := sstable.NewSimpleReadable(f)
+		if err != nil {
+			return firstError(err, f.Close())
+		}
+		r, err := sstable.NewReader(readable, d.opts.MakeReaderOptions())
+		if err != nil {
+			return firstError(err, readable.Close())
+		}
+		readers = append(readers, r)
+		iter, err := r.NewIter(nil /* lower */, nil /* upper */)
+		if err != nil {
+			return err
+		}
+		iters = append(iters, iter)
+		for _, newIter := range []func() (keyspan.FragmentIterator, error){
+			r.NewRawRangeDelIter, r.NewRawRangeKeyIter,
+		} {
+			spanIter, err := newIter()
+			if err != nil {
+				return err
+			}
+			if spanIter != nil {
+				spanIters = append(spanIters, spanIter)
+			}
+		}
+	}
+
+	var stats base.InternalIteratorStats
+	iter := newMergingIter(d.opts.Logger, &stats, d.cmp, d.split, iters...)
+	iters = nil
+	spanIter := &keyspan.MergingIter{}
+	spanIter.Init(d.cmp, keyspan.VisibleTransform(base.InternalKeySeqNumMax),
+		new(keyspan.MergingBuffers), spanIters...)
+	spanIters = nil
+	var w *sstable.Writer
+	defer func() {
+		if w != nil {
+			err = firstError(err, w.Close())
+		}
+		err = firstError(err, iter.Close())
+		err = firstError(err, spanIter.Close())
+	}()
+	var spans bulkSpanCursor
+	if err := spans.init(spanIter); err != nil {
+		return err
+	}
+	var keyBuf, valueBuf []byte
+	key, lv := iter.First()
+	for key != nil {
+		// An sstable that reached the target size ends before the key, so
+		// that its spans are truncated to the key.
+		if w != nil && w.EstimatedSize() >= uint64(b.opts.TargetFileSize) {
+			err := b.finishOutput(w, &spans, key.UserKey)
+			w = nil
+			if err != nil {
+				return err
+			}
+		}
+		if w == nil {
+			if w, err = b.createTemp(&b.outputs); err != nil {
+				return err
+			}
+		}
+
+		keyBuf = append(keyBuf[:0], key.UserKey...)
+		v, _, err := lv.Value(nil)
+		if err != nil {
+			return err
+		}
+		valueBuf = append(valueBuf[:0], v...)
+		value := valueBuf
+		var closer io.Closer
+		key, lv = iter.Next()
+		if key != nil && d.equal(key.UserKey, keyBuf) {
+			// The values of a key are returned from newest to oldest.
+			vm, err := d.merge(keyBuf, valueBuf)
+			if err != nil {
+				return err
+			}
+			for ; key != nil && d.equal(key.UserKey, keyBuf); key, lv = iter.Next() {
+				v, _, err := lv.Value(nil)
+				if err != nil {
+					return err
+				}
+				if err := vm.MergeOlder(v); err != nil {
+					return err
+				}
+			}
+			if value, closer, err = vm.Finish(true /* includesBase */); err != nil {
+				return err
+			}
+		}
+
+		err = w.Set(keyBuf, value)
+		if closer != nil {
+			err = firstError(err, closer.Close())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	// The last sstable holds the remaining spans, even if there are no keys.
+	if w == nil && spans.span != nil {
+		if w, err = b.createTemp(&b.outputs); err != nil {
+			return err
+		}
+	}
+	if w != nil {
+		err := b.finishOutput(w, &spans, nil /* end */)
+		w = nil
+		return err
+	}
+	return nil
+}
+
+// bulkSpanCursor walks the merged spans of the sorted runs as they're written
+// to the sstables to ingest.
+type bulkSpanCursor struct {
+	iter keyspan.FragmentIterator
+	// span is the current span, or nil once the spans are exhausted. Its part
+	// before start was written to the previous sstable.
+	span     *keyspan.Span
+	start    []byte
+	startBuf []byte
+}
+
+func (c *bulkSpanCursor) init(iter keyspan.FragmentIterator) error {
+	c.iter = iter
+	if c.span = iter.First(); c.span == nil {
+		return iter.Error()
+	}
+	c.start = c.span.Start
+	return nil
+}
+
+// write writes the spans that start before end to w, truncated to end. The
+// remainder of a span that extends past end is left to the next sstable. A nil
+// end writes all the remaining spans.
+func (c *bulkSpanCursor) write(cmp Compare, w *sstable.Writer, end []byte) error {
+	for c.span != nil && (end == nil || cmp(c.start, end) < 0) {
+		spanEnd := c.span.End
+		truncated := end != nil && cmp(spanEnd, end) > 0
+		if truncated {
+			spanEnd = end
+		}
+		// The span may hold the same key from multiple runs: a range deletion
+		// is written once, and a range key set once per suffix.
+		var deleted bool
+		for i, k := range c.span.Keys {
+			var err error
+			switch k.Kind() {
+			case InternalKeyKindRangeDelete:
+				if !deleted {
+					deleted = true
+					err = w.DeleteRange(c.start, spanEnd)
+				}
+			case InternalKeyKindRangeKeySet:
+				if !slices.ContainsFunc(c.span.Keys[:i], func(prev keyspan.Key) bool {
+					return prev.Kind() == InternalKeyKindRangeKeySet && bytes.Equal(prev.Suffix, k.Suffix)
+				}) {
+					err = w.RangeKeySet(c.start, spanEnd, k.Suffix, k.Value)
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if truncated {
+			c.startBuf = append(c.startBuf[:0], end...)
+			c.start = c.startBuf
+			break
+		}
+		if c.span = c.iter.Next(); c.span != nil {
+			c.start = c.span.Start
+		}
+	}
+	return c.iter.Error()
+}
+
+// finishOutput writes the spans that start before end to the sstable to
+// ingest, truncated to end, and closes its writer.
+func (b *BulkLoader) finishOutput(w *sstable.Writer, spans *bulkSpanCursor, end []byte) error {
+	if err := spans.write(b.d.cmp, w, end); err != nil {
+		return firstError(err, w.Close())
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	meta, err := w.Metadata()
+	if err != nil {
+		return err
+	}
+	b.progress.TablesWritten++
+	b.progress.BytesWritten += meta.Size
+	b.reportProgress()
+	return nil
+}
+
+func (b *BulkLoader) reportProgress() {
+	if b.opts.OnProgress != nil {
+		b.opts.OnProgress(b.progress)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkLoader(t *testing.T) {
+	fs := vfs.NewMem()
+	d, err := Open("", &Options{FS: fs, FormatMajorVersion: FormatNewest})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// tempFiles returns the temporary files in the DB's directory.
+	tempFiles := func() []string {
+		ls, err := fs.List("")
+		require.NoError(t, err)
+		var temp []string
+		for _, name := range ls {
+			if strings.HasSuffix(name, ".dbtmp") {
+				temp = append(temp, name)
+			}
+		}
+		return temp
+	}
+	key := func(i int) []byte { return []byte(fmt.Sprintf("%05d", i)) }
+
+	// An existing value of a loaded key is replaced.
+	require.NoError(t, d.Set(key(1), []byte("existing"), nil))
+	require.NoError(t, d.Set([]byte("untouched"), []byte("existing"), nil))
+	require.NoError(t, d.Flush())
+
+	var progress []BulkLoadProgress
+	b, err := d.NewBulkLoader(BulkLoaderOptions{
+		MemoryLimit:    64 << 10,
+		TargetFileSize: 16 << 10,
+		OnProgress: func(p BulkLoadProgress) {
+			progress = append(progress, p)
+		},
+	})
+	require.NoError(t, err)
+
+	// Add the keys in random order. Some keys are added multiple times, and
+	// their values are concatenated by the default merger in the order they
+	// were added, both within and across sorted runs.
+	const n = 5000
+	rng := rand.New(rand.NewSource(1))
+	expected := map[string]string{}
+	for round := 0; round < 3; round++ {
+		for _, i := range rng.Perm(n) {
+			if round > 0 && i%(round*7) != 0 {
+				continue
+			}
+			value := fmt.Sprintf("%d.%d;", i, round)
+			require.NoError(t, b.Add(key(i), []byte(value)))
+			expected[string(key(i))] += value
+		}
+	}
+	require.NotEmpty(t, tempFiles())
+	require.NoError(t, b.Finish())
+	require.Error(t, b.Add(key(0), nil))
+	require.NoError(t, b.Close())
+	require.Empty(t, tempFiles())
+
+	require.NotEmpty(t, progress)
+	last := progress[len(progress)-1]
+	require.Greater(t, last.Runs, 1)
+	require.Greater(t, last.TablesWritten, 1)
+	require.Greater(t, last.BytesWritten, uint64(0))
+	require.Equal(t, uint64(n+n/7+1+n/14+1), last.KeysAdded)
+	// The tables were ingested atomically, by a single ingestion.
+	require.Equal(t, uint64(1), d.Metrics().Ingest.Count)
+	require.Equal(t, uint64(last.TablesWritten), d.Metrics().Total().TablesIngested)
+
+	expected["untouched"] = "existing"
+	iter, _ := d.NewIter(nil)
+	var count int
+	for valid := iter.First(); valid; valid = iter.Next() {
+		require.Equal(t, expected[string(iter.Key())], string(iter.Value()), "key %s", iter.Key())
+		count++
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, len(expected), count)
+
+	// A bulk loader closed without finishing loads nothing, and removes its
+	// temporary files.
+	b, err = d.NewBulkLoader(BulkLoaderOptions{MemoryLimit: 1 << 10})
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, b.Add([]byte(fmt.Sprintf("abandoned-%03d", i)), []byte("x")))
+	}
+	require.NotEmpty(t, tempFiles())
+	require.NoError(t, b.Close())
+	require.Empty(t, tempFiles())
+	_, closer, err := d.Get([]byte("abandoned-000"))
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Nil(t, closer)
+
+	// A bulk loader whose merge fails, here after opening some of the sorted
+	// runs, loads nothing, and removes its temporary files.
+	b, err = d.NewBulkLoader(BulkLoaderOptions{MemoryLimit: 1 << 10})
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, b.Add([]byte(fmt.Sprintf("failed-%03d", i)), []byte("x")))
+	}
+	require.Greater(t, len(b.runs), 1)
+	require.NoError(t, fs.Remove(b.runs[len(b.runs)-1]))
+	require.Error(t, b.Finish())
+	require.NoError(t, b.Close())
+	require.Empty(t, tempFiles())
+	_, closer, err = d.Get([]byte("failed-000"))
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Nil(t, closer)
+
+	// Finishing a bulk loader without keys is a no-op.
+	b, err = d.NewBulkLoader(BulkLoaderOptions{})
+	require.NoError(t, err)
+	require.NoError(t, b.Finish())
+	require.NoError(t, b.Close())
+}
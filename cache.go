@@ -21,3 +21,31 @@ type Cache = cache.Cache
 func NewCache(size int64) *cache.Cache {
 	return cache.New(size)
 }
+
+// CacheTierOptions exports the cache.TierOptions type.
+type CacheTierOptions = cache.TierOptions
+
+// NewTieredCache creates a new cache of the specified size with a secondary
+// tier stored on a local file system, typically a fast local SSD. Blocks
+// evicted from the in-memory cache are written to the secondary tier, and
+// blocks that miss in memory are read from the secondary tier when present
+// instead of from their sstable. The blocks of the DBs using the cache are
+// persisted in the secondary tier when the cache is closed, and are available
+// to the DBs when they're next opened with a cache using the same directory;
+// see CacheTierOptions.Dir. See NewCache for the reference counting semantics
+// of the returned cache.
+//
+//	c, err := pebble.NewTieredCache(size, &pebble.CacheTierOptions{
+//		Dir:  "/mnt/nvme/pebble-cache",
+//		Size: 64 << 30,
+//	})
+//	defer c.Unref()
+//	d, err := pebble.Open(pebble.Options{Cache: c})
+func NewTieredCache(size int64, opts *CacheTierOptions) (*cache.Cache, error) {
+	return cache.NewTiered(size, opts)
+}
+
+// CacheEvictionPressureInfo exports the cache.EvictionPressureInfo type. See
+// EventListener.BlockCacheEvictionPressure and
+// Cache.SetEvictionPressureListener.
+type CacheEvictionPressureInfo = cache.EvictionPressureInfo
@@ -0,0 +1,121 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+
+	"github.com/cockroachdb/errors/oserror"
+	"github.com/cockroachdb/pebble/internal/base"
+)
+
+// cacheTierIDFilename is the name of the file in the data directory that holds
+// the identity of the DB, a random 128-bit value encoded in hex. Together with
+// the data directory, it forms the namespace under which the blocks of the DB
+// are persisted in the secondary tier of the block cache.
+//
+// File numbers are only unique within a DB, so the identity ensures that the
+// blocks persisted for a DB are never handed to a different DB that reuses its
+// file numbers. The identity is generated when the DB is created, so a DB
+// recreated in the same directory has a new identity, and it isn't copied by
+// DB.Checkpoint.
+const cacheTierIDFilename = "CACHE-TIER-ID"
+
+// cacheTierID returns the identity of the DB, generating and persisting a new
+// identity if the DB was just created or if the CACHE-TIER-ID file is missing
+// or invalid. An empty identity is returned if the DB is read-only and doesn't
+// have one.
+func (d *DB) cacheTierID(created bool) (string, error) {
+	fs := d.opts.FS
+	path := fs.PathJoin(d.dirname, cacheTierIDFilename)
+	if !created {
+		f, err := fs.Open(path)
+		if err == nil {
+			buf, err := io.ReadAll(f)
+			_ = f.Close()
+			if err != nil {
+				return "", err
+			}
+			if _, err := hex.DecodeString(string(buf)); err == nil && len(buf) == 32 {
+				return string(buf), nil
+			}
+		} else if !oserror.IsNotExist(err) {
+			return "", err
+		}
+	}
+	if d.opts.ReadOnly {
+		return "", nil
+	}
+
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	buf := []byte(hex.EncodeToString(id[:]))
+	tmpPath := path + ".tmp"
+	f, err := fs.Create(tmpPath)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(buf); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := fs.Rename(tmpPath, path); err != nil {
+		return "", err
+	}
+	if err := d.dataDir.Sync(); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// claimCacheTierNamespace claims the namespace of the DB in the secondary tier
+// of the block cache, so that the blocks of the DB in the tier are persisted
+// across restarts, taking over the blocks persisted by a previous instance of
+// the DB that belong to tables that are still live. It is a no-op if the block
+// cache doesn't have a secondary tier. Failures are logged: the DB still uses
+// the tier, but its blocks aren't persisted.
+//
+// d.mu must be held when calling this.
+func (d *DB) claimCacheTierNamespace(created bool) {
+	if !d.opts.Cache.HasTier() {
+		return
+	}
+	id, err := d.cacheTierID(created)
+	if err != nil {
+		d.opts.Logger.Errorf("pebble: unable to read or create %s: %v", cacheTierIDFilename, err)
+		return
+	}
+	if id == "" {
+		return
+	}
+
+	vers := d.mu.versions.currentVersion()
+	liveBackings := make(map[base.DiskFileNum]struct{})
+	for level := range vers.Levels {
+		iter := vers.Levels[level].Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			liveBackings[f.FileBacking.DiskFileNum] = struct{}{}
+		}
+	}
+	namespace := d.dirname + "@" + id
+	if !d.opts.Cache.ClaimTierNamespace(d.cacheID, namespace, func(fileNum base.DiskFileNum) bool {
+		_, ok := liveBackings[fileNum]
+		return ok
+	}) {
+		d.opts.Logger.Infof("pebble: block cache tier namespace %q is in use by another DB; "+
+			"the blocks of this DB won't be persisted in the tier", namespace)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+// cacheTierTestDB writes 4000 keys with values consisting of the given byte to
+// the DB and flushes them.
+func cacheTierTestDB(t *testing.T, d *DB, v byte) {
+	value := bytes.Repeat([]byte{v}, 1024)
+	for i := 0; i < 4000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("key%06d", i)), value, nil))
+	}
+	require.NoError(t, d.Flush())
+}
+
+// cacheTierTestScan scans the DB, verifying that each of the 4000 keys has a
+// value consisting of the given byte.
+func cacheTierTestScan(t *testing.T, d *DB, v byte) {
+	value := bytes.Repeat([]byte{v}, 1024)
+	iter, _ := d.NewIter(nil)
+	n := 0
+	for valid := iter.First(); valid; valid = iter.Next() {
+		require.Equal(t, value, iter.Value())
+		n++
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, 4000, n)
+}
+
+func TestBlockCacheTierPersistence(t *testing.T) {
+	fs := vfs.NewMem()
+	tierOpts := &CacheTierOptions{FS: fs, Dir: "cache-tier", Size: 16 << 20}
+
+	c, err := NewTieredCache(1<<20, tierOpts)
+	require.NoError(t, err)
+	d, err := Open("db", &Options{FS: fs, Cache: c, MemTableSize: 256 << 10})
+	require.NoError(t, err)
+	cacheTierTestDB(t, d, 'a')
+	cacheTierTestScan(t, d, 'a')
+	c.WaitForTierWritesForTesting()
+	written := c.TierMetrics().Count
+	require.NotZero(t, written)
+	require.NoError(t, d.Close())
+	c.Unref()
+
+	// The blocks written to the tier before the restart are read from it.
+	c, err = NewTieredCache(1<<20, tierOpts)
+	require.NoError(t, err)
+	require.Equal(t, written, c.TierMetrics().Count)
+	d, err = Open("db", &Options{FS: fs, Cache: c, MemTableSize: 256 << 10})
+	require.NoError(t, err)
+	require.Equal(t, written, c.TierMetrics().Count)
+	cacheTierTestScan(t, d, 'a')
+	require.NotZero(t, c.TierMetrics().Hits)
+
+	// A checkpoint of the DB has the same file numbers, but not the same
+	// identity, so the blocks of the DB aren't served to it even once it
+	// replaces the DB's directory.
+	require.NoError(t, d.Checkpoint("checkpoint"))
+	require.NoError(t, d.Close())
+	c.Unref()
+	d, err = Open("other", &Options{FS: fs, MemTableSize: 256 << 10})
+	require.NoError(t, err)
+	cacheTierTestDB(t, d, 'b')
+	require.NoError(t, d.Close())
+	require.NoError(t, fs.RemoveAll("db"))
+	require.NoError(t, fs.Rename("other", "db"))
+
+	c, err = NewTieredCache(1<<20, tierOpts)
+	require.NoError(t, err)
+	defer c.Unref()
+	d, err = Open("db", &Options{FS: fs, Cache: c, MemTableSize: 256 << 10})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	cacheTierTestScan(t, d, 'b')
+	require.Zero(t, c.TierMetrics().Hits)
+	d2, err := Open("checkpoint", &Options{FS: fs, Cache: c, MemTableSize: 256 << 10})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d2.Close()) }()
+	cacheTierTestScan(t, d2, 'a')
+	require.Zero(t, c.TierMetrics().Hits)
+}
+
+func TestBlockCacheTierSharedCache(t *testing.T) {
+	tierFS := vfs.NewMem()
+	tierOpts := &CacheTierOptions{FS: tierFS, Dir: "cache-tier", Size: 64 << 20}
+	// NB: The memtable size of each DB is reserved in the cache.
+	c, err := NewTieredCache(2<<20, tierOpts)
+	require.NoError(t, err)
+
+	// Two DBs using the same cache claim distinct namespaces, and the blocks of
+	// both are persisted.
+	fs1, fs2 := vfs.NewMem(), vfs.NewMem()
+	var log1, log2 base.InMemLogger
+	d1, err := Open("db", &Options{FS: fs1, Cache: c, Logger: &log1, MemTableSize: 256 << 10})
+	require.NoError(t, err)
+	d2, err := Open("db", &Options{FS: fs2, Cache: c, Logger: &log2, MemTableSize: 256 << 10})
+	require.NoError(t, err)
+	cacheTierTestDB(t, d1, 'a')
+	cacheTierTestDB(t, d2, 'b')
+	cacheTierTestScan(t, d1, 'a')
+	cacheTierTestScan(t, d2, 'b')
+	c.WaitForTierWritesForTesting()
+
+	// A copy of a DB's directory has the same identity, so it can't claim the
+	// namespace while the DB is open. Its blocks aren't persisted, and the
+	// failure is logged.
+	fs3 := vfs.NewMem()
+	require.NoError(t, fs3.MkdirAll("db", 0755))
+	ls, err := fs1.List("db")
+	require.NoError(t, err)
+	for _, name := range ls {
+		if name == "LOCK" {
+			continue
+		}
+		require.NoError(t, vfs.CopyAcrossFS(fs1, fs1.PathJoin("db", name), fs3, fs3.PathJoin("db", name)))
+	}
+	var log3 base.InMemLogger
+	d3, err := Open("db", &Options{FS: fs3, Cache: c, Logger: &log3, MemTableSize: 256 << 10})
+	require.NoError(t, err)
+	cacheTierTestScan(t, d3, 'a')
+	require.NoError(t, d3.Close())
+	require.Contains(t, log3.String(), "is in use by another DB")
+	require.NotContains(t, log1.String(), "is in use by another DB")
+	require.NotContains(t, log2.String(), "is in use by another DB")
+	require.NoError(t, d1.Close())
+	require.NoError(t, d2.Close())
+	c.Unref()
+
+	c, err = NewTieredCache(2<<20, tierOpts)
+	require.NoError(t, err)
+	defer c.Unref()
+	for _, db := range []struct {
+		fs vfs.FS
+		v  byte
+	}{{fs1, 'a'}, {fs2, 'b'}} {
+		hits := c.TierMetrics().Hits
+		d, err := Open("db", &Options{FS: db.fs, Cache: c, MemTableSize: 256 << 10})
+		require.NoError(t, err)
+		cacheTierTestScan(t, d, db.v)
+		require.Greater(t, c.TierMetrics().Hits, hits)
+		require.NoError(t, d.Close())
+	}
+}
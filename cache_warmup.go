@@ -0,0 +1,305 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/errors/oserror"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/cache"
+	"github.com/cockroachdb/pebble/internal/crc"
+	"github.com/cockroachdb/pebble/internal/rate"
+	"github.com/cockroachdb/pebble/sstable"
+)
+
+// blockCacheKeysFilename is the name of the file in the data directory that
+// holds the keys of the blocks that were resident in the block cache when it
+// was last written.
+//
+// The file consists of a version number followed by a sequence of per-file
+// records, each holding the file number, the number of blocks and the offsets
+// of the blocks in increasing order (delta encoded), all encoded as uvarints.
+// The file ends with a CRC-32C of the preceding contents.
+const blockCacheKeysFilename = "BLOCK-CACHE-KEYS"
+
+const blockCacheKeysVersion = 1
+
+// blockCacheWarmupMaxWait is the longest that the warm-up waits for the rate
+// limiter before checking whether the DB is being closed.
+const blockCacheWarmupMaxWait = 100 * time.Millisecond
+
+func encodeBlockCacheKeys(keys []cache.BlockKey) []byte {
+	slices.SortFunc(keys, func(a, b cache.BlockKey) int {
+		if c := cmp.Compare(a.FileNum, b.FileNum); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+	buf := binary.AppendUvarint(nil, blockCacheKeysVersion)
+	for i := 0; i < len(keys); {
+		j := i + 1
+		for j < len(keys) && keys[j].FileNum == keys[i].FileNum {
+			j++
+		}
+		buf = binary.AppendUvarint(buf, uint64(keys[i].FileNum))
+		buf = binary.AppendUvarint(buf, uint64(j-i))
+		var prev uint64
+		for _, k := range keys[i:j] {
+			buf = binary.AppendUvarint(buf, k.Offset-prev)
+			prev = k.Offset
+		}
+		i = j
+	}
+	return binary.LittleEndian.AppendUint32(buf, crc.New(buf).Value())
+}
+
+func decodeBlockCacheKeys(buf []byte) (map[base.DiskFileNum][]uint64, int, error) {
+	errCorrupt := base.CorruptionErrorf("pebble: corrupt %s file", errors.Safe(blockCacheKeysFilename))
+	if len(buf) < 4 {
+		return nil, 0, errCorrupt
+	}
+	buf, checksum := buf[:len(buf)-4], binary.LittleEndian.Uint32(buf[len(buf)-4:])
+	if crc.New(buf).Value() != checksum {
+		return nil, 0, errCorrupt
+	}
+	version, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return nil, 0, errCorrupt
+	}
+	if version != blockCacheKeysVersion {
+		return nil, 0, errors.Errorf("pebble: unsupported %s version %d",
+			errors.Safe(blockCacheKeysFilename), errors.Safe(version))
+	}
+	buf = buf[n:]
+	readUvarint := func() (uint64, bool) {
+		v, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return 0, false
+		}
+		buf = buf[n:]
+		return v, true
+	}
+
+	files := make(map[base.DiskFileNum][]uint64)
+	var total int
+	for len(buf) > 0 {
+		fileNum, ok1 := readUvarint()
+		count, ok2 := readUvarint()
+		if !ok1 || !ok2 || count > uint64(len(buf)) {
+			return nil, 0, errCorrupt
+		}
+		offsets := make([]uint64, count)
+		var prev uint64
+		for i := range offsets {
+			delta, ok := readUvarint()
+			if !ok {
+				return nil, 0, errCorrupt
+			}
+			prev += delta
+			offsets[i] = prev
+		}
+		files[base.DiskFileNum(fileNum)] = offsets
+		total += len(offsets)
+	}
+	return files, total, nil
+}
+
+// persistBlockCacheKeys writes the keys of the blocks belonging to the DB that
+// are resident in the block cache to the BLOCK-CACHE-KEYS file. The file is
+// written to a temporary path and renamed into place, and the data directory
+// is synced, so a crash never leaves a partially written file behind.
+func (d *DB) persistBlockCacheKeys() error {
+	buf := encodeBlockCacheKeys(d.opts.Cache.ResidentBlocks(d.cacheID))
+	fs := d.opts.FS
+	path := fs.PathJoin(d.dirname, blockCacheKeysFilename)
+	tmpPath := path + ".tmp"
+	f, err := fs.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := fs.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return d.dataDir.Sync()
+}
+
+// readBlockCacheKeys reads the BLOCK-CACHE-KEYS file, returning the offsets of
+// the listed blocks grouped by file and the total number of blocks. A missing
+// file is not an error.
+func (d *DB) readBlockCacheKeys() (map[base.DiskFileNum][]uint64, int, error) {
+	f, err := d.opts.FS.Open(d.opts.FS.PathJoin(d.dirname, blockCacheKeysFilename))
+	if err != nil {
+		if oserror.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+	defer f.Close()
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	return decodeBlockCacheKeys(buf)
+}
+
+// startBlockCacheWarmup starts the goroutine that warms up the block cache from
+// the BLOCK-CACHE-KEYS file, and unless the DB is read-only, the goroutine that
+// periodically rewrites the file. It is a no-op if
+// Options.BlockCacheWarmup.PersistInterval is zero.
+func (d *DB) startBlockCacheWarmup() {
+	if d.opts.BlockCacheWarmup.PersistInterval <= 0 {
+		return
+	}
+	d.cacheWarmup.stopCh = make(chan struct{})
+	d.cacheWarmup.inProgress.Store(true)
+	d.cacheWarmup.wg.Add(1)
+	go d.warmBlockCache()
+	if !d.opts.ReadOnly {
+		d.cacheWarmup.wg.Add(1)
+		go d.persistBlockCacheKeysLoop()
+	}
+}
+
+// stopBlockCacheWarmup stops the goroutines started by startBlockCacheWarmup
+// and writes the BLOCK-CACHE-KEYS file a final time. It must be called without
+// DB.mu held, since the goroutines may need to acquire it in order to release
+// the read states they hold.
+func (d *DB) stopBlockCacheWarmup() {
+	if d.cacheWarmup.stopCh == nil {
+		return
+	}
+	d.cacheWarmup.stopOnce.Do(func() {
+		close(d.cacheWarmup.stopCh)
+		d.cacheWarmup.wg.Wait()
+		if !d.opts.ReadOnly {
+			if err := d.persistBlockCacheKeys(); err != nil {
+				d.opts.Logger.Errorf("pebble: unable to persist block cache keys: %v", err)
+			}
+		}
+	})
+}
+
+func (d *DB) persistBlockCacheKeysLoop() {
+	defer d.cacheWarmup.wg.Done()
+	ticker := time.NewTicker(d.opts.BlockCacheWarmup.PersistInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.cacheWarmup.stopCh:
+			return
+		case <-ticker.C:
+			// Don't overwrite the keys persisted by the previous incarnation of
+			// the DB until the warm-up has loaded them.
+			if d.cacheWarmup.inProgress.Load() {
+				continue
+			}
+			if err := d.persistBlockCacheKeys(); err != nil {
+				d.opts.Logger.Errorf("pebble: unable to persist block cache keys: %v", err)
+			}
+		}
+	}
+}
+
+// warmBlockCache reads the blocks listed in the BLOCK-CACHE-KEYS file into the
+// block cache, at a rate limited by Options.BlockCacheWarmup.BytesPerSecond.
+// Blocks belonging to sstables that are no longer live are skipped.
+func (d *DB) warmBlockCache() {
+	defer d.cacheWarmup.wg.Done()
+	defer d.cacheWarmup.inProgress.Store(false)
+
+	files, total, err := d.readBlockCacheKeys()
+	if err != nil {
+		d.opts.Logger.Errorf("pebble: unable to read block cache keys: %v", err)
+		return
+	}
+	if total == 0 {
+		return
+	}
+	d.cacheWarmup.blocks.Store(int64(total))
+
+	type warmupFile struct {
+		level   int
+		meta    *fileMetadata
+		offsets []uint64
+	}
+	var warmupFiles []warmupFile
+	rs := d.loadReadState()
+	for level := range rs.current.Levels {
+		iter := rs.current.Levels[level].Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			// Virtual sstables share the blocks of their backing sstable, which
+			// are only warmed up once.
+			if offsets, ok := files[f.FileBacking.DiskFileNum]; ok {
+				warmupFiles = append(warmupFiles, warmupFile{level: level, meta: f, offsets: offsets})
+				delete(files, f.FileBacking.DiskFileNum)
+			}
+		}
+	}
+	rs.unref()
+	for _, offsets := range files {
+		d.cacheWarmup.blocksProcessed.Add(int64(len(offsets)))
+	}
+
+	bytesPerSecond := float64(d.opts.BlockCacheWarmup.BytesPerSecond)
+	limiter := rate.NewLimiter(bytesPerSecond, bytesPerSecond)
+	stopped := func() bool {
+		select {
+		case <-d.cacheWarmup.stopCh:
+			return true
+		default:
+			return false
+		}
+	}
+	// The wait happens while a read state is held, so it's cut short when the
+	// DB is closed rather than delaying Close.
+	wait := func(length uint64) error {
+		if !limiter.WaitCancellable(float64(length), blockCacheWarmupMaxWait, stopped) {
+			return ErrClosed
+		}
+		return nil
+	}
+	for _, wf := range warmupFiles {
+		// Hold a read state while the file is read so that the file isn't
+		// deleted, and skip the file if it was compacted away in the meantime.
+		rs := d.loadReadState()
+		var blocks int
+		var bytes uint64
+		var err error
+		if rs.current.Contains(wf.level, d.cmp, wf.meta) {
+			err = d.tableCache.withBackingReader(wf.meta, func(r *sstable.Reader) error {
+				var err error
+				blocks, bytes, err = r.WarmBlocks(context.Background(), wf.offsets, wait)
+				return err
+			})
+		}
+		rs.unref()
+		d.cacheWarmup.blocksProcessed.Add(int64(len(wf.offsets)))
+		d.cacheWarmup.blocksLoaded.Add(int64(blocks))
+		d.cacheWarmup.bytesLoaded.Add(int64(bytes))
+		if errors.Is(err, ErrClosed) {
+			return
+		} else if err != nil {
+			d.opts.Logger.Errorf("pebble: unable to warm up block cache from %s: %v", wf.meta.FileNum, err)
+		}
+	}
+}
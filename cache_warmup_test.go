@@ -0,0 +1,210 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/cache"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/rand"
+)
+
+func TestBlockCacheKeysEncoding(t *testing.T) {
+	rng := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
+	var keys []cache.BlockKey
+	want := make(map[base.DiskFileNum][]uint64)
+	for f := 1; f < 20; f++ {
+		fileNum := base.DiskFileNum(rng.Intn(1000))
+		if _, ok := want[fileNum]; ok {
+			continue
+		}
+		var offset uint64
+		for i := rng.Intn(50); i >= 0; i-- {
+			offset += uint64(rng.Intn(1 << 20))
+			keys = append(keys, cache.BlockKey{FileNum: fileNum, Offset: offset})
+			want[fileNum] = append(want[fileNum], offset)
+		}
+	}
+	rng.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+
+	buf := encodeBlockCacheKeys(keys)
+	files, total, err := decodeBlockCacheKeys(buf)
+	require.NoError(t, err)
+	require.Equal(t, len(keys), total)
+	require.Equal(t, want, files)
+
+	// Any corruption is detected.
+	for i := range buf {
+		corrupt := append([]byte(nil), buf...)
+		corrupt[i] ^= 0xff
+		_, _, err := decodeBlockCacheKeys(corrupt)
+		require.Error(t, err)
+	}
+	_, _, err = decodeBlockCacheKeys(buf[:len(buf)-1])
+	require.Error(t, err)
+}
+
+func TestBlockCacheWarmup(t *testing.T) {
+	mem := vfs.NewMem()
+	open := func() *DB {
+		c := NewCache(64 << 20)
+		defer c.Unref()
+		opts := &Options{
+			Cache:        c,
+			FS:           mem,
+			MemTableSize: 1 << 20,
+		}
+		// The keys are only persisted by Close during the test.
+		opts.BlockCacheWarmup.PersistInterval = time.Hour
+		d, err := Open("", opts)
+		require.NoError(t, err)
+		return d
+	}
+
+	d := open()
+	for i := 0; i < 10000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%08d", i)), make([]byte, 100), nil))
+	}
+	require.NoError(t, d.Flush())
+	iter, _ := d.NewIter(nil)
+	for valid := iter.First(); valid; valid = iter.Next() {
+	}
+	require.NoError(t, iter.Close())
+	resident := len(d.opts.Cache.ResidentBlocks(d.cacheID))
+	require.Greater(t, resident, 1)
+	require.NoError(t, d.Close())
+
+	// The blocks resident in the cache at Close are loaded into the new cache
+	// of the reopened DB.
+	d = open()
+	require.Eventually(t, func() bool {
+		return !d.Metrics().BlockCacheWarmup.InProgress
+	}, 10*time.Second, time.Millisecond)
+	m := d.Metrics()
+	require.Equal(t, int64(resident), m.BlockCacheWarmup.Blocks)
+	require.Equal(t, int64(resident), m.BlockCacheWarmup.BlocksProcessed)
+	require.NotZero(t, m.BlockCacheWarmup.BlocksLoaded)
+	require.NotZero(t, m.BlockCacheWarmup.BytesLoaded)
+	require.Contains(t, m.String(), "Block cache warm-up:")
+
+	// Reading the data is served entirely from the block cache.
+	misses := m.BlockCache.Misses
+	iter, _ = d.NewIter(nil)
+	for valid := iter.First(); valid; valid = iter.Next() {
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, misses, d.Metrics().BlockCache.Misses)
+
+	require.NoError(t, d.Close())
+
+	// Blocks of sstables that are no longer live are skipped.
+	f, err := mem.Create(blockCacheKeysFilename)
+	require.NoError(t, err)
+	_, err = f.Write(encodeBlockCacheKeys([]cache.BlockKey{
+		{FileNum: base.DiskFileNum(999), Offset: 0},
+		{FileNum: base.DiskFileNum(999), Offset: 4096},
+	}))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	d = open()
+	require.Eventually(t, func() bool {
+		return !d.Metrics().BlockCacheWarmup.InProgress
+	}, 10*time.Second, time.Millisecond)
+	m = d.Metrics()
+	require.Equal(t, int64(2), m.BlockCacheWarmup.Blocks)
+	require.Equal(t, int64(2), m.BlockCacheWarmup.BlocksProcessed)
+	require.Zero(t, m.BlockCacheWarmup.BlocksLoaded)
+	require.NoError(t, d.Close())
+}
+
+func TestBlockCacheWarmupClose(t *testing.T) {
+	mem := vfs.NewMem()
+	open := func(bytesPerSecond int64) *DB {
+		c := NewCache(64 << 20)
+		defer c.Unref()
+		opts := &Options{
+			Cache:        c,
+			FS:           mem,
+			MemTableSize: 1 << 20,
+		}
+		opts.BlockCacheWarmup.PersistInterval = time.Hour
+		opts.BlockCacheWarmup.BytesPerSecond = bytesPerSecond
+		d, err := Open("", opts)
+		require.NoError(t, err)
+		return d
+	}
+
+	d := open(0)
+	for i := 0; i < 10000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%08d", i)), make([]byte, 100), nil))
+	}
+	require.NoError(t, d.Flush())
+	iter, _ := d.NewIter(nil)
+	for valid := iter.First(); valid; valid = iter.Next() {
+	}
+	require.NoError(t, iter.Close())
+	require.NoError(t, d.Close())
+
+	// At a rate of one byte per second the warm-up would take hours. Closing
+	// the DB interrupts the warm-up while it's waiting for the rate limiter.
+	d = open(1)
+	require.True(t, d.Metrics().BlockCacheWarmup.InProgress)
+	// The first block is read immediately, putting the limiter into debt. Give
+	// the warm-up time to start waiting for the second one.
+	time.Sleep(50 * time.Millisecond)
+	require.True(t, d.Metrics().BlockCacheWarmup.InProgress)
+	closed := make(chan error, 1)
+	go func() { closed <- d.Close() }()
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Close blocked by the block cache warm-up")
+	}
+}
+
+func TestPersistBlockCacheKeysDurable(t *testing.T) {
+	mem := vfs.NewStrictMem()
+	c := NewCache(64 << 20)
+	defer c.Unref()
+	opts := &Options{
+		Cache:        c,
+		FS:           mem,
+		MemTableSize: 1 << 20,
+	}
+	opts.BlockCacheWarmup.PersistInterval = time.Hour
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%08d", i)), make([]byte, 100), nil))
+	}
+	require.NoError(t, d.Flush())
+	iter, _ := d.NewIter(nil)
+	for valid := iter.First(); valid; valid = iter.Next() {
+	}
+	require.NoError(t, iter.Close())
+	resident := len(d.opts.Cache.ResidentBlocks(d.cacheID))
+	require.NoError(t, d.persistBlockCacheKeys())
+
+	// The renamed file survives a crash once persistBlockCacheKeys returns.
+	mem.SetIgnoreSyncs(true)
+	require.NoError(t, d.Close())
+	mem.ResetToSyncedState()
+	mem.SetIgnoreSyncs(false)
+	f, err := mem.Open(blockCacheKeysFilename)
+	require.NoError(t, err)
+	buf, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	_, total, err := decodeBlockCacheKeys(buf)
+	require.NoError(t, err)
+	require.Equal(t, resident, total)
+}
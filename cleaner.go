@@ -56,6 +56,8 @@ type obsoleteFile struct {
 	fileNum  base.DiskFileNum
 	fileType fileType
 	fileSize uint64
+	// deleteReason is reported in the TableDeleteInfo of tables.
+	deleteReason string
 }
 
 type cleanupJob struct {
@@ -160,7 +162,7 @@ func (cm *cleanupManager) mainLoop() {
 			} else {
 				cm.maybePace(&tb, of.fileType, of.fileNum, of.fileSize)
 				cm.onTableDeleteFn(of.fileSize)
-				cm.deleteObsoleteObject(fileTypeTable, job.jobID, of.fileNum)
+				cm.deleteObsoleteObject(fileTypeTable, job.jobID, of.fileNum, of.deleteReason)
 			}
 		}
 		cm.mu.Lock()
@@ -244,7 +246,7 @@ func (cm *cleanupManager) deleteObsoleteFile(
 }
 
 func (cm *cleanupManager) deleteObsoleteObject(
-	fileType fileType, jobID int, fileNum base.DiskFileNum,
+	fileType fileType, jobID int, fileNum base.DiskFileNum, reason string,
 ) {
 	if fileType != fileTypeTable {
 		panic("not an object")
@@ -269,6 +271,7 @@ func (cm *cleanupManager) deleteObsoleteObject(
 			Path:    path,
 			FileNum: fileNum,
 			Err:     err,
+			Reason:  reason,
 		})
 	}
 }
@@ -14,6 +14,7 @@ import (
 	"runtime/pprof"
 	"slices"
 	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -437,6 +438,7 @@ type compactionWritable struct {
 
 	versions *versionSet
 	written  *int64
+	progress *compactionProgress
 }
 
 // Write is part of the objstorage.Writable interface.
@@ -446,6 +448,7 @@ func (c *compactionWritable) Write(p []byte) error {
 	}
 
 	*c.written += int64(len(p))
+	c.progress.bytesWritten.Add(uint64(len(p)))
 	c.versions.incrementCompactionBytes(int64(len(p)))
 	return nil
 }
@@ -466,6 +469,9 @@ const (
 	compactionKindRead
 	compactionKindRewrite
 	compactionKindIngestedFlushable
+	// compactionKindPeriodic denotes a compaction of a table older than
+	// Options.PeriodicCompactionInterval.
+	compactionKindPeriodic
 )
 
 func (k compactionKind) String() string {
@@ -488,6 +494,8 @@ func (k compactionKind) String() string {
 		return "ingested-flushable"
 	case compactionKindCopy:
 		return "copy"
+	case compactionKindPeriodic:
+		return "periodic"
 	}
 	return "?"
 }
@@ -562,6 +570,13 @@ type compaction struct {
 	// to cancel, such as if a conflicting excise operation raced it to manifest
 	// application. Only holders of the manifest lock will write to this atomic.
 	cancel atomic.Bool
+	// cancelMu protects cancelCtx, which, when non-nil, cancels the context of
+	// the CompactionExecutor running the compaction. It's called by
+	// setCancelled.
+	cancelMu struct {
+		sync.Mutex
+		cancelCtx context.CancelFunc
+	}
 
 	kind      compactionKind
 	cmp       Compare
@@ -668,6 +683,28 @@ type compaction struct {
 	metrics map[int]*LevelMetrics
 
 	pickerMetrics compactionPickerMetrics
+
+	// deleteReason, if non-empty, is reported in the TableDeleteInfo of the
+	// input tables of a delete-only compaction once they're deleted.
+	deleteReason string
+
+	// outputCreationTime, if non-zero, is the CreationTime of the output
+	// tables, in place of the current time.
+	outputCreationTime int64
+
+	// parent is set if the compaction is a subcompaction of a larger
+	// compaction, in which case it's bounded to the user keys within [lower,
+	// upper). A nil lower or upper leaves that side unbounded.
+	parent       *compaction
+	lower, upper []byte
+	// subcompactions describes the subcompactions the compaction was split
+	// into, if any.
+	subcompactions []SubcompactionInfo
+
+	// progress tracks the progress of the compaction, including that of its
+	// subcompactions. The progress of a subcompaction is tracked by its
+	// parent's.
+	progress compactionProgress
 }
 
 func (c *compaction) makeInfo(jobID int) CompactionInfo {
@@ -715,21 +752,27 @@ func (c *compaction) makeInfo(jobID int) CompactionInfo {
 func newCompaction(
 	pc *pickedCompaction, opts *Options, beganAt time.Time, provider objstorage.Provider,
 ) *compaction {
+	if pc.kind == compactionKindDeleteOnly {
+		c := newDeleteOnlyCompaction(opts, pc.version, pc.inputs, beganAt)
+		c.deleteReason = pc.deleteReason
+		return c
+	}
 	c := &compaction{
-		kind:              compactionKindDefault,
-		cmp:               pc.cmp,
-		equal:             opts.equal(),
-		comparer:          opts.Comparer,
-		formatKey:         opts.Comparer.FormatKey,
-		inputs:            pc.inputs,
-		smallest:          pc.smallest,
-		largest:           pc.largest,
-		logger:            opts.Logger,
-		version:           pc.version,
-		beganAt:           beganAt,
-		maxOutputFileSize: pc.maxOutputFileSize,
-		maxOverlapBytes:   pc.maxOverlapBytes,
-		pickerMetrics:     pc.pickerMetrics,
+		kind:               compactionKindDefault,
+		cmp:                pc.cmp,
+		equal:              opts.equal(),
+		comparer:           opts.Comparer,
+		formatKey:          opts.Comparer.FormatKey,
+		inputs:             pc.inputs,
+		smallest:           pc.smallest,
+		largest:            pc.largest,
+		logger:             opts.Logger,
+		version:            pc.version,
+		beganAt:            beganAt,
+		maxOutputFileSize:  pc.maxOutputFileSize,
+		maxOverlapBytes:    pc.maxOverlapBytes,
+		pickerMetrics:      pc.pickerMetrics,
+		outputCreationTime: pc.outputCreationTime,
 	}
 	c.startLevel = &c.inputs[0]
 	if pc.startLevel.l0SublevelInfo != nil {
@@ -758,6 +801,11 @@ func newCompaction(
 		// that will require a very expensive merge later on.
 		iter := c.startLevel.files.Iter()
 		meta := iter.First()
+		if spansOutputPartitions(opts, meta) {
+			// Rewrite the file so that the outputs respect the partition
+			// boundaries.
+			return c
+		}
 		isRemote := false
 		// We should always be passed a provider, except in some unit tests.
 		if provider != nil {
@@ -984,16 +1032,15 @@ func newFlush(
 }
 
 func (c *compaction) hasExtraLevelData() bool {
-	if len(c.extraLevels) == 0 {
-		// not a multi level compaction
-		return false
-	} else if c.extraLevels[0].files.Empty() {
-		// a multi level compaction without data in the intermediate input level;
-		// e.g. for a multi level compaction with levels 4,5, and 6, this could
-		// occur if there is no files to compact in 5, or in 5 and 6 (i.e. a move).
-		return false
+	// A multi level compaction may lack data in the intermediate input levels;
+	// e.g. for a multi level compaction with levels 4,5, and 6, this could
+	// occur if there is no files to compact in 5, or in 5 and 6 (i.e. a move).
+	for _, l := range c.extraLevels {
+		if !l.files.Empty() {
+			return true
+		}
 	}
-	return true
+	return false
 }
 
 func (c *compaction) setupInuseKeyRanges() {
@@ -1069,6 +1116,20 @@ func (c *compaction) findL0Limit(start []byte) []byte {
 	return nil
 }
 
+// spansOutputPartitions returns true if the file contains keys from more than
+// one of the partitions defined by Options.CompactionOutputSplitter.
+func spansOutputPartitions(opts *Options, meta *fileMetadata) bool {
+	if opts.CompactionOutputSplitter == nil {
+		return false
+	}
+	limit := opts.CompactionOutputSplitter(meta.Smallest.UserKey)
+	if limit == nil {
+		return false
+	}
+	v := opts.Comparer.Compare(limit, meta.Largest.UserKey)
+	return v < 0 || (v == 0 && !meta.Largest.IsExclusiveSentinel())
+}
+
 // errorOnUserKeyOverlap returns an error if the last two written sstables in
 // this compaction have revisions of the same user key present in both sstables,
 // when it shouldn't (eg. when splitting flushes).
@@ -1193,11 +1254,7 @@ func (c *compaction) newInputIter(
 				}
 			}
 		}
-		if len(c.extraLevels) > 0 {
-			if len(c.extraLevels) > 1 {
-				panic("n>2 multi level compaction not implemented yet")
-			}
-			interLevel := c.extraLevels[0]
+		for _, interLevel := range c.extraLevels {
 			err := manifest.CheckOrdering(c.cmp, c.formatKey,
 				manifest.Level(interLevel.level), interLevel.files.Iter())
 			if err != nil {
@@ -1260,15 +1317,23 @@ func (c *compaction) newInputIter(
 		}
 	} else {
 		addItersForLevel := func(level *compactionLevel, l manifest.Level) error {
+			// A subcompaction only reads the tables that overlap its bounds.
+			if c.parent != nil {
+				level = &compactionLevel{level: level.level, files: c.boundedFiles(level.files)}
+			}
 			// Add a *levelIter for point iterators. Because we don't call
 			// initRangeDel, the levelIter will close and forget the range
 			// deletion iterator when it steps on to a new file. Surfacing range
 			// deletions to compactions are handled below.
-			iters = append(iters, newLevelIter(context.Background(),
+			var pointIter internalIterator = newLevelIter(context.Background(),
 				iterOpts, c.comparer, newIters, level.files.Iter(), l, internalIterOpts{
 					bytesIterated: &c.bytesIterated,
 					bufferPool:    &c.bufferPool,
-				}))
+				})
+			if c.parent != nil {
+				pointIter = &boundedIter{internalIterator: pointIter, cmp: c.cmp, lower: c.lower, upper: c.upper}
+			}
+			iters = append(iters, pointIter)
 			// TODO(jackson): Use keyspan.LevelIter to avoid loading all the range
 			// deletions into memory upfront. (See #2015, which reverted this.)
 			// There will be no user keys that are split between sstables
@@ -1316,8 +1381,11 @@ func (c *compaction) newInputIter(
 				if rangeDelIter == nil {
 					continue
 				}
-				rangeDelIters = append(rangeDelIters, rangeDelIter)
 				c.closers = append(c.closers, closer)
+				if c.parent != nil {
+					rangeDelIter = c.truncateSpans(rangeDelIter)
+				}
+				rangeDelIters = append(rangeDelIters, rangeDelIter)
 			}
 
 			// Check if this level has any range keys.
@@ -1352,7 +1420,11 @@ func (c *compaction) newInputIter(
 					return iter, err
 				}
 				li.Init(keyspan.SpanIterOptions{}, c.cmp, newRangeKeyIterWrapper, level.files.Iter(), l, manifest.KeyTypeRange)
-				rangeKeyIters = append(rangeKeyIters, li)
+				if c.parent != nil {
+					rangeKeyIters = append(rangeKeyIters, c.truncateSpans(li))
+				} else {
+					rangeKeyIters = append(rangeKeyIters, li)
+				}
 			}
 			return nil
 		}
@@ -1468,9 +1540,8 @@ func (c *compaction) String() string {
 	}
 
 	var buf bytes.Buffer
-	for level := c.startLevel.level; level <= c.outputLevel.level; level++ {
-		i := level - c.startLevel.level
-		fmt.Fprintf(&buf, "%d:", level)
+	for i := range c.inputs {
+		fmt.Fprintf(&buf, "%d:", c.inputs[i].level)
 		iter := c.inputs[i].files.Iter()
 		for f := iter.First(); f != nil; f = iter.Next() {
 			fmt.Fprintf(&buf, " %s:%s-%s", f.FileNum, f.Smallest, f.Largest)
@@ -1490,6 +1561,13 @@ type manualCompaction struct {
 	start       []byte
 	end         []byte
 	split       bool
+	// rewrite, if set, is the table that the manual compaction rewrites in
+	// place, instead of compacting the level's tables within [start, end]
+	// into the next level.
+	rewrite *fileMetadata
+	// c is the compaction picked for the manual compaction, once it's
+	// scheduled.
+	c *compaction
 }
 
 type readCompaction struct {
@@ -2007,7 +2085,7 @@ func (d *DB) flush1() (bytesFlushed uint64, err error) {
 					iter := c2.inputs[i].files.Iter()
 					for f := iter.First(); f != nil; f = iter.Next() {
 						if _, ok := ve.DeletedFiles[deletedFileEntry{FileNum: f.FileNum, Level: c2.inputs[i].level}]; ok {
-							c2.cancel.Store(true)
+							c2.setCancelled()
 							break
 						}
 					}
@@ -2140,9 +2218,85 @@ func (d *DB) maybeScheduleCompactionAsync() {
 //
 // d.mu must be held when calling this.
 func (d *DB) maybeScheduleCompaction() {
+	if l := d.opts.CompactionRateLimiter; l != nil {
+		l.maybeAutoTune(d.timeNow(), func() uint64 {
+			return d.mu.versions.picker.estimatedCompactionDebt(0)
+		})
+	}
 	d.maybeScheduleCompactionPicker(pickAuto)
 }
 
+// compactionTimerMinWait is the minimum time between the checks made by
+// compactionTimerLoop. Table creation times have a resolution of a second.
+const compactionTimerMinWait = time.Second
+
+// compactionTimerWait returns how long to wait before the next compaction that
+// depends on the age of tables, rather than on writes, may be picked: a
+// periodic compaction, or a FIFOCompactionPicker drop of expired tables. It
+// returns false if there are no such compactions.
+//
+// d.mu must be held when calling this.
+func (d *DB) compactionTimerWait() (time.Duration, bool) {
+	var wait time.Duration
+	var ok bool
+	if d.opts.PeriodicCompactionInterval > 0 {
+		wait, ok = periodicCompactionSpacing(d.opts, d.mu.versions.currentVersion()), true
+	}
+	if p, isFIFO := d.mu.versions.picker.(*compactionPickerFIFO); isFIFO && p.config.TTL > 0 {
+		// Without tables, any table created in the meantime expires after TTL
+		// at the earliest.
+		fifoWait := p.config.TTL
+		if expiry, expires := p.nextExpiry(); expires {
+			fifoWait = expiry.Sub(d.timeNow())
+		}
+		if !ok || fifoWait < wait {
+			wait, ok = fifoWait, true
+		}
+	}
+	return wait, ok
+}
+
+// startCompactionTimer starts the goroutine that schedules the compactions
+// that depend on the age of tables while the DB is idle. Otherwise, they'd
+// only be picked when compactions are scheduled after flushes and
+// compactions.
+//
+// d.mu must be held when calling this.
+func (d *DB) startCompactionTimer() {
+	if d.opts.ReadOnly {
+		return
+	}
+	if _, ok := d.compactionTimerWait(); !ok {
+		return
+	}
+	go d.compactionTimerLoop()
+}
+
+// compactionTimerLoop schedules compactions each time a compaction that
+// depends on the age of tables may be picked, until the DB is closed.
+func (d *DB) compactionTimerLoop() {
+	for {
+		d.mu.Lock()
+		if d.closed.Load() != nil {
+			d.mu.Unlock()
+			return
+		}
+		wait, _ := d.compactionTimerWait()
+		d.mu.Unlock()
+
+		timer := time.NewTimer(max(wait, compactionTimerMinWait))
+		select {
+		case <-d.closedCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		d.mu.Lock()
+		d.maybeScheduleCompaction()
+		d.mu.Unlock()
+	}
+}
+
 func pickAuto(picker compactionPicker, env compactionEnv) *pickedCompaction {
 	return picker.pickAuto(env)
 }
@@ -2247,13 +2401,14 @@ func (d *DB) maybeScheduleCompactionPicker(
 		diskAvailBytes:          d.diskAvailBytes.Load(),
 		earliestSnapshotSeqNum:  d.mu.snapshots.earliest(),
 		earliestUnflushedSeqNum: d.getEarliestUnflushedSeqNumLocked(),
+		lastPeriodicCompaction:  d.mu.compact.lastPeriodic,
 	}
 
 	// Check for delete-only compactions first, because they're expected to be
 	// cheap and reduce future compaction work.
 	if !d.opts.private.disableDeleteOnlyCompactions &&
 		len(d.mu.compact.deletionHints) > 0 &&
-		!d.opts.DisableAutomaticCompactions {
+		!d.opts.DisableAutomaticCompactions && d.mu.compact.exclusiveManualCount == 0 {
 		v := d.mu.versions.currentVersion()
 		snapshots := d.mu.snapshots.toSlice()
 		inputs, unresolvedHints := checkDeleteCompactionHints(d.cmp, v, d.mu.compact.deletionHints, snapshots)
@@ -2274,6 +2429,7 @@ func (d *DB) maybeScheduleCompactionPicker(
 		pc, retryLater := pickManualCompaction(v, d.opts, env, d.mu.versions.picker.getBaseLevel(), manual)
 		if pc != nil {
 			c := newCompaction(pc, d.opts, d.timeNow(), d.ObjProvider())
+			manual.c = c
 			d.mu.compact.manual = d.mu.compact.manual[1:]
 			d.mu.compact.compactingCount++
 			d.addInProgressCompaction(c)
@@ -2289,7 +2445,8 @@ func (d *DB) maybeScheduleCompactionPicker(
 		}
 	}
 
-	for !d.opts.DisableAutomaticCompactions && d.mu.compact.compactingCount < maxConcurrentCompactions {
+	for !d.opts.DisableAutomaticCompactions && d.mu.compact.exclusiveManualCount == 0 &&
+		d.mu.compact.compactingCount < maxConcurrentCompactions {
 		env.inProgressCompactions = d.getInProgressCompactionInfoLocked(nil)
 		env.readCompactionEnv = readCompactionEnv{
 			readCompactions:          &d.mu.compact.readCompactions,
@@ -2300,6 +2457,10 @@ func (d *DB) maybeScheduleCompactionPicker(
 		if pc == nil {
 			break
 		}
+		if pc.kind == compactionKindPeriodic {
+			d.mu.compact.lastPeriodic = d.timeNow()
+			env.lastPeriodicCompaction = d.mu.compact.lastPeriodic
+		}
 		c := newCompaction(pc, d.opts, d.timeNow(), d.ObjProvider())
 		d.mu.compact.compactingCount++
 		d.addInProgressCompaction(c)
@@ -2619,6 +2780,7 @@ func (d *DB) compact1(c *compaction, errChannel chan error) (err error) {
 
 	jobID := d.mu.nextJobID
 	d.mu.nextJobID++
+	c.progress.jobID = jobID
 	info := c.makeInfo(jobID)
 	d.opts.EventListener.CompactionBegin(info)
 	startTime := d.timeNow()
@@ -2672,10 +2834,18 @@ func (d *DB) compact1(c *compaction, errChannel chan error) (err error) {
 			e := &ve.NewFiles[i]
 			info.Output.Tables = append(info.Output.Tables, e.Meta.TableInfo())
 		}
+		info.Subcompactions = c.subcompactions
 		d.mu.snapshots.cumulativePinnedCount += stats.cumulativePinnedKeys
 		d.mu.snapshots.cumulativePinnedSize += stats.cumulativePinnedSize
 		d.mu.versions.metrics.Keys.MissizedTombstonesCount += stats.countMissizedDels
 		d.maybeUpdateDeleteCompactionHints(c)
+		if c.deleteReason != "" {
+			for _, m := range ve.DeletedFiles {
+				if !m.Virtual {
+					d.mu.versions.tableDeleteReasons[m.FileBacking.DiskFileNum] = c.deleteReason
+				}
+			}
+		}
 	}
 
 	// NB: clearing compacting state must occur before updating the read state;
@@ -2898,11 +3068,154 @@ func (d *DB) runCompaction(
 		return ve, nil, stats, ErrCancelledCompaction
 	}
 
+	// Compactions of remote tables may be offloaded to the CompactionExecutor,
+	// which needs file numbers reserved for its outputs. Otherwise, the number
+	// of subcompactions depends on the number of compactions in progress. Both
+	// are decided before d.mu is released.
+	job, jobHandles := d.makeCompactionJob(jobID, c, snapshots)
+	// The subcompactions inherit allowedZeroSeqNum from c, so it must be
+	// computed before they're picked.
+	c.allowedZeroSeqNum = c.allowZeroSeqNum()
+	var subcompactions []*compaction
+	if job == nil {
+		subcompactions = d.pickSubcompactions(c)
+	}
+
 	// Release the d.mu lock while doing I/O.
 	// Note the unusual order: Unlock and then Lock.
 	d.mu.Unlock()
 	defer d.mu.Lock()
 
+	ve = &versionEdit{
+		DeletedFiles: map[deletedFileEntry]*fileMetadata{},
+	}
+
+	startLevelBytes := c.startLevel.files.SizeSum()
+	outputMetrics := &LevelMetrics{
+		BytesIn:   startLevelBytes,
+		BytesRead: c.outputLevel.files.SizeSum(),
+	}
+	for _, l := range c.extraLevels {
+		outputMetrics.BytesIn += l.files.SizeSum()
+	}
+	outputMetrics.BytesRead += outputMetrics.BytesIn
+
+	c.metrics = map[int]*LevelMetrics{
+		c.outputLevel.level: outputMetrics,
+	}
+	if len(c.flushing) == 0 && c.metrics[c.startLevel.level] == nil {
+		c.metrics[c.startLevel.level] = &LevelMetrics{}
+	}
+	for _, l := range c.extraLevels {
+		c.metrics[l.level] = &LevelMetrics{}
+	}
+	if len(c.extraLevels) > 0 {
+		outputMetrics.MultiLevel.BytesInTop = startLevelBytes
+		outputMetrics.MultiLevel.BytesIn = outputMetrics.BytesIn
+		outputMetrics.MultiLevel.BytesRead = outputMetrics.BytesRead
+	}
+
+	writerOpts := d.opts.makeCompactionWriterOptions(c.outputLevel.level, formatVers)
+
+	var outputs []*compactionOutput
+	defer func() {
+		if retErr != nil {
+			for _, o := range outputs {
+				for _, fileNum := range o.createdFiles {
+					_ = d.objProvider.Remove(fileTypeTable, fileNum)
+				}
+			}
+		}
+	}()
+	if job != nil {
+		outputs = []*compactionOutput{{}}
+		retErr = d.runRemoteCompaction(c, job, jobHandles, outputs[0])
+	} else if len(subcompactions) == 0 {
+		outputs = []*compactionOutput{{}}
+		retErr = d.compactAndWrite(jobID, c, snapshots, writerOpts, outputs[0])
+	} else {
+		outputs, retErr = d.runSubcompactions(jobID, c, subcompactions, snapshots, writerOpts)
+	}
+	for _, o := range outputs {
+		pendingOutputs = append(pendingOutputs, o.pendingOutputs...)
+	}
+	if retErr != nil {
+		return nil, pendingOutputs, stats, retErr
+	}
+	for _, o := range outputs {
+		ve.NewFiles = append(ve.NewFiles, o.newFiles...)
+		outputMetrics.Add(&o.metrics)
+		stats.cumulativePinnedKeys += o.stats.cumulativePinnedKeys
+		stats.cumulativePinnedSize += o.stats.cumulativePinnedSize
+		stats.countMissizedDels += o.stats.countMissizedDels
+	}
+
+	for _, cl := range c.inputs {
+		iter := cl.files.Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			ve.DeletedFiles[deletedFileEntry{
+				Level:   cl.level,
+				FileNum: f.FileNum,
+			}] = f
+		}
+	}
+
+	if err := d.objProvider.Sync(); err != nil {
+		return nil, pendingOutputs, stats, err
+	}
+
+	// Refresh the disk available statistic whenever a compaction/flush
+	// completes, before re-acquiring the mutex.
+	_ = d.calculateDiskAvailableBytes()
+
+	return ve, pendingOutputs, stats, nil
+}
+
+// makeCompactionWriterOptions returns the options of the tables written to
+// the given level by flushes and compactions.
+func (o *Options) makeCompactionWriterOptions(
+	level int, formatVers FormatMajorVersion,
+) sstable.WriterOptions {
+	// The table is typically written at the maximum allowable format implied by
+	// the current format major version of the DB.
+	tableFormat := formatVers.MaxTableFormat()
+
+	// In format major versions with maximum table formats of Pebblev3, value
+	// blocks were conditional on an experimental setting. In format major
+	// versions with maximum table formats of Pebblev4 and higher, value blocks
+	// are always enabled.
+	if tableFormat == sstable.TableFormatPebblev3 &&
+		(o.Experimental.EnableValueBlocks == nil || !o.Experimental.EnableValueBlocks()) {
+		tableFormat = sstable.TableFormatPebblev2
+	}
+	return o.MakeWriterOptions(level, tableFormat)
+}
+
+// compactionOutput holds the results of compacting the keys of a compaction,
+// or of one of its subcompactions, into new tables.
+type compactionOutput struct {
+	newFiles       []newFileEntry
+	pendingOutputs []physicalMeta
+	createdFiles   []base.DiskFileNum
+	metrics        LevelMetrics
+	stats          compactStats
+	// fileNumLimit, if non-zero, is the exclusive upper bound on the file
+	// numbers the compaction may allocate for its outputs.
+	fileNumLimit base.FileNum
+}
+
+// compactAndWrite compacts the keys of the compaction c into new tables,
+// recording the tables and their metrics in o. The tables created are recorded
+// in o even if an error is returned, so that the caller may remove them.
+//
+// d.mu must not be held when calling this.
+func (d *DB) compactAndWrite(
+	jobID int,
+	c *compaction,
+	snapshots []uint64,
+	writerOpts sstable.WriterOptions,
+	o *compactionOutput,
+) (retErr error) {
 	// Compactions use a pool of buffers to read blocks, avoiding polluting the
 	// block cache with blocks that will not be read again. We initialize the
 	// buffer pool with a size 12. This initial size does not need to be
@@ -2931,9 +3244,8 @@ func (d *DB) runCompaction(
 
 	iiter, err := c.newInputIter(d.newIters, d.tableNewRangeKeyIter, snapshots)
 	if err != nil {
-		return nil, pendingOutputs, stats, err
+		return err
 	}
-	c.allowedZeroSeqNum = c.allowZeroSeqNum()
 	iiter = invalidating.MaybeWrapIfInvariants(iiter)
 	iter := newCompactionIter(c.cmp, c.equal, c.formatKey, d.merge, iiter, snapshots,
 		&c.rangeDelFrag, &c.rangeKeyFrag, c.allowedZeroSeqNum, c.elideTombstone,
@@ -2942,7 +3254,6 @@ func (d *DB) runCompaction(
 		d.FormatMajorVersion())
 
 	var (
-		createdFiles    []base.DiskFileNum
 		tw              *sstable.Writer
 		pinnedKeySize   uint64
 		pinnedValueSize uint64
@@ -2955,57 +3266,14 @@ func (d *DB) runCompaction(
 		if tw != nil {
 			retErr = firstError(retErr, tw.Close())
 		}
-		if retErr != nil {
-			for _, fileNum := range createdFiles {
-				_ = d.objProvider.Remove(fileTypeTable, fileNum)
-			}
-		}
 		for _, closer := range c.closers {
 			retErr = firstError(retErr, closer.Close())
 		}
 	}()
 
-	ve = &versionEdit{
-		DeletedFiles: map[deletedFileEntry]*fileMetadata{},
-	}
-
-	startLevelBytes := c.startLevel.files.SizeSum()
-	outputMetrics := &LevelMetrics{
-		BytesIn:   startLevelBytes,
-		BytesRead: c.outputLevel.files.SizeSum(),
-	}
-	if len(c.extraLevels) > 0 {
-		outputMetrics.BytesIn += c.extraLevels[0].files.SizeSum()
-	}
-	outputMetrics.BytesRead += outputMetrics.BytesIn
-
-	c.metrics = map[int]*LevelMetrics{
-		c.outputLevel.level: outputMetrics,
-	}
-	if len(c.flushing) == 0 && c.metrics[c.startLevel.level] == nil {
-		c.metrics[c.startLevel.level] = &LevelMetrics{}
-	}
-	if len(c.extraLevels) > 0 {
-		c.metrics[c.extraLevels[0].level] = &LevelMetrics{}
-		outputMetrics.MultiLevel.BytesInTop = startLevelBytes
-		outputMetrics.MultiLevel.BytesIn = outputMetrics.BytesIn
-		outputMetrics.MultiLevel.BytesRead = outputMetrics.BytesRead
-	}
-
-	// The table is typically written at the maximum allowable format implied by
-	// the current format major version of the DB.
-	tableFormat := formatVers.MaxTableFormat()
-
-	// In format major versions with maximum table formats of Pebblev3, value
-	// blocks were conditional on an experimental setting. In format major
-	// versions with maximum table formats of Pebblev4 and higher, value blocks
-	// are always enabled.
-	if tableFormat == sstable.TableFormatPebblev3 &&
-		(d.opts.Experimental.EnableValueBlocks == nil || !d.opts.Experimental.EnableValueBlocks()) {
-		tableFormat = sstable.TableFormatPebblev2
-	}
-
-	writerOpts := d.opts.MakeWriterOptions(c.outputLevel.level, tableFormat)
+	ve := &versionEdit{}
+	outputMetrics := &o.metrics
+	stats := &o.stats
 
 	// prevPointKey is a sstable.WriterOption that provides access to
 	// the last point key written to a writer's sstable. When a new
@@ -3025,15 +3293,21 @@ func (d *DB) runCompaction(
 
 	newOutput := func() error {
 		// Check if we've been cancelled by a concurrent operation.
-		if c.cancel.Load() {
+		if c.cancelled() {
 			return ErrCancelledCompaction
 		}
 		fileMeta := &fileMetadata{}
 		d.mu.Lock()
+		if o.fileNumLimit != 0 && base.FileNum(d.mu.versions.nextFileNum) >= o.fileNumLimit {
+			d.mu.Unlock()
+			return errors.Errorf("pebble: compaction exhausted its output file numbers (limit %s)",
+				o.fileNumLimit)
+		}
 		fileNum := d.mu.versions.getNextFileNum()
 		fileMeta.FileNum = fileNum
-		pendingOutputs = append(pendingOutputs, fileMeta.PhysicalMeta())
+		o.pendingOutputs = append(o.pendingOutputs, fileMeta.PhysicalMeta())
 		d.mu.Unlock()
+		c.progressTracker().output.Store(uint64(fileNum))
 
 		ctx := context.TODO()
 		if objiotracing.Enabled {
@@ -3071,9 +3345,18 @@ func (d *DB) runCompaction(
 				Writable: writable,
 				versions: d.mu.versions,
 				written:  &c.bytesWritten,
+				progress: c.progressTracker(),
+			}
+		}
+		if d.opts.CompactionRateLimiter != nil {
+			writable = &rateLimitedWritable{
+				Writable:  writable,
+				limiter:   d.opts.CompactionRateLimiter,
+				flush:     c.kind == compactionKindFlush,
+				cancelled: c.cancelled,
 			}
 		}
-		createdFiles = append(createdFiles, fileNum.DiskFileNum())
+		o.createdFiles = append(o.createdFiles, fileNum.DiskFileNum())
 		cacheOpts := private.SSTableCacheOpts(d.cacheID, fileNum.DiskFileNum()).(sstable.WriterOption)
 
 		const MaxFileWriteAdditionalCPUTime = time.Millisecond * 100
@@ -3087,6 +3370,9 @@ func (d *DB) runCompaction(
 		tw = sstable.NewWriter(writable, writerOpts, cacheOpts, &prevPointKey)
 
 		fileMeta.CreationTime = time.Now().Unix()
+		if c.outputCreationTime != 0 {
+			fileMeta.CreationTime = c.outputCreationTime
+		}
 		ve.NewFiles = append(ve.NewFiles, newFileEntry{
 			Level: c.outputLevel.level,
 			Meta:  fileMeta,
@@ -3234,6 +3520,7 @@ func (d *DB) runCompaction(
 		outputMetrics.NumFiles++
 		outputMetrics.Additional.BytesWrittenDataBlocks += writerMeta.Properties.DataSize
 		outputMetrics.Additional.BytesWrittenValueBlocks += writerMeta.Properties.ValueBlocksSize
+		outputMetrics.Additional.Compression.addProperties(&writerMeta.Properties)
 
 		if n := len(ve.NewFiles); n > 1 {
 			// This is not the first output file. Ensure the sstable boundaries
@@ -3350,8 +3637,15 @@ func (d *DB) runCompaction(
 	if splitL0Outputs {
 		outputSplitters = append(outputSplitters, newLimitFuncSplitter(&iter.frontiers, c.findL0Limit))
 	}
+	if d.opts.CompactionOutputSplitter != nil {
+		outputSplitters = append(outputSplitters, newLimitFuncSplitter(&iter.frontiers, d.opts.CompactionOutputSplitter))
+	}
 	splitter := &splitterGroup{cmp: c.cmp, splitters: outputSplitters}
 
+	// bytesReported is the number of bytes of input that were reported to the
+	// compaction's progress tracker.
+	var bytesReported uint64
+
 	// Each outer loop iteration produces one output file. An iteration that
 	// produces a file containing point keys (and optionally range tombstones)
 	// guarantees that the input iterator advanced. An iteration that produces
@@ -3386,6 +3680,10 @@ func (d *DB) runCompaction(
 
 		// Each inner loop iteration processes one key from the input iterator.
 		for ; key != nil; key, val = iter.Next() {
+			if c.bytesIterated-bytesReported >= compactionProgressBytes {
+				d.reportCompactionProgress(c, c.bytesIterated-bytesReported)
+				bytesReported = c.bytesIterated
+			}
 			if split := splitter.shouldSplitBefore(key, tw); split == splitNow {
 				break
 			}
@@ -3447,11 +3745,11 @@ func (d *DB) runCompaction(
 			}
 			if tw == nil {
 				if err := newOutput(); err != nil {
-					return nil, pendingOutputs, stats, err
+					return err
 				}
 			}
 			if err := tw.AddWithForceObsolete(*key, val, iter.forceObsoleteDueToRangeDel); err != nil {
-				return nil, pendingOutputs, stats, err
+				return err
 			}
 			if iter.snapshotPinned {
 				// The kv pair we just added to the sstable was only surfaced by
@@ -3487,34 +3785,18 @@ func (d *DB) runCompaction(
 			splitKey = key.UserKey
 		}
 		if err := finishOutput(splitKey); err != nil {
-			return nil, pendingOutputs, stats, err
+			return err
 		}
 	}
 
-	for _, cl := range c.inputs {
-		iter := cl.files.Iter()
-		for f := iter.First(); f != nil; f = iter.Next() {
-			ve.DeletedFiles[deletedFileEntry{
-				Level:   cl.level,
-				FileNum: f.FileNum,
-			}] = f
-		}
-	}
+	c.progressTracker().bytesRead.Add(c.bytesIterated - bytesReported)
 
 	// The compaction iterator keeps track of a count of the number of DELSIZED
 	// keys that encoded an incorrect size. Propagate it up as a part of
 	// compactStats.
 	stats.countMissizedDels = iter.stats.countMissizedDels
-
-	if err := d.objProvider.Sync(); err != nil {
-		return nil, pendingOutputs, stats, err
-	}
-
-	// Refresh the disk available statistic whenever a compaction/flush
-	// completes, before re-acquiring the mutex.
-	_ = d.calculateDiskAvailableBytes()
-
-	return ve, pendingOutputs, stats, nil
+	o.newFiles = ve.NewFiles
+	return nil
 }
 
 // validateVersionEdit validates that start and end keys across new and deleted
@@ -3721,8 +4003,16 @@ func (d *DB) deleteObsoleteFiles(jobID int) {
 	obsoleteTables := append([]fileInfo(nil), d.mu.versions.obsoleteTables...)
 	d.mu.versions.obsoleteTables = nil
 
+	var deleteReasons map[base.DiskFileNum]string
 	for _, tbl := range obsoleteTables {
 		delete(d.mu.versions.zombieTables, tbl.fileNum)
+		if reason, ok := d.mu.versions.tableDeleteReasons[tbl.fileNum]; ok {
+			if deleteReasons == nil {
+				deleteReasons = make(map[base.DiskFileNum]string)
+			}
+			deleteReasons[tbl.fileNum] = reason
+			delete(d.mu.versions.tableDeleteReasons, tbl.fileNum)
+		}
 	}
 
 	// Sort the manifests cause we want to delete some contiguous prefix
@@ -3778,12 +4068,16 @@ func (d *DB) deleteObsoleteFiles(jobID int) {
 				d.tableCache.evict(fi.fileNum)
 			}
 
-			filesToDelete = append(filesToDelete, obsoleteFile{
+			of := obsoleteFile{
 				dir:      dir,
 				fileNum:  fi.fileNum,
 				fileType: f.fileType,
 				fileSize: fi.fileSize,
-			})
+			}
+			if f.fileType == fileTypeTable {
+				of.deleteReason = deleteReasons[fi.fileNum]
+			}
+			filesToDelete = append(filesToDelete, of)
 		}
 	}
 	if len(filesToDelete) > 0 {
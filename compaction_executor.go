@@ -0,0 +1,622 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/cache"
+	"github.com/cockroachdb/pebble/internal/manifest"
+	"github.com/cockroachdb/pebble/objstorage"
+	"github.com/cockroachdb/pebble/objstorage/objstorageprovider"
+	"github.com/cockroachdb/pebble/objstorage/remote"
+	"github.com/cockroachdb/pebble/sstable"
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// CompactionExecutor runs compactions outside of the DB's process. See
+// Options.Experimental.CompactionExecutor.
+//
+// A compaction is offloaded to the executor when all of its input tables are
+// on remote storage and its output tables would be created on shared storage,
+// which is typically the case for compactions into the lowest levels of a DB
+// configured with Options.Experimental.RemoteStorage. The executor only needs
+// access to the same remote storage as the DB: it reads the input tables and
+// writes the output tables there, and the DB installs the output tables in its
+// LSM once the job returns.
+//
+// The CompactionJob and CompactionJobResult types can be encoded as JSON, so
+// that the job can be sent to another process. RunCompactionJob runs a job
+// given the options of the worker, and is what the executor returned by
+// NewInProcessCompactionExecutor and the `pebble compact-worker` command use.
+type CompactionExecutor interface {
+	// ExecuteCompaction runs the compaction job and returns the tables that it
+	// produced. If an error is returned, the tables created by the job, if
+	// any, are expected to have been removed.
+	//
+	// ExecuteCompaction is called without holding any locks and may be called
+	// concurrently. The context is cancelled if the compaction is cancelled,
+	// e.g. because the DB is closing or an ingestion overlaps it.
+	ExecuteCompaction(ctx context.Context, job *CompactionJob) (*CompactionJobResult, error)
+}
+
+// CompactionJob describes a compaction of tables on remote storage, as given to
+// a CompactionExecutor.
+type CompactionJob struct {
+	JobID int
+	// CreatorID is the creator ID of the DB (see DB.SetCreatorID). The output
+	// tables are created on behalf of the DB, so they use the same creator ID.
+	CreatorID uint64
+	// Comparer and Merger are the names of the comparer and merger of the DB.
+	Comparer string
+	Merger   string
+	// FormatMajorVersion is the format major version of the DB, which
+	// determines the format of the output tables.
+	FormatMajorVersion FormatMajorVersion
+
+	// Inputs are the input tables of the compaction, by level, in the order of
+	// the levels. The last level is the output level.
+	Inputs      []CompactionJobLevel
+	OutputLevel int
+	// Grandparents are the tables of the level below the output level that
+	// overlap the compaction, which are used to split output tables. Their
+	// Backing is not set.
+	Grandparents []CompactionJobTable
+	// Smallest and Largest are the bounds of the compaction.
+	Smallest InternalKey
+	Largest  InternalKey
+	// Snapshots are the sequence numbers of the open snapshots of the DB.
+	Snapshots []uint64
+	// AllowZeroSeqNum is set if the sequence numbers of the output keys can be
+	// zeroed.
+	AllowZeroSeqNum bool
+	// InuseKeyRanges are the key ranges of the tables below the output level
+	// that overlap the compaction. Tombstones within them can't be elided.
+	InuseKeyRanges   []KeyRange
+	InuseEntireRange bool
+	// MaxOutputFileSize and MaxOverlapBytes bound the size of the output tables
+	// and their overlap with the grandparents.
+	MaxOutputFileSize uint64
+	MaxOverlapBytes   uint64
+
+	// OutputLocator is the locator of the remote storage that the output
+	// tables are created on.
+	OutputLocator remote.Locator
+	// FirstOutputFileNum and MaxOutputTables describe the range of file
+	// numbers reserved by the DB for the output tables.
+	FirstOutputFileNum base.DiskFileNum
+	MaxOutputTables    int
+}
+
+// CompactionJobLevel holds the input tables of one level of a CompactionJob.
+type CompactionJobLevel struct {
+	Level  int
+	Tables []CompactionJobTable
+}
+
+// CompactionJobTable describes an input or output table of a CompactionJob.
+type CompactionJobTable struct {
+	FileNum          base.FileNum
+	Size             uint64
+	SmallestSeqNum   uint64
+	LargestSeqNum    uint64
+	HasPointKeys     bool
+	SmallestPointKey InternalKey
+	LargestPointKey  InternalKey
+	HasRangeKeys     bool
+	SmallestRangeKey InternalKey
+	LargestRangeKey  InternalKey
+	CreationTime     int64
+	// Backing describes the remote object of the table; see
+	// objstorage.RemoteObjectBacking.
+	Backing objstorage.RemoteObjectBacking
+}
+
+// CompactionJobResult holds the output tables of a CompactionJob, in any order.
+type CompactionJobResult struct {
+	Tables []CompactionJobTable
+}
+
+func makeCompactionJobTable(
+	m *fileMetadata, backing objstorage.RemoteObjectBacking,
+) CompactionJobTable {
+	return CompactionJobTable{
+		FileNum:          m.FileNum,
+		Size:             m.Size,
+		SmallestSeqNum:   m.SmallestSeqNum,
+		LargestSeqNum:    m.LargestSeqNum,
+		HasPointKeys:     m.HasPointKeys,
+		SmallestPointKey: m.SmallestPointKey,
+		LargestPointKey:  m.LargestPointKey,
+		HasRangeKeys:     m.HasRangeKeys,
+		SmallestRangeKey: m.SmallestRangeKey,
+		LargestRangeKey:  m.LargestRangeKey,
+		CreationTime:     m.CreationTime,
+		Backing:          backing,
+	}
+}
+
+// fileMetadata returns the metadata of the physical table described by t.
+func (t *CompactionJobTable) fileMetadata(cmp Compare) *fileMetadata {
+	m := &fileMetadata{
+		FileNum:        t.FileNum,
+		Size:           t.Size,
+		SmallestSeqNum: t.SmallestSeqNum,
+		LargestSeqNum:  t.LargestSeqNum,
+		CreationTime:   t.CreationTime,
+	}
+	if t.HasPointKeys {
+		m.ExtendPointKeyBounds(cmp, t.SmallestPointKey, t.LargestPointKey)
+	}
+	if t.HasRangeKeys {
+		m.ExtendRangeKeyBounds(cmp, t.SmallestRangeKey, t.LargestRangeKey)
+	}
+	m.InitPhysicalBacking()
+	return m
+}
+
+// NewInProcessCompactionExecutor returns a CompactionExecutor that runs the
+// compaction jobs in the current process, with the given options. The options
+// must configure the same remote storage as the DB's. It's meant for testing,
+// and as a reference for executors that run the jobs elsewhere.
+func NewInProcessCompactionExecutor(opts *Options) CompactionExecutor {
+	return inProcessCompactionExecutor{opts: opts}
+}
+
+type inProcessCompactionExecutor struct {
+	opts *Options
+}
+
+// ExecuteCompaction implements CompactionExecutor.
+func (e inProcessCompactionExecutor) ExecuteCompaction(
+	ctx context.Context, job *CompactionJob,
+) (*CompactionJobResult, error) {
+	return RunCompactionJob(ctx, job, e.opts)
+}
+
+// RunCompactionJob runs a compaction job of a CompactionExecutor. The options
+// must use the comparer and merger of the DB that created the job, and
+// Options.Experimental.RemoteStorage must give access to the remote storage of
+// its input and output tables. Only the options that affect the output tables
+// (such as Levels and Cache) are used.
+//
+// The input tables are only read, and are left on remote storage for the DB to
+// remove once the result is installed. If an error is returned, the output
+// tables that were created are removed.
+func RunCompactionJob(
+	ctx context.Context, job *CompactionJob, opts *Options,
+) (_ *CompactionJobResult, retErr error) {
+	opts = opts.Clone().EnsureDefaults()
+	if opts.Comparer.Name != job.Comparer {
+		return nil, errors.Errorf("pebble: compaction job comparer %q does not match %q",
+			errors.Safe(job.Comparer), errors.Safe(opts.Comparer.Name))
+	}
+	if opts.Merger.Name != job.Merger {
+		return nil, errors.Errorf("pebble: compaction job merger %q does not match %q",
+			errors.Safe(job.Merger), errors.Safe(opts.Merger.Name))
+	}
+	if opts.Experimental.RemoteStorage == nil {
+		return nil, errors.New("pebble: compaction job requires remote storage")
+	}
+	if len(job.Inputs) < 2 || job.Inputs[len(job.Inputs)-1].Level != job.OutputLevel {
+		return nil, errors.New("pebble: compaction job has invalid input levels")
+	}
+	opts.Experimental.CreateOnShared = remote.CreateOnSharedAll
+	opts.Experimental.CreateOnSharedLocator = job.OutputLocator
+
+	// The worker has no local storage: the output tables are created on shared
+	// storage under the job's creator ID, as if the DB had created them.
+	providerSettings := objstorageprovider.Settings{
+		Logger: opts.Logger,
+		FS:     vfs.NewMem(),
+	}
+	providerSettings.Remote.StorageFactory = opts.Experimental.RemoteStorage
+	providerSettings.Remote.CreateOnShared = remote.CreateOnSharedAll
+	providerSettings.Remote.CreateOnSharedLocator = job.OutputLocator
+	provider, err := objstorageprovider.Open(providerSettings)
+	if err != nil {
+		return nil, err
+	}
+	defer provider.Close()
+	if err := provider.SetCreatorID(objstorage.CreatorID(job.CreatorID)); err != nil {
+		return nil, err
+	}
+
+	// Attach the input tables under the file numbers they have in the DB, so
+	// that the worker's references to them are those of the DB.
+	var objs []objstorage.RemoteObjectToAttach
+	for i := range job.Inputs {
+		for j := range job.Inputs[i].Tables {
+			t := &job.Inputs[i].Tables[j]
+			objs = append(objs, objstorage.RemoteObjectToAttach{
+				FileNum:  t.FileNum.DiskFileNum(),
+				FileType: fileTypeTable,
+				Backing:  t.Backing,
+			})
+		}
+	}
+	if _, err := provider.AttachRemoteObjects(objs); err != nil {
+		return nil, err
+	}
+
+	if opts.Cache == nil {
+		opts.Cache = cache.New(cacheDefaultSize)
+	} else {
+		opts.Cache.Ref()
+	}
+	defer opts.Cache.Unref()
+	cacheID := opts.Cache.NewID()
+	tableCache := newTableCacheContainer(nil, cacheID, provider, opts,
+		TableCacheSize(opts.MaxOpenFiles), &sstable.CategoryStatsCollector{})
+	defer func() {
+		if err := tableCache.close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+
+	d := &DB{
+		cacheID:              cacheID,
+		opts:                 opts,
+		cmp:                  opts.Comparer.Compare,
+		equal:                opts.equal(),
+		merge:                opts.Merger.Merge,
+		split:                opts.Comparer.Split,
+		objProvider:          provider,
+		newIters:             tableCache.newIters,
+		tableNewRangeKeyIter: tableCache.newRangeKeyIter,
+	}
+	d.mu.versions = &versionSet{nextFileNum: uint64(job.FirstOutputFileNum)}
+	d.mu.formatVers.vers.Store(uint64(job.FormatMajorVersion))
+
+	c := job.newCompaction(opts)
+	stop := context.AfterFunc(ctx, func() { c.setCancelled() })
+	defer stop()
+
+	// The output file numbers are bounded by the range reserved for the job;
+	// newOutput fails rather than allocating a file number past it.
+	o := &compactionOutput{
+		fileNumLimit: base.FileNum(job.FirstOutputFileNum) + base.FileNum(job.MaxOutputTables),
+	}
+	defer func() {
+		if retErr != nil {
+			for _, fileNum := range o.createdFiles {
+				_ = provider.Remove(fileTypeTable, fileNum)
+			}
+		}
+	}()
+	writerOpts := opts.makeCompactionWriterOptions(job.OutputLevel, job.FormatMajorVersion)
+	if err := d.compactAndWrite(job.JobID, c, job.Snapshots, writerOpts, o); err != nil {
+		return nil, err
+	}
+
+	result := &CompactionJobResult{}
+	for _, nf := range o.newFiles {
+		objMeta, err := provider.Lookup(fileTypeTable, nf.Meta.FileBacking.DiskFileNum)
+		if err != nil {
+			return nil, err
+		}
+		h, err := provider.RemoteObjectBacking(&objMeta)
+		if err != nil {
+			return nil, err
+		}
+		backing, err := h.Get()
+		if err == nil {
+			backing = append(objstorage.RemoteObjectBacking(nil), backing...)
+		}
+		h.Close()
+		if err != nil {
+			return nil, err
+		}
+		result.Tables = append(result.Tables, makeCompactionJobTable(nf.Meta, backing))
+	}
+	return result, nil
+}
+
+// newCompaction returns the compaction described by the job.
+func (job *CompactionJob) newCompaction(opts *Options) *compaction {
+	cmp := opts.Comparer.Compare
+	c := &compaction{
+		kind:              compactionKindDefault,
+		cmp:               cmp,
+		equal:             opts.equal(),
+		comparer:          opts.Comparer,
+		formatKey:         opts.Comparer.FormatKey,
+		logger:            opts.Logger,
+		smallest:          job.Smallest,
+		largest:           job.Largest,
+		maxOutputFileSize: job.MaxOutputFileSize,
+		maxOverlapBytes:   job.MaxOverlapBytes,
+		inuseEntireRange:  job.InuseEntireRange,
+		allowedZeroSeqNum: job.AllowZeroSeqNum,
+	}
+	levelSlice := func(tables []CompactionJobTable) manifest.LevelSlice {
+		files := make([]*fileMetadata, len(tables))
+		for i := range tables {
+			// The tables are referenced for as long as the job runs, as if they
+			// belonged to a version of the worker.
+			files[i] = tables[i].fileMetadata(cmp)
+			files[i].Ref()
+		}
+		return manifest.NewLevelSliceKeySorted(cmp, files)
+	}
+	for _, l := range job.Inputs {
+		c.inputs = append(c.inputs, compactionLevel{level: l.Level, files: levelSlice(l.Tables)})
+	}
+	c.startLevel = &c.inputs[0]
+	c.outputLevel = &c.inputs[len(c.inputs)-1]
+	for i := 1; i < len(c.inputs)-1; i++ {
+		c.extraLevels = append(c.extraLevels, &c.inputs[i])
+	}
+	c.grandparents = levelSlice(job.Grandparents)
+	for _, r := range job.InuseKeyRanges {
+		c.inuseKeyRanges = append(c.inuseKeyRanges, manifest.UserKeyRange{Start: r.Start, End: r.End})
+	}
+	return c
+}
+
+// makeCompactionJob returns the job that offloads the compaction c to the
+// CompactionExecutor, or nil if c can't be offloaded. The returned handles
+// keep the backings of the input tables valid, and must be closed once the job
+// is done.
+//
+// d.mu must be held when calling this.
+func (d *DB) makeCompactionJob(
+	jobID int, c *compaction, snapshots []uint64,
+) (*CompactionJob, []objstorage.RemoteObjectBackingHandle) {
+	if d.opts.Experimental.CompactionExecutor == nil || c.kind != compactionKindDefault ||
+		len(c.flushing) != 0 || c.startLevel.level == 0 ||
+		!remote.ShouldCreateShared(d.opts.Experimental.CreateOnShared, c.outputLevel.level) {
+		return nil, nil
+	}
+	creatorID := d.objProvider.CreatorID()
+	if !creatorID.IsSet() {
+		return nil, nil
+	}
+
+	job := &CompactionJob{
+		JobID:              jobID,
+		CreatorID:          uint64(creatorID),
+		Comparer:           d.opts.Comparer.Name,
+		Merger:             d.opts.Merger.Name,
+		FormatMajorVersion: d.FormatMajorVersion(),
+		OutputLevel:        c.outputLevel.level,
+		Smallest:           c.smallest,
+		Largest:            c.largest,
+		Snapshots:          snapshots,
+		AllowZeroSeqNum:    c.allowZeroSeqNum(),
+		InuseEntireRange:   c.inuseEntireRange,
+		MaxOutputFileSize:  c.maxOutputFileSize,
+		MaxOverlapBytes:    c.maxOverlapBytes,
+		OutputLocator:      d.opts.Experimental.CreateOnSharedLocator,
+	}
+	for _, r := range c.inuseKeyRanges {
+		job.InuseKeyRanges = append(job.InuseKeyRanges, KeyRange{Start: r.Start, End: r.End})
+	}
+
+	var handles []objstorage.RemoteObjectBackingHandle
+	release := func() {
+		for _, h := range handles {
+			h.Close()
+		}
+	}
+	var inputSize uint64
+	for _, cl := range c.inputs {
+		level := CompactionJobLevel{Level: cl.level}
+		iter := cl.files.Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			// Virtual tables would need their backings to be shared with the
+			// worker, which isn't supported.
+			if f.Virtual {
+				release()
+				return nil, nil
+			}
+			objMeta, err := d.objProvider.Lookup(fileTypeTable, f.FileBacking.DiskFileNum)
+			if err != nil || !objMeta.IsRemote() {
+				release()
+				return nil, nil
+			}
+			h, err := d.objProvider.RemoteObjectBacking(&objMeta)
+			if err != nil {
+				release()
+				return nil, nil
+			}
+			handles = append(handles, h)
+			backing, err := h.Get()
+			if err != nil {
+				release()
+				return nil, nil
+			}
+			level.Tables = append(level.Tables, makeCompactionJobTable(f, backing))
+			inputSize += f.Size
+		}
+		job.Inputs = append(job.Inputs, level)
+	}
+	iter := c.grandparents.Iter()
+	for f := iter.First(); f != nil; f = iter.Next() {
+		job.Grandparents = append(job.Grandparents, makeCompactionJobTable(f, nil))
+	}
+
+	// Reserve file numbers for the output tables. Outputs are split at
+	// grandparent boundaries as well as by size, and may be smaller than the
+	// target size, hence the generous reservation.
+	job.MaxOutputTables = 2*int(inputSize/max(c.maxOutputFileSize, 1)+1) + len(job.Grandparents) + 8
+	job.FirstOutputFileNum = d.mu.versions.getNextDiskFileNum()
+	d.mu.versions.nextFileNum += uint64(job.MaxOutputTables - 1)
+	return job, handles
+}
+
+// compactionContext returns a context that is cancelled once the compaction is
+// cancelled through setCancelled. The returned CancelFunc must be called once
+// the context is no longer needed.
+func compactionContext(c *compaction) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancelMu.Lock()
+	c.cancelMu.cancelCtx = cancel
+	c.cancelMu.Unlock()
+	// The compaction may have been cancelled before the context was set.
+	if c.cancelled() {
+		cancel()
+	}
+	return ctx, func() {
+		c.cancelMu.Lock()
+		c.cancelMu.cancelCtx = nil
+		c.cancelMu.Unlock()
+		cancel()
+	}
+}
+
+// runRemoteCompaction runs the compaction job with the CompactionExecutor and
+// attaches the tables it produced, filling in o. The handles returned by
+// makeCompactionJob are closed.
+//
+// d.mu must not be held when calling this.
+func (d *DB) runRemoteCompaction(
+	c *compaction,
+	job *CompactionJob,
+	handles []objstorage.RemoteObjectBackingHandle,
+	o *compactionOutput,
+) error {
+	result, err := func() (*CompactionJobResult, error) {
+		defer func() {
+			for _, h := range handles {
+				h.Close()
+			}
+		}()
+		ctx, cancel := compactionContext(c)
+		defer cancel()
+		return d.opts.Experimental.CompactionExecutor.ExecuteCompaction(ctx, job)
+	}()
+	if err != nil {
+		if c.cancelled() {
+			return ErrCancelledCompaction
+		}
+		return errors.Wrapf(err, "pebble: remote compaction")
+	}
+
+	// Validate the tables before attaching them: they must use the reserved file
+	// numbers, lie within the compaction's key and seqnum bounds, and not
+	// overlap one another.
+	metas := make([]*fileMetadata, len(result.Tables))
+	for i := range result.Tables {
+		metas[i] = result.Tables[i].fileMetadata(d.cmp)
+	}
+	sort.Sort(remoteTablesBySmallest{tables: result.Tables, metas: metas, cmp: d.cmp})
+	if err := c.validateRemoteOutputs(job, metas); err != nil {
+		return err
+	}
+
+	objs := make([]objstorage.RemoteObjectToAttach, len(result.Tables))
+	for i := range result.Tables {
+		objs[i] = objstorage.RemoteObjectToAttach{
+			FileNum:  result.Tables[i].FileNum.DiskFileNum(),
+			FileType: fileTypeTable,
+			Backing:  result.Tables[i].Backing,
+		}
+	}
+	objMetas, err := d.objProvider.AttachRemoteObjects(objs)
+	if err != nil {
+		return err
+	}
+	for _, objMeta := range objMetas {
+		o.createdFiles = append(o.createdFiles, objMeta.DiskFileNum)
+	}
+
+	ve := &versionEdit{}
+	for i, meta := range metas {
+		objMeta := objMetas[i]
+		o.pendingOutputs = append(o.pendingOutputs, meta.PhysicalMeta())
+		d.opts.EventListener.TableCreated(TableCreateInfo{
+			JobID:   job.JobID,
+			Reason:  "compacting",
+			Path:    d.objProvider.Path(objMeta),
+			FileNum: objMeta.DiskFileNum,
+		})
+		ve.NewFiles = append(ve.NewFiles, newFileEntry{Level: c.outputLevel.level, Meta: meta})
+		o.metrics.TablesCompacted++
+		o.metrics.BytesCompacted += meta.Size
+		o.metrics.Size += int64(meta.Size)
+		o.metrics.NumFiles++
+	}
+	o.newFiles = ve.NewFiles
+	return nil
+}
+
+// remoteTablesBySmallest sorts the tables of a CompactionJobResult, along with
+// their file metadata, by smallest key.
+type remoteTablesBySmallest struct {
+	tables []CompactionJobTable
+	metas  []*fileMetadata
+	cmp    Compare
+}
+
+func (s remoteTablesBySmallest) Len() int { return len(s.tables) }
+
+func (s remoteTablesBySmallest) Less(i, j int) bool {
+	return base.InternalCompare(s.cmp, s.metas[i].Smallest, s.metas[j].Smallest) < 0
+}
+
+func (s remoteTablesBySmallest) Swap(i, j int) {
+	s.tables[i], s.tables[j] = s.tables[j], s.tables[i]
+	s.metas[i], s.metas[j] = s.metas[j], s.metas[i]
+}
+
+// validateRemoteOutputs checks the metadata of the tables produced by the
+// CompactionExecutor for the job, sorted by smallest key.
+func (c *compaction) validateRemoteOutputs(job *CompactionJob, metas []*fileMetadata) error {
+	// The outputs' seqnums are bounded by the inputs', except that they may be
+	// zeroed.
+	var smallestSeqNum, largestSeqNum uint64 = math.MaxUint64, 0
+	for _, level := range c.inputs {
+		iter := level.files.Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			smallestSeqNum = min(smallestSeqNum, f.SmallestSeqNum)
+			largestSeqNum = max(largestSeqNum, f.LargestSeqNum)
+		}
+	}
+	if c.allowedZeroSeqNum {
+		smallestSeqNum = 0
+	}
+
+	lastFileNum := job.FirstOutputFileNum + base.DiskFileNum(job.MaxOutputTables)
+	seen := make(map[base.FileNum]struct{}, len(metas))
+	ve := &versionEdit{}
+	for _, meta := range metas {
+		if fileNum := meta.FileNum.DiskFileNum(); fileNum < job.FirstOutputFileNum || fileNum >= lastFileNum {
+			return errors.Errorf("pebble: remote compaction produced table %s outside of the reserved file numbers",
+				fileNum)
+		}
+		if _, ok := seen[meta.FileNum]; ok {
+			return errors.Errorf("pebble: remote compaction produced table %s more than once", meta.FileNum)
+		}
+		seen[meta.FileNum] = struct{}{}
+		if err := meta.Validate(c.cmp, c.formatKey); err != nil {
+			return err
+		}
+		if c.cmp(meta.Smallest.UserKey, c.smallest.UserKey) < 0 ||
+			c.cmp(meta.Largest.UserKey, c.largest.UserKey) > 0 {
+			return errors.Errorf("pebble: remote compaction produced table %s outside of the compaction bounds",
+				meta.FileNum)
+		}
+		if meta.SmallestSeqNum < smallestSeqNum || meta.LargestSeqNum > largestSeqNum {
+			return errors.Errorf("pebble: remote compaction produced table %s with seqnums [%d, %d] outside of the inputs' [%d, %d]",
+				meta.FileNum, meta.SmallestSeqNum, meta.LargestSeqNum, smallestSeqNum, largestSeqNum)
+		}
+		if n := len(ve.NewFiles); n > 0 {
+			if prev := ve.NewFiles[n-1].Meta; c.cmp(prev.Largest.UserKey, meta.Smallest.UserKey) > 0 {
+				return errors.Errorf("pebble: remote compaction produced overlapping tables %s and %s",
+					prev.FileNum, meta.FileNum)
+			}
+		}
+		ve.NewFiles = append(ve.NewFiles, newFileEntry{Level: c.outputLevel.level, Meta: meta})
+		if err := c.errorOnUserKeyOverlap(ve); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cockroachdb/pebble/objstorage/remote"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonCompactionExecutor runs compaction jobs in-process after a round trip
+// through JSON, as an executor in another process would.
+type jsonCompactionExecutor struct {
+	executor CompactionExecutor
+	jobs     atomic.Int32
+}
+
+func (e *jsonCompactionExecutor) ExecuteCompaction(
+	ctx context.Context, job *CompactionJob,
+) (*CompactionJobResult, error) {
+	e.jobs.Add(1)
+	roundTrip := func(in, out interface{}) error {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, out)
+	}
+	var decoded CompactionJob
+	if err := roundTrip(job, &decoded); err != nil {
+		return nil, err
+	}
+	result, err := e.executor.ExecuteCompaction(ctx, &decoded)
+	if err != nil {
+		return nil, err
+	}
+	var decodedResult CompactionJobResult
+	if err := roundTrip(result, &decodedResult); err != nil {
+		return nil, err
+	}
+	return &decodedResult, nil
+}
+
+func TestCompactionExecutor(t *testing.T) {
+	storage := remote.NewInMem()
+	factory := remote.MakeSimpleFactory(map[remote.Locator]remote.Storage{"": storage})
+	workerOpts := &Options{}
+	workerOpts.Experimental.RemoteStorage = factory
+	executor := &jsonCompactionExecutor{executor: NewInProcessCompactionExecutor(workerOpts)}
+
+	opts := &Options{
+		FS:                 vfs.NewMem(),
+		DebugCheck:         DebugCheckLevels,
+		FormatMajorVersion: FormatNewest,
+		// A tiny LBaseMaxBytes lowers the base level, so that manual compactions
+		// compact tables from level to level.
+		LBaseMaxBytes: 1,
+	}
+	opts.Experimental.RemoteStorage = factory
+	opts.Experimental.CreateOnShared = remote.CreateOnSharedAll
+	opts.Experimental.CompactionExecutor = executor
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	require.NoError(t, d.SetCreatorID(1))
+
+	key := func(i int) []byte { return []byte(fmt.Sprintf("%06d", i)) }
+	expected := map[string]string{}
+	for round := 0; round < 3; round++ {
+		for i := round; i < 2000; i += round + 1 {
+			value := fmt.Sprintf("%d-%d", round, i)
+			require.NoError(t, d.Set(key(i), []byte(value), nil))
+			expected[string(key(i))] = value
+		}
+		for i := 0; i < 2000; i += 11 * (round + 1) {
+			require.NoError(t, d.Delete(key(i), nil))
+			delete(expected, string(key(i)))
+		}
+		require.NoError(t, d.Flush())
+		require.NoError(t, d.Compact(key(0), key(2000), false /* parallelize */))
+	}
+	require.Greater(t, executor.jobs.Load(), int32(0))
+
+	// The compactions run by the executor preserved the contents of the DB.
+	iter, _ := d.NewIter(nil)
+	var n int
+	for valid := iter.First(); valid; valid = iter.Next() {
+		require.Equal(t, expected[string(iter.Key())], string(iter.Value()))
+		n++
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, len(expected), n)
+
+	// Every table left on remote storage is a live table of the DB: neither the
+	// inputs of the compactions nor the outputs of the worker were leaked.
+	d.mu.Lock()
+	d.deleteObsoleteFiles(d.mu.nextJobID)
+	d.mu.Unlock()
+	d.cleanupManager.Wait()
+	objs, err := storage.List("", "")
+	require.NoError(t, err)
+	var tables int
+	for _, obj := range objs {
+		if strings.HasSuffix(obj, ".sst") {
+			tables++
+		}
+	}
+	require.Equal(t, int(d.Metrics().Total().NumFiles), tables)
+	require.NoError(t, d.Close())
+}
+
+func TestCompactionContext(t *testing.T) {
+	c := &compaction{}
+	ctx, cancel := compactionContext(c)
+	defer cancel()
+	require.NoError(t, ctx.Err())
+	c.setCancelled()
+	require.ErrorIs(t, ctx.Err(), context.Canceled)
+
+	// A compaction cancelled before its context was created.
+	ctx2, cancel2 := compactionContext(c)
+	defer cancel2()
+	require.ErrorIs(t, ctx2.Err(), context.Canceled)
+}
+
+// creationCountingStorage counts the tables created on the wrapped storage.
+type creationCountingStorage struct {
+	remote.Storage
+	tables atomic.Int32
+}
+
+func (s *creationCountingStorage) CreateObject(objName string) (io.WriteCloser, error) {
+	if strings.HasSuffix(objName, ".sst") {
+		s.tables.Add(1)
+	}
+	return s.Storage.CreateObject(objName)
+}
+
+// limitingCompactionExecutor runs compaction jobs with a lowered
+// MaxOutputTables, recording the tables created by each job.
+type limitingCompactionExecutor struct {
+	executor        CompactionExecutor
+	storage         *creationCountingStorage
+	maxOutputTables int
+	created         []int32
+	errs            []error
+}
+
+func (e *limitingCompactionExecutor) ExecuteCompaction(
+	ctx context.Context, job *CompactionJob,
+) (*CompactionJobResult, error) {
+	job.MaxOutputTables = e.maxOutputTables
+	job.MaxOutputFileSize = 1
+	e.storage.tables.Store(0)
+	result, err := e.executor.ExecuteCompaction(ctx, job)
+	e.created = append(e.created, e.storage.tables.Load())
+	e.errs = append(e.errs, err)
+	return result, err
+}
+
+func TestCompactionExecutorMaxOutputTables(t *testing.T) {
+	storage := &creationCountingStorage{Storage: remote.NewInMem()}
+	factory := remote.MakeSimpleFactory(map[remote.Locator]remote.Storage{"": storage})
+	workerOpts := &Options{}
+	workerOpts.Experimental.RemoteStorage = factory
+	executor := &limitingCompactionExecutor{
+		executor:        NewInProcessCompactionExecutor(workerOpts),
+		storage:         storage,
+		maxOutputTables: 2,
+	}
+
+	opts := &Options{
+		FS:                          vfs.NewMem(),
+		FormatMajorVersion:          FormatNewest,
+		DisableAutomaticCompactions: true,
+		// A tiny LBaseMaxBytes lowers the base level, so that manual compactions
+		// compact tables from level to level.
+		LBaseMaxBytes: 1,
+	}
+	opts.Experimental.RemoteStorage = factory
+	opts.Experimental.CreateOnShared = remote.CreateOnSharedAll
+	opts.Experimental.CompactionExecutor = executor
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	require.NoError(t, d.SetCreatorID(1))
+
+	// Overlapping tables that compact into many tiny output tables, once
+	// they're compacted out of L0.
+	value := strings.Repeat("x", 100)
+	for round := 0; round < 3 && len(executor.errs) == 0; round++ {
+		for i := 0; i < 2000; i++ {
+			require.NoError(t, d.Set([]byte(fmt.Sprintf("%06d", i)), []byte(value), nil))
+		}
+		require.NoError(t, d.Flush())
+		_ = d.Compact([]byte("000000"), []byte("002000"), false /* parallelize */)
+	}
+
+	// The job failed once it ran out of file numbers, without creating a table
+	// past the reserved range.
+	require.NotEmpty(t, executor.errs)
+	require.ErrorContains(t, executor.errs[0], "exhausted its output file numbers")
+	require.Equal(t, int32(executor.maxOutputTables), executor.created[0])
+}
+
+// tamperingCompactionExecutor runs compaction jobs with tiny output tables and
+// alters their results.
+type tamperingCompactionExecutor struct {
+	executor  CompactionExecutor
+	tamper    func(*CompactionJobResult)
+	tampering bool
+	jobs      int
+}
+
+func (e *tamperingCompactionExecutor) ExecuteCompaction(
+	ctx context.Context, job *CompactionJob,
+) (*CompactionJobResult, error) {
+	job.MaxOutputFileSize = 1
+	e.jobs++
+	result, err := e.executor.ExecuteCompaction(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	if e.tampering {
+		e.tamper(result)
+	}
+	return result, nil
+}
+
+func TestCompactionExecutorInvalidResults(t *testing.T) {
+	testCases := []struct {
+		name   string
+		tamper func(*CompactionJobResult)
+		err    string
+	}{
+		{
+			name:   "unsorted",
+			tamper: func(r *CompactionJobResult) { slices.Reverse(r.Tables) },
+		},
+		{
+			name: "duplicate",
+			tamper: func(r *CompactionJobResult) {
+				r.Tables = append(r.Tables, r.Tables[len(r.Tables)-1])
+			},
+			err: "more than once",
+		},
+		{
+			name: "overlap",
+			tamper: func(r *CompactionJobResult) {
+				r.Tables[0].LargestPointKey = r.Tables[len(r.Tables)-1].LargestPointKey
+			},
+			err: "overlapping tables",
+		},
+		{
+			name:   "seqnum",
+			tamper: func(r *CompactionJobResult) { r.Tables[0].LargestSeqNum = math.MaxUint32 },
+			err:    "outside of the inputs'",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := remote.NewInMem()
+			factory := remote.MakeSimpleFactory(map[remote.Locator]remote.Storage{"": storage})
+			workerOpts := &Options{}
+			workerOpts.Experimental.RemoteStorage = factory
+			opts := &Options{
+				FS:                          vfs.NewMem(),
+				FormatMajorVersion:          FormatNewest,
+				DisableAutomaticCompactions: true,
+				LBaseMaxBytes:               1,
+			}
+			opts.Experimental.RemoteStorage = factory
+			opts.Experimental.CreateOnShared = remote.CreateOnSharedAll
+			executor := &tamperingCompactionExecutor{
+				executor: NewInProcessCompactionExecutor(workerOpts),
+				tamper:   tc.tamper,
+			}
+			opts.Experimental.CompactionExecutor = executor
+			d, err := Open("", opts)
+			require.NoError(t, err)
+			defer func() { require.NoError(t, d.Close()) }()
+			require.NoError(t, d.SetCreatorID(1))
+
+			// The first compaction moves the table out of L0, and the second one
+			// compacts it with overlapping tables with the executor; its results
+			// are tampered with.
+			for round := 0; round < 2; round++ {
+				for i := 0; i < 5; i++ {
+					require.NoError(t, d.Set([]byte(fmt.Sprintf("%06d", i)), []byte("x"), nil))
+				}
+				require.NoError(t, d.Flush())
+				executor.tampering = round == 1
+				err = d.Compact([]byte("000000"), []byte("000005"), false /* parallelize */)
+			}
+			require.Equal(t, 1, executor.jobs)
+			if tc.err == "" {
+				require.NoError(t, err)
+				require.Greater(t, d.Metrics().Total().NumFiles, int64(1))
+			} else {
+				require.ErrorContains(t, err, tc.err)
+				// The tables were rejected before they were attached to the DB.
+				require.Equal(t, int(d.Metrics().Total().NumFiles), len(d.objProvider.List()))
+			}
+
+			iter, _ := d.NewIter(nil)
+			var n int
+			for valid := iter.First(); valid; valid = iter.Next() {
+				n++
+			}
+			require.NoError(t, iter.Close())
+			require.Equal(t, 5, n)
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"math"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/cockroachdb/pebble/internal/base"
 	"github.com/cockroachdb/pebble/internal/humanize"
@@ -36,6 +37,9 @@ type compactionEnv struct {
 	earliestSnapshotSeqNum  uint64
 	inProgressCompactions   []compactionInfo
 	readCompactionEnv       readCompactionEnv
+	// lastPeriodicCompaction is the time at which the last periodic compaction
+	// was started, which is zero if none was.
+	lastPeriodicCompaction time.Time
 }
 
 type compactionPicker interface {
@@ -63,6 +67,7 @@ type compactionInfo struct {
 	// been committed. The compaction may still be in-progress deleting newly
 	// obsolete files.
 	versionEditApplied bool
+	kind               compactionKind
 	inputs             []compactionLevel
 	outputLevel        int
 	smallest           InternalKey
@@ -223,6 +228,12 @@ type pickedCompaction struct {
 	largest       InternalKey
 	version       *version
 	pickerMetrics compactionPickerMetrics
+	// deleteReason is the reason reported when the tables of a delete-only
+	// compaction are deleted. See compaction.deleteReason.
+	deleteReason string
+	// outputCreationTime is the creation time of the compaction's output
+	// tables. See compaction.outputCreationTime.
+	outputCreationTime int64
 }
 
 func defaultOutputLevel(startLevel, baseLevel int) int {
@@ -575,17 +586,49 @@ func anyTablesCompacting(inputs manifest.LevelSlice) bool {
 	return false
 }
 
-func newCompactionPicker(
+// CompactionPicker determines the strategy a DB uses to pick automatic
+// compactions. See Options.CompactionPicker.
+type CompactionPicker interface {
+	// newPicker returns a compaction picker for the given version.
+	newPicker(v *version, opts *Options, inProgressCompactions []compactionInfo) compactionPicker
+
+	// String implements fmt.Stringer.
+	String() string
+}
+
+// LeveledCompactionPicker picks compactions using leveled compaction: each
+// level below L0 is a single sorted run whose target size grows by a constant
+// multiplier per level, and compactions are picked from the level whose size
+// most exceeds its target. L0 is compacted using its sublevels. This is the
+// default strategy.
+type LeveledCompactionPicker struct{}
+
+var _ CompactionPicker = LeveledCompactionPicker{}
+
+func (LeveledCompactionPicker) newPicker(
 	v *version, opts *Options, inProgressCompactions []compactionInfo,
 ) compactionPicker {
 	p := &compactionPickerByScore{
 		opts: opts,
 		vers: v,
+		now:  time.Now,
 	}
 	p.initLevelMaxBytes(inProgressCompactions)
 	return p
 }
 
+// String implements fmt.Stringer.
+func (LeveledCompactionPicker) String() string { return "leveled" }
+
+func newCompactionPicker(
+	v *version, opts *Options, inProgressCompactions []compactionInfo,
+) compactionPicker {
+	if opts.CompactionPicker == nil {
+		return LeveledCompactionPicker{}.newPicker(v, opts, inProgressCompactions)
+	}
+	return opts.CompactionPicker.newPicker(v, opts, inProgressCompactions)
+}
+
 // Information about a candidate compaction level that has been identified by
 // the compaction picker.
 type candidateLevelInfo struct {
@@ -688,6 +731,9 @@ type compactionPickerByScore struct {
 	// levelMaxBytes holds the dynamically adjusted max bytes setting for each
 	// level.
 	levelMaxBytes [numLevels]int64
+	// now returns the current time, against which the age of tables is
+	// measured for periodic compactions.
+	now func() time.Time
 }
 
 var _ compactionPicker = &compactionPickerByScore{}
@@ -1322,6 +1368,12 @@ func (p *compactionPickerByScore) pickAuto(env compactionEnv) (pc *pickedCompact
 		}
 	}
 
+	// Below everything else, rewrite tables that haven't been compacted for
+	// longer than the periodic compaction interval.
+	if pc := p.pickPeriodicCompaction(env); pc != nil {
+		return pc
+	}
+
 	return nil
 }
 
@@ -1536,6 +1588,129 @@ func (p *compactionPickerByScore) pickRewriteCompaction(env compactionEnv) (pc *
 	return nil
 }
 
+// periodicCompactionAnnotator implements the manifest.Annotator interface,
+// annotating B-Tree nodes with the *fileMetadata of the file with the oldest
+// known creation time within the subtree.
+type periodicCompactionAnnotator struct{}
+
+var _ manifest.Annotator = periodicCompactionAnnotator{}
+
+func (a periodicCompactionAnnotator) Zero(interface{}) interface{} {
+	return nil
+}
+
+func (a periodicCompactionAnnotator) Accumulate(
+	f *fileMetadata, dst interface{},
+) (interface{}, bool) {
+	if f.CreationTime == 0 {
+		// The creation time of the file is unknown.
+		return dst, true
+	}
+	return periodicMergeHelper(f, dst), true
+}
+
+func (a periodicCompactionAnnotator) Merge(v interface{}, accum interface{}) interface{} {
+	if v == nil {
+		return accum
+	}
+	return periodicMergeHelper(v.(*fileMetadata), accum)
+}
+
+func periodicMergeHelper(f *fileMetadata, dst interface{}) interface{} {
+	if dst == nil {
+		return f
+	} else if dstV := dst.(*fileMetadata); dstV.CreationTime > f.CreationTime {
+		return f
+	}
+	return dst
+}
+
+// periodicCompactionSpacing returns the minimum time between the starts of two
+// periodic compactions: PeriodicCompactionInterval divided by the number of
+// tables below L0, so that periodic compactions rewrite at most all of the
+// tables once per interval, spread evenly across it.
+func periodicCompactionSpacing(opts *Options, vers *version) time.Duration {
+	var n int
+	for l := 1; l < numLevels; l++ {
+		n += vers.Levels[l].Len()
+	}
+	return opts.PeriodicCompactionInterval / time.Duration(max(n, 1))
+}
+
+// pickPeriodicCompaction attempts to construct a compaction of the oldest
+// table that was created more than Options.PeriodicCompactionInterval ago. A
+// table in the bottommost level is rewritten in place, while a table in
+// another level is compacted into the next level, like an automatic
+// compaction seeded with the table. At most one periodic compaction runs at a
+// time, so that they don't compete with other compactions, and they're rate
+// limited by periodicCompactionSpacing.
+func (p *compactionPickerByScore) pickPeriodicCompaction(
+	env compactionEnv,
+) (pc *pickedCompaction) {
+	if p.opts.PeriodicCompactionInterval <= 0 {
+		return nil
+	}
+	for _, info := range env.inProgressCompactions {
+		if info.kind == compactionKindPeriodic {
+			return nil
+		}
+	}
+	if !env.lastPeriodicCompaction.IsZero() &&
+		p.now().Sub(env.lastPeriodicCompaction) < periodicCompactionSpacing(p.opts, p.vers) {
+		return nil
+	}
+
+	// L0 is excluded: its tables are compacted regularly as new data is
+	// flushed.
+	threshold := p.now().Add(-p.opts.PeriodicCompactionInterval).Unix()
+	level := -1
+	var candidate *fileMetadata
+	for l := 1; l < numLevels; l++ {
+		v := p.vers.Levels[l].Annotation(periodicCompactionAnnotator{})
+		if v == nil {
+			continue
+		}
+		f := v.(*fileMetadata)
+		if f.CreationTime >= threshold || f.IsCompacting() {
+			continue
+		}
+		if candidate == nil || f.CreationTime < candidate.CreationTime {
+			level, candidate = l, f
+		}
+	}
+	if candidate == nil {
+		return nil
+	}
+	lf := p.vers.Levels[level].Find(p.opts.Comparer.Compare, candidate)
+	if lf == nil {
+		panic(fmt.Sprintf("file %s not found in level %d as expected", candidate.FileNum, level))
+	}
+
+	if level == numLevels-1 {
+		pc = newPickedCompaction(p.opts, p.vers, level, level, p.baseLevel)
+		pc.startLevel.files = lf.Slice()
+		if anyTablesCompacting(pc.startLevel.files) {
+			return nil
+		}
+		pc.smallest, pc.largest = manifest.KeyRange(pc.cmp, pc.startLevel.files.Iter())
+	} else {
+		pc = pickAutoLPositive(env, p.opts, p.vers, candidateLevelInfo{
+			level:       level,
+			outputLevel: defaultOutputLevel(level, p.baseLevel),
+			file:        *lf,
+		}, p.baseLevel, p.levelMaxBytes)
+		if pc == nil {
+			return nil
+		}
+	}
+	pc.kind = compactionKindPeriodic
+	// Fail-safe to protect against compacting the same sstable concurrently.
+	if inputRangeAlreadyCompacting(env, pc) {
+		return nil
+	}
+	return pc
+}
+
 // pickAutoLPositive picks an automatic compaction for the candidate
 // file in a positive-numbered level. This function must not be used for
 // L0.
@@ -1746,6 +1921,9 @@ func pickL0(env compactionEnv, opts *Options, vers *version, baseLevel int) (pc
 func pickManualCompaction(
 	vers *version, opts *Options, env compactionEnv, baseLevel int, manual *manualCompaction,
 ) (pc *pickedCompaction, retryLater bool) {
+	if manual.rewrite != nil {
+		return pickManualRewriteCompaction(vers, opts, env, baseLevel, manual)
+	}
 	outputLevel := manual.level + 1
 	if manual.level == 0 {
 		outputLevel = baseLevel
@@ -1795,6 +1973,37 @@ func pickManualCompaction(
 	return pc, false
 }
 
+// pickManualRewriteCompaction picks a compaction that rewrites the table of a
+// manual compaction in place, along with the other tables of its atomic
+// compaction unit.
+func pickManualRewriteCompaction(
+	vers *version, opts *Options, env compactionEnv, baseLevel int, manual *manualCompaction,
+) (pc *pickedCompaction, retryLater bool) {
+	lf := vers.Levels[manual.level].Find(opts.Comparer.Compare, manual.rewrite)
+	if lf == nil {
+		// The table was compacted since the manual compaction was queued.
+		return nil, false
+	}
+	inputs := lf.Slice()
+	if anyTablesCompacting(inputs) {
+		return nil, true
+	}
+	pc = newPickedCompaction(opts, vers, manual.level, manual.level, baseLevel)
+	pc.outputLevel.level = manual.level
+	pc.kind = compactionKindRewrite
+	pc.startLevel.files = inputs
+	pc.smallest, pc.largest = manifest.KeyRange(pc.cmp, pc.startLevel.files.Iter())
+	manual.outputLevel = manual.level
+	// Fail-safe to protect against compacting the same sstable concurrently.
+	if inputRangeAlreadyCompacting(env, pc) {
+		return nil, true
+	}
+	if pc.startLevel.level == 0 {
+		pc.startLevel.l0SublevelInfo = generateSublevelInfo(pc.cmp, pc.startLevel.files)
+	}
+	return pc, false
+}
+
 func pickDownloadCompaction(
 	vers *version,
 	opts *Options,
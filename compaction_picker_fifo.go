@@ -0,0 +1,338 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/cockroachdb/pebble/internal/manifest"
+)
+
+// Reasons reported in TableDeleteInfo for tables dropped by
+// FIFOCompactionPicker.
+const (
+	fifoDeleteReasonSize = "fifo-size"
+	fifoDeleteReasonTTL  = "fifo-ttl"
+)
+
+// FIFOCompactionPicker picks compactions for append-only, time-ordered data
+// such as metrics or logs, for which keys are never updated and the oldest data
+// is discarded. Flushed tables stay in L0 and are never compacted into lower
+// levels. Instead:
+//
+//   - Once the total size of all tables exceeds MaxTableFilesSize, the oldest
+//     tables are dropped until it no longer does.
+//   - Tables created longer than TTL ago are dropped, oldest first.
+//   - Runs of at least MinMergeWidth adjacent L0 tables whose total size is at
+//     most MaxMergeBytes are merged into a single table, to keep the number of
+//     tables in check when flushes are small.
+//
+// Tables are dropped whole, using delete-only compactions, and their
+// TableDeleted events carry the reason "fifo-size" or "fifo-ttl". Tables are
+// ordered by sequence number, so tables added to lower levels by ingestion or a
+// manual compaction are dropped in order with those in L0. A table merged from
+// others keeps the creation time of the newest of them, so merging doesn't
+// extend the lifetime of the data. Tables that exceed the TTL are dropped
+// even while the DB is idle, within about a second of expiring.
+//
+// At most one automatic compaction runs at a time.
+type FIFOCompactionPicker struct {
+	// MaxTableFilesSize is the total size of the tables in the LSM above which
+	// the oldest tables are dropped. Zero means no limit.
+	MaxTableFilesSize uint64
+	// TTL is the age, relative to its creation time, beyond which a table is
+	// dropped. Zero means tables are never dropped because of their age.
+	TTL time.Duration
+	// MinMergeWidth is the minimum number of adjacent L0 tables merged by an
+	// intra-L0 compaction. The default value is 4. A negative value disables
+	// merges.
+	MinMergeWidth int
+	// MaxMergeBytes is the maximum total size of the tables merged by an
+	// intra-L0 compaction. The default value is the L0 TargetFileSize.
+	MaxMergeBytes uint64
+}
+
+var _ CompactionPicker = FIFOCompactionPicker{}
+
+func (f FIFOCompactionPicker) minMergeWidth() int {
+	if f.MinMergeWidth == 0 {
+		return minIntraL0Count
+	}
+	return max(f.MinMergeWidth, 2)
+}
+
+func (f FIFOCompactionPicker) maxMergeBytes(opts *Options) uint64 {
+	if f.MaxMergeBytes == 0 {
+		return uint64(opts.Level(0).TargetFileSize)
+	}
+	return f.MaxMergeBytes
+}
+
+func (f FIFOCompactionPicker) newPicker(
+	v *version, opts *Options, inProgressCompactions []compactionInfo,
+) compactionPicker {
+	p := &compactionPickerFIFO{
+		config:  f,
+		opts:    opts,
+		vers:    v,
+		leveled: LeveledCompactionPicker{}.newPicker(v, opts, inProgressCompactions),
+		now:     time.Now,
+	}
+	for level := range v.Levels {
+		iter := v.Levels[level].Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			p.files = append(p.files, fifoFile{level: level, meta: f})
+			p.size += f.Size
+		}
+	}
+	// Order the tables from oldest to newest. L0 tables are already ordered by
+	// sequence number, and stable sorting preserves that order among tables
+	// with equal sequence numbers.
+	slices.SortStableFunc(p.files, func(a, b fifoFile) int {
+		switch {
+		case a.meta.LargestSeqNum < b.meta.LargestSeqNum:
+			return -1
+		case a.meta.LargestSeqNum > b.meta.LargestSeqNum:
+			return +1
+		}
+		return 0
+	})
+	return p
+}
+
+// String implements fmt.Stringer.
+func (f FIFOCompactionPicker) String() string {
+	return fmt.Sprintf("fifo(%d, %s, %d, %d)", f.MaxTableFilesSize, f.TTL, f.MinMergeWidth, f.MaxMergeBytes)
+}
+
+// fifoFile is a table of the LSM and the level it is in.
+type fifoFile struct {
+	level int
+	meta  *fileMetadata
+}
+
+// compactionPickerFIFO implements the compactionPicker interface for
+// FIFOCompactionPicker. Like compactionPickerByScore, it is associated with a
+// single version.
+type compactionPickerFIFO struct {
+	config FIFOCompactionPicker
+	opts   *Options
+	vers   *version
+	// leveled picks rewrite compactions, which rewrite individual files in
+	// place and are independent of the strategy.
+	leveled compactionPicker
+	now     func() time.Time
+	// files holds all the tables of the version, from oldest to newest.
+	files []fifoFile
+	// size is the total size of files.
+	size uint64
+}
+
+var _ compactionPicker = &compactionPickerFIFO{}
+
+// expired returns true if the table was created more than TTL ago.
+func (p *compactionPickerFIFO) expired(f *fileMetadata) bool {
+	return p.config.TTL > 0 &&
+		p.now().Sub(time.Unix(f.CreationTime, 0)) > p.config.TTL
+}
+
+// nextExpiry returns the time at which the oldest table expires, or false if
+// tables don't expire or there are none.
+func (p *compactionPickerFIFO) nextExpiry() (time.Time, bool) {
+	if p.config.TTL <= 0 || len(p.files) == 0 {
+		return time.Time{}, false
+	}
+	// Creation times have a resolution of a second, and a table expires once
+	// it's strictly older than TTL.
+	return time.Unix(p.files[0].meta.CreationTime, 0).Add(p.config.TTL + time.Second), true
+}
+
+func (p *compactionPickerFIFO) getScores(inProgress []compactionInfo) [numLevels]float64 {
+	var scores [numLevels]float64
+	if p.config.MaxTableFilesSize > 0 {
+		scores[0] = float64(p.size) / float64(p.config.MaxTableFilesSize)
+	}
+	if len(p.files) > 0 && p.expired(p.files[0].meta) {
+		scores[0] = max(scores[0], 1)
+	}
+	return scores
+}
+
+func (p *compactionPickerFIFO) getBaseLevel() int {
+	return p.leveled.getBaseLevel()
+}
+
+// estimatedCompactionDebt implements the compactionPicker interface. Dropping
+// tables doesn't require reading or writing any data, and merges are bounded by
+// MaxMergeBytes, so there's never any compaction debt.
+func (p *compactionPickerFIFO) estimatedCompactionDebt(l0ExtraSize uint64) uint64 {
+	return 0
+}
+
+func (p *compactionPickerFIFO) pickAuto(env compactionEnv) *pickedCompaction {
+	if len(env.inProgressCompactions) > 0 {
+		return nil
+	}
+	if pc := p.pickDrop(); pc != nil {
+		return pc
+	}
+	return p.pickMerge(env)
+}
+
+// pickDrop returns a delete-only compaction of the oldest tables that exceed
+// the size limit or, failing that, of the oldest tables that exceed the TTL.
+func (p *compactionPickerFIFO) pickDrop() *pickedCompaction {
+	var n int
+	reason := fifoDeleteReasonSize
+	if p.config.MaxTableFilesSize > 0 {
+		for size := p.size; n < len(p.files) && size > p.config.MaxTableFilesSize; n++ {
+			size -= p.files[n].meta.Size
+		}
+	}
+	if n == 0 {
+		reason = fifoDeleteReasonTTL
+		for n < len(p.files) && p.expired(p.files[n].meta) {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+
+	var byLevel [numLevels][]*fileMetadata
+	for _, f := range p.files[:n] {
+		if f.meta.IsCompacting() {
+			return nil
+		}
+		byLevel[f.level] = append(byLevel[f.level], f.meta)
+	}
+	pc := &pickedCompaction{
+		cmp:          p.opts.Comparer.Compare,
+		version:      p.vers,
+		baseLevel:    p.getBaseLevel(),
+		kind:         compactionKindDeleteOnly,
+		deleteReason: reason,
+		score:        1,
+	}
+	iters := make([]manifest.LevelIterator, 0, numLevels)
+	for level, files := range byLevel {
+		if len(files) == 0 {
+			continue
+		}
+		cl := compactionLevel{level: level}
+		if level == 0 {
+			cl.files = manifest.NewLevelSliceSeqSorted(files)
+		} else {
+			cl.files = manifest.NewLevelSliceKeySorted(pc.cmp, files)
+		}
+		pc.inputs = append(pc.inputs, cl)
+		iters = append(iters, cl.files.Iter())
+	}
+	pc.startLevel = &pc.inputs[0]
+	pc.outputLevel = &pc.inputs[len(pc.inputs)-1]
+	pc.smallest, pc.largest = manifest.KeyRange(pc.cmp, iters...)
+	return pc
+}
+
+// pickMerge returns an intra-L0 compaction of the oldest run of at least
+// MinMergeWidth adjacent L0 tables whose total size is at most MaxMergeBytes.
+func (p *compactionPickerFIFO) pickMerge(env compactionEnv) *pickedCompaction {
+	if p.config.MinMergeWidth < 0 {
+		return nil
+	}
+	minWidth, maxBytes := p.config.minMergeWidth(), p.config.maxMergeBytes(p.opts)
+	var l0 []*fileMetadata
+	iter := p.vers.Levels[0].Iter()
+	for f := iter.First(); f != nil; f = iter.Next() {
+		l0 = append(l0, f)
+	}
+
+	mergeable := func(f *fileMetadata) bool {
+		return !f.IsCompacting() && f.Size <= maxBytes && f.LargestSeqNum < env.earliestUnflushedSeqNum
+	}
+	for first := 0; first+minWidth <= len(l0); first++ {
+		if !mergeable(l0[first]) {
+			continue
+		}
+		last, size := first, l0[first].Size
+		for last+1 < len(l0) && mergeable(l0[last+1]) && size+l0[last+1].Size <= maxBytes {
+			last++
+			size += l0[last].Size
+		}
+		if last+1-first < minWidth {
+			continue
+		}
+		files := l0[first : last+1]
+		if !p.sequential(l0, files) {
+			continue
+		}
+		return p.newMergeCompaction(files)
+	}
+	return nil
+}
+
+// sequential returns true if no other L0 table that overlaps the given tables
+// in key space has sequence numbers within theirs, so that the tables can be
+// replaced by a single table containing all their keys.
+func (p *compactionPickerFIFO) sequential(l0, files []*fileMetadata) bool {
+	cmp := p.opts.Comparer.Compare
+	smallestSeqNum, largestSeqNum := files[0].SmallestSeqNum, files[0].LargestSeqNum
+	for _, f := range files[1:] {
+		smallestSeqNum = min(smallestSeqNum, f.SmallestSeqNum)
+		largestSeqNum = max(largestSeqNum, f.LargestSeqNum)
+	}
+	slice := manifest.NewLevelSliceSeqSorted(files)
+	smallest, largest := manifest.KeyRange(cmp, slice.Iter())
+	for _, f := range l0 {
+		if slices.Contains(files, f) {
+			continue
+		}
+		if f.LargestSeqNum < smallestSeqNum || f.SmallestSeqNum > largestSeqNum {
+			continue
+		}
+		if f.Overlaps(cmp, smallest.UserKey, largest.UserKey, largest.IsExclusiveSentinel()) {
+			return false
+		}
+	}
+	return true
+}
+
+// newMergeCompaction returns an intra-L0 compaction of the given tables.
+func (p *compactionPickerFIFO) newMergeCompaction(files []*fileMetadata) *pickedCompaction {
+	pc := newPickedCompaction(p.opts, p.vers, 0, 0, p.getBaseLevel())
+	pc.score = 1
+	pc.maxOutputFileSize = max(pc.maxOutputFileSize, p.config.maxMergeBytes(p.opts))
+	pc.startLevel.files = manifest.NewLevelSliceSeqSorted(files)
+	pc.smallest, pc.largest = manifest.KeyRange(pc.cmp, pc.startLevel.files.Iter())
+	pc.startLevel.l0SublevelInfo = generateSublevelInfo(pc.cmp, pc.startLevel.files)
+	for _, f := range files {
+		pc.outputCreationTime = max(pc.outputCreationTime, f.CreationTime)
+	}
+	return pc
+}
+
+// pickElisionOnlyCompaction implements the compactionPicker interface. Tables
+// are dropped whole rather than compacted into the bottommost level, so there
+// are no elision-only compactions.
+func (p *compactionPickerFIFO) pickElisionOnlyCompaction(env compactionEnv) *pickedCompaction {
+	return nil
+}
+
+func (p *compactionPickerFIFO) pickRewriteCompaction(env compactionEnv) *pickedCompaction {
+	return p.leveled.pickRewriteCompaction(env)
+}
+
+// pickReadTriggeredCompaction implements the compactionPicker interface.
+// Read-triggered compactions move tables into lower levels, so they are never
+// picked.
+func (p *compactionPickerFIFO) pickReadTriggeredCompaction(env compactionEnv) *pickedCompaction {
+	return nil
+}
+
+func (p *compactionPickerFIFO) forceBaseLevel1() {
+	p.leveled.forceBaseLevel1()
+}
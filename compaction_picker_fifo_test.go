@@ -0,0 +1,287 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/rand"
+)
+
+func TestFIFOCompactionPicker(t *testing.T) {
+	opts := (&Options{}).EnsureDefaults()
+	now := time.Unix(1000000, 0)
+
+	type file struct {
+		level int
+		// smallestSeqNum defaults to seqNum, the table's largest sequence
+		// number.
+		smallestSeqNum uint64
+		seqNum         uint64
+		size           uint64
+		age            time.Duration
+		smallest       string
+		largest        string
+	}
+	makeVersion := func(files []file) *version {
+		var levels [numLevels][]*fileMetadata
+		for i, f := range files {
+			if f.smallestSeqNum == 0 {
+				f.smallestSeqNum = f.seqNum
+			}
+			m := (&fileMetadata{
+				FileNum:        base.FileNum(i + 1),
+				SmallestSeqNum: f.smallestSeqNum,
+				LargestSeqNum:  f.seqNum,
+				Size:           f.size,
+				CreationTime:   now.Add(-f.age).Unix(),
+			}).ExtendPointKeyBounds(opts.Comparer.Compare,
+				base.MakeInternalKey([]byte(f.smallest), f.smallestSeqNum, InternalKeyKindSet),
+				base.MakeInternalKey([]byte(f.largest), f.seqNum, InternalKeyKindSet))
+			m.InitPhysicalBacking()
+			levels[f.level] = append(levels[f.level], m)
+		}
+		return newVersion(opts, levels)
+	}
+
+	testCases := []struct {
+		name   string
+		picker FIFOCompactionPicker
+		files  []file
+		// kind, reason and fileNums describe the expected compaction. An empty
+		// kind means no compaction is picked.
+		kind     string
+		reason   string
+		fileNums []base.FileNum
+	}{
+		{
+			name:   "under limits",
+			picker: FIFOCompactionPicker{MaxTableFilesSize: 100, TTL: time.Hour},
+			files: []file{
+				{seqNum: 1, size: 40, smallest: "a", largest: "b"},
+				{seqNum: 2, size: 40, smallest: "c", largest: "d"},
+			},
+		},
+		{
+			name:   "size",
+			picker: FIFOCompactionPicker{MaxTableFilesSize: 100},
+			files: []file{
+				{seqNum: 1, size: 40, smallest: "a", largest: "b"},
+				{seqNum: 2, size: 40, smallest: "c", largest: "d"},
+				{seqNum: 3, size: 40, smallest: "e", largest: "f"},
+				{seqNum: 4, size: 40, smallest: "g", largest: "h"},
+			},
+			kind:     "delete-only",
+			reason:   "fifo-size",
+			fileNums: []base.FileNum{1, 2},
+		},
+		{
+			name:   "size across levels",
+			picker: FIFOCompactionPicker{MaxTableFilesSize: 100},
+			files: []file{
+				{level: 6, seqNum: 1, size: 40, smallest: "a", largest: "b"},
+				{level: 6, seqNum: 5, size: 40, smallest: "x", largest: "y"},
+				{seqNum: 2, size: 40, smallest: "c", largest: "d"},
+			},
+			kind:     "delete-only",
+			reason:   "fifo-size",
+			fileNums: []base.FileNum{1},
+		},
+		{
+			name:   "ttl",
+			picker: FIFOCompactionPicker{MaxTableFilesSize: 1000, TTL: time.Hour},
+			files: []file{
+				{seqNum: 1, size: 40, age: 3 * time.Hour, smallest: "a", largest: "b"},
+				{seqNum: 2, size: 40, age: 2 * time.Hour, smallest: "c", largest: "d"},
+				{seqNum: 3, size: 40, age: time.Minute, smallest: "e", largest: "f"},
+			},
+			kind:     "delete-only",
+			reason:   "fifo-ttl",
+			fileNums: []base.FileNum{1, 2},
+		},
+		{
+			name:   "merge",
+			picker: FIFOCompactionPicker{MaxMergeBytes: 100},
+			files: []file{
+				{seqNum: 1, size: 200, smallest: "a", largest: "b"},
+				{seqNum: 2, size: 20, smallest: "c", largest: "d"},
+				{seqNum: 3, size: 20, smallest: "e", largest: "f"},
+				{seqNum: 4, size: 20, smallest: "g", largest: "h"},
+				{seqNum: 5, size: 20, smallest: "i", largest: "j"},
+				{seqNum: 6, size: 30, smallest: "k", largest: "l"},
+			},
+			kind:     "default",
+			fileNums: []base.FileNum{2, 3, 4, 5},
+		},
+		{
+			name:   "merge too narrow",
+			picker: FIFOCompactionPicker{MaxMergeBytes: 100},
+			files: []file{
+				{seqNum: 1, size: 20, smallest: "a", largest: "b"},
+				{seqNum: 2, size: 20, smallest: "c", largest: "d"},
+				{seqNum: 3, size: 200, smallest: "e", largest: "f"},
+				{seqNum: 4, size: 20, smallest: "g", largest: "h"},
+			},
+		},
+		{
+			name:   "merge disabled",
+			picker: FIFOCompactionPicker{MinMergeWidth: -1},
+			files: []file{
+				{seqNum: 1, size: 20, smallest: "a", largest: "b"},
+				{seqNum: 2, size: 20, smallest: "c", largest: "d"},
+				{seqNum: 3, size: 20, smallest: "e", largest: "f"},
+				{seqNum: 4, size: 20, smallest: "g", largest: "h"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := makeVersion(tc.files)
+			p := tc.picker.newPicker(v, opts, nil).(*compactionPickerFIFO)
+			p.now = func() time.Time { return now }
+			pc := p.pickAuto(compactionEnv{earliestUnflushedSeqNum: base.InternalKeySeqNumMax})
+			if tc.kind == "" {
+				require.Nil(t, pc)
+				return
+			}
+			require.NotNil(t, pc)
+			require.Equal(t, tc.kind, pc.kind.String())
+			require.Equal(t, tc.reason, pc.deleteReason)
+			var fileNums []base.FileNum
+			for _, in := range pc.inputs {
+				iter := in.files.Iter()
+				for f := iter.First(); f != nil; f = iter.Next() {
+					fileNums = append(fileNums, f.FileNum)
+				}
+			}
+			require.ElementsMatch(t, tc.fileNums, fileNums)
+			if tc.kind == "default" {
+				require.Equal(t, 0, pc.startLevel.level)
+				require.Equal(t, 0, pc.outputLevel.level)
+			}
+
+			// No compaction is picked while another one is in progress.
+			require.Nil(t, p.pickAuto(compactionEnv{inProgressCompactions: []compactionInfo{{}}}))
+		})
+	}
+
+	// Tables can't be merged if an overlapping table has sequence numbers
+	// between theirs.
+	v := makeVersion([]file{
+		{seqNum: 2, size: 20, smallest: "a", largest: "b"},
+		{seqNum: 3, size: 20, smallest: "c", largest: "d"},
+		{smallestSeqNum: 1, seqNum: 4, size: 20, smallest: "b", largest: "w"},
+		{seqNum: 5, size: 20, smallest: "x", largest: "y"},
+		{seqNum: 6, size: 20, smallest: "x", largest: "y"},
+	})
+	p := FIFOCompactionPicker{MinMergeWidth: 2, MaxMergeBytes: 100}.newPicker(v, opts, nil).(*compactionPickerFIFO)
+	var l0 []*fileMetadata
+	iter := v.Levels[0].Iter()
+	for f := iter.First(); f != nil; f = iter.Next() {
+		l0 = append(l0, f)
+	}
+	require.False(t, p.sequential(l0, l0[:2]))
+	require.True(t, p.sequential(l0, l0[3:]))
+
+	var parsed Options
+	picker := FIFOCompactionPicker{MaxTableFilesSize: 1 << 30, TTL: 24 * time.Hour, MinMergeWidth: 8}
+	require.NoError(t, parsed.Parse(fmt.Sprintf("[Options]\n  compaction_picker=%s\n", picker), nil))
+	require.Equal(t, picker, parsed.CompactionPicker)
+}
+
+func TestFIFOCompaction(t *testing.T) {
+	var mu sync.Mutex
+	reasons := map[string]int{}
+
+	run := func(picker FIFOCompactionPicker) *DB {
+		d, err := Open("", &Options{
+			FS:               vfs.NewMem(),
+			CompactionPicker: picker,
+			DebugCheck:       DebugCheckLevels,
+			EventListener: &EventListener{
+				TableDeleted: func(info TableDeleteInfo) {
+					mu.Lock()
+					defer mu.Unlock()
+					reasons[info.Reason]++
+				},
+			},
+		})
+		require.NoError(t, err)
+		return d
+	}
+	wait := func(d *DB) {
+		d.mu.Lock()
+		for d.mu.compact.compactingCount > 0 {
+			d.mu.compact.cond.Wait()
+		}
+		d.mu.Unlock()
+	}
+
+	// Size-based retention: the newest keys survive and the oldest ones are
+	// dropped, with flushed tables merged along the way.
+	d := run(FIFOCompactionPicker{MaxTableFilesSize: 128 << 10, MaxMergeBytes: 32 << 10})
+	const numKeys = 5000
+	key := func(i int) []byte { return []byte(fmt.Sprintf("%08d", i)) }
+	rng := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
+	value := make([]byte, 100)
+	for i := 0; i < numKeys; i++ {
+		// Random values don't compress, so the tables add up to more than the
+		// size limit.
+		rng.Read(value)
+		require.NoError(t, d.Set(key(i), value, nil))
+		if i%20 == 19 {
+			require.NoError(t, d.Flush())
+		}
+	}
+	wait(d)
+
+	m := d.Metrics()
+	require.Equal(t, "fifo(131072, 0s, 0, 32768)", m.Compact.Strategy)
+	require.LessOrEqual(t, m.Total().Size, int64(128<<10))
+	for level := 1; level < numLevels; level++ {
+		require.Zero(t, m.Levels[level].NumFiles)
+	}
+	require.Greater(t, m.Compact.DefaultCount, int64(0))
+	mu.Lock()
+	require.Greater(t, reasons["fifo-size"], 0)
+	require.Zero(t, reasons["fifo-ttl"])
+	mu.Unlock()
+
+	_, closer, err := d.Get(key(numKeys - 1))
+	require.NoError(t, err)
+	require.NoError(t, closer.Close())
+	_, _, err = d.Get(key(0))
+	require.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, d.Close())
+
+	// Age-based retention: with a tiny TTL, every table is dropped by the next
+	// compaction that is scheduled.
+	d = run(FIFOCompactionPicker{TTL: time.Nanosecond, MinMergeWidth: -1})
+	for i := 0; i < 3; i++ {
+		require.NoError(t, d.Set(key(i), value, nil))
+		require.NoError(t, d.Flush())
+		wait(d)
+	}
+	mu.Lock()
+	require.Greater(t, reasons["fifo-ttl"], 0)
+	mu.Unlock()
+	require.NoError(t, d.Close())
+
+	// Tables expire while the DB is idle.
+	d = run(FIFOCompactionPicker{TTL: time.Second, MinMergeWidth: -1})
+	require.NoError(t, d.Set(key(0), value, nil))
+	require.NoError(t, d.Flush())
+	require.Equal(t, int64(1), d.Metrics().Total().NumFiles)
+	require.Eventually(t, func() bool {
+		return d.Metrics().Total().NumFiles == 0
+	}, 10*time.Second, 50*time.Millisecond)
+	require.NoError(t, d.Close())
+}
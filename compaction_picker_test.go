@@ -1607,3 +1607,142 @@ func checkTableBoundary(a, b *fileMetadata, cmp base.Compare) (ok bool) {
 	c := cmp(a.LargestPointKey.UserKey, b.SmallestPointKey.UserKey)
 	return c < 0 || (c == 0 && a.LargestPointKey.IsExclusiveSentinel())
 }
+
+func TestCompactionPickerPeriodic(t *testing.T) {
+	opts := (&Options{PeriodicCompactionInterval: time.Hour}).EnsureDefaults()
+	now := time.Unix(1000000, 0)
+
+	type file struct {
+		level    int
+		age      time.Duration
+		smallest string
+		largest  string
+	}
+	var lastPeriodic time.Time
+	pick := func(files []file, inProgress ...compactionInfo) *pickedCompaction {
+		var levels [numLevels][]*fileMetadata
+		for i, f := range files {
+			m := (&fileMetadata{
+				FileNum:        base.FileNum(i + 1),
+				SmallestSeqNum: uint64(i + 1),
+				LargestSeqNum:  uint64(i + 1),
+				Size:           1,
+			}).ExtendPointKeyBounds(opts.Comparer.Compare,
+				base.MakeInternalKey([]byte(f.smallest), uint64(i+1), InternalKeyKindSet),
+				base.MakeInternalKey([]byte(f.largest), uint64(i+1), InternalKeyKindSet))
+			if f.age != 0 {
+				m.CreationTime = now.Add(-f.age).Unix()
+			}
+			m.InitPhysicalBacking()
+			levels[f.level] = append(levels[f.level], m)
+		}
+		p := LeveledCompactionPicker{}.newPicker(newVersion(opts, levels), opts, nil).(*compactionPickerByScore)
+		p.now = func() time.Time { return now }
+		return p.pickPeriodicCompaction(compactionEnv{
+			earliestSnapshotSeqNum: base.InternalKeySeqNumMax,
+			inProgressCompactions:  inProgress,
+			lastPeriodicCompaction: lastPeriodic,
+		})
+	}
+	fileNums := func(pc *pickedCompaction) string {
+		var buf strings.Builder
+		for _, cl := range pc.inputs {
+			fmt.Fprintf(&buf, "L%d:", cl.level)
+			iter := cl.files.Iter()
+			for f := iter.First(); f != nil; f = iter.Next() {
+				fmt.Fprintf(&buf, " %s", f.FileNum)
+			}
+			buf.WriteString(" ")
+		}
+		return strings.TrimSpace(buf.String())
+	}
+
+	// No table is old enough, or its age is unknown.
+	require.Nil(t, pick([]file{
+		{level: 6, age: time.Minute, smallest: "a", largest: "b"},
+		{level: 6, smallest: "c", largest: "d"},
+	}))
+
+	// The oldest table of the bottommost level is rewritten in place.
+	pc := pick([]file{
+		{level: 6, age: 2 * time.Hour, smallest: "a", largest: "b"},
+		{level: 6, age: 3 * time.Hour, smallest: "c", largest: "d"},
+	})
+	require.NotNil(t, pc)
+	require.Equal(t, compactionKindPeriodic, pc.kind)
+	require.Equal(t, "L6: 000002 L6:", fileNums(pc))
+
+	// A table in another level is compacted into the next level, along with
+	// the tables it overlaps.
+	pc = pick([]file{
+		{level: 5, age: 4 * time.Hour, smallest: "a", largest: "c"},
+		{level: 6, age: time.Minute, smallest: "b", largest: "d"},
+		{level: 6, age: 3 * time.Hour, smallest: "x", largest: "y"},
+	})
+	require.NotNil(t, pc)
+	require.Equal(t, compactionKindPeriodic, pc.kind)
+	require.Equal(t, "L5: 000001 L6: 000002", fileNums(pc))
+
+	// At most one periodic compaction runs at a time.
+	require.Nil(t, pick([]file{
+		{level: 6, age: 2 * time.Hour, smallest: "a", largest: "b"},
+	}, compactionInfo{kind: compactionKindPeriodic, outputLevel: 6}))
+
+	// Periodic compactions are spread across the interval: with two tables, one
+	// may start every half hour.
+	twoTables := []file{
+		{level: 6, age: 2 * time.Hour, smallest: "a", largest: "b"},
+		{level: 6, age: 2 * time.Hour, smallest: "c", largest: "d"},
+	}
+	lastPeriodic = now.Add(-29 * time.Minute)
+	require.Nil(t, pick(twoTables))
+	lastPeriodic = now.Add(-30 * time.Minute)
+	require.NotNil(t, pick(twoTables))
+	lastPeriodic = time.Time{}
+
+	// Periodic compactions are disabled by default.
+	opts.PeriodicCompactionInterval = 0
+	require.Nil(t, pick([]file{
+		{level: 6, age: 2 * time.Hour, smallest: "a", largest: "b"},
+	}))
+
+	var parsed Options
+	require.NoError(t, parsed.Parse("[Options]\n  periodic_compaction_interval=1h0m0s\n", nil))
+	require.Equal(t, time.Hour, parsed.PeriodicCompactionInterval)
+}
+
+func TestPeriodicCompaction(t *testing.T) {
+	opts := &Options{
+		FS:                         vfs.NewMem(),
+		DebugCheck:                 DebugCheckLevels,
+		PeriodicCompactionInterval: time.Nanosecond,
+	}
+	opts.private.disableElisionOnlyCompactions = true
+	d, err := Open("", opts)
+	require.NoError(t, err)
+
+	// Leave the deleted keys and their tombstones in L6 by compacting them
+	// while a snapshot is open.
+	key := func(i int) []byte { return []byte(fmt.Sprintf("%04d", i)) }
+	for i := 0; i < 100; i++ {
+		require.NoError(t, d.Set(key(i), []byte("value"), nil))
+	}
+	require.NoError(t, d.Compact(key(0), key(100), false /* parallelize */))
+	snap := d.NewSnapshot()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, d.Delete(key(i), nil))
+	}
+	require.NoError(t, d.Compact(key(0), key(100), false /* parallelize */))
+	require.NoError(t, snap.Close())
+	require.NotZero(t, d.Metrics().Levels[numLevels-1].NumFiles)
+
+	// Creation times have a resolution of a second, so the tables become
+	// eligible for a periodic compaction within a second. Although the DB is
+	// idle, the compaction is scheduled, and it drops the tombstones and the
+	// keys they delete.
+	require.Eventually(t, func() bool {
+		m := d.Metrics()
+		return m.Compact.PeriodicCount > 0 && m.Levels[numLevels-1].NumFiles == 0
+	}, 10*time.Second, 50*time.Millisecond)
+	require.NoError(t, d.Close())
+}
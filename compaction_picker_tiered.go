@@ -0,0 +1,314 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/pebble/internal/manifest"
+)
+
+// TieredCompactionPicker picks compactions using size-tiered (universal)
+// compaction, which reduces write amplification at the cost of space and
+// read amplification. It is suited to write-heavy workloads such as the
+// ingestion of time-series data.
+//
+// All of L0 and each non-empty level below it form a sorted run, ordered from
+// the newest (L0) to the oldest (the bottommost non-empty level). Flushed data
+// accumulates in L0 until it reaches L0CompactionThreshold sublevels or
+// L0CompactionFileThreshold files. A compaction then merges a sequence of
+// adjacent sorted runs in their entirety, writing the result to the
+// bottommost level above the next older run, so that each level is rewritten
+// only when the runs newer than it have grown comparable in size. The
+// compaction is picked by the first of these triggers that applies:
+//
+//   - Space amplification: if the sorted runs other than the oldest add up to
+//     at least MaxSizeAmplificationPercent percent of the oldest, all runs are
+//     merged into the bottommost level.
+//   - Size ratio: starting from each run in turn, from newest to oldest, runs
+//     are accumulated as long as the next older run's size is at most
+//     SizeRatio percent larger than the total size of the runs accumulated so
+//     far. The first sequence of at least MinMergeWidth runs is merged.
+//   - Otherwise L0 is written out as a new sorted run, merging it with the
+//     newest run below it only if there is no empty level to hold it.
+//
+// At most one automatic compaction runs at a time, since each compaction
+// rewrites entire levels.
+type TieredCompactionPicker struct {
+	// SizeRatio is the percentage by which the size of a sorted run may exceed
+	// the total size of the newer runs it is merged with. The default value
+	// is 1.
+	SizeRatio int
+	// MinMergeWidth is the minimum number of sorted runs merged by a
+	// compaction triggered by the size ratio. The default value is 2.
+	MinMergeWidth int
+	// MaxMergeWidth is the maximum number of sorted runs merged by a
+	// compaction triggered by the size ratio. The default value doesn't
+	// limit the number of runs.
+	MaxMergeWidth int
+	// MaxSizeAmplificationPercent is the size of the sorted runs other than
+	// the oldest, as a percentage of the size of the oldest run, at which all
+	// runs are merged. The default value is 200.
+	MaxSizeAmplificationPercent int
+}
+
+var _ CompactionPicker = TieredCompactionPicker{}
+
+func (t TieredCompactionPicker) sizeRatio() uint64 {
+	if t.SizeRatio <= 0 {
+		return 1
+	}
+	return uint64(t.SizeRatio)
+}
+
+func (t TieredCompactionPicker) minMergeWidth() int {
+	if t.MinMergeWidth < 2 {
+		return 2
+	}
+	return t.MinMergeWidth
+}
+
+func (t TieredCompactionPicker) maxMergeWidth() int {
+	if t.MaxMergeWidth <= 0 {
+		return numLevels
+	}
+	return t.MaxMergeWidth
+}
+
+func (t TieredCompactionPicker) maxSizeAmplificationPercent() uint64 {
+	if t.MaxSizeAmplificationPercent <= 0 {
+		return 200
+	}
+	return uint64(t.MaxSizeAmplificationPercent)
+}
+
+func (t TieredCompactionPicker) newPicker(
+	v *version, opts *Options, inProgressCompactions []compactionInfo,
+) compactionPicker {
+	p := &compactionPickerTiered{
+		config:  t,
+		opts:    opts,
+		vers:    v,
+		leveled: LeveledCompactionPicker{}.newPicker(v, opts, inProgressCompactions),
+	}
+	p.baseLevel = numLevels - 1
+	for level := numLevels - 1; level >= 0; level-- {
+		size := v.Levels[level].Size()
+		if size == 0 && (level > 0 || v.Levels[0].Empty()) {
+			continue
+		}
+		p.runs = append(p.runs, sortedRun{level: level, size: size})
+		if level > 0 {
+			p.baseLevel = level
+		}
+	}
+	// Order the runs from newest to oldest.
+	for i, j := 0, len(p.runs)-1; i < j; i, j = i+1, j-1 {
+		p.runs[i], p.runs[j] = p.runs[j], p.runs[i]
+	}
+	return p
+}
+
+// String implements fmt.Stringer.
+func (t TieredCompactionPicker) String() string {
+	return fmt.Sprintf("tiered(%d, %d, %d, %d)",
+		t.SizeRatio, t.MinMergeWidth, t.MaxMergeWidth, t.MaxSizeAmplificationPercent)
+}
+
+// sortedRun is a sorted run of the tiered compaction picker: either all of L0
+// or a non-empty level below it.
+type sortedRun struct {
+	level int
+	size  uint64
+}
+
+// compactionPickerTiered implements the compactionPicker interface for
+// TieredCompactionPicker. Like compactionPickerByScore, it is associated with
+// a single version.
+type compactionPickerTiered struct {
+	config TieredCompactionPicker
+	opts   *Options
+	vers   *version
+	// leveled picks elision-only and rewrite compactions, which rewrite
+	// individual files in place and are independent of the strategy.
+	leveled compactionPicker
+	// baseLevel is the newest non-empty level below L0, or the bottommost
+	// level if all such levels are empty.
+	baseLevel int
+	// runs holds the sorted runs from newest to oldest.
+	runs []sortedRun
+}
+
+var _ compactionPicker = &compactionPickerTiered{}
+
+// l0Score returns the score of L0. A compaction is picked once it reaches 1.
+func (p *compactionPickerTiered) l0Score() float64 {
+	sublevels := float64(len(p.vers.L0SublevelFiles)) / float64(p.opts.L0CompactionThreshold)
+	files := float64(p.vers.Levels[0].Len()) / float64(p.opts.L0CompactionFileThreshold)
+	return max(sublevels, files)
+}
+
+// spaceAmplification returns the size of the sorted runs other than the
+// oldest as a percentage of the size of the oldest run.
+func (p *compactionPickerTiered) spaceAmplification() uint64 {
+	if len(p.runs) < 2 {
+		return 0
+	}
+	var newer uint64
+	for _, r := range p.runs[:len(p.runs)-1] {
+		newer += r.size
+	}
+	oldest := p.runs[len(p.runs)-1].size
+	if oldest == 0 {
+		return 0
+	}
+	return newer * 100 / oldest
+}
+
+func (p *compactionPickerTiered) getScores(inProgress []compactionInfo) [numLevels]float64 {
+	var scores [numLevels]float64
+	scores[0] = p.l0Score()
+	if len(p.runs) >= 2 {
+		scores[p.runs[len(p.runs)-1].level] = float64(p.spaceAmplification()) /
+			float64(p.config.maxSizeAmplificationPercent())
+	}
+	return scores
+}
+
+func (p *compactionPickerTiered) getBaseLevel() int {
+	return p.baseLevel
+}
+
+// estimatedCompactionDebt estimates the number of bytes which need to be
+// compacted before the LSM tree becomes stable. Once L0 needs compacting,
+// this is the size of the sorted runs that the next compaction will merge.
+func (p *compactionPickerTiered) estimatedCompactionDebt(l0ExtraSize uint64) uint64 {
+	if l0ExtraSize == 0 && p.l0Score() < compactionScoreThreshold {
+		return 0
+	}
+	first, last, ok := p.pickRuns()
+	if !ok {
+		return l0ExtraSize
+	}
+	debt := l0ExtraSize
+	for _, r := range p.runs[first : last+1] {
+		debt += r.size
+	}
+	return debt
+}
+
+// pickRuns returns the indexes of the newest and oldest sorted runs that the
+// next compaction should merge, assuming L0 needs compacting.
+func (p *compactionPickerTiered) pickRuns() (first, last int, ok bool) {
+	if len(p.runs) == 0 || p.runs[0].level != 0 {
+		return 0, 0, false
+	}
+	if p.spaceAmplification() >= p.config.maxSizeAmplificationPercent() {
+		return 0, len(p.runs) - 1, true
+	}
+	minWidth, maxWidth := p.config.minMergeWidth(), p.config.maxMergeWidth()
+	for first = range p.runs {
+		size := p.runs[first].size
+		last = first
+		for last+1 < len(p.runs) && last+2-first <= maxWidth {
+			next := p.runs[last+1].size
+			if next*100 > size*(100+p.config.sizeRatio()) {
+				break
+			}
+			size += next
+			last++
+		}
+		if last+1-first >= minWidth {
+			return first, last, true
+		}
+	}
+	// Write L0 out as a new sorted run, merging it with the newest run below it
+	// if there's no empty level in between.
+	if len(p.runs) > 1 && p.runs[1].level == 1 {
+		return 0, 1, true
+	}
+	return 0, 0, true
+}
+
+func (p *compactionPickerTiered) pickAuto(env compactionEnv) *pickedCompaction {
+	// Tiered compactions rewrite entire levels, and the output level may be an
+	// empty level that another compaction would also target. Avoid conflicts
+	// by running one compaction at a time.
+	if len(env.inProgressCompactions) > 0 {
+		return nil
+	}
+	score := p.l0Score()
+	if score < compactionScoreThreshold {
+		return nil
+	}
+	first, last, ok := p.pickRuns()
+	if !ok {
+		return nil
+	}
+	return p.newPickedCompaction(first, last, score)
+}
+
+// newPickedCompaction returns a compaction that merges the sorted runs with
+// indexes [first, last], or nil if any of their files are already being
+// compacted. The output is written to the bottommost level above the next
+// older run.
+func (p *compactionPickerTiered) newPickedCompaction(
+	first, last int, score float64,
+) *pickedCompaction {
+	outputLevel := numLevels - 1
+	if last+1 < len(p.runs) {
+		outputLevel = p.runs[last+1].level - 1
+	}
+	levels := make([]int, 0, last+2-first)
+	for _, r := range p.runs[first : last+1] {
+		levels = append(levels, r.level)
+	}
+	if levels[len(levels)-1] != outputLevel {
+		levels = append(levels, outputLevel)
+	}
+
+	pc := newPickedCompaction(p.opts, p.vers, levels[0], outputLevel, p.baseLevel)
+	pc.score = score
+	pc.inputs = make([]compactionLevel, len(levels))
+	iters := make([]manifest.LevelIterator, len(levels))
+	for i, level := range levels {
+		pc.inputs[i] = compactionLevel{level: level, files: p.vers.Levels[level].Slice()}
+		if anyTablesCompacting(pc.inputs[i].files) {
+			return nil
+		}
+		iters[i] = pc.inputs[i].files.Iter()
+	}
+	pc.startLevel = &pc.inputs[0]
+	pc.outputLevel = &pc.inputs[len(pc.inputs)-1]
+	pc.extraLevels = nil
+	for i := 1; i < len(pc.inputs)-1; i++ {
+		pc.extraLevels = append(pc.extraLevels, &pc.inputs[i])
+	}
+	pc.smallest, pc.largest = manifest.KeyRange(pc.cmp, iters...)
+	if pc.startLevel.level == 0 {
+		pc.startLevel.l0SublevelInfo = generateSublevelInfo(pc.cmp, pc.startLevel.files)
+	}
+	return pc
+}
+
+func (p *compactionPickerTiered) pickElisionOnlyCompaction(env compactionEnv) *pickedCompaction {
+	return p.leveled.pickElisionOnlyCompaction(env)
+}
+
+func (p *compactionPickerTiered) pickRewriteCompaction(env compactionEnv) *pickedCompaction {
+	return p.leveled.pickRewriteCompaction(env)
+}
+
+// pickReadTriggeredCompaction implements the compactionPicker interface.
+// Read-triggered compactions move individual files into the next level, which
+// would fragment the sorted runs, so they are never picked.
+func (p *compactionPickerTiered) pickReadTriggeredCompaction(env compactionEnv) *pickedCompaction {
+	return nil
+}
+
+func (p *compactionPickerTiered) forceBaseLevel1() {
+	p.baseLevel = 1
+	p.leveled.forceBaseLevel1()
+}
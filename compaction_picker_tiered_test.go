@@ -0,0 +1,159 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/rand"
+)
+
+func TestTieredCompactionPicker(t *testing.T) {
+	opts := (&Options{L0CompactionThreshold: 4}).EnsureDefaults()
+	picker := TieredCompactionPicker{}
+
+	// makeVersion creates a version with the given number of overlapping L0
+	// files of size 1, and a single file of the given size in other levels.
+	makeVersion := func(l0Files int, sizes map[int]uint64) *version {
+		var files [numLevels][]*fileMetadata
+		newFile := func(fileNum base.FileNum, seqNum uint64, size uint64) *fileMetadata {
+			m := (&fileMetadata{
+				FileNum:        fileNum,
+				SmallestSeqNum: seqNum,
+				LargestSeqNum:  seqNum,
+				Size:           size,
+			}).ExtendPointKeyBounds(opts.Comparer.Compare,
+				base.MakeInternalKey([]byte("a"), seqNum, InternalKeyKindSet),
+				base.MakeInternalKey([]byte("z"), seqNum, InternalKeyKindSet))
+			m.InitPhysicalBacking()
+			return m
+		}
+		for level, size := range sizes {
+			files[level] = append(files[level], newFile(base.FileNum(level), uint64(numLevels-level), size))
+		}
+		for i := 0; i < l0Files; i++ {
+			files[0] = append(files[0], newFile(base.FileNum(100+i), uint64(100+i), 1))
+		}
+		return newVersion(opts, files)
+	}
+
+	testCases := []struct {
+		l0Files int
+		sizes   map[int]uint64
+		// inputs lists the levels of the compaction's inputs, ending with the
+		// output level. A nil value means no compaction is picked.
+		inputs []int
+	}{
+		// L0 hasn't reached the threshold.
+		{l0Files: 3, sizes: map[int]uint64{6: 1000}},
+		// L0 becomes the first sorted run.
+		{l0Files: 4, inputs: []int{0, 6}},
+		// L0 is written out as a new run above the existing ones.
+		{l0Files: 4, sizes: map[int]uint64{6: 1000}, inputs: []int{0, 5}},
+		{l0Files: 4, sizes: map[int]uint64{3: 1000, 6: 100000}, inputs: []int{0, 2}},
+		// Size ratio: runs of similar sizes are merged.
+		{l0Files: 4, sizes: map[int]uint64{5: 4, 6: 1000}, inputs: []int{0, 5}},
+		{l0Files: 4, sizes: map[int]uint64{4: 3, 5: 50, 6: 1000}, inputs: []int{0, 4}},
+		{l0Files: 4, sizes: map[int]uint64{3: 3, 4: 6, 6: 1000}, inputs: []int{0, 3, 4, 5}},
+		{l0Files: 4, sizes: map[int]uint64{1: 1000, 6: 1000}, inputs: []int{1, 6}},
+		{l0Files: 4, sizes: map[int]uint64{3: 1000, 6: 1000}, inputs: []int{3, 6}},
+		// There's no free level for L0, so it's merged with L1.
+		{l0Files: 4, sizes: map[int]uint64{1: 100, 6: 1000}, inputs: []int{0, 1, 5}},
+		{l0Files: 4, sizes: map[int]uint64{1: 100, 2: 1000, 6: 100000}, inputs: []int{0, 1}},
+		// Space amplification: all runs are merged.
+		{l0Files: 4, sizes: map[int]uint64{3: 2500, 6: 1000}, inputs: []int{0, 3, 6}},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("L0=%d,%v", tc.l0Files, tc.sizes), func(t *testing.T) {
+			v := makeVersion(tc.l0Files, tc.sizes)
+			p := picker.newPicker(v, opts, nil)
+			pc := p.pickAuto(compactionEnv{})
+			if tc.inputs == nil {
+				require.Nil(t, pc)
+				require.Zero(t, p.estimatedCompactionDebt(0))
+				return
+			}
+			require.NotNil(t, pc)
+			var inputs []int
+			var size uint64
+			for _, in := range pc.inputs {
+				inputs = append(inputs, in.level)
+				size += in.files.SizeSum()
+			}
+			require.Equal(t, tc.inputs, inputs)
+			require.Equal(t, size, p.estimatedCompactionDebt(0))
+			require.Equal(t, tc.inputs[0], pc.startLevel.level)
+			require.Equal(t, tc.inputs[len(tc.inputs)-1], pc.outputLevel.level)
+			require.Len(t, pc.extraLevels, len(tc.inputs)-2)
+
+			// No compaction is picked while another one is in progress.
+			require.Nil(t, p.pickAuto(compactionEnv{inProgressCompactions: []compactionInfo{{}}}))
+		})
+	}
+}
+
+func TestTieredCompaction(t *testing.T) {
+	seed := uint64(time.Now().UnixNano())
+	t.Logf("seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+
+	run := func(picker CompactionPicker) *Metrics {
+		d, err := Open("", &Options{
+			FS:                    vfs.NewMem(),
+			CompactionPicker:      picker,
+			DebugCheck:            DebugCheckLevels,
+			L0CompactionThreshold: 2,
+			LBaseMaxBytes:         64 << 10,
+		})
+		require.NoError(t, err)
+		defer func() { require.NoError(t, d.Close()) }()
+
+		const numKeys = 2000
+		expected := make([][]byte, numKeys)
+		for i := 0; i < 40; i++ {
+			b := d.NewBatch()
+			for j := 0; j < 500; j++ {
+				k := rng.Intn(numKeys)
+				expected[k] = []byte(fmt.Sprintf("%d-%d-%d", k, i, j))
+				require.NoError(t, b.Set([]byte(fmt.Sprintf("%05d", k)), expected[k], nil))
+			}
+			require.NoError(t, b.Commit(nil))
+			require.NoError(t, d.Flush())
+		}
+		d.mu.Lock()
+		for d.mu.compact.compactingCount > 0 {
+			d.mu.compact.cond.Wait()
+		}
+		d.mu.Unlock()
+
+		for k, v := range expected {
+			got, closer, err := d.Get([]byte(fmt.Sprintf("%05d", k)))
+			if v == nil {
+				require.ErrorIs(t, err, ErrNotFound)
+				continue
+			}
+			require.NoError(t, err)
+			require.Equal(t, string(v), string(got))
+			require.NoError(t, closer.Close())
+		}
+		return d.Metrics()
+	}
+
+	tiered := run(TieredCompactionPicker{})
+	require.Equal(t, "tiered(0, 0, 0, 0)", tiered.Compact.Strategy)
+	require.Greater(t, tiered.Compact.DefaultCount, int64(0))
+	require.Greater(t, tiered.Compact.MultiLevelCount, int64(0))
+	require.Less(t, int(tiered.Levels[0].Sublevels), 2)
+
+	leveled := run(nil)
+	require.Equal(t, "leveled", leveled.Compact.Strategy)
+	tieredTotal, leveledTotal := tiered.Total(), leveled.Total()
+	t.Logf("write amp: tiered %.2f, leveled %.2f", tieredTotal.WriteAmp(), leveledTotal.WriteAmp())
+}
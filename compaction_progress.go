@@ -0,0 +1,121 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"cmp"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/pebble/internal/base"
+)
+
+// compactionProgressBytes is the number of bytes of input that a compaction
+// reads between updates of its progress tracker.
+const compactionProgressBytes = 1 << 20 // 1 MB
+
+// compactionProgress tracks the progress of a running compaction, for
+// EventListener.CompactionProgress and DB.InProgressCompactions. It's updated
+// by the goroutines of the compaction and its subcompactions, and read
+// concurrently.
+type compactionProgress struct {
+	// jobID is the ID of the compaction job, or zero if the compaction hasn't
+	// started. It's set with DB.mu held, before the compaction runs.
+	jobID int
+	// bytesRead and bytesWritten are the number of bytes that the compaction
+	// has read from its inputs, and written to its outputs.
+	bytesRead    atomic.Uint64
+	bytesWritten atomic.Uint64
+	// output is the base.DiskFileNum of the output table created last.
+	output atomic.Uint64
+	// lastReport is the time, in nanoseconds since the epoch, at which the
+	// progress was last reported to the event listener.
+	lastReport atomic.Int64
+}
+
+// progressTracker returns the progress tracker of the compaction, which is its
+// parent's if it's a subcompaction.
+func (c *compaction) progressTracker() *compactionProgress {
+	if c.parent != nil {
+		return &c.parent.progress
+	}
+	return &c.progress
+}
+
+// reportCompactionProgress adds the given number of bytes read to the progress
+// of the compaction c, and invokes EventListener.CompactionProgress if
+// Options.CompactionProgressInterval elapsed since the last report.
+func (d *DB) reportCompactionProgress(c *compaction, bytesRead uint64) {
+	p := c.progressTracker()
+	p.bytesRead.Add(bytesRead)
+	if c.flushing != nil {
+		return
+	}
+	root := c
+	if c.parent != nil {
+		root = c.parent
+	}
+	now := d.timeNow()
+	last := p.lastReport.Load()
+	lastTime := root.beganAt
+	if last != 0 {
+		lastTime = time.Unix(0, last)
+	}
+	if now.Sub(lastTime) < d.opts.CompactionProgressInterval {
+		return
+	}
+	// Of the subcompactions that race to report the progress, only one does.
+	if !p.lastReport.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	d.opts.EventListener.CompactionProgress(root.progressInfo(now))
+}
+
+// progressInfo returns the progress of the compaction as of now.
+func (c *compaction) progressInfo(now time.Time) CompactionProgressInfo {
+	info := c.makeInfo(c.progress.jobID)
+	p := CompactionProgressInfo{
+		JobID:         info.JobID,
+		Reason:        info.Reason,
+		Input:         info.Input,
+		OutputLevel:   info.Output.Level,
+		Elapsed:       now.Sub(c.beganAt),
+		BytesRead:     c.progress.bytesRead.Load(),
+		BytesWritten:  c.progress.bytesWritten.Load(),
+		CurrentOutput: base.DiskFileNum(c.progress.output.Load()),
+	}
+	for _, cl := range c.inputs {
+		p.InputBytes += cl.files.SizeSum()
+	}
+	if p.BytesRead < p.InputBytes {
+		p.BytesRemaining = p.InputBytes - p.BytesRead
+	}
+	if p.BytesRead > 0 {
+		p.EstimatedTimeRemaining = time.Duration(
+			float64(p.Elapsed) * float64(p.BytesRemaining) / float64(p.BytesRead))
+	}
+	return p
+}
+
+// InProgressCompactions returns the progress of the compactions that are
+// running, ordered by job ID. Flushes aren't included, and the compactions run
+// by a CompactionExecutor don't report the bytes they read or write.
+func (d *DB) InProgressCompactions() []CompactionProgressInfo {
+	now := d.timeNow()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var infos []CompactionProgressInfo
+	for c := range d.mu.compact.inProgress {
+		if c.flushing != nil || c.progress.jobID == 0 {
+			continue
+		}
+		infos = append(infos, c.progressInfo(now))
+	}
+	slices.SortFunc(infos, func(a, b CompactionProgressInfo) int {
+		return cmp.Compare(a.JobID, b.JobID)
+	})
+	return infos
+}
@@ -0,0 +1,144 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/pebble/internal/rate"
+	"github.com/cockroachdb/pebble/objstorage"
+)
+
+// Parameters of the auto-tuning of a CompactionRateLimiter. The limit is
+// adjusted at most once per compactionRateLimiterTuneInterval, by a factor of
+// compactionRateLimiterTuneFactor.
+const (
+	compactionRateLimiterTuneInterval = time.Second
+	compactionRateLimiterTuneFactor   = 1.25
+)
+
+// compactionRateLimiterMaxWait is the longest that a compaction write waits
+// for the limiter before checking whether the compaction was cancelled.
+const compactionRateLimiterMaxWait = 100 * time.Millisecond
+
+// CompactionRateLimiter limits the rate at which compactions write sstables,
+// leaving disk bandwidth for foreground reads and writes. Flushes are never
+// delayed, since delaying them would stall writes, but the bytes they write
+// count against the limit and delay subsequent compaction writes.
+//
+// An auto-tuned CompactionRateLimiter adjusts the limit within a range
+// according to the estimated compaction debt of the DB (see
+// Metrics.Compact.EstimatedDebt): the limit is raised while there is debt and
+// it isn't shrinking, so that compactions keep up with the incoming writes, and
+// lowered while there is no debt.
+//
+// A CompactionRateLimiter may be shared by multiple DBs to limit their combined
+// write rate, but auto-tuning then reacts to the debt of each DB in turn.
+type CompactionRateLimiter struct {
+	limiter *rate.Limiter
+
+	autoTune bool
+	// minRate and maxRate bound the limit, in bytes per second, when the limit
+	// is auto-tuned.
+	minRate, maxRate float64
+
+	mu struct {
+		sync.Mutex
+		// lastTune is the time the limit was last considered for tuning.
+		lastTune time.Time
+		// lastDebt is the compaction debt at lastTune.
+		lastDebt uint64
+	}
+}
+
+// NewCompactionRateLimiter returns a CompactionRateLimiter that limits
+// compactions to writing bytesPerSecond bytes per second.
+func NewCompactionRateLimiter(bytesPerSecond int64) *CompactionRateLimiter {
+	r := float64(max(bytesPerSecond, 1))
+	return &CompactionRateLimiter{
+		limiter: rate.NewLimiter(r, r),
+		minRate: r,
+		maxRate: r,
+	}
+}
+
+// NewAutoTunedCompactionRateLimiter returns a CompactionRateLimiter whose limit
+// is auto-tuned between minBytesPerSecond and maxBytesPerSecond bytes per
+// second. The limit starts at minBytesPerSecond.
+func NewAutoTunedCompactionRateLimiter(
+	minBytesPerSecond, maxBytesPerSecond int64,
+) *CompactionRateLimiter {
+	minRate := float64(max(minBytesPerSecond, 1))
+	maxRate := max(float64(maxBytesPerSecond), minRate)
+	return &CompactionRateLimiter{
+		limiter:  rate.NewLimiter(minRate, maxRate),
+		autoTune: true,
+		minRate:  minRate,
+		maxRate:  maxRate,
+	}
+}
+
+// BytesPerSecond returns the current limit, in bytes per second.
+func (l *CompactionRateLimiter) BytesPerSecond() int64 {
+	return int64(l.limiter.Rate())
+}
+
+// maybeAutoTune adjusts the limit according to the compaction debt, returned
+// by debtFn, if the limiter is auto-tuned and the limit hasn't been considered
+// for tuning in the last compactionRateLimiterTuneInterval.
+func (l *CompactionRateLimiter) maybeAutoTune(now time.Time, debtFn func() uint64) {
+	if !l.autoTune {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elapsed := now.Sub(l.mu.lastTune)
+	if elapsed < compactionRateLimiterTuneInterval {
+		return
+	}
+	debt := debtFn()
+	r := l.limiter.Rate()
+	switch {
+	case debt > 0 && debt >= l.mu.lastDebt:
+		r *= compactionRateLimiterTuneFactor
+	case debt == 0:
+		// The DB may have been idle for many intervals without being tuned, in
+		// which case the limit is lowered by as many steps, up to the point
+		// where it reaches its minimum anyway.
+		for steps := elapsed / compactionRateLimiterTuneInterval; steps > 0 && r > l.minRate; steps-- {
+			r /= compactionRateLimiterTuneFactor
+		}
+	}
+	r = min(max(r, l.minRate), l.maxRate)
+	if r != l.limiter.Rate() {
+		l.limiter.SetRate(r)
+	}
+	l.mu.lastTune = now
+	l.mu.lastDebt = debt
+}
+
+// rateLimitedWritable is an objstorage.Writable wrapper that charges the
+// bytes written to a CompactionRateLimiter. Compaction writes wait for the
+// limiter, while flush writes proceed immediately.
+type rateLimitedWritable struct {
+	objstorage.Writable
+
+	limiter *CompactionRateLimiter
+	flush   bool
+	// cancelled returns true if the compaction was cancelled, in which case a
+	// write returns ErrCancelledCompaction instead of waiting for the limiter.
+	cancelled func() bool
+}
+
+// Write is part of the objstorage.Writable interface.
+func (w *rateLimitedWritable) Write(p []byte) error {
+	if w.flush {
+		w.limiter.limiter.Remove(float64(len(p)))
+	} else if !w.limiter.limiter.WaitCancellable(float64(len(p)), compactionRateLimiterMaxWait, w.cancelled) {
+		return ErrCancelledCompaction
+	}
+	return w.Writable.Write(p)
+}
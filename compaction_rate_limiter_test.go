@@ -0,0 +1,146 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble/internal/rate"
+	"github.com/cockroachdb/pebble/objstorage"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/rand"
+)
+
+func TestCompactionRateLimiterAutoTune(t *testing.T) {
+	l := NewAutoTunedCompactionRateLimiter(1000, 2000)
+	require.EqualValues(t, 1000, l.BytesPerSecond())
+
+	now := time.Unix(1000, 0)
+	tune := func(elapsed time.Duration, debt uint64) int64 {
+		now = now.Add(elapsed)
+		l.maybeAutoTune(now, func() uint64 { return debt })
+		return l.BytesPerSecond()
+	}
+	// The limit is raised while the debt isn't shrinking, up to the maximum.
+	require.EqualValues(t, 1250, tune(time.Second, 100))
+	require.EqualValues(t, 1562, tune(time.Second, 100))
+	// It's tuned at most once per interval.
+	require.EqualValues(t, 1562, tune(time.Millisecond, 200))
+	require.EqualValues(t, 1953, tune(time.Second, 200))
+	require.EqualValues(t, 2000, tune(time.Second, 300))
+	// It's left unchanged while the debt shrinks.
+	require.EqualValues(t, 2000, tune(time.Second, 100))
+	// It's lowered when there's no debt, by one step per interval.
+	require.EqualValues(t, 1600, tune(time.Second, 0))
+	require.EqualValues(t, 1024, tune(2*time.Second, 0))
+	require.EqualValues(t, 1000, tune(time.Hour, 0))
+
+	// A fixed limit is never tuned.
+	l = NewCompactionRateLimiter(1000)
+	l.maybeAutoTune(now.Add(time.Hour), func() uint64 { return 100 })
+	require.EqualValues(t, 1000, l.BytesPerSecond())
+}
+
+// fakeRateLimiterClock is the clock of a CompactionRateLimiter whose waits
+// advance the clock instead of sleeping, and are recorded.
+type fakeRateLimiterClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	waited time.Duration
+}
+
+func (c *fakeRateLimiterClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeRateLimiterClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.waited += d
+}
+
+func (c *fakeRateLimiterClock) Waited() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.waited
+}
+
+func (c *fakeRateLimiterClock) newLimiter(bytesPerSecond float64) *CompactionRateLimiter {
+	return &CompactionRateLimiter{
+		limiter: rate.NewLimiterWithCustomTime(bytesPerSecond, bytesPerSecond, c.Now, c.Sleep),
+		minRate: bytesPerSecond,
+		maxRate: bytesPerSecond,
+	}
+}
+
+func TestCompactionRateLimiter(t *testing.T) {
+	const bytesPerSecond = 1 << 20
+	clock := &fakeRateLimiterClock{now: time.Unix(1000, 0)}
+	d, err := Open("", &Options{
+		FS:                    vfs.NewMem(),
+		CompactionRateLimiter: clock.newLimiter(bytesPerSecond),
+		Levels:                []LevelOptions{{Compression: NoCompression}},
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	rng := rand.New(rand.NewSource(1))
+	value := make([]byte, 1<<10)
+	for j := 0; j < 2; j++ {
+		for i := 0; i < 512; i++ {
+			rng.Read(value)
+			require.NoError(t, d.Set([]byte{byte(i >> 8), byte(i)}, value, nil))
+		}
+		require.NoError(t, d.Flush())
+	}
+	// The flushes aren't delayed, but the 1MB they write empties the bucket,
+	// and the compactions must wait for it to refill as they write at least
+	// another 512KB.
+	require.NoError(t, d.Compact([]byte{0}, []byte{0xff}, false /* parallelize */))
+	require.GreaterOrEqual(t, clock.Waited(), 500*time.Millisecond)
+	m := d.Metrics()
+	require.Greater(t, m.Levels[6].NumFiles, int64(0))
+}
+
+// countingWritable is an objstorage.Writable that counts the bytes written to
+// it.
+type countingWritable struct {
+	objstorage.Writable
+	n int
+}
+
+func (w *countingWritable) Write(p []byte) error {
+	w.n += len(p)
+	return nil
+}
+
+func TestRateLimitedWritableCancel(t *testing.T) {
+	clock := &fakeRateLimiterClock{now: time.Unix(1000, 0)}
+	var cancelled bool
+	inner := &countingWritable{}
+	w := &rateLimitedWritable{
+		Writable:  inner,
+		limiter:   clock.newLimiter(100),
+		cancelled: func() bool { return cancelled },
+	}
+	// The first write empties the bucket, and the second waits for it to
+	// refill, checking for cancellation at least every
+	// compactionRateLimiterMaxWait.
+	require.NoError(t, w.Write(make([]byte, 100)))
+	require.NoError(t, w.Write(make([]byte, 100)))
+	require.Equal(t, time.Second, clock.Waited())
+	require.Equal(t, 200, inner.n)
+
+	cancelled = true
+	require.ErrorIs(t, w.Write(make([]byte, 100)), ErrCancelledCompaction)
+	require.Equal(t, time.Second, clock.Waited())
+	require.Equal(t, 200, inner.n)
+}
@@ -3002,6 +3002,73 @@ func TestCompactionOutputSplitters(t *testing.T) {
 		})
 }
 
+func TestCompactionOutputSplitterOption(t *testing.T) {
+	// Partition the keyspace by the first byte of the key.
+	partitionLimit := func(userKey []byte) []byte {
+		if len(userKey) == 0 || userKey[0] == 0xff {
+			return nil
+		}
+		return []byte{userKey[0] + 1}
+	}
+	mem := vfs.NewMem()
+	opts := (&Options{
+		FS:                       mem,
+		CompactionOutputSplitter: partitionLimit,
+	}).WithFSDefaults()
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	checkFiles := func() (numFiles int) {
+		t.Helper()
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for level, files := range d.mu.versions.currentVersion().Levels {
+			iter := files.Iter()
+			for f := iter.First(); f != nil; f = iter.Next() {
+				numFiles++
+				require.False(t, spansOutputPartitions(d.opts, f), "L%d: %s", level, f)
+			}
+		}
+		return numFiles
+	}
+
+	// Flushes split their output at partition boundaries, including range
+	// tombstones that span several partitions.
+	for _, k := range []string{"a1", "a2", "b1", "c1", "c2", "e1"} {
+		require.NoError(t, d.Set([]byte(k), nil, nil))
+	}
+	require.NoError(t, d.DeleteRange([]byte("c5"), []byte("f"), nil))
+	require.NoError(t, d.Flush())
+	require.Equal(t, 5, checkFiles())
+
+	// An ingested file that spans partitions is split when compacted. It
+	// overlaps g5 in L6, so it's ingested into a higher level.
+	require.NoError(t, d.Set([]byte("g5"), nil, nil))
+	require.NoError(t, d.Compact([]byte("a"), []byte("z"), false))
+	require.Equal(t, 4, checkFiles())
+	f, err := mem.Create("ext")
+	require.NoError(t, err)
+	w := sstable.NewWriter(objstorageprovider.NewFileWritable(f), sstable.WriterOptions{
+		TableFormat: d.FormatMajorVersion().MaxTableFormat(),
+	})
+	for _, k := range []string{"g1", "h1", "h2"} {
+		require.NoError(t, w.Set([]byte(k), nil))
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, d.Ingest([]string{"ext"}))
+	require.NoError(t, d.Compact([]byte("a"), []byte("z"), false))
+	require.Equal(t, 5, checkFiles())
+
+	iter, _ := d.NewIter(nil)
+	var keys []string
+	for valid := iter.First(); valid; valid = iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, []string{"a1", "a2", "b1", "c1", "c2", "g1", "g5", "h1", "h2"}, keys)
+}
+
 func TestCompactFlushQueuedMemTableAndFlushMetrics(t *testing.T) {
 	t.Run("", func(t *testing.T) {
 		// Verify that manual compaction forces a flush of a queued memtable.
@@ -3821,3 +3888,176 @@ func TestCompactionErrorStats(t *testing.T) {
 	d.mu.Unlock()
 	require.NoError(t, d.Close())
 }
+
+func TestCompactRange(t *testing.T) {
+	key := func(i int) []byte { return []byte(fmt.Sprintf("%04d", i)) }
+	open := func(t *testing.T, compression Compression) *DB {
+		opts := &Options{
+			FS:                 vfs.NewMem(),
+			DebugCheck:         DebugCheckLevels,
+			FormatMajorVersion: FormatNewest,
+		}
+		opts.Levels = make([]LevelOptions, numLevels)
+		for i := range opts.Levels {
+			opts.Levels[i].Compression = compression
+		}
+		opts.DisableAutomaticCompactions = true
+		d, err := Open("", opts)
+		require.NoError(t, err)
+		d.mu.Lock()
+		d.mu.versions.dynamicBaseLevel = false
+		d.mu.versions.picker.forceBaseLevel1()
+		d.mu.Unlock()
+		// Write three overlapping tables to L0.
+		for round := 0; round < 3; round++ {
+			for i := 0; i < 100; i++ {
+				require.NoError(t, d.Set(key(i), []byte(fmt.Sprint(round)), nil))
+			}
+			require.NoError(t, d.Flush())
+		}
+		return d
+	}
+	levelFiles := func(d *DB) []int64 {
+		m := d.Metrics()
+		var files []int64
+		for l := range m.Levels {
+			files = append(files, m.Levels[l].NumFiles)
+		}
+		return files
+	}
+
+	t.Run("target-level", func(t *testing.T) {
+		d := open(t, SnappyCompression)
+		defer d.Close()
+		require.NoError(t, d.CompactRange(context.Background(), key(0), key(100), CompactRangeOptions{
+			TargetLevel: 3,
+		}))
+		require.Equal(t, []int64{0, 0, 0, 1, 0, 0, 0}, levelFiles(d))
+
+		require.NoError(t, d.CompactRange(context.Background(), key(0), key(100), CompactRangeOptions{
+			TargetLevel: numLevels - 1,
+		}))
+		require.Equal(t, []int64{0, 0, 0, 0, 0, 0, 1}, levelFiles(d))
+	})
+
+	t.Run("invalid-target-level", func(t *testing.T) {
+		d := open(t, SnappyCompression)
+		defer d.Close()
+		for _, level := range []int{-1, numLevels} {
+			require.Error(t, d.CompactRange(context.Background(), key(0), key(100), CompactRangeOptions{
+				TargetLevel: level,
+			}))
+		}
+	})
+
+	t.Run("bottommost", func(t *testing.T) {
+		d := open(t, NoCompression)
+		defer d.Close()
+		compactRange := func(policy BottommostPolicy) int64 {
+			before := d.Metrics().Compact.RewriteCount
+			require.NoError(t, d.CompactRange(context.Background(), key(0), key(100), CompactRangeOptions{
+				TargetLevel:      numLevels - 1,
+				BottommostPolicy: policy,
+			}))
+			require.Equal(t, []int64{0, 0, 0, 0, 0, 0, 1}, levelFiles(d))
+			return d.Metrics().Compact.RewriteCount - before
+		}
+		require.Equal(t, int64(0), compactRange(BottommostSkip))
+		require.Equal(t, int64(1), compactRange(BottommostForce))
+		// The table's settings match the options, so it isn't stale.
+		require.Equal(t, int64(0), compactRange(BottommostRewriteStale))
+
+		// Changing the compression of the level makes the table stale. It's
+		// rewritten once, with the new compression.
+		d.opts.Levels[numLevels-1].Compression = SnappyCompression
+		require.Equal(t, int64(1), compactRange(BottommostRewriteStale))
+		require.Equal(t, int64(0), compactRange(BottommostRewriteStale))
+	})
+
+	t.Run("bottommost-default-target-level", func(t *testing.T) {
+		d := open(t, NoCompression)
+		defer d.Close()
+		compactRange := func() int64 {
+			before := d.Metrics().Compact.RewriteCount
+			require.NoError(t, d.CompactRange(context.Background(), key(0), key(100), CompactRangeOptions{
+				BottommostPolicy: BottommostForce,
+			}))
+			return d.Metrics().Compact.RewriteCount - before
+		}
+		// The keys are compacted from L0 into the base level, whose table is
+		// rewritten.
+		require.Equal(t, int64(1), compactRange())
+		require.Equal(t, []int64{0, 1, 0, 0, 0, 0, 0}, levelFiles(d))
+		// The keys are compacted from L1 down through the empty L2 into L3, whose
+		// table is rewritten.
+		require.Equal(t, int64(1), compactRange())
+		require.Equal(t, []int64{0, 0, 0, 1, 0, 0, 0}, levelFiles(d))
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		d := open(t, SnappyCompression)
+		defer d.Close()
+		var progress []CompactRangeProgress
+		require.NoError(t, d.CompactRange(context.Background(), key(0), key(100), CompactRangeOptions{
+			TargetLevel:      numLevels - 1,
+			BottommostPolicy: BottommostForce,
+			OnProgress: func(p CompactRangeProgress) {
+				progress = append(progress, p)
+			},
+		}))
+		require.NotEmpty(t, progress)
+		for i := 1; i < len(progress); i++ {
+			require.Greater(t, progress[i].BytesProcessed, progress[i-1].BytesProcessed)
+			require.GreaterOrEqual(t, progress[i].Level, progress[i-1].Level)
+		}
+		last := progress[len(progress)-1]
+		require.Equal(t, numLevels-1, last.Level)
+		require.Equal(t, uint64(0), last.BytesRemaining)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		d := open(t, SnappyCompression)
+		defer d.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := d.CompactRange(ctx, key(0), key(100), CompactRangeOptions{})
+		require.ErrorIs(t, err, context.Canceled)
+
+		// Cancel the manual compaction after the compaction out of L0.
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+		err = d.CompactRange(ctx, key(0), key(100), CompactRangeOptions{
+			TargetLevel: numLevels - 1,
+			OnProgress:  func(CompactRangeProgress) { cancel() },
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		files := levelFiles(d)
+		require.Equal(t, int64(0), files[0])
+		require.Equal(t, int64(0), files[numLevels-1])
+
+		// The DB remains usable, and a later CompactRange completes.
+		require.NoError(t, d.CompactRange(context.Background(), key(0), key(100), CompactRangeOptions{
+			TargetLevel: numLevels - 1,
+		}))
+		require.Equal(t, []int64{0, 0, 0, 0, 0, 0, 1}, levelFiles(d))
+	})
+
+	t.Run("exclusive", func(t *testing.T) {
+		d := open(t, SnappyCompression)
+		defer d.Close()
+		d.mu.Lock()
+		d.opts.DisableAutomaticCompactions = false
+		d.mu.Unlock()
+		require.NoError(t, d.CompactRange(context.Background(), key(0), key(100), CompactRangeOptions{
+			Exclusive: true,
+			OnProgress: func(CompactRangeProgress) {
+				d.mu.Lock()
+				defer d.mu.Unlock()
+				require.Equal(t, 1, d.mu.compact.exclusiveManualCount)
+			},
+		}))
+		d.mu.Lock()
+		require.Equal(t, 0, d.mu.compact.exclusiveManualCount)
+		d.mu.Unlock()
+	})
+}
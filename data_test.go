@@ -6,6 +6,7 @@ package pebble
 
 import (
 	"bytes"
+	"context"
 	crand "crypto/rand"
 	"fmt"
 	"io"
@@ -672,7 +673,7 @@ func runCompactCmd(td *datadriven.TestData, d *DB) error {
 		if err != nil {
 			return err
 		}
-		return d.manualCompact(iStart.UserKey, iEnd.UserKey, level, parallelize)
+		return d.manualCompact(context.Background(), iStart.UserKey, iEnd.UserKey, level, parallelize, nil)
 	}
 	return d.Compact([]byte(parts[0]), []byte(parts[1]), parallelize)
 }
@@ -321,6 +321,28 @@ type DB struct {
 
 	cleanupManager *cleanupManager
 
+	// removeEvictionPressureListener removes the listener that reports the
+	// eviction pressure of the block cache to the EventListener. It is nil if
+	// Options.BlockCacheEvictionPressure.Threshold is zero.
+	removeEvictionPressureListener func()
+
+	// cacheWarmup holds the state of the goroutines that persist the keys of
+	// the blocks resident in the block cache and that warm up the block cache
+	// after Open. See Options.BlockCacheWarmup.
+	cacheWarmup struct {
+		// stopCh is closed to stop the goroutines. It is nil if block cache
+		// warm-up is disabled.
+		stopCh   chan struct{}
+		stopOnce sync.Once
+		// wg is used to wait for the goroutines to exit on Close.
+		wg              sync.WaitGroup
+		blocks          atomic.Int64
+		blocksProcessed atomic.Int64
+		blocksLoaded    atomic.Int64
+		bytesLoaded     atomic.Int64
+		inProgress      atomic.Bool
+	}
+
 	// During an iterator close, we may asynchronously schedule read compactions.
 	// We want to wait for those goroutines to finish, before closing the DB.
 	// compactionShedulers.Wait() should not be called while the DB.mu is held.
@@ -421,12 +443,23 @@ type DB struct {
 			flushing bool
 			// The number of ongoing compactions.
 			compactingCount int
+			// The number of compaction slots held by the subcompactions of
+			// ongoing compactions, which are included in compactingCount. See
+			// DB.pickSubcompactions.
+			subcompactionSlots int
 			// The list of deletion hints, suggesting ranges for delete-only
 			// compactions.
 			deletionHints []deleteCompactionHint
 			// The list of manual compactions. The next manual compaction to perform
 			// is at the start of the list. New entries are added to the end.
 			manual []*manualCompaction
+			// exclusiveManualCount is the number of manual compactions in
+			// progress that prevent automatic compactions from starting. See
+			// CompactRangeOptions.Exclusive.
+			exclusiveManualCount int
+			// lastPeriodic is the time at which the last periodic compaction
+			// was started. See periodicCompactionSpacing.
+			lastPeriodic time.Time
 			// downloads is the list of suggested download tasks. The next download to
 			// perform is at the start of the list. New entries are added to the end.
 			downloads []*downloadSpan
@@ -1592,6 +1625,8 @@ func (d *DB) NewEventuallyFileOnlySnapshot(keyRanges []KeyRange) *EventuallyFile
 // or to call Close concurrently with any other DB method. It is not valid
 // to call any of a DB's methods after the DB has been closed.
 func (d *DB) Close() error {
+	d.stopBlockCacheWarmup()
+
 	// Lock the commit pipeline for the duration of Close. This prevents a race
 	// with makeRoomForWrite. Rotating the WAL in makeRoomForWrite requires
 	// dropping d.mu several times for I/O. If Close only holds d.mu, an
@@ -1620,6 +1655,9 @@ func (d *DB) Close() error {
 	d.closed.Store(errors.WithStack(ErrClosed))
 	close(d.closedCh)
 
+	if d.removeEvictionPressureListener != nil {
+		d.removeEvictionPressureListener()
+	}
 	defer d.opts.Cache.Unref()
 
 	for d.mu.compact.compactingCount > 0 || d.mu.compact.flushing {
@@ -1643,6 +1681,9 @@ func (d *DB) Close() error {
 	} else if d.mu.log.LogWriter != nil {
 		panic("pebble: log-writer should be nil in read-only mode")
 	}
+	// Release the cache's secondary tier namespace before the lock, so that a
+	// DB reopened in the same directory can claim it.
+	d.opts.Cache.ReleaseTierNamespace(d.cacheID)
 	err = firstError(err, d.fileLock.Close())
 
 	// Note that versionSet.close() only closes the MANIFEST. The versions list
@@ -1731,6 +1772,74 @@ func (d *DB) Close() error {
 
 // Compact the specified range of keys in the database.
 func (d *DB) Compact(start, end []byte, parallelize bool) error {
+	return d.CompactRange(context.Background(), start, end, CompactRangeOptions{
+		Parallelize: parallelize,
+	})
+}
+
+// BottommostPolicy determines how DB.CompactRange treats the tables of the
+// last level that it compacts into.
+type BottommostPolicy int8
+
+const (
+	// BottommostSkip leaves the tables of the last level as they are: they're
+	// only rewritten by the compactions from the level above that they overlap.
+	BottommostSkip BottommostPolicy = iota
+	// BottommostRewriteStale also rewrites the tables of the last level that
+	// were written with a compression algorithm other than the level's, or
+	// with an older table format than the format major version of the DB
+	// allows.
+	BottommostRewriteStale
+	// BottommostForce also rewrites all of the tables of the last level.
+	BottommostForce
+)
+
+// CompactRangeOptions configures a manual compaction with DB.CompactRange.
+type CompactRangeOptions struct {
+	// TargetLevel is the last level that the manual compaction compacts into:
+	// the keys within the range are compacted level by level, starting with
+	// L0, and the levels below TargetLevel are left untouched. Keys in L0 are
+	// compacted into the base level even if it's below TargetLevel.
+	//
+	// The default value compacts the keys as far down as DB.Compact does: the
+	// keys of each level are compacted into the next, down to the deepest
+	// level that contained keys within the range, or into the base level if
+	// only L0 contained keys within the range.
+	TargetLevel int
+	// BottommostPolicy determines whether the tables within the range of the
+	// last level that the keys are compacted into are rewritten: TargetLevel
+	// if it's set, and otherwise the level that the keys end up in.
+	BottommostPolicy BottommostPolicy
+	// Exclusive, if true, prevents automatic compactions from starting while
+	// the manual compaction runs. Automatic compactions in progress aren't
+	// interrupted.
+	Exclusive bool
+	// Parallelize splits the compaction of each level into compactions of the
+	// disjoint key ranges of the level's tables, which may run concurrently.
+	Parallelize bool
+	// OnProgress, if set, is called after each of the compactions that make up
+	// the manual compaction completes.
+	OnProgress func(CompactRangeProgress)
+}
+
+// CompactRangeProgress describes the progress of DB.CompactRange.
+type CompactRangeProgress struct {
+	// Level is the start level of the compaction that completed.
+	Level int
+	// BytesProcessed is the size of the input tables of the compactions that
+	// have completed.
+	BytesProcessed uint64
+	// BytesRemaining estimates the size of the input tables of the compactions
+	// that remain. With BottommostRewriteStale, the stale tables of the last
+	// level are only accounted for once all the levels above are compacted.
+	BytesRemaining uint64
+}
+
+// CompactRange compacts the specified range of keys in the database, as
+// configured by opts. It returns when the compaction is complete, or with the
+// context's error if the context is cancelled first; the compactions that are
+// in progress are then cancelled, and waited for.
+func (d *DB) CompactRange(ctx context.Context, start, end []byte, opts CompactRangeOptions) error {
 	if err := d.closed.Load(); err != nil {
 		panic(err)
 	}
@@ -1741,8 +1850,24 @@ func (d *DB) Compact(start, end []byte, parallelize bool) error {
 		return errors.Errorf("Compact start %s is not less than end %s",
 			d.opts.Comparer.FormatKey(start), d.opts.Comparer.FormatKey(end))
 	}
+	if opts.TargetLevel < 0 || opts.TargetLevel >= numLevels {
+		return errors.Errorf("pebble: invalid target level %d", errors.Safe(opts.TargetLevel))
+	}
+	lastLevel := opts.TargetLevel
+	if lastLevel == 0 {
+		lastLevel = numLevels - 1
+	}
 
 	d.mu.Lock()
+	if opts.Exclusive {
+		d.mu.compact.exclusiveManualCount++
+		defer func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			d.mu.compact.exclusiveManualCount--
+			d.maybeScheduleCompaction()
+		}()
+	}
 	maxLevelWithFiles := 1
 	cur := d.mu.versions.currentVersion()
 	for level := 0; level < numLevels; level++ {
@@ -1796,14 +1921,55 @@ func (d *DB) Compact(start, end []byte, parallelize bool) error {
 		return err
 	}
 	if mem != nil {
-		<-mem.flushed
+		select {
+		case <-mem.flushed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 
-	for level := 0; level < maxLevelWithFiles; {
+	// Without a target level, the keys are compacted as far as the deepest
+	// level that contains keys within the range.
+	endLevel := lastLevel
+	if opts.TargetLevel == 0 {
+		endLevel = min(maxLevelWithFiles, lastLevel)
+	}
+	// BottommostPolicy applies to the level that the keys within the range are
+	// compacted into. Without a target level, that's the deepest level below
+	// L0 that contains keys within the range, which may be deeper than
+	// endLevel if a compaction was extended to more levels, or the base level
+	// if only L0 contains keys within the range.
+	bottommostLevel := func() int {
+		if opts.TargetLevel != 0 {
+			return endLevel
+		}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		baseLevel := d.mu.versions.picker.getBaseLevel()
+		cur := d.mu.versions.currentVersion()
+		for level := numLevels - 1; level > baseLevel; level-- {
+			if overlaps := cur.Overlaps(level, d.cmp, start, end, false); !overlaps.Empty() {
+				return level
+			}
+		}
+		return baseLevel
+	}
+	p := &compactRangeProgress{
+		fn:        opts.OnProgress,
+		start:     start,
+		end:       end,
+		endLevel:  endLevel,
+		lastLevel: bottommostLevel(),
+		force:     opts.BottommostPolicy == BottommostForce,
+	}
+	for level := 0; level < endLevel; {
+		p.level = level
 		for {
-			if err := d.manualCompact(
-				start, end, level, parallelize); err != nil {
-				if errors.Is(err, ErrCancelledCompaction) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := d.manualCompact(ctx, start, end, level, opts.Parallelize, p); err != nil {
+				if errors.Is(err, ErrCancelledCompaction) && ctx.Err() == nil {
 					continue
 				}
 				return err
@@ -1817,10 +1983,21 @@ func (d *DB) Compact(start, end []byte, parallelize bool) error {
 			break
 		}
 	}
+	if opts.BottommostPolicy != BottommostSkip {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// The compactions above may have moved the keys deeper than expected.
+		p.lastLevel = bottommostLevel()
+		p.level = p.lastLevel
+		return d.manualRewrite(ctx, start, end, p.lastLevel, opts.BottommostPolicy == BottommostRewriteStale, p)
+	}
 	return nil
 }
 
-func (d *DB) manualCompact(start, end []byte, level int, parallelize bool) error {
+func (d *DB) manualCompact(
+	ctx context.Context, start, end []byte, level int, parallelize bool, p *compactRangeProgress,
+) error {
 	d.mu.Lock()
 	curr := d.mu.versions.currentVersion()
 	files := curr.Overlaps(level, d.cmp, start, end, false)
@@ -1840,6 +2017,79 @@ func (d *DB) manualCompact(start, end []byte, level int, parallelize bool) error
 			end:   end,
 		})
 	}
+	return d.runManualCompactions(ctx, compactions, p)
+}
+
+// manualRewrite rewrites the tables of the given level that overlap [start,
+// end] in place, or only those whose settings are stale if staleOnly is set.
+// Each table is rewritten by its own compaction, along with the other tables
+// of its atomic compaction unit.
+func (d *DB) manualRewrite(
+	ctx context.Context, start, end []byte, level int, staleOnly bool, p *compactRangeProgress,
+) error {
+	d.mu.Lock()
+	curr := d.mu.versions.currentVersion()
+	curr.Ref()
+	writerOpts := d.opts.makeCompactionWriterOptions(level, d.FormatMajorVersion())
+	d.mu.Unlock()
+
+	var compactions []*manualCompaction
+	err := func() error {
+		defer curr.Unref()
+		files := curr.Overlaps(level, d.cmp, start, end, false)
+		iter := files.Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			if staleOnly {
+				if stale, err := d.tableSettingsStale(f, writerOpts); err != nil {
+					return err
+				} else if !stale {
+					continue
+				}
+			}
+			compactions = append(compactions, &manualCompaction{
+				level:   level,
+				done:    make(chan error, 1),
+				start:   f.Smallest.UserKey,
+				end:     f.Largest.UserKey,
+				rewrite: f,
+			})
+			p.rewriteBytes += f.Size
+		}
+		return nil
+	}()
+	if err != nil || len(compactions) == 0 {
+		return err
+	}
+
+	d.mu.Lock()
+	return d.runManualCompactions(ctx, compactions, p)
+}
+
+// tableSettingsStale returns true if the table was written with a compression
+// algorithm other than writerOpts', or with an older table format.
+func (d *DB) tableSettingsStale(f *fileMetadata, writerOpts sstable.WriterOptions) (bool, error) {
+	var stale bool
+	err := d.tableCache.withBackingReader(f, func(r *sstable.Reader) error {
+		format, err := r.TableFormat()
+		if err != nil {
+			return err
+		}
+		stale = format < writerOpts.TableFormat ||
+			r.Properties.CompressionName != writerOpts.Compression.String()
+		return nil
+	})
+	return stale, err
+}
+
+// runManualCompactions queues the manual compactions and waits for them to
+// complete. If the context is cancelled first, the manual compactions that
+// haven't started are dropped, and those that have are cancelled and waited
+// for.
+//
+// d.mu must be held when calling this, and is released.
+func (d *DB) runManualCompactions(
+	ctx context.Context, compactions []*manualCompaction, p *compactRangeProgress,
+) error {
 	d.mu.compact.manual = append(d.mu.compact.manual, compactions...)
 	d.maybeScheduleCompaction()
 	d.mu.Unlock()
@@ -1851,14 +2101,95 @@ func (d *DB) manualCompact(start, end []byte, level int, parallelize bool) error
 	// a value to the done channel. Since the channels are buffered, it is not
 	// necessary to read from each channel, and so we can exit early in the event
 	// of an error.
-	for _, compaction := range compactions {
-		if err := <-compaction.done; err != nil {
-			return err
+	for i, compaction := range compactions {
+		select {
+		case err := <-compaction.done:
+			if err != nil {
+				return err
+			}
+			p.completed(d, compaction)
+		case <-ctx.Done():
+			d.cancelManualCompactions(compactions[i:])
+			return ctx.Err()
 		}
 	}
 	return nil
 }
 
+// cancelManualCompactions drops the given manual compactions from the queue,
+// and cancels those that have started and waits for them to complete. The done
+// channels of the manual compactions must not have been read.
+func (d *DB) cancelManualCompactions(compactions []*manualCompaction) {
+	d.mu.Lock()
+	var started []*manualCompaction
+	for _, m := range compactions {
+		if m.c != nil {
+			m.c.setCancelled()
+			started = append(started, m)
+			continue
+		}
+		for i := range d.mu.compact.manual {
+			if d.mu.compact.manual[i] == m {
+				d.mu.compact.manual = append(d.mu.compact.manual[:i:i], d.mu.compact.manual[i+1:]...)
+				break
+			}
+		}
+	}
+	d.mu.Unlock()
+	for _, m := range started {
+		<-m.done
+	}
+}
+
+// compactRangeProgress tracks the progress of DB.CompactRange, to report it
+// to CompactRangeOptions.OnProgress.
+type compactRangeProgress struct {
+	fn         func(CompactRangeProgress)
+	start, end []byte
+	// level is the level being compacted, endLevel the level at which the
+	// level by level compaction stops, and lastLevel the level to which
+	// BottommostPolicy applies.
+	level, endLevel, lastLevel int
+	// force is set if the tables of lastLevel are rewritten.
+	force bool
+	// rewriteBytes is the size of the tables of lastLevel that are queued to
+	// be rewritten.
+	rewriteBytes uint64
+	processed    uint64
+}
+
+// completed records the completion of the manual compaction m, and reports
+// the progress.
+func (p *compactRangeProgress) completed(d *DB, m *manualCompaction) {
+	if p == nil || p.fn == nil || m.c == nil {
+		return
+	}
+	for _, cl := range m.c.inputs {
+		p.processed += cl.files.SizeSum()
+	}
+	if m.rewrite != nil {
+		p.rewriteBytes -= min(p.rewriteBytes, m.rewrite.Size)
+	}
+
+	remaining := p.rewriteBytes
+	d.mu.Lock()
+	curr := d.mu.versions.currentVersion()
+	for l := p.level; l < p.endLevel; l++ {
+		files := curr.Overlaps(l, d.cmp, p.start, p.end, false)
+		remaining += files.SizeSum()
+	}
+	if p.force && p.level < p.lastLevel {
+		files := curr.Overlaps(p.lastLevel, d.cmp, p.start, p.end, false)
+		remaining += files.SizeSum()
+	}
+	d.mu.Unlock()
+	p.fn(CompactRangeProgress{
+		Level:          m.level,
+		BytesProcessed: p.processed,
+		BytesRemaining: remaining,
+	})
+}
+
 // splitManualCompaction splits a manual compaction over [start,end] on level
 // such that the resulting compactions have no key overlap.
 func (d *DB) splitManualCompaction(
@@ -2069,9 +2400,13 @@ func (d *DB) Metrics() *Metrics {
 	*metrics = d.mu.versions.metrics
 	metrics.Compact.EstimatedDebt = d.mu.versions.picker.estimatedCompactionDebt(0)
 	metrics.Compact.InProgressBytes = d.mu.versions.atomicInProgressBytes.Load()
-	metrics.Compact.NumInProgress = int64(d.mu.compact.compactingCount)
+	metrics.Compact.NumInProgress = int64(d.mu.compact.compactingCount - d.mu.compact.subcompactionSlots)
 	metrics.Compact.MarkedFiles = vers.Stats.MarkedForCompaction
 	metrics.Compact.Duration = d.mu.compact.duration
+	metrics.Compact.Strategy = LeveledCompactionPicker{}.String()
+	if d.opts.CompactionPicker != nil {
+		metrics.Compact.Strategy = d.opts.CompactionPicker.String()
+	}
 	for c := range d.mu.compact.inProgress {
 		if c.kind != compactionKindFlush {
 			metrics.Compact.Duration += d.timeNow().Sub(c.beganAt)
@@ -2157,6 +2492,12 @@ func (d *DB) Metrics() *Metrics {
 	d.mu.Unlock()
 
 	metrics.BlockCache = d.opts.Cache.Metrics()
+	metrics.BlockCacheTier = d.opts.Cache.TierMetrics()
+	metrics.BlockCacheWarmup.Blocks = d.cacheWarmup.blocks.Load()
+	metrics.BlockCacheWarmup.BlocksProcessed = d.cacheWarmup.blocksProcessed.Load()
+	metrics.BlockCacheWarmup.BlocksLoaded = d.cacheWarmup.blocksLoaded.Load()
+	metrics.BlockCacheWarmup.BytesLoaded = d.cacheWarmup.bytesLoaded.Load()
+	metrics.BlockCacheWarmup.InProgress = d.cacheWarmup.inProgress.Load()
 	metrics.TableCache, metrics.Filter = d.tableCache.metrics()
 	metrics.TableIters = int64(d.tableCache.iterCount())
 	metrics.CategoryStats = d.tableCache.dbOpts.sstStatsCollector.GetStats()
@@ -2471,6 +2812,23 @@ func (d *DB) walPreallocateSize() int {
 	return int(size)
 }
 
+// walCompression returns the algorithm used to compress records written to a
+// new WAL. WAL records are only compressed once the database's format major
+// version supports compressed records.
+func (d *DB) walCompression() record.Compression {
+	if d.FormatMajorVersion() < FormatWALCompression {
+		return record.NoCompression
+	}
+	switch d.opts.WALCompression {
+	case SnappyCompression:
+		return record.SnappyCompression
+	case ZstdCompression:
+		return record.ZstdCompression
+	default:
+		return record.NoCompression
+	}
+}
+
 func (d *DB) newMemTable(logNum base.DiskFileNum, logSeqNum uint64) (*memTable, *flushableEntry) {
 	size := d.mu.mem.nextSize
 	if d.mu.mem.nextSize < d.opts.MemTableSize {
@@ -2831,6 +3189,7 @@ func (d *DB) recycleWAL() (newLogNum base.DiskFileNum, prevLogSize uint64) {
 		WALFsyncLatency:    d.mu.log.metrics.fsyncLatency,
 		WALMinSyncInterval: d.opts.WALMinSyncInterval,
 		QueueSemChan:       d.commit.logSyncQSem,
+		Compression:        d.walCompression(),
 	})
 	if d.mu.log.registerLogWriterForTesting != nil {
 		d.mu.log.registerLogWriterForTesting(d.mu.log.LogWriter)
@@ -2855,6 +3214,7 @@ func (d *DB) getInProgressCompactionInfoLocked(finishing *compaction) (rv []comp
 		if len(c.flushing) == 0 && (finishing == nil || c != finishing) {
 			info := compactionInfo{
 				versionEditApplied: c.versionEditApplied,
+				kind:               c.kind,
 				inputs:             c.inputs,
 				smallest:           c.smallest,
 				largest:            c.largest,
@@ -1963,3 +1963,41 @@ func BenchmarkRotateMemtables(b *testing.B) {
 		}
 	}
 }
+
+func TestDirectIO(t *testing.T) {
+	dir := t.TempDir()
+	opts := (&Options{DirectIO: true, FS: vfs.Default}).WithFSDefaults()
+	opts.Levels = []LevelOptions{{TargetFileSize: 64 << 10}}
+	opts.EnsureDefaults()
+	d, err := Open(dir, opts)
+	require.NoError(t, err)
+
+	const numKeys = 20000
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key%06d", i)) }
+	for i := 0; i < numKeys; i++ {
+		require.NoError(t, d.Set(key(i), bytes.Repeat([]byte{byte(i)}, 100), nil))
+		if i%5000 == 4999 {
+			require.NoError(t, d.Flush())
+		}
+	}
+	require.NoError(t, d.Compact(key(0), key(numKeys), false))
+	require.NoError(t, d.Close())
+
+	// Reopen the DB and check its contents.
+	d, err = Open(dir, opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+	iter, _ := d.NewIter(nil)
+	i := 0
+	for valid := iter.First(); valid; valid = iter.Next() {
+		require.Equal(t, key(i), iter.Key())
+		require.Equal(t, bytes.Repeat([]byte{byte(i)}, 100), iter.Value())
+		i++
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, numKeys, i)
+	v, closer, err := d.Get(key(1234))
+	require.NoError(t, err)
+	require.Equal(t, bytes.Repeat([]byte{byte(1234 % 256)}, 100), v)
+	require.NoError(t, closer.Close())
+}
@@ -0,0 +1,486 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/record"
+)
+
+// A dump is a logical copy of the keys of a DB, written by Snapshot.Dump and
+// read by DB.Load. It doesn't depend on the format of the DB's files, so it can
+// be loaded by a DB with a different format major version or Comparer.
+//
+// A dump is a sequence of records, written with the record package, which
+// checksums them. The first record is the header:
+//
+//	magic, version (uvarint), comparer name, merger name, flags (byte)
+//
+// The following records hold a sequence of entries, each of which is an entry
+// kind (byte) followed by its fields:
+//
+//	dumpKindSet:         key, value
+//	dumpKindMerge:       key, operand
+//	dumpKindRangeDelete: start, end
+//	dumpKindRangeKeySet: start, end, suffix, value
+//	dumpKindEnd:         sets, merges, range deletes, range key sets (uvarints)
+//
+// Byte string fields are prefixed with their length (uvarint). The end entry is
+// the last entry of the dump, and holds the number of entries of each kind, so
+// that a truncated dump is detected.
+const (
+	dumpMagic   = "pebble-dump"
+	dumpVersion = 1
+	// dumpRecordSize is the size above which a record of entries is written.
+	dumpRecordSize = 32 << 10 // 32 KB
+)
+
+// dumpFlagMergesResolved is set in the flags of a dump header if the merges of
+// the dump were resolved, in which case the dump holds no merge operands.
+const dumpFlagMergesResolved = 1 << 0
+
+// The kinds of the entries of a dump.
+const (
+	dumpKindSet byte = iota + 1
+	dumpKindMerge
+	dumpKindRangeDelete
+	dumpKindRangeKeySet
+	dumpKindEnd
+)
+
+// DumpOptions configures Snapshot.Dump.
+type DumpOptions struct {
+	// ResolveMerges resolves the merge operands of each key with the DB's
+	// Merger, so that the dump holds the resulting values instead of the
+	// operands. A dump with resolved merges may be loaded by a DB with a
+	// different Merger.
+	ResolveMerges bool
+}
+
+// DumpStats counts the entries of a dump.
+type DumpStats struct {
+	// Sets is the number of point keys with a base value.
+	Sets uint64
+	// Merges is the number of merge operands.
+	Merges uint64
+	// RangeDeletes is the number of range deletions.
+	RangeDeletes uint64
+	// RangeKeySets is the number of range keys.
+	RangeKeySets uint64
+}
+
+// Dump writes a dump of the keys visible to the snapshot to w. The dump holds
+// the point keys with their base value and the merge operands applied on top of
+// it, the range deletions and the range keys. Point keys deleted before the
+// snapshot was taken are omitted. The dump may be loaded with DB.Load.
+func (s *Snapshot) Dump(w io.Writer, opts DumpOptions) (DumpStats, error) {
+	if s.db == nil {
+		panic(ErrClosed)
+	}
+	d := s.db
+	dw := &dumpWriter{d: d, w: record.NewWriter(w), resolveMerges: opts.ResolveMerges}
+	var flags byte
+	if opts.ResolveMerges {
+		flags |= dumpFlagMergesResolved
+	}
+	header := append([]byte(nil), dumpMagic...)
+	header = binary.AppendUvarint(header, dumpVersion)
+	header = appendDumpBytes(header, []byte(d.opts.Comparer.Name))
+	header = appendDumpBytes(header, []byte(d.opts.Merger.Name))
+	header = append(header, flags)
+	if _, err := dw.w.WriteRecord(header); err != nil {
+		return DumpStats{}, err
+	}
+
+	// The point keys and range deletions are read with an internal iterator
+	// that exposes all the versions of each key, so that the merge operands
+	// are preserved.
+	scanOpts := &scanInternalOptions{
+		visitPointKey:       dw.visitPointKey,
+		visitRangeDel:       dw.visitRangeDel,
+		includeObsoleteKeys: true,
+		IterOptions:         IterOptions{KeyTypes: IterKeyTypePointsOnly},
+	}
+	ctx := context.Background()
+	iter, err := d.newInternalIter(ctx, snapshotIterOpts{seqNum: s.seqNum}, scanOpts)
+	if err != nil {
+		return DumpStats{}, err
+	}
+	err = scanInternalImpl(ctx, nil, nil, iter, scanOpts)
+	err = firstError(err, iter.close())
+	if err == nil {
+		err = dw.finishKey()
+	}
+	if err == nil {
+		err = dw.dumpRangeKeys(s)
+	}
+	if err != nil {
+		return DumpStats{}, err
+	}
+
+	dw.rec = append(dw.rec, dumpKindEnd)
+	dw.rec = binary.AppendUvarint(dw.rec, dw.stats.Sets)
+	dw.rec = binary.AppendUvarint(dw.rec, dw.stats.Merges)
+	dw.rec = binary.AppendUvarint(dw.rec, dw.stats.RangeDeletes)
+	dw.rec = binary.AppendUvarint(dw.rec, dw.stats.RangeKeySets)
+	if _, err := dw.w.WriteRecord(dw.rec); err != nil {
+		return DumpStats{}, err
+	}
+	if err := dw.w.Close(); err != nil {
+		return DumpStats{}, err
+	}
+	return dw.stats, nil
+}
+
+// dumpWriter writes the entries of Snapshot.Dump.
+type dumpWriter struct {
+	d             *DB
+	w             *record.Writer
+	resolveMerges bool
+	// rec is the record of entries being built.
+	rec   []byte
+	stats DumpStats
+
+	// key is the user key whose versions are being visited, from newest to
+	// oldest. Once a version that isn't a merge operand is visited, done is set
+	// and the older versions are ignored. The value of a visited set is the
+	// base value, and operands are the merge operands, from newest to oldest.
+	key      []byte
+	hasKey   bool
+	done     bool
+	base     []byte
+	hasBase  bool
+	operands [][]byte
+
+	// The range deletion fragment visited last, which deletes the versions of
+	// the keys within [delStart, delEnd) older than delSeqNum.
+	delEnd    []byte
+	delSeqNum uint64
+}
+
+func (dw *dumpWriter) visitPointKey(key *InternalKey, lv LazyValue, _ IteratorLevel) error {
+	if !dw.hasKey || !dw.d.equal(key.UserKey, dw.key) {
+		if err := dw.finishKey(); err != nil {
+			return err
+		}
+		dw.key = append(dw.key[:0], key.UserKey...)
+		dw.hasKey = true
+	}
+	if dw.done {
+		return nil
+	}
+	if dw.delEnd != nil && dw.d.cmp(key.UserKey, dw.delEnd) < 0 && key.SeqNum() < dw.delSeqNum {
+		dw.done = true
+		return nil
+	}
+	switch key.Kind() {
+	case InternalKeyKindSet, InternalKeyKindSetWithDelete:
+		v, _, err := lv.Value(nil)
+		if err != nil {
+			return err
+		}
+		dw.base = append(dw.base[:0], v...)
+		dw.hasBase = true
+		dw.done = true
+	case InternalKeyKindMerge:
+		v, _, err := lv.Value(nil)
+		if err != nil {
+			return err
+		}
+		dw.operands = append(dw.operands, append([]byte(nil), v...))
+	case InternalKeyKindDelete, InternalKeyKindSingleDelete, InternalKeyKindDeleteSized:
+		dw.done = true
+	default:
+		return errors.Errorf("pebble: unexpected key kind %s", key.Kind())
+	}
+	return nil
+}
+
+func (dw *dumpWriter) visitRangeDel(start, end []byte, seqNum uint64) error {
+	// The fragment is visited before the keys within it, so the versions of
+	// the key visited last are all visited.
+	if err := dw.finishKey(); err != nil {
+		return err
+	}
+	dw.hasKey = false
+	dw.delEnd = append(dw.delEnd[:0], end...)
+	dw.delSeqNum = seqNum
+	dw.stats.RangeDeletes++
+	return dw.addEntry(dumpKindRangeDelete, start, end)
+}
+
+// finishKey writes the entries of the key whose versions were visited.
+func (dw *dumpWriter) finishKey() error {
+	defer func() {
+		dw.done, dw.hasBase = false, false
+		dw.operands = dw.operands[:0]
+	}()
+	if !dw.hasKey || (!dw.hasBase && len(dw.operands) == 0) {
+		return nil
+	}
+	if dw.resolveMerges && len(dw.operands) > 0 {
+		vm, err := dw.d.merge(dw.key, dw.operands[0])
+		if err != nil {
+			return err
+		}
+		for _, operand := range dw.operands[1:] {
+			if err := vm.MergeOlder(operand); err != nil {
+				return err
+			}
+		}
+		if dw.hasBase {
+			if err := vm.MergeOlder(dw.base); err != nil {
+				return err
+			}
+		}
+		value, closer, err := vm.Finish(true /* includesBase */)
+		if err != nil {
+			return err
+		}
+		dw.stats.Sets++
+		err = dw.addEntry(dumpKindSet, dw.key, value)
+		if closer != nil {
+			err = firstError(err, closer.Close())
+		}
+		return err
+	}
+	if dw.hasBase {
+		dw.stats.Sets++
+		if err := dw.addEntry(dumpKindSet, dw.key, dw.base); err != nil {
+			return err
+		}
+	}
+	for i := len(dw.operands) - 1; i >= 0; i-- {
+		dw.stats.Merges++
+		if err := dw.addEntry(dumpKindMerge, dw.key, dw.operands[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dumpRangeKeys writes the range keys visible to the snapshot.
+func (dw *dumpWriter) dumpRangeKeys(s *Snapshot) error {
+	iter, err := s.NewIter(&IterOptions{KeyTypes: IterKeyTypeRangesOnly})
+	if err != nil {
+		return err
+	}
+	for valid := iter.First(); valid; valid = iter.Next() {
+		start, end := iter.RangeBounds()
+		for _, rk := range iter.RangeKeys() {
+			dw.stats.RangeKeySets++
+			if err := dw.addEntry(dumpKindRangeKeySet, start, end, rk.Suffix, rk.Value); err != nil {
+				return firstError(err, iter.Close())
+			}
+		}
+	}
+	return iter.Close()
+}
+
+// addEntry adds an entry to the record being built, and writes the record once
+// it's large enough.
+func (dw *dumpWriter) addEntry(kind byte, fields ...[]byte) error {
+	dw.rec = append(dw.rec, kind)
+	for _, field := range fields {
+		dw.rec = appendDumpBytes(dw.rec, field)
+	}
+	if len(dw.rec) < dumpRecordSize {
+		return nil
+	}
+	_, err := dw.w.WriteRecord(dw.rec)
+	dw.rec = dw.rec[:0]
+	return err
+}
+
+func appendDumpBytes(buf, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+// dumpDecoder decodes the fields of a dump record. The first error is
+// retained, and the fields decoded after it are empty.
+type dumpDecoder struct {
+	buf []byte
+	err error
+}
+
+func (dd *dumpDecoder) uvarint() uint64 {
+	if dd.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(dd.buf)
+	if n <= 0 {
+		dd.err = base.CorruptionErrorf("pebble: corrupt dump: invalid uvarint")
+		return 0
+	}
+	dd.buf = dd.buf[n:]
+	return v
+}
+
+func (dd *dumpDecoder) readByte() byte {
+	if dd.err != nil {
+		return 0
+	}
+	if len(dd.buf) == 0 {
+		dd.err = base.CorruptionErrorf("pebble: corrupt dump: truncated record")
+		return 0
+	}
+	b := dd.buf[0]
+	dd.buf = dd.buf[1:]
+	return b
+}
+
+func (dd *dumpDecoder) bytes() []byte {
+	n := dd.uvarint()
+	if dd.err != nil {
+		return nil
+	}
+	if uint64(len(dd.buf)) < n {
+		dd.err = base.CorruptionErrorf("pebble: corrupt dump: truncated record")
+		return nil
+	}
+	b := dd.buf[:n:n]
+	dd.buf = dd.buf[n:]
+	return b
+}
+
+// Load loads a dump written by Snapshot.Dump into the DB. The dump is loaded
+// with a BulkLoader configured with opts, whose sstables hold the point keys,
+// range deletions and range keys of the dump, and are ingested once the end of
+// the dump is validated. The loaded point keys replace the values of the keys
+// in the DB, and the range deletions delete the keys of the DB, but not the
+// loaded ones. The dump is loaded atomically: a truncated or corrupt dump, or
+// a failure to load it, leaves the DB unchanged.
+//
+// The dump may be loaded by a DB with a different Comparer than the DB that
+// wrote it. If the merges of the dump weren't resolved, the DB must have the
+// same Merger, which is used to merge the operands of each key.
+func (d *DB) Load(r io.Reader, opts BulkLoaderOptions) (DumpStats, error) {
+	rr := record.NewReader(r, 0 /* logNum */)
+	header, err := readDumpRecord(rr)
+	if err != nil {
+		return DumpStats{}, err
+	}
+	if len(header) < len(dumpMagic) || string(header[:len(dumpMagic)]) != dumpMagic {
+		return DumpStats{}, base.CorruptionErrorf("pebble: corrupt dump: bad magic")
+	}
+	dec := dumpDecoder{buf: header[len(dumpMagic):]}
+	version := dec.uvarint()
+	_ = dec.bytes() // The comparer name.
+	mergerName := string(dec.bytes())
+	flags := dec.readByte()
+	if dec.err != nil {
+		return DumpStats{}, dec.err
+	}
+	if version != dumpVersion {
+		return DumpStats{}, errors.Errorf("pebble: unsupported dump version %d", version)
+	}
+	if flags&dumpFlagMergesResolved == 0 && mergerName != d.opts.Merger.Name {
+		return DumpStats{}, errors.Errorf("pebble: dump merger %q does not match DB merger %q",
+			errors.Safe(mergerName), errors.Safe(d.opts.Merger.Name))
+	}
+
+	b, err := d.NewBulkLoader(opts)
+	if err != nil {
+		return DumpStats{}, err
+	}
+	defer b.Close()
+
+	var stats DumpStats
+	for {
+		rec, err := readDumpRecord(rr)
+		if err != nil {
+			return DumpStats{}, err
+		}
+		dec := dumpDecoder{buf: rec}
+		for len(dec.buf) > 0 && dec.err == nil {
+			switch kind := dec.readByte(); kind {
+			case dumpKindSet, dumpKindMerge:
+				key, value := dec.bytes(), dec.bytes()
+				if dec.err != nil {
+					break
+				}
+				if kind == dumpKindSet {
+					stats.Sets++
+				} else {
+					stats.Merges++
+				}
+				// The BulkLoader merges the values added for a key in the order
+				// they're added, so the merge operands are merged onto the base
+				// value that precedes them.
+				if err := b.Add(key, value); err != nil {
+					return DumpStats{}, err
+				}
+			case dumpKindRangeDelete:
+				start, end := dec.bytes(), dec.bytes()
+				if dec.err != nil {
+					break
+				}
+				stats.RangeDeletes++
+				if err := b.addSpan(InternalKeyKindRangeDelete, start, end, nil, nil); err != nil {
+					return DumpStats{}, err
+				}
+			case dumpKindRangeKeySet:
+				start, end, suffix, value := dec.bytes(), dec.bytes(), dec.bytes(), dec.bytes()
+				if dec.err != nil {
+					break
+				}
+				stats.RangeKeySets++
+				if err := b.addSpan(InternalKeyKindRangeKeySet, start, end, suffix, value); err != nil {
+					return DumpStats{}, err
+				}
+			case dumpKindEnd:
+				expected := DumpStats{
+					Sets:         dec.uvarint(),
+					Merges:       dec.uvarint(),
+					RangeDeletes: dec.uvarint(),
+					RangeKeySets: dec.uvarint(),
+				}
+				if dec.err != nil {
+					break
+				}
+				if len(dec.buf) > 0 {
+					return DumpStats{}, base.CorruptionErrorf("pebble: corrupt dump: entries after end")
+				}
+				if _, err := rr.Next(); err != io.EOF {
+					return DumpStats{}, base.CorruptionErrorf("pebble: corrupt dump: records after end")
+				}
+				if stats != expected {
+					return DumpStats{}, base.CorruptionErrorf(
+						"pebble: corrupt dump: loaded %+v, expected %+v", stats, expected)
+				}
+				if err := b.Finish(); err != nil {
+					return DumpStats{}, err
+				}
+				return stats, nil
+			default:
+				return DumpStats{}, base.CorruptionErrorf("pebble: corrupt dump: unknown entry kind %d", kind)
+			}
+		}
+		if dec.err != nil {
+			return DumpStats{}, dec.err
+		}
+	}
+}
+
+// readDumpRecord reads the next record of a dump.
+func readDumpRecord(rr *record.Reader) ([]byte, error) {
+	r, err := rr.Next()
+	if err == io.EOF {
+		return nil, base.CorruptionErrorf("pebble: corrupt dump: truncated")
+	} else if err != nil {
+		return nil, err
+	}
+	rec, err := io.ReadAll(r)
+	if err == io.ErrUnexpectedEOF {
+		return nil, base.CorruptionErrorf("pebble: corrupt dump: truncated")
+	}
+	return rec, err
+}
@@ -0,0 +1,273 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/testkeys"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/cockroachdb/pebble/vfs/errorfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpLoad(t *testing.T) {
+	open := func(merger *Merger) *DB {
+		d, err := Open("", &Options{
+			Comparer:           testkeys.Comparer,
+			Merger:             merger,
+			FS:                 vfs.NewMem(),
+			FormatMajorVersion: FormatNewest,
+		})
+		require.NoError(t, err)
+		return d
+	}
+	contents := func(r Reader) string { return dumpTestContents(t, r) }
+
+	src := open(nil)
+	defer func() { require.NoError(t, src.Close()) }()
+	set := func(k, v string) { require.NoError(t, src.Set([]byte(k), []byte(v), nil)) }
+	merge := func(k, v string) { require.NoError(t, src.Merge([]byte(k), []byte(v), nil)) }
+
+	set("a", "1")
+	set("b", "1")
+	set("c", "1")
+	set("d", "1")
+	merge("e", "1")
+	set("g", "1")
+	require.NoError(t, src.Compact([]byte("a"), []byte("z"), false))
+	merge("b", "2")
+	require.NoError(t, src.Delete([]byte("c"), nil))
+	merge("d", "2")
+	merge("e", "2")
+	require.NoError(t, src.SingleDelete([]byte("g"), nil))
+	require.NoError(t, src.DeleteRange([]byte("f"), []byte("i"), nil))
+	set("h", "2")
+	require.NoError(t, src.Flush())
+	merge("a", "2")
+	merge("b", "3")
+	merge("h", "3")
+	set("j", "1")
+	require.NoError(t, src.RangeKeySet([]byte("k"), []byte("m"), []byte("@1"), []byte("x"), nil))
+	require.NoError(t, src.RangeKeySet([]byte("l"), []byte("n"), []byte("@2"), []byte("y"), nil))
+
+	snap := src.NewSnapshot()
+	defer func() { require.NoError(t, snap.Close()) }()
+	// Writes after the snapshot was taken aren't dumped.
+	set("a", "after")
+	set("z", "after")
+	expected := contents(snap)
+
+	var unresolved, resolved bytes.Buffer
+	stats, err := snap.Dump(&unresolved, DumpOptions{})
+	require.NoError(t, err)
+	// The base values of a, b, d, h and j, and the operands of a, b (2), d, e
+	// (2) and h.
+	require.Equal(t, uint64(5), stats.Sets)
+	require.Equal(t, uint64(7), stats.Merges)
+	require.Equal(t, uint64(1), stats.RangeDeletes)
+	// The range keys are dumped as the fragments [k-l), [l-m) (2) and [m-n).
+	require.Equal(t, uint64(4), stats.RangeKeySets)
+	resolvedStats, err := snap.Dump(&resolved, DumpOptions{ResolveMerges: true})
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), resolvedStats.Sets)
+	require.Zero(t, resolvedStats.Merges)
+
+	for _, dump := range []*bytes.Buffer{&unresolved, &resolved} {
+		dst := open(nil)
+		// The loaded keys replace the existing ones.
+		require.NoError(t, dst.Set([]byte("a"), []byte("existing"), nil))
+		loaded, err := dst.Load(bytes.NewReader(dump.Bytes()), BulkLoaderOptions{MemoryLimit: 1})
+		require.NoError(t, err)
+		if dump == &unresolved {
+			require.Equal(t, stats, loaded)
+		} else {
+			require.Equal(t, resolvedStats, loaded)
+		}
+		require.Equal(t, expected, contents(dst))
+		require.NoError(t, dst.Close())
+	}
+
+	// A dump with merge operands can only be loaded by a DB with the same
+	// Merger, unlike a dump with resolved merges.
+	otherMerger := &Merger{Merge: DefaultMerger.Merge, Name: "other"}
+	dst := open(otherMerger)
+	_, err = dst.Load(bytes.NewReader(unresolved.Bytes()), BulkLoaderOptions{})
+	require.Error(t, err)
+	_, err = dst.Load(bytes.NewReader(resolved.Bytes()), BulkLoaderOptions{})
+	require.NoError(t, err)
+	require.Equal(t, expected, contents(dst))
+	require.NoError(t, dst.Close())
+
+	// A truncated or corrupted dump isn't loaded.
+	for _, tc := range []struct {
+		name string
+		data func([]byte) []byte
+	}{
+		{"empty", func(b []byte) []byte { return nil }},
+		{"truncated", func(b []byte) []byte { return b[:len(b)-3] }},
+		{"corrupted", func(b []byte) []byte { b[len(b)/2] ^= 0xff; return b }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := open(nil)
+			defer func() { require.NoError(t, dst.Close()) }()
+			data := tc.data(append([]byte(nil), unresolved.Bytes()...))
+			_, err := dst.Load(bytes.NewReader(data), BulkLoaderOptions{})
+			require.True(t, errors.Is(err, base.ErrCorruption), "%v", err)
+			require.Equal(t, "", contents(dst))
+		})
+	}
+}
+
+// dumpTestContents returns the point keys and range keys visible to the reader.
+func dumpTestContents(t *testing.T, r Reader) string {
+	iter, err := r.NewIter(&IterOptions{KeyTypes: IterKeyTypePointsAndRanges})
+	require.NoError(t, err)
+	var buf strings.Builder
+	for valid := iter.First(); valid; valid = iter.Next() {
+		hasPoint, hasRange := iter.HasPointAndRange()
+		if hasRange && iter.RangeKeyChanged() {
+			start, end := iter.RangeBounds()
+			fmt.Fprintf(&buf, "[%s-%s):", start, end)
+			for _, rk := range iter.RangeKeys() {
+				fmt.Fprintf(&buf, " %s=%s", rk.Suffix, rk.Value)
+			}
+			fmt.Fprintln(&buf)
+		}
+		if hasPoint {
+			fmt.Fprintf(&buf, "%s: %s\n", iter.Key(), iter.Value())
+		}
+	}
+	require.NoError(t, iter.Close())
+	return buf.String()
+}
+
+// TestLoadSpans tests that the range deletions and range keys of a dump are
+// spilled to sorted runs and ingested with its point keys, in sstables split at
+// the target file size, and that a dump that fails to load after it's
+// validated leaves the DB unchanged.
+func TestLoadSpans(t *testing.T) {
+	// failRuns fails the opening of the sorted runs of the BulkLoader, which
+	// are merged once the dump is validated.
+	var failRuns atomic.Bool
+	open := func() *DB {
+		fs := errorfs.Wrap(vfs.NewMem(), errorfs.InjectorFunc(func(op errorfs.Op) error {
+			if failRuns.Load() && op.Kind == errorfs.OpOpen && strings.HasSuffix(op.Path, ".dbtmp") {
+				return errorfs.ErrInjected
+			}
+			return nil
+		}))
+		d, err := Open("", &Options{
+			Comparer:           testkeys.Comparer,
+			FS:                 fs,
+			FormatMajorVersion: FormatNewest,
+		})
+		require.NoError(t, err)
+		return d
+	}
+	src := open()
+	defer func() { require.NoError(t, src.Close()) }()
+	key := func(i int) []byte { return []byte(fmt.Sprintf("k%04d", i)) }
+	for i := 0; i < 1000; i++ {
+		if i%2 == 0 {
+			require.NoError(t, src.Set(key(i), []byte("loaded"), nil))
+		} else {
+			require.NoError(t, src.DeleteRange(key(i), key(i+1), nil))
+		}
+		if i%100 == 0 {
+			require.NoError(t, src.RangeKeySet(key(i), key(i+50), []byte("@1"), []byte("x"), nil))
+		}
+	}
+	snap := src.NewSnapshot()
+	defer func() { require.NoError(t, snap.Close()) }()
+	var dump bytes.Buffer
+	_, err := snap.Dump(&dump, DumpOptions{})
+	require.NoError(t, err)
+	expected := dumpTestContents(t, snap)
+
+	// The keys of the DB are replaced by the loaded keys, or deleted by the
+	// loaded range deletions.
+	dst := open()
+	defer func() { require.NoError(t, dst.Close()) }()
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, dst.Set(key(i), []byte("existing"), nil))
+	}
+	require.NoError(t, dst.Flush())
+	existing := dumpTestContents(t, dst)
+
+	failRuns.Store(true)
+	_, err = dst.Load(bytes.NewReader(dump.Bytes()), BulkLoaderOptions{MemoryLimit: 4 << 10})
+	require.True(t, errors.Is(err, errorfs.ErrInjected), "%v", err)
+	failRuns.Store(false)
+	require.Equal(t, existing, dumpTestContents(t, dst))
+
+	var progress BulkLoadProgress
+	_, err = dst.Load(bytes.NewReader(dump.Bytes()), BulkLoaderOptions{
+		MemoryLimit:    4 << 10,
+		TargetFileSize: 1 << 10,
+		OnProgress:     func(p BulkLoadProgress) { progress = p },
+	})
+	require.NoError(t, err)
+	require.Greater(t, progress.Runs, 1)
+	require.Greater(t, progress.TablesWritten, 1)
+	require.Equal(t, uint64(1), dst.Metrics().Ingest.Count)
+	require.Equal(t, expected, dumpTestContents(t, dst))
+}
+
+// TestLoadTruncatedDump tests that a truncated dump whose range deletions span
+// many records leaves a non-empty DB unchanged.
+func TestLoadTruncatedDump(t *testing.T) {
+	open := func() *DB {
+		d, err := Open("", &Options{
+			Comparer:           testkeys.Comparer,
+			FS:                 vfs.NewMem(),
+			FormatMajorVersion: FormatNewest,
+		})
+		require.NoError(t, err)
+		return d
+	}
+	src := open()
+	defer func() { require.NoError(t, src.Close()) }()
+	key := func(i int) []byte { return []byte(fmt.Sprintf("%s%06d", strings.Repeat("k", 100), i)) }
+	for i := 0; i < 20000; i += 2 {
+		require.NoError(t, src.DeleteRange(key(i), key(i+1), nil))
+	}
+	require.NoError(t, src.RangeKeySet(key(0), key(20000), []byte("@1"), []byte("x"), nil))
+	snap := src.NewSnapshot()
+	defer func() { require.NoError(t, snap.Close()) }()
+	var dump bytes.Buffer
+	stats, err := snap.Dump(&dump, DumpOptions{})
+	require.NoError(t, err)
+	require.Equal(t, uint64(10000), stats.RangeDeletes)
+	// The dump spans several megabytes; drop its end.
+	require.Greater(t, dump.Len(), 2<<20)
+	data := dump.Bytes()[:dump.Len()-dumpRecordSize]
+
+	dst := open()
+	defer func() { require.NoError(t, dst.Close()) }()
+	for i := 0; i < 20000; i++ {
+		require.NoError(t, dst.Set(key(i), []byte("existing"), nil))
+	}
+	_, err = dst.Load(bytes.NewReader(data), BulkLoaderOptions{})
+	require.True(t, errors.Is(err, base.ErrCorruption), "%v", err)
+
+	iter, err := dst.NewIter(&IterOptions{KeyTypes: IterKeyTypePointsAndRanges})
+	require.NoError(t, err)
+	var n int
+	for valid := iter.First(); valid; valid = iter.Next() {
+		hasPoint, hasRange := iter.HasPointAndRange()
+		require.True(t, hasPoint)
+		require.False(t, hasRange)
+		n++
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, 20000, n)
+}
@@ -86,6 +86,83 @@ type CompactionInfo struct {
 
 	// Annotations specifies additional info to appear in a compaction's event log line
 	Annotations compactionAnnotations
+
+	// Subcompactions describes the subcompactions the compaction was split
+	// into, in key order. It is only set for the compaction end event of a
+	// compaction that was split. See Options.Experimental.MaxSubcompactions.
+	Subcompactions []SubcompactionInfo
+}
+
+// SubcompactionInfo contains the info for one of the subcompactions of a
+// compaction.
+type SubcompactionInfo struct {
+	// Start and End bound the user keys compacted by the subcompaction to
+	// [Start, End). A nil Start or End leaves that side bounded only by the
+	// compaction's inputs.
+	Start, End []byte
+	// Tables contains the output tables generated by the subcompaction.
+	Tables []TableInfo
+	// Duration is the time spent by the subcompaction reading and writing
+	// sstables.
+	Duration time.Duration
+}
+
+// CompactionProgressInfo contains the info for a compaction progress event.
+// It's also the progress of a compaction returned by
+// DB.InProgressCompactions.
+type CompactionProgressInfo struct {
+	// JobID is the ID of the compaction job.
+	JobID int
+	// Reason is the reason for the compaction.
+	Reason string
+	// Input contains the input tables for the compaction organized by level.
+	Input []LevelInfo
+	// OutputLevel is the level that the compaction writes to.
+	OutputLevel int
+	// Elapsed is the time since the compaction began.
+	Elapsed time.Duration
+	// InputBytes is the total size of the compaction's input tables.
+	InputBytes uint64
+	// BytesRead is the number of bytes of the input tables that the compaction
+	// has read so far. It's updated in increments of about 1 MB.
+	BytesRead uint64
+	// BytesWritten is the number of bytes that the compaction has written to
+	// its output tables so far.
+	BytesWritten uint64
+	// CurrentOutput is the file number of the output table that the compaction
+	// created last, or zero if it hasn't created any. A compaction that's split
+	// into subcompactions writes multiple output tables at once.
+	CurrentOutput base.DiskFileNum
+	// BytesRemaining estimates the number of bytes of the input tables that
+	// remain to be read.
+	BytesRemaining uint64
+	// EstimatedTimeRemaining estimates the time until the compaction completes,
+	// extrapolated from the rate at which it has read its input tables so far.
+	// It's zero until the compaction has read any bytes.
+	EstimatedTimeRemaining time.Duration
+}
+
+func (i CompactionProgressInfo) String() string {
+	return redact.StringWithoutMarkers(i)
+}
+
+// SafeFormat implements redact.SafeFormatter.
+func (i CompactionProgressInfo) SafeFormat(w redact.SafePrinter, _ rune) {
+	w.Printf("[JOB %d] compacting(%s) %s -> L%d: read %s of %s, wrote %s",
+		redact.Safe(i.JobID),
+		redact.SafeString(i.Reason),
+		levelInfos(i.Input),
+		redact.Safe(i.OutputLevel),
+		redact.Safe(humanize.Bytes.Uint64(i.BytesRead)),
+		redact.Safe(humanize.Bytes.Uint64(i.InputBytes)),
+		redact.Safe(humanize.Bytes.Uint64(i.BytesWritten)))
+	if i.CurrentOutput != 0 {
+		w.Printf(" (to %s)", i.CurrentOutput)
+	}
+	w.Printf(", in %.1fs", redact.Safe(i.Elapsed.Seconds()))
+	if i.EstimatedTimeRemaining != 0 {
+		w.Printf(", %.1fs remaining", redact.Safe(i.EstimatedTimeRemaining.Seconds()))
+	}
 }
 
 type compactionAnnotations []string
@@ -135,6 +212,9 @@ func (i CompactionInfo) SafeFormat(w redact.SafePrinter, _ rune) {
 		redact.Safe(i.Duration.Seconds()),
 		redact.Safe(i.TotalDuration.Seconds()),
 		redact.Safe(humanize.Bytes.Uint64(uint64(float64(outputSize)/i.Duration.Seconds()))))
+	if len(i.Subcompactions) > 0 {
+		w.Printf(", %d subcompactions", redact.Safe(len(i.Subcompactions)))
+	}
 }
 
 type levelInfos []LevelInfo
@@ -313,6 +393,11 @@ type TableDeleteInfo struct {
 	Path    string
 	FileNum base.DiskFileNum
 	Err     error
+	// Reason is the reason the table was dropped from the LSM, if it was
+	// dropped by a compaction picker rather than made obsolete by a
+	// compaction, ingestion or excise: "fifo-size" or "fifo-ttl" for tables
+	// dropped by FIFOCompactionPicker. It is empty otherwise.
+	Reason string
 }
 
 func (i TableDeleteInfo) String() string {
@@ -326,6 +411,10 @@ func (i TableDeleteInfo) SafeFormat(w redact.SafePrinter, _ rune) {
 			redact.Safe(i.JobID), i.FileNum, i.Err)
 		return
 	}
+	if i.Reason != "" {
+		w.Printf("[JOB %d] %s: sstable deleted %s", redact.Safe(i.JobID), redact.Safe(i.Reason), i.FileNum)
+		return
+	}
 	w.Printf("[JOB %d] sstable deleted %s", redact.Safe(i.JobID), i.FileNum)
 }
 
@@ -488,6 +577,12 @@ type EventListener struct {
 	// operation such as flush or compaction.
 	BackgroundError func(error)
 
+	// BlockCacheEvictionPressure is invoked when the eviction pressure of the
+	// block cache crosses Options.BlockCacheEvictionPressure.Threshold, in
+	// either direction. It's invoked by the goroutine that added a block to
+	// the cache or changed its capacity, and should return quickly.
+	BlockCacheEvictionPressure func(CacheEvictionPressureInfo)
+
 	// CompactionBegin is invoked after the inputs to a compaction have been
 	// determined, but before the compaction has produced any output.
 	CompactionBegin func(CompactionInfo)
@@ -496,6 +591,12 @@ type EventListener struct {
 	// has been installed.
 	CompactionEnd func(CompactionInfo)
 
+	// CompactionProgress is invoked periodically while a compaction runs, every
+	// Options.CompactionProgressInterval. It's invoked by the goroutine of the
+	// compaction, or of one of its subcompactions, and delays the compaction
+	// until it returns.
+	CompactionProgress func(CompactionProgressInfo)
+
 	// DiskSlow is invoked after a disk write operation on a file created with a
 	// disk health checking vfs.FS (see vfs.DefaultWithDiskHealthChecks) is
 	// observed to exceed the specified disk slowness threshold duration. DiskSlow
@@ -568,12 +669,18 @@ func (l *EventListener) EnsureDefaults(logger Logger) {
 			l.BackgroundError = func(error) {}
 		}
 	}
+	if l.BlockCacheEvictionPressure == nil {
+		l.BlockCacheEvictionPressure = func(info CacheEvictionPressureInfo) {}
+	}
 	if l.CompactionBegin == nil {
 		l.CompactionBegin = func(info CompactionInfo) {}
 	}
 	if l.CompactionEnd == nil {
 		l.CompactionEnd = func(info CompactionInfo) {}
 	}
+	if l.CompactionProgress == nil {
+		l.CompactionProgress = func(info CompactionProgressInfo) {}
+	}
 	if l.DiskSlow == nil {
 		l.DiskSlow = func(info DiskSlowInfo) {}
 	}
@@ -632,12 +739,18 @@ func MakeLoggingEventListener(logger Logger) EventListener {
 		BackgroundError: func(err error) {
 			logger.Errorf("background error: %s", err)
 		},
+		BlockCacheEvictionPressure: func(info CacheEvictionPressureInfo) {
+			logger.Infof("%s", info)
+		},
 		CompactionBegin: func(info CompactionInfo) {
 			logger.Infof("%s", info)
 		},
 		CompactionEnd: func(info CompactionInfo) {
 			logger.Infof("%s", info)
 		},
+		CompactionProgress: func(info CompactionProgressInfo) {
+			logger.Infof("%s", info)
+		},
 		DiskSlow: func(info DiskSlowInfo) {
 			logger.Infof("%s", info)
 		},
@@ -695,6 +808,10 @@ func TeeEventListener(a, b EventListener) EventListener {
 			a.BackgroundError(err)
 			b.BackgroundError(err)
 		},
+		BlockCacheEvictionPressure: func(info CacheEvictionPressureInfo) {
+			a.BlockCacheEvictionPressure(info)
+			b.BlockCacheEvictionPressure(info)
+		},
 		CompactionBegin: func(info CompactionInfo) {
 			a.CompactionBegin(info)
 			b.CompactionBegin(info)
@@ -703,6 +820,10 @@ func TeeEventListener(a, b EventListener) EventListener {
 			a.CompactionEnd(info)
 			b.CompactionEnd(info)
 		},
+		CompactionProgress: func(info CompactionProgressInfo) {
+			a.CompactionProgress(info)
+			b.CompactionProgress(info)
+		},
 		DiskSlow: func(info DiskSlowInfo) {
 			a.DiskSlow(info)
 			b.DiskSlow(info)
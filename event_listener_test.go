@@ -7,6 +7,7 @@ package pebble
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
 	"reflect"
 	"runtime"
 	"strings"
@@ -313,6 +314,80 @@ func TestWriteStallEvents(t *testing.T) {
 	}
 }
 
+func TestCompactionProgressEvents(t *testing.T) {
+	var d *DB
+	var mu sync.Mutex
+	var begin []int
+	var progress []CompactionProgressInfo
+	var inProgress []CompactionProgressInfo
+	listener := &EventListener{
+		CompactionBegin: func(info CompactionInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			begin = append(begin, info.JobID)
+		},
+		CompactionProgress: func(info CompactionProgressInfo) {
+			// The listener runs on the compaction's goroutine, without DB.mu
+			// held, so it may ask for the compactions in progress.
+			compactions := d.InProgressCompactions()
+			mu.Lock()
+			defer mu.Unlock()
+			progress = append(progress, info)
+			inProgress = append(inProgress, compactions...)
+		},
+	}
+	opts := &Options{
+		EventListener:               listener,
+		FS:                          vfs.NewMem(),
+		CompactionProgressInterval:  time.Nanosecond,
+		DisableAutomaticCompactions: true,
+		Levels: []LevelOptions{{
+			Compression:    NoCompression,
+			TargetFileSize: 256 << 10,
+		}},
+	}
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer d.Close()
+
+	// Write 4 MB of incompressible values, so that the compaction reports its
+	// progress multiple times.
+	rng := rand.New(rand.NewSource(1))
+	value := make([]byte, 1<<10)
+	for i := 0; i < 4<<10; i++ {
+		rng.Read(value)
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%06d", i)), value, nil))
+		if i%1024 == 1023 {
+			require.NoError(t, d.Flush())
+		}
+	}
+	require.NoError(t, d.Compact([]byte("000000"), []byte("999999"), false /* parallelize */))
+	require.Empty(t, d.InProgressCompactions())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, begin, 1)
+	require.Greater(t, len(progress), 1)
+	for i, p := range progress {
+		require.Equal(t, begin[0], p.JobID)
+		require.Equal(t, "default", p.Reason)
+		require.Equal(t, 0, p.Input[0].Level)
+		require.Less(t, p.BytesRead, p.InputBytes)
+		require.Equal(t, p.InputBytes-p.BytesRead, p.BytesRemaining)
+		require.NotZero(t, p.CurrentOutput)
+		require.NotZero(t, p.EstimatedTimeRemaining)
+		if i > 0 {
+			require.Greater(t, p.BytesRead, progress[i-1].BytesRead)
+			require.GreaterOrEqual(t, p.BytesWritten, progress[i-1].BytesWritten)
+		}
+		require.NotEmpty(t, p.String())
+	}
+	require.Len(t, inProgress, len(progress))
+	for _, p := range inProgress {
+		require.Equal(t, begin[0], p.JobID)
+	}
+}
+
 type redactLogger struct {
 	logger Logger
 }
@@ -332,6 +407,59 @@ func (l redactLogger) Fatalf(format string, args ...interface{}) {
 	l.logger.Fatalf("%s", redact.Sprintf(format, args...).Redact())
 }
 
+func TestBlockCacheEvictionPressureEvent(t *testing.T) {
+	var mu sync.Mutex
+	var events []CacheEvictionPressureInfo
+	opts := &Options{
+		Cache: NewCache(1 << 20),
+		FS:    vfs.NewMem(),
+		// Memtables reserve their size from the cache.
+		MemTableSize: 64 << 10,
+		EventListener: &EventListener{
+			BlockCacheEvictionPressure: func(info CacheEvictionPressureInfo) {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, info)
+			},
+		},
+	}
+	defer opts.Cache.Unref()
+	opts.BlockCacheEvictionPressure.Threshold = 1
+	opts.BlockCacheEvictionPressure.Interval = time.Millisecond
+	d, err := Open("", opts)
+	require.NoError(t, err)
+
+	// Reading far more data than the cache holds replaces its contents many
+	// times a second.
+	value := bytes.Repeat([]byte("v"), 1<<10)
+	for i := 0; i < 4000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("%04d", i)), value, nil))
+	}
+	require.NoError(t, d.Flush())
+	require.Eventually(t, func() bool {
+		iter, _ := d.NewIter(nil)
+		for valid := iter.First(); valid; valid = iter.Next() {
+		}
+		require.NoError(t, iter.Close())
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) > 0
+	}, 10*time.Second, time.Millisecond)
+	mu.Lock()
+	require.True(t, events[0].Above)
+	n := len(events)
+	mu.Unlock()
+
+	// Closing the DB removes its listener from the cache, which outlives it:
+	// the drop in pressure once the reads stop isn't reported.
+	require.NoError(t, d.Close())
+	time.Sleep(2 * time.Millisecond)
+	opts.Cache.SetCapacity(512 << 10)
+	mu.Lock()
+	require.Len(t, events, n)
+	mu.Unlock()
+}
+
 func TestEventListenerRedact(t *testing.T) {
 	// The vast majority of event listener fields logged are safe and do not
 	// need to be redacted. Verify that the rare, unsafe error does appear in
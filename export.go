@@ -0,0 +1,204 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/objstorage/objstorageprovider"
+	"github.com/cockroachdb/pebble/sstable"
+)
+
+// ExportOptions configures Snapshot.Export.
+type ExportOptions struct {
+	// TargetFileSize is the target size of the exported sstables.
+	//
+	// The default value is the target file size of the bottommost level.
+	TargetFileSize int64
+	// TableFormat is the format of the exported sstables, which must be
+	// supported by the format major version of the DBs that ingest them.
+	//
+	// The default value is the newest format supported by the format major
+	// version of the DB.
+	TableFormat sstable.TableFormat
+}
+
+// exportSpan is a range key span to export, with its range keys.
+type exportSpan struct {
+	start, end []byte
+	keys       []RangeKeyData
+}
+
+// Export writes the keys within [start, end) visible to the snapshot to
+// sstables in the directory dir, which is created if it doesn't exist. It
+// returns the paths of the sstables, which partition [start, end) in order, and
+// may be passed to DB.Ingest on another DB.
+//
+// The sstables contain the point keys and range keys visible to the snapshot,
+// with merges resolved. Each sstable also contains a range deletion and a range
+// key deletion over the part of [start, end) that it covers, so that ingesting
+// the sstables replaces the keys within [start, end) of the DB that ingests
+// them with those visible to the snapshot, including the absence of the keys
+// deleted before the snapshot was taken.
+func (s *Snapshot) Export(start, end []byte, dir string, opts ExportOptions) ([]string, error) {
+	if s.db == nil {
+		panic(ErrClosed)
+	}
+	d := s.db
+	if end == nil || d.cmp(start, end) >= 0 {
+		return nil, errors.Errorf("pebble: invalid export span [%s, %s)",
+			d.opts.Comparer.FormatKey(start), d.opts.Comparer.FormatKey(end))
+	}
+	if opts.TargetFileSize <= 0 {
+		opts.TargetFileSize = d.opts.Level(numLevels - 1).TargetFileSize
+	}
+	if opts.TableFormat == sstable.TableFormatUnspecified {
+		opts.TableFormat = d.FormatMajorVersion().MaxTableFormat()
+	}
+	if err := d.opts.FS.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	e := &exporter{
+		d:          d,
+		dir:        dir,
+		writerOpts: d.opts.MakeWriterOptions(0, opts.TableFormat),
+		targetSize: uint64(opts.TargetFileSize),
+		start:      start,
+	}
+
+	iter, err := s.NewIter(&IterOptions{
+		LowerBound: start,
+		UpperBound: end,
+		KeyTypes:   IterKeyTypePointsAndRanges,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = e.export(iter, end)
+	err = firstError(err, iter.Close())
+	if err != nil {
+		if e.w != nil {
+			_ = e.w.Close()
+		}
+		for _, path := range e.paths {
+			_ = d.opts.FS.Remove(path)
+		}
+		return nil, err
+	}
+	return e.paths, nil
+}
+
+// exporter writes the sstables of Snapshot.Export.
+type exporter struct {
+	d          *DB
+	dir        string
+	writerOpts sstable.WriterOptions
+	targetSize uint64
+
+	// w is the writer of the current sstable, which covers the keys from start.
+	// Its range keys are buffered in spans, and written when it's finished.
+	w     *sstable.Writer
+	start []byte
+	spans []exportSpan
+	paths []string
+}
+
+func (e *exporter) export(iter *Iterator, end []byte) error {
+	for valid := iter.First(); valid; valid = iter.Next() {
+		key := iter.Key()
+		if e.w != nil && e.w.EstimatedSize() >= e.targetSize {
+			if err := e.finish(key); err != nil {
+				return err
+			}
+		}
+		if e.w == nil {
+			if err := e.create(); err != nil {
+				return err
+			}
+		}
+		hasPoint, hasRange := iter.HasPointAndRange()
+		if hasRange && iter.RangeKeyChanged() {
+			start, end := iter.RangeBounds()
+			span := exportSpan{
+				start: append([]byte(nil), start...),
+				end:   append([]byte(nil), end...),
+			}
+			for _, rk := range iter.RangeKeys() {
+				span.keys = append(span.keys, RangeKeyData{
+					Suffix: append([]byte(nil), rk.Suffix...),
+					Value:  append([]byte(nil), rk.Value...),
+				})
+			}
+			e.spans = append(e.spans, span)
+		}
+		if hasPoint {
+			value, err := iter.ValueAndErr()
+			if err != nil {
+				return err
+			}
+			if err := e.w.Set(key, value); err != nil {
+				return err
+			}
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	// The last sstable covers the rest of the span, even if no keys remain.
+	if e.w == nil {
+		if err := e.create(); err != nil {
+			return err
+		}
+	}
+	return e.finish(end)
+}
+
+// create creates the next sstable.
+func (e *exporter) create() error {
+	fs := e.d.opts.FS
+	path := fs.PathJoin(e.dir, fmt.Sprintf("%06d.sst", len(e.paths)+1))
+	f, err := fs.Create(path)
+	if err != nil {
+		return err
+	}
+	e.paths = append(e.paths, path)
+	e.w = sstable.NewWriter(objstorageprovider.NewFileWritable(f), e.writerOpts)
+	return nil
+}
+
+// finish finishes the current sstable, which covers the keys from e.start up
+// to end. The range key spans that extend past end are truncated, and their
+// remainder is carried over to the next sstable.
+func (e *exporter) finish(end []byte) error {
+	cmp := e.d.cmp
+	end = append([]byte(nil), end...)
+	w := e.w
+	e.w = nil
+	err := w.DeleteRange(e.start, end)
+	if err == nil {
+		err = w.RangeKeyDelete(e.start, end)
+	}
+	var carried []exportSpan
+	for _, span := range e.spans {
+		if err != nil {
+			break
+		}
+		spanEnd := span.end
+		if cmp(spanEnd, end) > 0 {
+			spanEnd = end
+			carried = append(carried, exportSpan{start: end, end: span.end, keys: span.keys})
+		}
+		for _, rk := range span.keys {
+			if err = w.RangeKeySet(span.start, spanEnd, rk.Suffix, rk.Value); err != nil {
+				break
+			}
+		}
+	}
+	err = firstError(err, w.Close())
+	e.start = end
+	e.spans = carried
+	return err
+}
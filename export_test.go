@@ -0,0 +1,101 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/pebble/internal/testkeys"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotExport(t *testing.T) {
+	fs := vfs.NewMem()
+	open := func(dir string) *DB {
+		d, err := Open(dir, &Options{
+			FS:                 fs,
+			FormatMajorVersion: FormatNewest,
+			Comparer:           testkeys.Comparer,
+		})
+		require.NoError(t, err)
+		return d
+	}
+	key := func(i int) []byte { return []byte(fmt.Sprintf("k%04d", i)) }
+	// dump returns the keys within [start, end) visible to the reader.
+	dump := func(r Reader, start, end []byte) string {
+		iter, err := r.NewIter(&IterOptions{
+			LowerBound: start,
+			UpperBound: end,
+			KeyTypes:   IterKeyTypePointsAndRanges,
+		})
+		require.NoError(t, err)
+		var buf strings.Builder
+		for valid := iter.First(); valid; valid = iter.Next() {
+			hasPoint, hasRange := iter.HasPointAndRange()
+			if hasPoint {
+				fmt.Fprintf(&buf, "%s=%s\n", iter.Key(), iter.Value())
+			}
+			if hasRange && iter.RangeKeyChanged() {
+				start, end := iter.RangeBounds()
+				fmt.Fprintf(&buf, "[%s, %s):", start, end)
+				for _, rk := range iter.RangeKeys() {
+					fmt.Fprintf(&buf, " %s=%s", rk.Suffix, rk.Value)
+				}
+				buf.WriteString("\n")
+			}
+		}
+		require.NoError(t, iter.Close())
+		return buf.String()
+	}
+
+	src := open("src")
+	defer src.Close()
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, src.Set(key(i), []byte(fmt.Sprint(i)), nil))
+		if i%3 == 0 {
+			require.NoError(t, src.Merge(key(i), []byte("+merged"), nil))
+		}
+	}
+	require.NoError(t, src.Flush())
+	require.NoError(t, src.DeleteRange(key(100), key(200), nil))
+	require.NoError(t, src.Delete(key(300), nil))
+	require.NoError(t, src.RangeKeySet(key(50), key(650), []byte("@5"), []byte("a"), nil))
+	require.NoError(t, src.RangeKeySet(key(400), key(500), []byte("@7"), []byte("b"), nil))
+	snap := src.NewSnapshot()
+	defer snap.Close()
+	// Writes after the snapshot aren't exported.
+	require.NoError(t, src.Set(key(10), []byte("after"), nil))
+	require.NoError(t, src.DeleteRange(key(600), key(700), nil))
+
+	start, end := key(20), key(900)
+	paths, err := snap.Export(start, end, "export", ExportOptions{TargetFileSize: 2 << 10})
+	require.NoError(t, err)
+	require.Greater(t, len(paths), 1)
+
+	// The destination's keys within the span are replaced by the exported
+	// ones, and those outside of it are untouched.
+	dest := open("dest")
+	defer dest.Close()
+	for _, i := range []int{0, 150, 300, 950} {
+		require.NoError(t, dest.Set(key(i), []byte("dest"), nil))
+	}
+	require.NoError(t, dest.RangeKeySet(key(0), key(1000), []byte("@1"), []byte("dest"), nil))
+	require.NoError(t, dest.Ingest(paths))
+
+	require.Equal(t, dump(snap, start, end), dump(dest, start, end))
+	require.Equal(t, "k0000=dest\n[k0000, k0020): @1=dest\n", dump(dest, nil, start))
+	require.Equal(t, "[k0900, k1000): @1=dest\nk0950=dest\n", dump(dest, end, nil))
+
+	// An empty span is exported as a single sstable that clears it.
+	paths, err = snap.Export([]byte("x"), []byte("y"), "export-empty", ExportOptions{})
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+
+	_, err = snap.Export(end, start, "export-invalid", ExportOptions{})
+	require.Error(t, err)
+}
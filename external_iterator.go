@@ -216,7 +216,8 @@ func createExternalPointIter(ctx context.Context, it *Iterator) (internalIterato
 				ctx, it.opts.LowerBound, it.opts.UpperBound, nil, /* BlockPropertiesFilterer */
 				false /* hideObsoletePoints */, false, /* useFilterBlock */
 				&it.stats.InternalStats, it.opts.CategoryAndQoS, nil,
-				sstable.TrivialReaderProvider{Reader: r})
+				sstable.TrivialReaderProvider{Reader: r}, it.opts.BlockCacheMode,
+				it.opts.PrefetchBlocks)
 			if err != nil {
 				return nil, err
 			}
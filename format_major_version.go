@@ -185,6 +185,22 @@ const (
 	// requires a format major version.
 	FormatSyntheticPrefixes
 
+	// FormatWALCompression is a format major version that adds support for
+	// compressed WAL records (see Options.WALCompression). Compressed records
+	// use new chunk types that previous Pebble versions do not recognize.
+	FormatWALCompression
+
+	// FormatDataBlockHashIndex is a format major version that adds support for
+	// sstables with a hash index in their data blocks (see
+	// LevelOptions.DataBlockHashIndex). Such sstables are written using
+	// sstable.TableFormatPebblev5.
+	FormatDataBlockHashIndex
+
+	// FormatColumnarDataBlocks is a format major version that adds support for
+	// sstables with columnar data blocks (see LevelOptions.ColumnarDataBlocks).
+	// Such sstables are written using sstable.TableFormatPebblev6.
+	FormatColumnarDataBlocks
+
 	// -- Add new versions here --
 
 	// FormatNewest is the most recent format major version.
@@ -220,8 +236,13 @@ func (v FormatMajorVersion) MaxTableFormat() sstable.TableFormat {
 	switch v {
 	case FormatDefault, FormatFlushableIngest, FormatPrePebblev1MarkedCompacted:
 		return sstable.TableFormatPebblev3
-	case FormatDeleteSizedAndObsolete, FormatVirtualSSTables, FormatSyntheticPrefixes:
+	case FormatDeleteSizedAndObsolete, FormatVirtualSSTables, FormatSyntheticPrefixes,
+		FormatWALCompression:
 		return sstable.TableFormatPebblev4
+	case FormatDataBlockHashIndex:
+		return sstable.TableFormatPebblev5
+	case FormatColumnarDataBlocks:
+		return sstable.TableFormatPebblev6
 	default:
 		panic(fmt.Sprintf("pebble: unsupported format major version: %s", v))
 	}
@@ -232,7 +253,8 @@ func (v FormatMajorVersion) MaxTableFormat() sstable.TableFormat {
 func (v FormatMajorVersion) MinTableFormat() sstable.TableFormat {
 	switch v {
 	case FormatDefault, FormatFlushableIngest, FormatPrePebblev1MarkedCompacted,
-		FormatDeleteSizedAndObsolete, FormatVirtualSSTables, FormatSyntheticPrefixes:
+		FormatDeleteSizedAndObsolete, FormatVirtualSSTables, FormatSyntheticPrefixes,
+		FormatWALCompression, FormatDataBlockHashIndex, FormatColumnarDataBlocks:
 		return sstable.TableFormatPebblev1
 	default:
 		panic(fmt.Sprintf("pebble: unsupported format major version: %s", v))
@@ -269,6 +291,15 @@ var formatMajorVersionMigrations = map[FormatMajorVersion]func(*DB) error{
 	FormatSyntheticPrefixes: func(d *DB) error {
 		return d.finalizeFormatVersUpgrade(FormatSyntheticPrefixes)
 	},
+	FormatWALCompression: func(d *DB) error {
+		return d.finalizeFormatVersUpgrade(FormatWALCompression)
+	},
+	FormatDataBlockHashIndex: func(d *DB) error {
+		return d.finalizeFormatVersUpgrade(FormatDataBlockHashIndex)
+	},
+	FormatColumnarDataBlocks: func(d *DB) error {
+		return d.finalizeFormatVersUpgrade(FormatColumnarDataBlocks)
+	},
 }
 
 const formatVersionMarkerName = `format-version`
@@ -24,11 +24,14 @@ func TestFormatMajorVersionStableValues(t *testing.T) {
 	require.Equal(t, FormatDeleteSizedAndObsolete, FormatMajorVersion(15))
 	require.Equal(t, FormatVirtualSSTables, FormatMajorVersion(16))
 	require.Equal(t, FormatSyntheticPrefixes, FormatMajorVersion(17))
+	require.Equal(t, FormatWALCompression, FormatMajorVersion(18))
+	require.Equal(t, FormatDataBlockHashIndex, FormatMajorVersion(19))
+	require.Equal(t, FormatColumnarDataBlocks, FormatMajorVersion(20))
 
 	// When we add a new version, we should add a check for the new version in
 	// addition to updating these expected values.
-	require.Equal(t, FormatNewest, FormatMajorVersion(17))
-	require.Equal(t, internalFormatNewest, FormatMajorVersion(17))
+	require.Equal(t, FormatNewest, FormatMajorVersion(20))
+	require.Equal(t, internalFormatNewest, FormatMajorVersion(20))
 }
 
 func TestFormatMajorVersion_MigrationDefined(t *testing.T) {
@@ -53,6 +56,12 @@ func TestRatchetFormat(t *testing.T) {
 	require.Equal(t, FormatVirtualSSTables, d.FormatMajorVersion())
 	require.NoError(t, d.RatchetFormatMajorVersion(FormatSyntheticPrefixes))
 	require.Equal(t, FormatSyntheticPrefixes, d.FormatMajorVersion())
+	require.NoError(t, d.RatchetFormatMajorVersion(FormatWALCompression))
+	require.Equal(t, FormatWALCompression, d.FormatMajorVersion())
+	require.NoError(t, d.RatchetFormatMajorVersion(FormatDataBlockHashIndex))
+	require.Equal(t, FormatDataBlockHashIndex, d.FormatMajorVersion())
+	require.NoError(t, d.RatchetFormatMajorVersion(FormatColumnarDataBlocks))
+	require.Equal(t, FormatColumnarDataBlocks, d.FormatMajorVersion())
 
 	require.NoError(t, d.Close())
 
@@ -207,6 +216,9 @@ func TestFormatMajorVersions_TableFormat(t *testing.T) {
 		FormatDeleteSizedAndObsolete:     {sstable.TableFormatPebblev1, sstable.TableFormatPebblev4},
 		FormatVirtualSSTables:            {sstable.TableFormatPebblev1, sstable.TableFormatPebblev4},
 		FormatSyntheticPrefixes:          {sstable.TableFormatPebblev1, sstable.TableFormatPebblev4},
+		FormatWALCompression:             {sstable.TableFormatPebblev1, sstable.TableFormatPebblev4},
+		FormatDataBlockHashIndex:         {sstable.TableFormatPebblev1, sstable.TableFormatPebblev5},
+		FormatColumnarDataBlocks:         {sstable.TableFormatPebblev1, sstable.TableFormatPebblev6},
 	}
 
 	// Valid versions.
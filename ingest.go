@@ -2210,7 +2210,7 @@ func (d *DB) ingestApply(
 			// to error out the whole compaction as we can't guarantee it hasn't/won't
 			// write a file overlapping with the excise span.
 			if exciseSpan.OverlapsInternalKeyRange(d.cmp, c.smallest, c.largest) {
-				c.cancel.Store(true)
+				c.setCancelled()
 			}
 			// Check if this compaction's inputs have been replaced due to an
 			// ingest-time split. In that case, cancel the compaction as a newly picked
@@ -2223,7 +2223,7 @@ func (d *DB) ingestApply(
 					iter := c.inputs[i].files.Iter()
 					for f := iter.First(); f != nil; f = iter.Next() {
 						if _, ok := replacedFiles[f.FileNum]; ok {
-							c.cancel.Store(true)
+							c.setCancelled()
 							break
 						}
 					}
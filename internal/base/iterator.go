@@ -361,6 +361,17 @@ type InternalIteratorStats struct {
 	// TODO(sumeer): this currently excludes the time spent in Reader creation,
 	// and in reading the rangedel and rangekey blocks. Fix that.
 	BlockReadDuration time.Duration
+	// PrefetchedBlocks is the number of data blocks that were scheduled to be
+	// read asynchronously ahead of forward iteration. See
+	// IterOptions.PrefetchBlocks in the pebble package. The bytes of a
+	// prefetched block are included in BlockBytes once the block is used.
+	PrefetchedBlocks uint64
+	// PrefetchHits is the number of prefetched blocks that were used by the
+	// iteration and had been read by the time they were needed.
+	PrefetchHits uint64
+	// PrefetchWaits is the number of prefetched blocks that were used by the
+	// iteration but were still being read when they were needed.
+	PrefetchWaits uint64
 	// The following can repeatedly count the same points if they are iterated
 	// over multiple times. Additionally, they may count a point twice when
 	// switching directions. The latter could be improved if needed.
@@ -404,6 +415,9 @@ func (s *InternalIteratorStats) Merge(from InternalIteratorStats) {
 	s.BlockBytes += from.BlockBytes
 	s.BlockBytesInCache += from.BlockBytesInCache
 	s.BlockReadDuration += from.BlockReadDuration
+	s.PrefetchedBlocks += from.PrefetchedBlocks
+	s.PrefetchHits += from.PrefetchHits
+	s.PrefetchWaits += from.PrefetchWaits
 	s.KeyBytes += from.KeyBytes
 	s.ValueBytes += from.ValueBytes
 	s.PointCount += from.PointCount
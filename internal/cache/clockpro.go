@@ -52,6 +52,47 @@ func (k key) String() string {
 	return fmt.Sprintf("%d/%d/%d", k.id, k.fileNum, k.offset)
 }
 
+// Priority is the priority with which a value is added to the cache.
+type Priority int8
+
+const (
+	// NormalPriority is the priority used by Cache.Set. Normal priority values
+	// are subject to the regular Clock-PRO replacement policy.
+	NormalPriority Priority = iota
+	// HighPriority is intended for values, such as sstable index and filter
+	// blocks, that are needed by most reads of a file. High priority values are
+	// protected from eviction as long as the total size of the high priority
+	// values in a shard fits within the shard's high priority pool (see
+	// Cache.SetHighPriorityFraction). Beyond that, they are treated as normal
+	// priority values.
+	HighPriority
+	// LowPriority is intended for values that are unlikely to be reused, such
+	// as the data blocks read by a large scan. Low priority values are the
+	// first to be considered for eviction. Unless it is accessed again, a low
+	// priority value is removed from the cache entirely when it is evicted,
+	// rather than being retained as a test page, and it is not admitted to the
+	// cache's secondary tier.
+	LowPriority
+)
+
+// String implements fmt.Stringer.
+func (p Priority) String() string {
+	switch p {
+	case NormalPriority:
+		return "normal"
+	case HighPriority:
+		return "high"
+	case LowPriority:
+		return "low"
+	default:
+		return fmt.Sprintf("Priority(%d)", p)
+	}
+}
+
+// DefaultHighPriorityFraction is the default fraction of the cache capacity
+// reserved for high priority values.
+const DefaultHighPriorityFraction = 0.5
+
 // Handle provides a strong reference to a value in the cache. The reference
 // does not pin the value in the cache, but it does prevent the underlying byte
 // slice from being reused.
@@ -69,6 +110,18 @@ func (h Handle) Get() []byte {
 	return nil
 }
 
+// NewUncachedHandle returns a Handle for a value that has not been, and will
+// not be, added to the cache. The value must have been allocated by Alloc, and
+// ownership of it is transferred to the Handle: releasing the Handle frees the
+// value. This allows callers that read values without caching them to use the
+// same code paths as for cached values.
+func NewUncachedHandle(value *Value) Handle {
+	if n := value.refs(); n != 1 {
+		panic(fmt.Sprintf("pebble: Value has been added to the cache: refs=%d", n))
+	}
+	return Handle{value: value}
+}
+
 // Release releases the reference to the cache entry.
 func (h Handle) Release() {
 	h.value.release()
@@ -81,10 +134,15 @@ type shard struct {
 	mu sync.RWMutex
 
 	reservedSize int64
-	maxSize      int64
-	coldTarget   int64
-	blocks       robinHoodMap // fileNum+offset -> block
-	files        robinHoodMap // fileNum -> list of blocks
+	// maxSize is the current capacity of the shard. When the capacity is
+	// reduced by Cache.SetCapacity, maxSize is lowered towards capacity
+	// incrementally, so that the evictions are spread across many
+	// acquisitions of the shard mutex.
+	maxSize    int64
+	capacity   int64
+	coldTarget int64
+	blocks     robinHoodMap // fileNum+offset -> block
+	files      robinHoodMap // fileNum -> list of blocks
 
 	// The blocks and files maps store values in manually managed memory that is
 	// invisible to the Go GC. This is fine for Value and entry objects that are
@@ -101,6 +159,12 @@ type shard struct {
 	sizeCold int64
 	sizeTest int64
 
+	// sizeHigh is the size of the resident (hot or cold) high priority
+	// entries. High priority entries are protected from eviction while
+	// sizeHigh is within highFraction of the target size.
+	sizeHigh     int64
+	highFraction float64
+
 	// The count fields are used exclusively for asserting expectations.
 	// We've seen infinite looping (cockroachdb/cockroach#70154) that
 	// could be explained by a corrupted sizeCold. Through asserting on
@@ -109,6 +173,15 @@ type shard struct {
 	countHot  int64
 	countCold int64
 	countTest int64
+
+	// tier is the cache's secondary tier, if any. Blocks evicted from the
+	// shard are offered to it.
+	tier *tier
+
+	// evictedBytes is the total size of the values evicted from the shard to
+	// make room for other values. It does not include values removed by
+	// Delete or EvictFile.
+	evictedBytes atomic.Int64
 }
 
 func (c *shard) Get(id uint64, fileNum base.DiskFileNum, offset uint64) Handle {
@@ -129,7 +202,9 @@ func (c *shard) Get(id uint64, fileNum base.DiskFileNum, offset uint64) Handle {
 	return Handle{value: value}
 }
 
-func (c *shard) Set(id uint64, fileNum base.DiskFileNum, offset uint64, value *Value) Handle {
+func (c *shard) Set(
+	id uint64, fileNum base.DiskFileNum, offset uint64, value *Value, pri Priority,
+) Handle {
 	if n := value.refs(); n != 1 {
 		panic(fmt.Sprintf("pebble: Value has already been added to the cache: refs=%d", n))
 	}
@@ -144,11 +219,13 @@ func (c *shard) Set(id uint64, fileNum base.DiskFileNum, offset uint64, value *V
 	case e == nil:
 		// no cache entry? add it
 		e = newEntry(c, k, int64(len(value.buf)))
+		e.priority = pri
 		e.setValue(value)
 		if c.metaAdd(k, e) {
 			value.ref.trace("add-cold")
 			c.sizeCold += e.size
 			c.countCold++
+			c.addHigh(e, e.size)
 		} else {
 			value.ref.trace("skip-cold")
 			e.free()
@@ -159,8 +236,11 @@ func (c *shard) Set(id uint64, fileNum base.DiskFileNum, offset uint64, value *V
 		// cache entry was a hot or cold page
 		e.setValue(value)
 		e.referenced.Store(true)
+		c.addHigh(e, -e.size)
+		e.priority = pri
 		delta := int64(len(value.buf)) - e.size
 		e.size = int64(len(value.buf))
+		c.addHigh(e, e.size)
 		if e.ptype == etHot {
 			value.ref.trace("add-hot")
 			c.sizeHot += delta
@@ -185,11 +265,25 @@ func (c *shard) Set(id uint64, fileNum base.DiskFileNum, offset uint64, value *V
 
 		e.referenced.Store(false)
 		e.setValue(value)
-		e.ptype = etHot
+		e.priority = pri
+		if pri == LowPriority {
+			// A low priority value does not benefit from the test page, and is
+			// added as a cold page like a new entry.
+			e.ptype = etCold
+		} else {
+			e.ptype = etHot
+		}
 		if c.metaAdd(k, e) {
-			value.ref.trace("add-hot")
-			c.sizeHot += e.size
-			c.countHot++
+			if e.ptype == etHot {
+				value.ref.trace("add-hot")
+				c.sizeHot += e.size
+				c.countHot++
+			} else {
+				value.ref.trace("add-cold")
+				c.sizeCold += e.size
+				c.countCold++
+			}
+			c.addHigh(e, e.size)
 		} else {
 			value.ref.trace("skip-hot")
 			e.free()
@@ -210,6 +304,9 @@ func (c *shard) checkConsistency() {
 	case c.sizeHot < 0 || c.sizeCold < 0 || c.sizeTest < 0 || c.countHot < 0 || c.countCold < 0 || c.countTest < 0:
 		panic(fmt.Sprintf("pebble: unexpected negative: %d (%d bytes) hot, %d (%d bytes) cold, %d (%d bytes) test",
 			c.countHot, c.sizeHot, c.countCold, c.sizeCold, c.countTest, c.sizeTest))
+	case c.sizeHigh < 0 || c.sizeHigh > c.sizeHot+c.sizeCold:
+		panic(fmt.Sprintf("pebble: unexpected high priority size %d (hot %d, cold %d)",
+			c.sizeHigh, c.sizeHot, c.sizeCold))
 	case c.sizeHot > 0 && c.countHot == 0:
 		panic(fmt.Sprintf("pebble: mismatch %d hot size, %d hot count", c.sizeHot, c.countHot))
 	case c.sizeCold > 0 && c.countCold == 0:
@@ -344,6 +441,47 @@ func (c *shard) Size() int64 {
 	return size
 }
 
+// appendResident appends the keys of the resident entries belonging to the
+// specified cache ID to hot and cold, according to the entries' type.
+func (c *shard) appendResident(id uint64, hot, cold []BlockKey) ([]BlockKey, []BlockKey) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.handHot == nil {
+		return hot, cold
+	}
+	e := c.handHot
+	for {
+		if e.key.id == id {
+			k := BlockKey{FileNum: e.key.fileNum, Offset: e.key.offset}
+			switch e.ptype {
+			case etHot:
+				hot = append(hot, k)
+			case etCold:
+				cold = append(cold, k)
+			}
+		}
+		if e = e.next(); e == c.handHot {
+			break
+		}
+	}
+	return hot, cold
+}
+
+// addHigh adjusts sizeHigh by delta if e is a high priority entry.
+func (c *shard) addHigh(e *entry, delta int64) {
+	if e.priority == HighPriority {
+		c.sizeHigh += delta
+	}
+}
+
+// protected returns true if the entry is a high priority entry that is
+// protected from eviction because the high priority entries fit within the
+// high priority pool.
+func (c *shard) protected(e *entry) bool {
+	return e.priority == HighPriority &&
+		c.sizeHigh <= int64(float64(c.targetSize())*c.highFraction)
+}
+
 func (c *shard) targetSize() int64 {
 	target := c.maxSize - c.reservedSize
 	// Always return a positive integer for targetSize. This is so that we don't
@@ -376,6 +514,11 @@ func (c *shard) metaAdd(key key, e *entry) bool {
 		c.handHot = e
 		c.handCold = e
 		c.handTest = e
+	} else if e.priority == LowPriority {
+		// Low priority entries are linked in front of the cold hand, making them
+		// the first entries considered for eviction.
+		c.handCold.link(e)
+		c.handCold = e
 	} else {
 		c.handHot.link(e)
 	}
@@ -499,9 +642,11 @@ func (c *shard) metaEvict(e *entry) (evictedValue *Value) {
 	case etHot:
 		c.sizeHot -= e.size
 		c.countHot--
+		c.addHigh(e, -e.size)
 	case etCold:
 		c.sizeCold -= e.size
 		c.countCold--
+		c.addHigh(e, -e.size)
 	case etTest:
 		c.sizeTest -= e.size
 		c.countTest--
@@ -518,6 +663,60 @@ func (c *shard) evict() {
 	}
 }
 
+// setCapacity sets the capacity of the shard. Growing the shard takes effect
+// immediately. Shrinking the shard lowers maxSize in steps of at most
+// shrinkStep bytes, evicting the values that no longer fit after each step and
+// dropping the shard mutex in between, so that concurrent operations on the
+// shard are not stalled while a large amount of data is evicted.
+func (c *shard) setCapacity(capacity int64) {
+	// shrinkStep bounds the number of bytes evicted per acquisition of the
+	// shard mutex.
+	const shrinkStep = 1 << 20 // 1 MiB
+
+	c.mu.Lock()
+	c.capacity = capacity
+	if c.maxSize < capacity {
+		c.maxSize = capacity
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+
+	for {
+		done := func() bool {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			// NB: a concurrent call may have changed the capacity.
+			if c.maxSize <= c.capacity {
+				return true
+			}
+			c.maxSize -= shrinkStep
+			if c.maxSize < c.capacity {
+				c.maxSize = c.capacity
+			}
+			// Changing c.maxSize decreases targetSize, and coldTarget must
+			// remain in the range [0, targetSize].
+			if targetSize := c.targetSize(); c.coldTarget > targetSize {
+				c.coldTarget = targetSize
+			}
+			c.evict()
+			// Trim the test pages as well, which would otherwise only happen as
+			// values are evicted.
+			for c.targetSize() < c.sizeTest && c.handTest != nil {
+				c.runHandTest()
+			}
+			c.checkConsistency()
+			return c.maxSize <= c.capacity
+		}()
+		if done {
+			return
+		}
+		// Sched switch to give another goroutine an opportunity to acquire the
+		// shard mutex.
+		runtime.Gosched()
+	}
+}
+
 func (c *shard) runHandCold(countColdDebug, sizeColdDebug int64) {
 	// countColdDebug and sizeColdDebug should equal c.countCold and
 	// c.sizeCold. They're parameters only to aid in debugging of
@@ -531,14 +730,30 @@ func (c *shard) runHandCold(countColdDebug, sizeColdDebug int64) {
 
 	e := c.handCold
 	if e.ptype == etCold {
-		if e.referenced.Load() {
+		if referenced := e.referenced.Load(); referenced || c.protected(e) {
 			e.referenced.Store(false)
+			if referenced && e.priority == LowPriority {
+				// The value was accessed again after being added at low
+				// priority. It is no longer treated as such.
+				e.priority = NormalPriority
+			}
 			e.ptype = etHot
 			c.sizeCold -= e.size
 			c.countCold--
 			c.sizeHot += e.size
 			c.countHot++
+		} else if e.priority == LowPriority {
+			// Low priority entries are removed entirely, rather than becoming
+			// test pages, so that a subsequent access does not promote them to
+			// hot pages.
+			c.evictedBytes.Add(e.size)
+			c.metaEvict(e).release()
 		} else {
+			c.evictedBytes.Add(e.size)
+			if c.tier != nil {
+				c.tier.admit(e.key, e.peekValue())
+			}
+			c.addHigh(e, -e.size)
 			e.setValue(nil)
 			e.ptype = etTest
 			c.sizeCold -= e.size
@@ -661,10 +876,12 @@ type Metrics struct {
 // used in combination by specifying `-tags invariants,tracing`. Note that
 // "tracing" produces a significant slowdown, while "invariants" does not.
 type Cache struct {
-	refs    atomic.Int64
-	maxSize int64
-	idAlloc atomic.Uint64
-	shards  []shard
+	refs     atomic.Int64
+	maxSize  atomic.Int64
+	idAlloc  atomic.Uint64
+	shards   []shard
+	tier     *tier
+	pressure atomic.Pointer[pressureListener]
 
 	// Traces recorded by Cache.trace. Used for debugging.
 	tr struct {
@@ -713,18 +930,43 @@ func New(size int64) *Cache {
 	return newShards(size, m)
 }
 
+// NewTiered creates a new cache of the specified size, with a secondary tier
+// stored on a local file system as configured by opts. Blocks evicted from
+// the in-memory cache are admitted to the secondary tier, and lookups that
+// miss in memory are satisfied from the secondary tier when possible, in
+// which case the block is promoted back into memory. See New for the
+// reference counting semantics of the returned cache.
+func NewTiered(size int64, opts *TierOptions) (*Cache, error) {
+	t, err := openTier(opts)
+	if err != nil {
+		return nil, err
+	}
+	c := New(size)
+	c.setTier(t)
+	return c, nil
+}
+
+func (c *Cache) setTier(t *tier) {
+	c.tier = t
+	for i := range c.shards {
+		c.shards[i].tier = t
+	}
+}
+
 func newShards(size int64, shards int) *Cache {
 	c := &Cache{
-		maxSize: size,
-		shards:  make([]shard, shards),
+		shards: make([]shard, shards),
 	}
+	c.maxSize.Store(size)
 	c.refs.Store(1)
 	c.idAlloc.Store(1)
 	c.trace("alloc", c.refs.Load())
 	for i := range c.shards {
 		c.shards[i] = shard{
-			maxSize:    size / int64(len(c.shards)),
-			coldTarget: size / int64(len(c.shards)),
+			maxSize:      size / int64(len(c.shards)),
+			capacity:     size / int64(len(c.shards)),
+			coldTarget:   size / int64(len(c.shards)),
+			highFraction: DefaultHighPriorityFraction,
 		}
 		if entriesGoAllocated {
 			c.shards[i].entries = make(map[*entry]struct{})
@@ -801,13 +1043,24 @@ func (c *Cache) Unref() {
 		for i := range c.shards {
 			c.shards[i].Free()
 		}
+		if c.tier != nil {
+			c.tier.close()
+		}
 	}
 }
 
 // Get retrieves the cache value for the specified file and offset, returning
 // nil if no value is present.
 func (c *Cache) Get(id uint64, fileNum base.DiskFileNum, offset uint64) Handle {
-	return c.getShard(id, fileNum, offset).Get(id, fileNum, offset)
+	s := c.getShard(id, fileNum, offset)
+	h := s.Get(id, fileNum, offset)
+	if h.value == nil && c.tier != nil {
+		if v := c.tier.get(key{fileKey{id, fileNum}, offset}); v != nil {
+			h = s.Set(id, fileNum, offset, v, NormalPriority)
+			c.maybeReportEvictionPressure()
+		}
+	}
+	return h
 }
 
 // Set sets the cache value for the specified file and offset, overwriting an
@@ -815,12 +1068,43 @@ func (c *Cache) Get(id uint64, fileNum base.DiskFileNum, offset uint64) Handle {
 // retrieval of the cached value than Get (lock-free and avoidance of the map
 // lookup). The value must have been allocated by Cache.Alloc.
 func (c *Cache) Set(id uint64, fileNum base.DiskFileNum, offset uint64, value *Value) Handle {
-	return c.getShard(id, fileNum, offset).Set(id, fileNum, offset, value)
+	h := c.getShard(id, fileNum, offset).Set(id, fileNum, offset, value, NormalPriority)
+	c.maybeReportEvictionPressure()
+	return h
+}
+
+// SetWithPriority is like Set, but adds the value with the specified
+// priority. See Priority.
+func (c *Cache) SetWithPriority(
+	id uint64, fileNum base.DiskFileNum, offset uint64, value *Value, pri Priority,
+) Handle {
+	h := c.getShard(id, fileNum, offset).Set(id, fileNum, offset, value, pri)
+	c.maybeReportEvictionPressure()
+	return h
+}
+
+// SetHighPriorityFraction sets the fraction of the cache capacity reserved for
+// high priority values. High priority values are protected from eviction as
+// long as their total size is within the reserved fraction. The fraction must
+// be in the range [0, 1). The default is DefaultHighPriorityFraction.
+func (c *Cache) SetHighPriorityFraction(f float64) {
+	if f < 0 || f >= 1 {
+		panic(fmt.Sprintf("pebble: invalid high priority fraction: %f", f))
+	}
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		s.highFraction = f
+		s.mu.Unlock()
+	}
 }
 
 // Delete deletes the cached value for the specified file and offset.
 func (c *Cache) Delete(id uint64, fileNum base.DiskFileNum, offset uint64) {
 	c.getShard(id, fileNum, offset).Delete(id, fileNum, offset)
+	if c.tier != nil {
+		c.tier.delete(key{fileKey{id, fileNum}, offset})
+	}
 }
 
 // EvictFile evicts all of the cache values for the specified file.
@@ -831,11 +1115,45 @@ func (c *Cache) EvictFile(id uint64, fileNum base.DiskFileNum) {
 	for i := range c.shards {
 		c.shards[i].EvictFile(id, fileNum)
 	}
+	if c.tier != nil {
+		c.tier.evictFile(key{fileKey{id, fileNum}, 0})
+	}
+}
+
+// EvictFileFromMemory evicts the in-memory cache values for the specified
+// file, leaving its blocks in the secondary tier. It is used when the owner of
+// the file is closed, so that the blocks may be persisted for its next
+// instance. See ClaimTierNamespace.
+func (c *Cache) EvictFileFromMemory(id uint64, fileNum base.DiskFileNum) {
+	if id == 0 {
+		panic("pebble: 0 cache ID is invalid")
+	}
+	for i := range c.shards {
+		c.shards[i].EvictFile(id, fileNum)
+	}
 }
 
 // MaxSize returns the max size of the cache.
 func (c *Cache) MaxSize() int64 {
-	return c.maxSize
+	return c.maxSize.Load()
+}
+
+// SetCapacity changes the max size of the cache. The capacity is divided
+// evenly among the cache's shards, as it is when the cache is created.
+// Increasing the capacity takes effect immediately. When the capacity is
+// decreased, the values that no longer fit are evicted incrementally, a small
+// batch at a time, so that concurrent readers are not stalled while a large
+// amount of memory is released. SetCapacity returns once the cache has been
+// shrunk to its new capacity.
+func (c *Cache) SetCapacity(size int64) {
+	if size < 0 {
+		panic(fmt.Sprintf("pebble: invalid cache capacity: %d", size))
+	}
+	c.maxSize.Store(size)
+	for i := range c.shards {
+		c.shards[i].setCapacity(size / int64(len(c.shards)))
+	}
+	c.maybeReportEvictionPressure()
 }
 
 // Size returns the current space used by the cache.
@@ -902,6 +1220,79 @@ func (c *Cache) Metrics() Metrics {
 	return m
 }
 
+// BlockKey identifies a block within the namespace of a cache ID.
+type BlockKey struct {
+	FileNum base.DiskFileNum
+	Offset  uint64
+}
+
+// ResidentBlocks returns the keys of the blocks belonging to the specified
+// cache ID that are resident in memory. The blocks in the hot set of each
+// shard are returned before the blocks in the cold set, so that a prefix of
+// the result holds the blocks that are most worth retaining. Test pages, which
+// only record the key of a recently evicted block, are not included.
+func (c *Cache) ResidentBlocks(id uint64) []BlockKey {
+	var hot, cold []BlockKey
+	for i := range c.shards {
+		hot, cold = c.shards[i].appendResident(id, hot, cold)
+	}
+	return append(hot, cold...)
+}
+
+// TierMetrics returns the metrics for the cache's secondary tier. The zero
+// value is returned if the cache does not have a secondary tier.
+func (c *Cache) TierMetrics() TierMetrics {
+	if c.tier == nil {
+		return TierMetrics{}
+	}
+	return c.tier.metrics()
+}
+
+// HasTier returns true if the cache has a secondary tier.
+func (c *Cache) HasTier() bool {
+	return c.tier != nil
+}
+
+// ClaimTierNamespace associates the specified cache ID with a namespace that
+// identifies the owner of the ID's blocks across restarts, such as the
+// directory of a DB. The blocks of the ID in the secondary tier are persisted
+// under the namespace when the cache is closed. The blocks persisted under the
+// namespace by a previous instance of the tier, or by a previous owner of the
+// namespace that has released it, are handed to the ID, except for the blocks
+// of the files for which live returns false. Blocks are identified by their
+// file number and offset, so a namespace must only ever be claimed by the
+// owners of a single set of files, in which a file number always refers to the
+// same file; a DB's namespace includes an identity that's unique to the DB.
+//
+// Returns false if the cache does not have a secondary tier or if the
+// namespace is claimed by another ID, in which case the blocks of the ID are
+// not persisted.
+func (c *Cache) ClaimTierNamespace(
+	id uint64, namespace string, live func(base.DiskFileNum) bool,
+) bool {
+	if c.tier == nil {
+		return false
+	}
+	return c.tier.claimNamespace(id, namespace, live)
+}
+
+// ReleaseTierNamespace releases the namespace claimed by the specified cache
+// ID, so that it may be claimed by another ID. The blocks of the ID remain
+// persisted under the namespace.
+func (c *Cache) ReleaseTierNamespace(id uint64) {
+	if c.tier != nil {
+		c.tier.releaseNamespace(id)
+	}
+}
+
+// WaitForTierWritesForTesting waits for the writes of blocks that have been
+// evicted to the cache's secondary tier to complete.
+func (c *Cache) WaitForTierWritesForTesting() {
+	if c.tier != nil {
+		c.tier.waitForWrites()
+	}
+}
+
 // NewID returns a new ID to be used as a namespace for cached file
 // blocks.
 func (c *Cache) NewID() uint64 {
@@ -277,3 +277,240 @@ func TestReserveColdTarget(t *testing.T) {
 		t.Fatalf("expected positive cache size %d, but found %d", 48, cache.Size())
 	}
 }
+
+func TestCachePriority(t *testing.T) {
+	// run adds 40 high priority values to a cache of size 100, and then
+	// repeatedly accesses a working set of normal priority values that doesn't
+	// fit in the cache. It returns the number of high priority values that
+	// remain in the cache.
+	run := func(highFraction float64) int {
+		cache := newShards(100, 1)
+		defer cache.Unref()
+		cache.SetHighPriorityFraction(highFraction)
+
+		for i := 0; i < 40; i++ {
+			cache.SetWithPriority(1, base.DiskFileNum(0), uint64(i), testValue(cache, "a", 1), HighPriority).Release()
+		}
+		for j := 0; j < 3; j++ {
+			for i := 0; i < 500; i++ {
+				h := cache.Get(1, base.DiskFileNum(1), uint64(i))
+				if h.Get() == nil {
+					cache.Set(1, base.DiskFileNum(1), uint64(i), testValue(cache, "a", 1)).Release()
+				}
+				h.Release()
+				cache.Get(1, base.DiskFileNum(1), uint64(i)).Release()
+			}
+		}
+		var n int
+		for i := 0; i < 40; i++ {
+			h := cache.Get(1, base.DiskFileNum(0), uint64(i))
+			if h.Get() != nil {
+				n++
+			}
+			h.Release()
+		}
+		return n
+	}
+
+	// The high priority values fit within the high priority pool, and are
+	// protected from eviction.
+	require.Equal(t, 40, run(0.5))
+	// Without a high priority pool, the high priority values are evicted like
+	// normal priority values.
+	require.Equal(t, 0, run(0))
+}
+
+func TestCacheLowPriority(t *testing.T) {
+	// run adds 80 normal priority values to a cache of size 100 and accesses
+	// them repeatedly, and then scans through 1000 values added with the
+	// specified priority. It returns the number of the original values that
+	// remain in the cache, and the number of test pages.
+	run := func(pri Priority) (int, int64) {
+		cache := newShards(100, 1)
+		defer cache.Unref()
+
+		for i := 0; i < 80; i++ {
+			cache.Set(1, base.DiskFileNum(0), uint64(i), testValue(cache, "a", 1)).Release()
+		}
+		for j := 0; j < 3; j++ {
+			for i := 0; i < 80; i++ {
+				cache.Get(1, base.DiskFileNum(0), uint64(i)).Release()
+			}
+		}
+		for i := 0; i < 1000; i++ {
+			cache.SetWithPriority(1, base.DiskFileNum(1), uint64(i), testValue(cache, "a", 1), pri).Release()
+		}
+		var n int
+		for i := 0; i < 80; i++ {
+			h := cache.Get(1, base.DiskFileNum(0), uint64(i))
+			if h.Get() != nil {
+				n++
+			}
+			h.Release()
+		}
+		return n, countTestPages(&cache.shards[0], base.DiskFileNum(1))
+	}
+
+	// A scan at normal priority evicts the working set and leaves behind test
+	// pages.
+	n, testPages := run(NormalPriority)
+	require.Less(t, n, 10)
+	require.NotZero(t, testPages)
+	// A scan at low priority leaves the working set in place, without leaving
+	// behind test pages.
+	n, testPages = run(LowPriority)
+	require.Greater(t, n, 70)
+	require.Zero(t, testPages)
+
+	// A low priority value that is accessed again is retained like a normal
+	// priority value.
+	cache := newShards(100, 1)
+	defer cache.Unref()
+	cache.SetWithPriority(1, base.DiskFileNum(0), 0, testValue(cache, "a", 1), LowPriority).Release()
+	cache.Get(1, base.DiskFileNum(0), 0).Release()
+	for i := 0; i < 50; i++ {
+		cache.SetWithPriority(1, base.DiskFileNum(1), uint64(i), testValue(cache, "a", 1), LowPriority).Release()
+	}
+	h := cache.Get(1, base.DiskFileNum(0), 0)
+	require.NotNil(t, h.Get())
+	h.Release()
+}
+
+// countTestPages returns the number of test pages in the shard for the
+// specified file.
+func countTestPages(s *shard, fileNum base.DiskFileNum) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var n int64
+	for e := s.handHot.next(); e != nil; e = e.next() {
+		if e.ptype == etTest && e.key.fileNum == fileNum {
+			n++
+		}
+		if e == s.handHot {
+			break
+		}
+	}
+	return n
+}
+
+func TestCachePriorityRandomized(t *testing.T) {
+	seed := uint64(time.Now().UnixNano())
+	t.Logf("seed %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+
+	cache := newShards(1000, 2)
+	defer cache.Unref()
+	cache.SetHighPriorityFraction(rng.Float64() * 0.9)
+
+	for i := 0; i < 100000; i++ {
+		offset := uint64(rng.Intn(2000))
+		switch rng.Intn(4) {
+		case 0:
+			cache.Get(1, base.DiskFileNum(0), offset).Release()
+		case 1:
+			cache.Delete(1, base.DiskFileNum(0), offset)
+		default:
+			pri := Priority(rng.Intn(3))
+			v := testValue(cache, "a", 1+rng.Intn(20))
+			cache.SetWithPriority(1, base.DiskFileNum(0), offset, v, pri).Release()
+		}
+	}
+	for i := range cache.shards {
+		cache.shards[i].mu.Lock()
+		cache.shards[i].checkConsistency()
+		cache.shards[i].mu.Unlock()
+	}
+	// NB: The cache may exceed its capacity by up to the size of the most
+	// recently added value in each shard.
+	require.LessOrEqual(t, cache.Size(), int64(1000+2*20))
+}
+
+func TestCacheResidentBlocks(t *testing.T) {
+	cache := newShards(100, 2)
+	defer cache.Unref()
+
+	require.Empty(t, cache.ResidentBlocks(1))
+
+	// Add values for two cache IDs. Only the values for the requested ID are
+	// returned.
+	for i := 0; i < 10; i++ {
+		cache.Set(1, base.DiskFileNum(1), uint64(i), testValue(cache, "a", 1)).Release()
+		cache.Set(2, base.DiskFileNum(1), uint64(i), testValue(cache, "a", 1)).Release()
+	}
+	keys := cache.ResidentBlocks(1)
+	require.Len(t, keys, 10)
+	seen := make(map[uint64]bool)
+	for _, k := range keys {
+		require.Equal(t, base.DiskFileNum(1), k.FileNum)
+		seen[k.Offset] = true
+	}
+	require.Len(t, seen, 10)
+
+	// Evicted values are not returned, even though their test pages remain.
+	for i := 0; i < 500; i++ {
+		cache.Set(2, base.DiskFileNum(2), uint64(i), testValue(cache, "a", 1)).Release()
+	}
+	for _, k := range cache.ResidentBlocks(1) {
+		h := cache.Get(1, k.FileNum, k.Offset)
+		require.NotNil(t, h.Get())
+		h.Release()
+	}
+	// Every value has size 1, so the cache size is the number of resident values.
+	require.Len(t, cache.ResidentBlocks(2), int(cache.Size())-len(cache.ResidentBlocks(1)))
+}
+
+func TestCacheSetCapacity(t *testing.T) {
+	const shards = 4
+	cache := newShards(1<<20, shards)
+	defer cache.Unref()
+
+	const valueSize = 1 << 10
+	fill := func(fileNum base.DiskFileNum) {
+		for i := 0; i < 2<<10; i++ {
+			cache.Set(1, fileNum, uint64(i), testValue(cache, "a", valueSize)).Release()
+		}
+	}
+	fill(1)
+	require.LessOrEqual(t, cache.Size(), int64(1<<20))
+	require.Greater(t, cache.Size(), int64(768<<10))
+
+	// Shrinking the cache evicts values until the cache fits in its new
+	// capacity, while concurrent readers and writers continue to use it.
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			offset := uint64(i % (2 << 10))
+			h := cache.Get(1, base.DiskFileNum(2), offset)
+			if h.Get() == nil {
+				cache.Set(1, base.DiskFileNum(2), offset, testValue(cache, "b", valueSize)).Release()
+			}
+			h.Release()
+		}
+	}()
+	cache.SetCapacity(128 << 10)
+	close(stop)
+	wg.Wait()
+	require.Equal(t, int64(128<<10), cache.MaxSize())
+	require.LessOrEqual(t, cache.Size(), int64(128<<10))
+	for i := range cache.shards {
+		s := &cache.shards[i]
+		s.mu.RLock()
+		require.Equal(t, int64(128<<10)/shards, s.maxSize)
+		require.LessOrEqual(t, s.sizeTest, s.targetSize())
+		s.mu.RUnlock()
+	}
+
+	// Growing the cache allows it to hold more values.
+	cache.SetCapacity(512 << 10)
+	fill(3)
+	require.LessOrEqual(t, cache.Size(), int64(512<<10))
+	require.Greater(t, cache.Size(), int64(384<<10))
+}
@@ -54,8 +54,9 @@ type entry struct {
 		next *entry
 		prev *entry
 	}
-	size  int64
-	ptype entryType
+	size     int64
+	ptype    entryType
+	priority Priority
 	// referenced is atomically set to indicate that this entry has been accessed
 	// since the last time one of the clock hands swept it.
 	referenced atomic.Bool
@@ -0,0 +1,137 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EvictionPressureInfo describes a change in the eviction pressure of a
+// cache. The eviction pressure is the rate at which values are evicted from the
+// cache to make room for other values, expressed as the fraction of the
+// cache's capacity evicted per second. For example, a pressure of 0.5 means
+// that half of the cache's contents is replaced every second.
+type EvictionPressureInfo struct {
+	// Capacity is the capacity of the cache at the end of the interval.
+	Capacity int64
+	// EvictedBytes is the number of bytes evicted during the interval.
+	EvictedBytes int64
+	// Interval is the duration over which the pressure was measured.
+	Interval time.Duration
+	// Pressure is the eviction pressure measured over the interval.
+	Pressure float64
+	// Threshold is the threshold that the pressure crossed.
+	Threshold float64
+	// Above is true if the pressure rose to or above the threshold, and false
+	// if it fell back below it.
+	Above bool
+}
+
+func (i EvictionPressureInfo) String() string {
+	dir := "below"
+	if i.Above {
+		dir = "above"
+	}
+	return fmt.Sprintf("block cache eviction pressure %.3f/s %s threshold %.3f/s: %d bytes evicted in %s (capacity %d)",
+		i.Pressure, dir, i.Threshold, i.EvictedBytes, i.Interval, i.Capacity)
+}
+
+type pressureListener struct {
+	threshold float64
+	interval  time.Duration
+	fn        func(EvictionPressureInfo)
+	epoch     time.Time
+
+	// windowStart is the start of the current measurement window, as the
+	// number of nanoseconds since epoch.
+	windowStart atomic.Int64
+
+	mu struct {
+		sync.Mutex
+		// evicted is the total number of bytes evicted by the cache at the
+		// start of the current window.
+		evicted int64
+		above   bool
+	}
+}
+
+// SetEvictionPressureListener registers fn to be called when the eviction
+// pressure of the cache crosses threshold, in either direction. The pressure
+// is measured over consecutive windows of the specified interval, and is
+// evaluated as values are added to the cache and when the cache's capacity is
+// changed. fn is called synchronously by the goroutine that triggered the
+// evaluation, without any cache locks held, and should return quickly.
+// Passing a nil fn removes the listener.
+//
+// A cache has a single listener, which replaces any previous one. The returned
+// function removes the listener unless it has since been replaced.
+func (c *Cache) SetEvictionPressureListener(
+	threshold float64, interval time.Duration, fn func(EvictionPressureInfo),
+) (remove func()) {
+	if fn == nil {
+		c.pressure.Store(nil)
+		return func() {}
+	}
+	if interval <= 0 {
+		panic(fmt.Sprintf("pebble: invalid eviction pressure interval: %s", interval))
+	}
+	l := &pressureListener{
+		threshold: threshold,
+		interval:  interval,
+		fn:        fn,
+		epoch:     time.Now(),
+	}
+	l.mu.evicted = c.evictedBytes()
+	c.pressure.Store(l)
+	return func() { c.pressure.CompareAndSwap(l, nil) }
+}
+
+// evictedBytes returns the total size of the values evicted from the cache to
+// make room for other values.
+func (c *Cache) evictedBytes() int64 {
+	var n int64
+	for i := range c.shards {
+		n += c.shards[i].evictedBytes.Load()
+	}
+	return n
+}
+
+func (c *Cache) maybeReportEvictionPressure() {
+	l := c.pressure.Load()
+	if l == nil {
+		return
+	}
+	now := int64(time.Since(l.epoch))
+	start := l.windowStart.Load()
+	if time.Duration(now-start) < l.interval || !l.windowStart.CompareAndSwap(start, now) {
+		return
+	}
+
+	l.mu.Lock()
+	evicted := c.evictedBytes()
+	info := EvictionPressureInfo{
+		Capacity:     c.MaxSize(),
+		EvictedBytes: evicted - l.mu.evicted,
+		Interval:     time.Duration(now - start),
+		Threshold:    l.threshold,
+	}
+	l.mu.evicted = evicted
+	capacity := info.Capacity
+	if capacity < 1 {
+		capacity = 1
+	}
+	info.Pressure = float64(info.EvictedBytes) / float64(capacity) / info.Interval.Seconds()
+	info.Above = info.Pressure >= l.threshold
+	changed := info.Above != l.mu.above
+	l.mu.above = info.Above
+	l.mu.Unlock()
+
+	if changed {
+		l.fn(info)
+	}
+}
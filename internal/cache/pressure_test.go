@@ -0,0 +1,68 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvictionPressure(t *testing.T) {
+	cache := newShards(100<<10, 1)
+	defer cache.Unref()
+
+	var events []EvictionPressureInfo
+	cache.SetEvictionPressureListener(1, time.Millisecond, func(info EvictionPressureInfo) {
+		events = append(events, info)
+	})
+
+	// Churn through the cache, replacing its contents many times a second.
+	var i int
+	for len(events) == 0 {
+		cache.Set(1, base.DiskFileNum(1), uint64(i), testValue(cache, "a", 1<<10)).Release()
+		i++
+	}
+	require.True(t, events[0].Above)
+	require.Greater(t, events[0].Pressure, 1.0)
+	require.NotZero(t, events[0].EvictedBytes)
+	require.Equal(t, int64(100<<10), events[0].Capacity)
+
+	// Once the churn stops, the pressure falls below the threshold. Growing the
+	// capacity doesn't evict anything, but triggers an evaluation.
+	time.Sleep(2 * time.Millisecond)
+	cache.SetCapacity(200 << 10)
+	time.Sleep(2 * time.Millisecond)
+	cache.SetCapacity(200 << 10)
+	require.Len(t, events, 2)
+	require.False(t, events[1].Above)
+	require.Zero(t, events[1].EvictedBytes)
+	require.Equal(t, int64(200<<10), events[1].Capacity)
+
+	// Removing the listener stops the events.
+	cache.SetEvictionPressureListener(0, 0, nil)
+	for j := 0; j < 1000; j++ {
+		cache.Set(1, base.DiskFileNum(1), uint64(i+j), testValue(cache, "a", 1<<10)).Release()
+	}
+	require.Len(t, events, 2)
+}
+
+func TestEvictionPressureListenerRemove(t *testing.T) {
+	cache := newShards(100<<10, 1)
+	defer cache.Unref()
+
+	remove := cache.SetEvictionPressureListener(1, time.Second, func(EvictionPressureInfo) {})
+	remove()
+	require.Nil(t, cache.pressure.Load())
+
+	// Removing a listener that was replaced leaves the new one in place.
+	remove = cache.SetEvictionPressureListener(1, time.Second, func(EvictionPressureInfo) {})
+	cache.SetEvictionPressureListener(2, time.Second, func(EvictionPressureInfo) {})
+	remove()
+	require.NotNil(t, cache.pressure.Load())
+	require.Equal(t, 2.0, cache.pressure.Load().threshold)
+}
@@ -0,0 +1,851 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/errors/oserror"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// TierOptions configures the secondary tier of a Cache. The secondary tier
+// stores blocks evicted from the in-memory cache in files on a local file
+// system, typically a small but fast local SSD, and is consulted when a block
+// is not found in memory. Blocks are stored exactly as they were added to the
+// in-memory cache, so the tier works for the blocks of any sstable regardless
+// of where the sstable itself is stored.
+type TierOptions struct {
+	// FS is the file system on which the tier's files are stored.
+	FS vfs.FS
+	// Dir is the directory in which the tier's files are stored. The directory
+	// is created if it does not exist.
+	//
+	// The tier persists across restarts. When the cache is closed, the index of
+	// the blocks in the tier is written to the directory alongside the tier's
+	// segments, and it is loaded and validated when the tier is next opened.
+	// Blocks are persisted under the namespace claimed by their owner with
+	// Cache.ClaimTierNamespace (a DB claims a namespace unique to it), keyed by
+	// the file number and offset of the block. Blocks of IDs without a
+	// namespace are discarded when the cache is closed. If the index is missing or corrupt,
+	// for example because the process crashed, the tier starts empty.
+	Dir string
+	// Size is the capacity of the tier in bytes.
+	Size int64
+	// SegmentSize is the size of each of the files making up the tier. Blocks
+	// are appended to the most recent segment, and space is reclaimed by
+	// deleting the oldest segment in its entirety. Defaults to the smaller of
+	// 64 MiB and Size/8.
+	SegmentSize int64
+	// WriteQueueDepth is the maximum number of evicted blocks waiting to be
+	// written to the tier. Blocks evicted while the queue is full are not
+	// admitted to the tier. Defaults to 64.
+	WriteQueueDepth int
+}
+
+// EnsureDefaults ensures that the default values for all of the options have
+// been initialized. It is valid to call EnsureDefaults on a nil receiver. A
+// non-nil result will always be returned.
+func (o *TierOptions) EnsureDefaults() *TierOptions {
+	if o == nil {
+		o = &TierOptions{}
+	}
+	if o.FS == nil {
+		o.FS = vfs.Default
+	}
+	if o.SegmentSize <= 0 {
+		o.SegmentSize = o.Size / 8
+		if o.SegmentSize > 64<<20 {
+			o.SegmentSize = 64 << 20
+		}
+	}
+	if o.WriteQueueDepth <= 0 {
+		o.WriteQueueDepth = 64
+	}
+	return o
+}
+
+// TierMetrics holds metrics for the secondary tier of a cache.
+type TierMetrics struct {
+	// The capacity of the tier in bytes.
+	Capacity int64
+	// The number of bytes used by the tier's files.
+	Size int64
+	// The count of blocks in the tier.
+	Count int64
+	// The number of lookups that were satisfied by the tier.
+	Hits int64
+	// The number of lookups that were not satisfied by the tier.
+	Misses int64
+	// The number of blocks admitted to the tier.
+	Writes int64
+	// The number of evicted blocks that were not admitted to the tier because
+	// the write queue was full.
+	DroppedWrites int64
+	// The number of errors encountered while writing blocks to the tier.
+	WriteErrors int64
+}
+
+const tierSegmentPrefix = "CACHE-TIER-"
+
+// tierIndexFilename is the name of the file in the tier's directory that holds
+// the index of the blocks persisted when the tier was last closed.
+//
+// The file consists of a version number, the list of segments, each holding
+// the segment number and size, and a sequence of per-namespace records, each
+// holding the namespace, the number of blocks and for each block its file
+// number, offset, segment (as an index into the list of segments), offset
+// within the segment and length, all encoded as uvarints, followed by the
+// block's CRC-32C as a little-endian uint32. The file ends with a CRC-32C of
+// the preceding contents.
+const tierIndexFilename = "CACHE-TIER-INDEX"
+
+const tierIndexVersion = 1
+
+var tierCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+type tierSegment struct {
+	num  uint64
+	path string
+	file vfs.File
+	// size is the number of bytes written to the segment. Protected by
+	// tier.mu.
+	size int64
+	// keys holds the keys of the blocks written to the segment. Protected by
+	// tier.mu.
+	keys []key
+}
+
+type tierLocation struct {
+	segment  *tierSegment
+	offset   int64
+	length   int
+	checksum uint32
+}
+
+type tierWrite struct {
+	key   key
+	value *Value
+	// done, if non-nil, is closed when the write goroutine reaches this
+	// request. Used by tests to wait for queued writes.
+	done chan struct{}
+}
+
+// tierOwner is the ID that owns the blocks of a namespace. See
+// Cache.ClaimTierNamespace.
+type tierOwner struct {
+	id uint64
+	// claimed is false if the ID is a placeholder for the blocks loaded from
+	// the index, or if the owner released the namespace.
+	claimed bool
+}
+
+// tier implements the secondary tier of a Cache. Blocks are appended by a
+// single background goroutine to the current segment file, and an in-memory
+// index maps block keys to their location. When the tier is full, the oldest
+// segment is deleted along with all of the index entries pointing into it.
+// The index is persisted when the tier is closed, and loaded when it's opened.
+type tier struct {
+	opts  TierOptions
+	queue chan tierWrite
+	wg    sync.WaitGroup
+
+	// cur, nextSegment and buf are only accessed by the write goroutine.
+	cur         *tierSegment
+	nextSegment uint64
+	buf         []byte
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	writes        atomic.Int64
+	droppedWrites atomic.Int64
+	writeErrors   atomic.Int64
+
+	mu struct {
+		sync.Mutex
+		closed bool
+		size   int64
+		blocks map[key]tierLocation
+		files  map[key]map[uint64]struct{}
+		// writing is the key of the block being written by the write
+		// goroutine, which inserts it into blocks once written if
+		// writingValid is still set. A concurrent delete or evictFile of the
+		// block clears writingValid, so that it isn't inserted.
+		writing      key
+		writingValid bool
+		// segments holds the live segments, ordered from oldest to newest.
+		segments []*tierSegment
+		// namespaces maps the IDs whose blocks are persisted to the namespace
+		// they're persisted under, and owners maps each namespace back to its
+		// ID. The blocks loaded from the index are keyed by placeholder IDs
+		// until their namespace is claimed.
+		namespaces map[uint64]string
+		owners     map[string]tierOwner
+	}
+}
+
+func openTier(opts *TierOptions) (*tier, error) {
+	opts = opts.EnsureDefaults()
+	if opts.Size <= 0 {
+		return nil, errors.Errorf("pebble: cache tier size must be positive: %d", opts.Size)
+	}
+	if opts.SegmentSize > opts.Size {
+		return nil, errors.Errorf("pebble: cache tier segment size %d exceeds tier size %d",
+			opts.SegmentSize, opts.Size)
+	}
+	if err := opts.FS.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	t := &tier{
+		opts:  *opts,
+		queue: make(chan tierWrite, opts.WriteQueueDepth),
+	}
+	t.mu.blocks = make(map[key]tierLocation)
+	t.mu.files = make(map[key]map[uint64]struct{})
+	t.mu.namespaces = make(map[uint64]string)
+	t.mu.owners = make(map[string]tierOwner)
+	if err := t.load(); err != nil {
+		t.closeSegments()
+		return nil, err
+	}
+	t.wg.Add(1)
+	go t.writeLoop()
+	return t, nil
+}
+
+// load loads the index persisted when the tier was last closed, removing the
+// index file and the segments that it doesn't reference. The index is removed
+// so that it can't describe blocks deleted after the tier is opened should the
+// process crash. An index that can't be decoded is ignored.
+func (t *tier) load() error {
+	fs := t.opts.FS
+	indexPath := fs.PathJoin(t.opts.Dir, tierIndexFilename)
+	var idx *tierIndex
+	if f, err := fs.Open(indexPath); err == nil {
+		buf, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+		idx, _ = decodeTierIndex(buf)
+		if err := fs.Remove(indexPath); err != nil {
+			return err
+		}
+		if err := t.syncDir(); err != nil {
+			return err
+		}
+	} else if !oserror.IsNotExist(err) {
+		return err
+	}
+
+	// Open the segments of the index, skipping those that are missing or that
+	// are shorter than recorded.
+	segments := make(map[uint64]*tierSegment)
+	if idx != nil {
+		for _, s := range idx.segments {
+			path := fs.PathJoin(t.opts.Dir, tierSegmentName(s.num))
+			f, err := fs.Open(path)
+			if err != nil {
+				continue
+			}
+			if stat, err := f.Stat(); err != nil || stat.Size() < s.size {
+				_ = f.Close()
+				continue
+			}
+			seg := &tierSegment{num: s.num, path: path, file: f, size: s.size}
+			segments[s.num] = seg
+			t.mu.segments = append(t.mu.segments, seg)
+			t.mu.size += s.size
+			t.nextSegment = max(t.nextSegment, s.num+1)
+		}
+	}
+	ls, err := fs.List(t.opts.Dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range ls {
+		// Remove the segments that aren't referenced by the index, and any
+		// index left behind by a crash while it was being written.
+		num, ok := parseTierSegmentName(name)
+		if (!ok || segments[num] != nil) && name != tierIndexFilename+".tmp" {
+			continue
+		}
+		if err := fs.Remove(fs.PathJoin(t.opts.Dir, name)); err != nil {
+			return err
+		}
+	}
+	if idx == nil {
+		return nil
+	}
+
+	// Index the blocks under placeholder IDs, which count down from the largest
+	// ID so that they don't collide with the IDs allocated by Cache.NewID.
+	placeholder := uint64(math.MaxUint64)
+	for _, ns := range idx.namespaces {
+		id := placeholder
+		placeholder--
+		t.mu.namespaces[id] = ns.namespace
+		t.mu.owners[ns.namespace] = tierOwner{id: id}
+		for _, b := range ns.blocks {
+			seg := segments[b.segment]
+			if seg == nil || b.segmentOffset+int64(b.length) > seg.size {
+				continue
+			}
+			t.insertLocked(key{fileKey{id, b.fileNum}, b.offset}, tierLocation{
+				segment:  seg,
+				offset:   b.segmentOffset,
+				length:   b.length,
+				checksum: b.checksum,
+			})
+		}
+	}
+	return nil
+}
+
+// persist writes the index of the blocks that belong to a namespace, and
+// removes the segments that don't contain any of them. The index is written
+// to a temporary file that is renamed into place, so a crash never leaves a
+// partially written index behind.
+func (t *tier) persist() error {
+	var idx tierIndex
+	byNamespace := make(map[string]int)
+	referenced := make(map[*tierSegment]bool)
+	for k, loc := range t.mu.blocks {
+		namespace, ok := t.mu.namespaces[k.id]
+		if !ok {
+			continue
+		}
+		i, ok := byNamespace[namespace]
+		if !ok {
+			i = len(idx.namespaces)
+			byNamespace[namespace] = i
+			idx.namespaces = append(idx.namespaces, tierIndexNamespace{namespace: namespace})
+		}
+		idx.namespaces[i].blocks = append(idx.namespaces[i].blocks, tierIndexBlock{
+			fileNum:       k.fileNum,
+			offset:        k.offset,
+			segment:       loc.segment.num,
+			segmentOffset: loc.offset,
+			length:        loc.length,
+			checksum:      loc.checksum,
+		})
+		referenced[loc.segment] = true
+	}
+	segments := t.mu.segments[:0]
+	for _, seg := range t.mu.segments {
+		if !referenced[seg] {
+			_ = seg.file.Close()
+			_ = t.opts.FS.Remove(seg.path)
+			continue
+		}
+		segments = append(segments, seg)
+		idx.segments = append(idx.segments, tierIndexSegment{num: seg.num, size: seg.size})
+	}
+	t.mu.segments = segments
+	if len(idx.namespaces) == 0 {
+		return nil
+	}
+
+	fs := t.opts.FS
+	path := fs.PathJoin(t.opts.Dir, tierIndexFilename)
+	tmpPath := path + ".tmp"
+	f, err := fs.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(encodeTierIndex(&idx)); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := fs.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return t.syncDir()
+}
+
+func (t *tier) syncDir() error {
+	dir, err := t.opts.FS.OpenDir(t.opts.Dir)
+	if err != nil {
+		return err
+	}
+	return errors.CombineErrors(dir.Sync(), dir.Close())
+}
+
+// claimNamespace implements Cache.ClaimTierNamespace.
+func (t *tier) claimNamespace(id uint64, namespace string, live func(base.DiskFileNum) bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.mu.closed {
+		return false
+	}
+	prev, ok := t.mu.owners[namespace]
+	if ok && prev.claimed {
+		return false
+	}
+	t.mu.owners[namespace] = tierOwner{id: id, claimed: true}
+	t.mu.namespaces[id] = namespace
+	if !ok {
+		return true
+	}
+
+	// Move the blocks of the previous owner to id, dropping those of files that
+	// are no longer live.
+	delete(t.mu.namespaces, prev.id)
+	for fkey, offsets := range t.mu.files {
+		if fkey.id != prev.id {
+			continue
+		}
+		keep := live(fkey.fileNum)
+		for offset := range offsets {
+			k := key{fkey.fileKey, offset}
+			loc := t.mu.blocks[k]
+			t.removeLocked(k)
+			if !keep {
+				continue
+			}
+			// A block read by id before the namespace was claimed supersedes
+			// the persisted block.
+			k.id = id
+			if _, exists := t.mu.blocks[k]; !exists {
+				t.insertLocked(k, loc)
+			}
+		}
+	}
+	return true
+}
+
+// releaseNamespace implements Cache.ReleaseTierNamespace.
+func (t *tier) releaseNamespace(id uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if namespace, ok := t.mu.namespaces[id]; ok {
+		t.mu.owners[namespace] = tierOwner{id: id}
+	}
+}
+
+// admit offers a block evicted from the in-memory cache to the tier. The
+// block is written asynchronously; if the write queue is full the block is
+// dropped. admit is called with the shard mutex held and must not block.
+func (t *tier) admit(k key, v *Value) {
+	if v == nil || int64(len(v.buf)) > t.opts.SegmentSize {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.mu.closed {
+		return
+	}
+	if _, ok := t.mu.blocks[k]; ok {
+		return
+	}
+	v.acquire()
+	select {
+	case t.queue <- tierWrite{key: k, value: v}:
+	default:
+		v.release()
+		t.droppedWrites.Add(1)
+	}
+}
+
+// get returns a newly allocated value containing the block for the specified
+// key, or nil if the block is not present in the tier.
+func (t *tier) get(k key) *Value {
+	t.mu.Lock()
+	loc, ok := t.mu.blocks[k]
+	t.mu.Unlock()
+	if !ok {
+		t.misses.Add(1)
+		return nil
+	}
+	v := newValue(loc.length)
+	// The segment may be concurrently deleted, in which case the read fails
+	// and the lookup is treated as a miss.
+	_, err := loc.segment.file.ReadAt(v.buf, loc.offset)
+	if err != nil || crc32.Checksum(v.buf, tierCRCTable) != loc.checksum {
+		v.release()
+		t.misses.Add(1)
+		return nil
+	}
+	t.hits.Add(1)
+	return v
+}
+
+// delete removes the block for the specified key from the tier.
+func (t *tier) delete(k key) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.mu.writingValid && t.mu.writing == k {
+		t.mu.writingValid = false
+	}
+	t.removeLocked(k)
+}
+
+// evictFile removes all of the blocks for the specified file from the tier.
+func (t *tier) evictFile(fkey key) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.mu.writingValid && t.mu.writing.file() == fkey {
+		t.mu.writingValid = false
+	}
+	for offset := range t.mu.files[fkey] {
+		k := fkey
+		k.offset = offset
+		t.removeLocked(k)
+	}
+}
+
+func (t *tier) insertLocked(k key, loc tierLocation) {
+	loc.segment.keys = append(loc.segment.keys, k)
+	t.mu.blocks[k] = loc
+	fkey := k.file()
+	offsets := t.mu.files[fkey]
+	if offsets == nil {
+		offsets = make(map[uint64]struct{})
+		t.mu.files[fkey] = offsets
+	}
+	offsets[k.offset] = struct{}{}
+}
+
+func (t *tier) removeLocked(k key) {
+	if _, ok := t.mu.blocks[k]; !ok {
+		return
+	}
+	delete(t.mu.blocks, k)
+	fkey := k.file()
+	offsets := t.mu.files[fkey]
+	delete(offsets, k.offset)
+	if len(offsets) == 0 {
+		delete(t.mu.files, fkey)
+	}
+}
+
+func (t *tier) writeLoop() {
+	defer t.wg.Done()
+	for w := range t.queue {
+		if w.done != nil {
+			close(w.done)
+			continue
+		}
+		t.write(w.key, w.value.buf)
+		w.value.release()
+	}
+}
+
+func (t *tier) write(k key, buf []byte) {
+	t.mu.Lock()
+	_, exists := t.mu.blocks[k]
+	closed := t.mu.closed
+	if !exists && !closed {
+		t.mu.writing, t.mu.writingValid = k, true
+	}
+	t.mu.Unlock()
+	if exists || closed {
+		return
+	}
+	defer func() {
+		t.mu.Lock()
+		t.mu.writingValid = false
+		t.mu.Unlock()
+	}()
+
+	n := int64(len(buf))
+	if t.cur == nil || t.cur.size+n > t.opts.SegmentSize {
+		if err := t.rotate(); err != nil {
+			t.writeErrors.Add(1)
+			return
+		}
+	}
+	seg := t.cur
+	checksum := crc32.Checksum(buf, tierCRCTable)
+	// NB: vfs.File.Write is permitted to mangle the buffer it is given, and buf
+	// belongs to a value that may still be referenced by readers.
+	t.buf = append(t.buf[:0], buf...)
+	if _, err := seg.file.Write(t.buf); err != nil {
+		// The state of the segment file is unknown. Stop appending to it; it
+		// will be reclaimed along with the blocks it contains once it becomes
+		// the oldest segment.
+		t.cur = nil
+		t.writeErrors.Add(1)
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	offset := seg.size
+	seg.size += n
+	t.mu.size += n
+	if !t.mu.writingValid {
+		// The block was deleted while it was being written. Its space is
+		// reclaimed along with the segment.
+		return
+	}
+	t.insertLocked(k, tierLocation{
+		segment:  seg,
+		offset:   offset,
+		length:   len(buf),
+		checksum: checksum,
+	})
+	t.writes.Add(1)
+}
+
+// rotate creates a new segment, deleting the oldest segments as necessary to
+// keep the tier within its capacity once the new segment is full.
+func (t *tier) rotate() error {
+	// The blocks of the previous segment may be persisted when the tier is
+	// closed.
+	if t.cur != nil {
+		if err := t.cur.file.Sync(); err != nil {
+			return err
+		}
+	}
+	path := t.opts.FS.PathJoin(t.opts.Dir, tierSegmentName(t.nextSegment))
+	f, err := t.opts.FS.Create(path)
+	if err != nil {
+		return err
+	}
+	seg := &tierSegment{num: t.nextSegment, path: path, file: f}
+	t.nextSegment++
+
+	var obsolete []*tierSegment
+	t.mu.Lock()
+	t.mu.segments = append(t.mu.segments, seg)
+	for len(t.mu.segments) > 1 && t.mu.size+t.opts.SegmentSize > t.opts.Size {
+		old := t.mu.segments[0]
+		t.mu.segments = t.mu.segments[1:]
+		t.mu.size -= old.size
+		for _, k := range old.keys {
+			if loc, ok := t.mu.blocks[k]; ok && loc.segment == old {
+				t.removeLocked(k)
+			}
+		}
+		obsolete = append(obsolete, old)
+	}
+	t.mu.Unlock()
+
+	t.cur = seg
+	for _, old := range obsolete {
+		_ = old.file.Close()
+		_ = t.opts.FS.Remove(old.path)
+	}
+	return nil
+}
+
+// waitForWrites waits for all of the writes queued before the call to be
+// processed.
+func (t *tier) waitForWrites() {
+	done := make(chan struct{})
+	t.queue <- tierWrite{done: done}
+	<-done
+}
+
+// close stops the write goroutine, releasing any queued blocks, and persists
+// the index of the blocks that belong to a namespace. The segments that don't
+// contain any such block are removed.
+func (t *tier) close() {
+	t.mu.Lock()
+	t.mu.closed = true
+	t.mu.writingValid = false
+	close(t.queue)
+	t.mu.Unlock()
+	t.wg.Wait()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cur != nil && t.cur.file.Sync() != nil {
+		// The blocks of the current segment may not be durable, and can't be
+		// persisted.
+		for _, k := range t.cur.keys {
+			t.removeLocked(k)
+		}
+	}
+	if t.persist() != nil {
+		// Without an index, the segments are removed when the tier is next
+		// opened.
+		t.writeErrors.Add(1)
+	}
+	t.closeSegments()
+	t.mu.blocks = nil
+	t.mu.files = nil
+	t.mu.size = 0
+}
+
+func (t *tier) closeSegments() {
+	for _, seg := range t.mu.segments {
+		_ = seg.file.Close()
+	}
+	t.mu.segments = nil
+}
+
+func (t *tier) metrics() TierMetrics {
+	t.mu.Lock()
+	m := TierMetrics{
+		Capacity: t.opts.Size,
+		Size:     t.mu.size,
+		Count:    int64(len(t.mu.blocks)),
+	}
+	t.mu.Unlock()
+	m.Hits = t.hits.Load()
+	m.Misses = t.misses.Load()
+	m.Writes = t.writes.Load()
+	m.DroppedWrites = t.droppedWrites.Load()
+	m.WriteErrors = t.writeErrors.Load()
+	return m
+}
+
+func tierSegmentName(num uint64) string {
+	return fmt.Sprintf("%s%06d", tierSegmentPrefix, num)
+}
+
+func parseTierSegmentName(name string) (uint64, bool) {
+	if !strings.HasPrefix(name, tierSegmentPrefix) {
+		return 0, false
+	}
+	num, err := strconv.ParseUint(name[len(tierSegmentPrefix):], 10, 64)
+	return num, err == nil
+}
+
+// tierIndex is the decoded form of the CACHE-TIER-INDEX file.
+type tierIndex struct {
+	segments   []tierIndexSegment
+	namespaces []tierIndexNamespace
+}
+
+type tierIndexSegment struct {
+	num  uint64
+	size int64
+}
+
+type tierIndexNamespace struct {
+	namespace string
+	blocks    []tierIndexBlock
+}
+
+type tierIndexBlock struct {
+	fileNum       base.DiskFileNum
+	offset        uint64
+	segment       uint64
+	segmentOffset int64
+	length        int
+	checksum      uint32
+}
+
+func encodeTierIndex(idx *tierIndex) []byte {
+	buf := binary.AppendUvarint(nil, tierIndexVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(idx.segments)))
+	segments := make(map[uint64]uint64, len(idx.segments))
+	for i, s := range idx.segments {
+		segments[s.num] = uint64(i)
+		buf = binary.AppendUvarint(buf, s.num)
+		buf = binary.AppendUvarint(buf, uint64(s.size))
+	}
+	for _, ns := range idx.namespaces {
+		buf = binary.AppendUvarint(buf, uint64(len(ns.namespace)))
+		buf = append(buf, ns.namespace...)
+		buf = binary.AppendUvarint(buf, uint64(len(ns.blocks)))
+		for _, b := range ns.blocks {
+			buf = binary.AppendUvarint(buf, uint64(b.fileNum))
+			buf = binary.AppendUvarint(buf, b.offset)
+			buf = binary.AppendUvarint(buf, segments[b.segment])
+			buf = binary.AppendUvarint(buf, uint64(b.segmentOffset))
+			buf = binary.AppendUvarint(buf, uint64(b.length))
+			buf = binary.LittleEndian.AppendUint32(buf, b.checksum)
+		}
+	}
+	return binary.LittleEndian.AppendUint32(buf, crc32.Checksum(buf, tierCRCTable))
+}
+
+func decodeTierIndex(buf []byte) (*tierIndex, error) {
+	errCorrupt := errors.Errorf("pebble: corrupt %s file", errors.Safe(tierIndexFilename))
+	if len(buf) < 4 {
+		return nil, errCorrupt
+	}
+	buf, checksum := buf[:len(buf)-4], binary.LittleEndian.Uint32(buf[len(buf)-4:])
+	if crc32.Checksum(buf, tierCRCTable) != checksum {
+		return nil, errCorrupt
+	}
+	readUvarint := func() (uint64, bool) {
+		v, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return 0, false
+		}
+		buf = buf[n:]
+		return v, true
+	}
+	version, ok := readUvarint()
+	if !ok {
+		return nil, errCorrupt
+	}
+	if version != tierIndexVersion {
+		return nil, errors.Errorf("pebble: unsupported %s version %d",
+			errors.Safe(tierIndexFilename), errors.Safe(version))
+	}
+
+	idx := &tierIndex{}
+	count, ok := readUvarint()
+	if !ok || count > uint64(len(buf)) {
+		return nil, errCorrupt
+	}
+	idx.segments = make([]tierIndexSegment, count)
+	for i := range idx.segments {
+		num, ok1 := readUvarint()
+		size, ok2 := readUvarint()
+		if !ok1 || !ok2 {
+			return nil, errCorrupt
+		}
+		idx.segments[i] = tierIndexSegment{num: num, size: int64(size)}
+	}
+	for len(buf) > 0 {
+		n, ok := readUvarint()
+		if !ok || n > uint64(len(buf)) {
+			return nil, errCorrupt
+		}
+		ns := tierIndexNamespace{namespace: string(buf[:n])}
+		buf = buf[n:]
+		count, ok := readUvarint()
+		if !ok || count > uint64(len(buf)) {
+			return nil, errCorrupt
+		}
+		ns.blocks = make([]tierIndexBlock, count)
+		for i := range ns.blocks {
+			fileNum, ok1 := readUvarint()
+			offset, ok2 := readUvarint()
+			segment, ok3 := readUvarint()
+			segmentOffset, ok4 := readUvarint()
+			length, ok5 := readUvarint()
+			if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || segment >= uint64(len(idx.segments)) || len(buf) < 4 {
+				return nil, errCorrupt
+			}
+			ns.blocks[i] = tierIndexBlock{
+				fileNum:       base.DiskFileNum(fileNum),
+				offset:        offset,
+				segment:       idx.segments[segment].num,
+				segmentOffset: int64(segmentOffset),
+				length:        int(length),
+				checksum:      binary.LittleEndian.Uint32(buf),
+			}
+			buf = buf[4:]
+		}
+		idx.namespaces = append(idx.namespaces, ns)
+	}
+	return idx, nil
+}
@@ -0,0 +1,305 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors/oserror"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func tierTestValue(fileNum, offset int, size int) *Value {
+	v := Alloc(size)
+	copy(v.Buf(), bytes.Repeat([]byte(fmt.Sprintf("%d/%d;", fileNum, offset)), size))
+	return v
+}
+
+func TestTier(t *testing.T) {
+	fs := vfs.NewMem()
+	opts := &TierOptions{FS: fs, Dir: "tier", Size: 1 << 20, SegmentSize: 64 << 10}
+	t1, err := openTier(opts)
+	require.NoError(t, err)
+	c := newShards(10<<10, 1)
+	c.setTier(t1)
+	defer c.Unref()
+
+	// Fill the cache well beyond its capacity. The evicted blocks are admitted
+	// to the tier.
+	const blockSize = 1 << 10
+	const numBlocks = 100
+	for i := 0; i < numBlocks; i++ {
+		c.Set(1, base.DiskFileNum(1), uint64(i*blockSize), tierTestValue(1, i, blockSize)).Release()
+		// Ensure that none of the admissions are dropped.
+		t1.waitForWrites()
+	}
+	m := c.TierMetrics()
+	require.Greater(t, m.Writes, int64(numBlocks/2))
+	require.Zero(t, m.DroppedWrites)
+
+	// Every block is either in memory or in the tier.
+	for i := 0; i < numBlocks; i++ {
+		h := c.Get(1, base.DiskFileNum(1), uint64(i*blockSize))
+		t1.waitForWrites()
+		require.NotNil(t, h.Get(), "block %d", i)
+		want := tierTestValue(1, i, blockSize)
+		require.Equal(t, want.Buf(), h.Get())
+		Free(want)
+		h.Release()
+	}
+	require.NotZero(t, c.TierMetrics().Hits)
+
+	// Evicting the file removes its blocks from the tier.
+	c.EvictFile(1, base.DiskFileNum(1))
+	require.Zero(t, c.TierMetrics().Count)
+	require.Nil(t, c.Get(1, base.DiskFileNum(1), 0).Get())
+}
+
+func TestTierCapacity(t *testing.T) {
+	fs := vfs.NewMem()
+	opts := &TierOptions{FS: fs, Dir: "tier", Size: 64 << 10, SegmentSize: 16 << 10}
+	t1, err := openTier(opts)
+	require.NoError(t, err)
+	defer t1.close()
+
+	const blockSize = 1 << 10
+	for i := 0; i < 1000; i++ {
+		v := tierTestValue(1, i, blockSize)
+		t1.write(key{fileKey{1, base.DiskFileNum(1)}, uint64(i)}, v.Buf())
+		Free(v)
+		m := t1.metrics()
+		require.LessOrEqual(t, m.Size, opts.Size)
+	}
+	m := t1.metrics()
+	require.Equal(t, int64(1000), m.Writes)
+	require.Less(t, m.Count, int64(64))
+
+	// The oldest blocks have been discarded, the most recent blocks remain.
+	require.Nil(t, t1.get(key{fileKey{1, base.DiskFileNum(1)}, 0}))
+	v := t1.get(key{fileKey{1, base.DiskFileNum(1)}, 999})
+	require.NotNil(t, v)
+	want := tierTestValue(1, 999, blockSize)
+	require.Equal(t, want.Buf(), v.Buf())
+	Free(want)
+	Free(v)
+
+	// Only the live segments remain on disk.
+	ls, err := fs.List("tier")
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(ls), 4)
+}
+
+func TestTierOpenRemovesStaleSegments(t *testing.T) {
+	fs := vfs.NewMem()
+	require.NoError(t, fs.MkdirAll("tier", 0755))
+	f, err := fs.Create(fs.PathJoin("tier", tierSegmentPrefix+"000007"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	f, err = fs.Create(fs.PathJoin("tier", tierIndexFilename+".tmp"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	f, err = fs.Create(fs.PathJoin("tier", "unrelated"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	c, err := NewTiered(1<<20, &TierOptions{FS: fs, Dir: "tier", Size: 1 << 20})
+	require.NoError(t, err)
+	ls, err := fs.List("tier")
+	require.NoError(t, err)
+	require.Equal(t, "unrelated", strings.Join(ls, ","))
+
+	c.Unref()
+	_, err = NewTiered(1<<20, &TierOptions{FS: fs, Dir: "tier", Size: 1 << 10, SegmentSize: 1 << 20})
+	require.Error(t, err)
+}
+
+func TestTierPersistence(t *testing.T) {
+	fs := vfs.NewMem()
+	opts := &TierOptions{FS: fs, Dir: "tier", Size: 1 << 20, SegmentSize: 16 << 10}
+	const blockSize = 1 << 10
+	k := func(id uint64, fileNum, offset int) key {
+		return key{fileKey{id, base.DiskFileNum(fileNum)}, uint64(offset)}
+	}
+	write := func(t1 *tier, id uint64, fileNum, offset int) {
+		v := tierTestValue(fileNum, offset, blockSize)
+		t1.write(k(id, fileNum, offset), v.Buf())
+		Free(v)
+	}
+	requireBlock := func(t1 *tier, id uint64, fileNum, offset int) {
+		v := t1.get(k(id, fileNum, offset))
+		require.NotNil(t, v, "block %d/%d/%d", id, fileNum, offset)
+		want := tierTestValue(fileNum, offset, blockSize)
+		require.Equal(t, want.Buf(), v.Buf())
+		Free(want)
+		Free(v)
+	}
+	all := func(base.DiskFileNum) bool { return true }
+
+	// Write the blocks of two files for each of two namespaces, and of a file
+	// for an ID without a namespace, spanning several segments.
+	t1, err := openTier(opts)
+	require.NoError(t, err)
+	require.True(t, t1.claimNamespace(1, "a", all))
+	require.False(t, t1.claimNamespace(2, "a", all))
+	require.True(t, t1.claimNamespace(2, "b", all))
+	for i := 0; i < 20; i++ {
+		write(t1, 1, 1, i)
+		write(t1, 1, 2, i)
+		write(t1, 2, 1, i)
+		write(t1, 3, 1, i)
+	}
+	t1.releaseNamespace(2)
+	t1.close()
+
+	// The index is removed when the tier is opened. The blocks of each
+	// namespace are handed to the ID that claims it, except for those of files
+	// that are no longer live.
+	t2, err := openTier(opts)
+	require.NoError(t, err)
+	_, err = fs.Stat(fs.PathJoin("tier", tierIndexFilename))
+	require.True(t, oserror.IsNotExist(err))
+	require.Equal(t, int64(60), t2.metrics().Count)
+	require.Nil(t, t2.get(k(1, 1, 0)))
+	require.True(t, t2.claimNamespace(4, "a", func(fileNum base.DiskFileNum) bool {
+		return fileNum == 1
+	}))
+	require.True(t, t2.claimNamespace(5, "b", all))
+	require.Equal(t, int64(40), t2.metrics().Count)
+	for i := 0; i < 20; i++ {
+		requireBlock(t2, 4, 1, i)
+		require.Nil(t, t2.get(k(4, 2, i)))
+		requireBlock(t2, 5, 1, i)
+		require.Nil(t, t2.get(k(3, 1, i)))
+	}
+
+	// New blocks are written to new segments and persisted along with the
+	// loaded blocks. A namespace that isn't claimed remains persisted.
+	write(t2, 4, 3, 0)
+	t2.releaseNamespace(4)
+	require.True(t, t2.claimNamespace(6, "a", all))
+	requireBlock(t2, 6, 3, 0)
+	t2.close()
+	t3, err := openTier(opts)
+	require.NoError(t, err)
+	require.True(t, t3.claimNamespace(7, "a", all))
+	requireBlock(t3, 7, 1, 0)
+	requireBlock(t3, 7, 3, 0)
+	require.Equal(t, int64(41), t3.metrics().Count)
+	t3.close()
+
+	// A corrupt index is ignored, and the segments are removed.
+	path := fs.PathJoin("tier", tierIndexFilename)
+	f, err := fs.OpenReadWrite(path)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("x"), 10)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	t4, err := openTier(opts)
+	require.NoError(t, err)
+	require.Zero(t, t4.metrics().Count)
+	require.Zero(t, t4.metrics().Size)
+	ls, err := fs.List("tier")
+	require.NoError(t, err)
+	require.Empty(t, ls)
+	t4.close()
+}
+
+func TestTierIndexRoundTrip(t *testing.T) {
+	idx := &tierIndex{
+		segments: []tierIndexSegment{{num: 3, size: 100}, {num: 7, size: 4096}},
+		namespaces: []tierIndexNamespace{
+			{namespace: "a", blocks: []tierIndexBlock{
+				{fileNum: 1, offset: 0, segment: 3, segmentOffset: 0, length: 100, checksum: 12},
+				{fileNum: 9, offset: 4096, segment: 7, segmentOffset: 96, length: 4000, checksum: 34},
+			}},
+			{namespace: "b", blocks: []tierIndexBlock{
+				{fileNum: 2, offset: 10, segment: 7, segmentOffset: 0, length: 96, checksum: 56},
+			}},
+		},
+	}
+	buf := encodeTierIndex(idx)
+	decoded, err := decodeTierIndex(buf)
+	require.NoError(t, err)
+	require.Equal(t, idx, decoded)
+
+	// Every truncation, and every single bit flip, is detected.
+	for i := 0; i < len(buf); i++ {
+		_, err := decodeTierIndex(buf[:i])
+		require.Error(t, err, "truncated to %d bytes", i)
+		corrupt := append([]byte(nil), buf...)
+		corrupt[i] ^= 1
+		_, err = decodeTierIndex(corrupt)
+		require.Error(t, err, "bit flipped in byte %d", i)
+	}
+}
+
+// onWriteFS is a vfs.FS whose created files call onWrite before each write.
+type onWriteFS struct {
+	vfs.FS
+	onWrite func()
+}
+
+func (fs *onWriteFS) Create(name string) (vfs.File, error) {
+	f, err := fs.FS.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &onWriteFile{File: f, onWrite: fs.onWrite}, nil
+}
+
+type onWriteFile struct {
+	vfs.File
+	onWrite func()
+}
+
+func (f *onWriteFile) Write(p []byte) (int, error) {
+	f.onWrite()
+	return f.File.Write(p)
+}
+
+func TestTierDeleteDuringWrite(t *testing.T) {
+	var onWrite func()
+	fs := &onWriteFS{FS: vfs.NewMem(), onWrite: func() { onWrite() }}
+	t1, err := openTier(&TierOptions{FS: fs, Dir: "tier", Size: 1 << 20, SegmentSize: 64 << 10})
+	require.NoError(t, err)
+	defer t1.close()
+
+	const blockSize = 1 << 10
+	k := func(fileNum, offset int) key {
+		return key{fileKey{1, base.DiskFileNum(fileNum)}, uint64(offset)}
+	}
+	write := func(fileNum, offset int) {
+		v := tierTestValue(fileNum, offset, blockSize)
+		t1.write(k(fileNum, offset), v.Buf())
+		Free(v)
+	}
+
+	// A block deleted, or whose file is evicted, while it's being written
+	// isn't inserted into the tier, though its space is still used.
+	onWrite = func() { t1.delete(k(1, 0)) }
+	write(1, 0)
+	onWrite = func() { t1.evictFile(k(2, 0)) }
+	write(2, 0)
+	require.Nil(t, t1.get(k(1, 0)))
+	require.Nil(t, t1.get(k(2, 0)))
+	m := t1.metrics()
+	require.Zero(t, m.Count)
+	require.Equal(t, int64(2*blockSize), m.Size)
+
+	// Deleting other blocks doesn't prevent the insertion.
+	onWrite = func() {
+		t1.delete(k(3, 1))
+		t1.evictFile(k(4, 0))
+	}
+	write(3, 0)
+	v := t1.get(k(3, 0))
+	require.NotNil(t, v)
+	Free(v)
+}
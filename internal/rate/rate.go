@@ -72,6 +72,29 @@ func (l *Limiter) Wait(n float64) {
 	}
 }
 
+// WaitCancellable is like Wait, but gives up and returns false if cancelled
+// returns true before enough tokens are available. cancelled is called before
+// each attempt to take the tokens, at least once every maxSleep while waiting.
+func (l *Limiter) WaitCancellable(n float64, maxSleep time.Duration, cancelled func() bool) bool {
+	for {
+		if cancelled() {
+			return false
+		}
+		l.mu.Lock()
+		ok, d := l.mu.tb.TryToFulfill(tokenbucket.Tokens(n))
+		l.mu.Unlock()
+		if ok {
+			return true
+		}
+		d = min(d, maxSleep)
+		if l.sleepFn != nil {
+			l.sleepFn(d)
+		} else {
+			time.Sleep(d)
+		}
+	}
+}
+
 // Remove removes tokens for an operation that bypassed any waiting; it can put
 // the token bucket into debt, delaying future operations.
 func (l *Limiter) Remove(n float64) {
@@ -2619,7 +2619,9 @@ func (i *Iterator) SetOptions(o *IterOptions) {
 		(i.pointIter != nil || !i.opts.pointKeys()) &&
 		(i.rangeKey != nil || !i.opts.rangeKeys() || i.opts.KeyTypes == IterKeyTypePointsAndRanges) &&
 		i.equal(o.RangeKeyMasking.Suffix, i.opts.RangeKeyMasking.Suffix) &&
-		o.UseL6Filters == i.opts.UseL6Filters {
+		o.UseL6Filters == i.opts.UseL6Filters &&
+		o.BlockCacheMode == i.opts.BlockCacheMode &&
+		o.PrefetchBlocks == i.opts.PrefetchBlocks {
 		// The options are identical, so we can likely use the fast path. In
 		// addition to all the above constraints, we cannot use the fast path if
 		// configured to perform lazy combined iteration but an indexed batch
@@ -2840,11 +2842,17 @@ func (stats *IteratorStats) SafeFormat(s redact.SafePrinter, verb rune) {
 	}
 	if stats.InternalStats != (InternalIteratorStats{}) {
 		s.SafeString(",\n(internal-stats: ")
-		s.Printf("(block-bytes: (total %s, cached %s, read-time %s)), "+
-			"(points: (count %s, key-bytes %s, value-bytes %s, tombstoned %s))",
+		s.Printf("(block-bytes: (total %s, cached %s, read-time %s)), ",
 			humanize.Bytes.Uint64(stats.InternalStats.BlockBytes),
 			humanize.Bytes.Uint64(stats.InternalStats.BlockBytesInCache),
-			humanize.FormattedString(stats.InternalStats.BlockReadDuration.String()),
+			humanize.FormattedString(stats.InternalStats.BlockReadDuration.String()))
+		if stats.InternalStats.PrefetchedBlocks != 0 {
+			s.Printf("(prefetch: (blocks %s, hits %s, waits %s)), ",
+				humanize.Count.Uint64(stats.InternalStats.PrefetchedBlocks),
+				humanize.Count.Uint64(stats.InternalStats.PrefetchHits),
+				humanize.Count.Uint64(stats.InternalStats.PrefetchWaits))
+		}
+		s.Printf("(points: (count %s, key-bytes %s, value-bytes %s, tombstoned %s))",
 			humanize.Count.Uint64(stats.InternalStats.PointCount),
 			humanize.Bytes.Uint64(stats.InternalStats.KeyBytes),
 			humanize.Bytes.Uint64(stats.InternalStats.ValueBytes),
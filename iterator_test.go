@@ -1561,6 +1561,9 @@ func TestIteratorStatsMerge(t *testing.T) {
 			BlockBytes:                     9,
 			BlockBytesInCache:              10,
 			BlockReadDuration:              3 * time.Millisecond,
+			PrefetchedBlocks:               5,
+			PrefetchHits:                   3,
+			PrefetchWaits:                  1,
 			KeyBytes:                       11,
 			ValueBytes:                     12,
 			PointCount:                     13,
@@ -1584,6 +1587,9 @@ func TestIteratorStatsMerge(t *testing.T) {
 			BlockBytes:                     9,
 			BlockBytesInCache:              10,
 			BlockReadDuration:              4 * time.Millisecond,
+			PrefetchedBlocks:               5,
+			PrefetchHits:                   3,
+			PrefetchWaits:                  1,
 			KeyBytes:                       11,
 			ValueBytes:                     12,
 			PointCount:                     13,
@@ -1608,6 +1614,9 @@ func TestIteratorStatsMerge(t *testing.T) {
 			BlockBytes:                     18,
 			BlockBytesInCache:              20,
 			BlockReadDuration:              7 * time.Millisecond,
+			PrefetchedBlocks:               10,
+			PrefetchHits:                   6,
+			PrefetchWaits:                  2,
 			KeyBytes:                       22,
 			ValueBytes:                     24,
 			PointCount:                     26,
@@ -1625,6 +1634,94 @@ func TestIteratorStatsMerge(t *testing.T) {
 	require.Equal(t, expected, s)
 }
 
+func TestIteratorPrefetchBlocks(t *testing.T) {
+	opts := &Options{
+		FS:                          vfs.NewMem(),
+		DisableAutomaticCompactions: true,
+	}
+	opts.Levels = make([]LevelOptions, numLevels)
+	for i := range opts.Levels {
+		opts.Levels[i].BlockSize = 256
+	}
+	d, err := Open("", opts)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	// Flush a few non-overlapping tables, which are iterated over by the same
+	// levelIter, and compact some of them into L6.
+	const numKeys = 5000
+	key := func(i int) []byte { return []byte(fmt.Sprintf("%08d", i)) }
+	for i := 0; i < numKeys; i++ {
+		require.NoError(t, d.Set(key(i), bytes.Repeat([]byte{'v'}, 20), nil))
+		if (i+1)%1000 == 0 {
+			require.NoError(t, d.Flush())
+		}
+		if i+1 == 2000 {
+			require.NoError(t, d.Compact(key(0), key(2000), false /* parallelize */))
+		}
+	}
+	tables, err := d.SSTables(WithProperties())
+	require.NoError(t, err)
+	var numBlocks, nonEmptyLevels int
+	for _, level := range tables {
+		for _, table := range level {
+			numBlocks += int(table.Properties.NumDataBlocks)
+		}
+		if len(level) > 0 {
+			nonEmptyLevels++
+		}
+	}
+	require.Equal(t, 2, nonEmptyLevels)
+
+	scan := func(iterOpts *IterOptions) IteratorStats {
+		iter, _ := d.NewIter(iterOpts)
+		n := 0
+		for valid := iter.First(); valid; valid = iter.Next() {
+			require.Equal(t, string(key(n)), string(iter.Key()))
+			n++
+		}
+		require.Equal(t, numKeys, n)
+		stats := iter.Stats()
+		require.NoError(t, iter.Close())
+		return stats
+	}
+	stats := scan(nil)
+	require.Zero(t, stats.InternalStats.PrefetchedBlocks)
+
+	// Every data block is prefetched, except for the first block of each
+	// level: the blocks of the following tables of a level are prefetched
+	// before the scan reaches them.
+	stats = scan(&IterOptions{PrefetchBlocks: 4})
+	require.Equal(t, uint64(numBlocks-nonEmptyLevels), stats.InternalStats.PrefetchedBlocks)
+	require.Equal(t, uint64(numBlocks-nonEmptyLevels),
+		stats.InternalStats.PrefetchHits+stats.InternalStats.PrefetchWaits)
+	require.Contains(t, stats.String(), "(prefetch: (blocks")
+
+	// Seeks and reverse iteration abandon the prefetched blocks.
+	iter, _ := d.NewIter(&IterOptions{PrefetchBlocks: 4})
+	rng := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
+	for i := 0; i < 1000; i++ {
+		k := rng.Intn(numKeys)
+		switch rng.Intn(4) {
+		case 0:
+			require.True(t, iter.SeekGE(key(k)))
+			require.Equal(t, string(key(k)), string(iter.Key()))
+		case 1:
+			valid := iter.SeekLT(key(k))
+			require.Equal(t, k > 0, valid)
+		case 2:
+			if iter.Valid() {
+				iter.Prev()
+			}
+		default:
+			for j := 0; j < 100 && iter.Valid(); j++ {
+				iter.Next()
+			}
+		}
+	}
+	require.NoError(t, iter.Close())
+}
+
 // TestSetOptionsEquivalence tests equivalence between SetOptions to mutate an
 // iterator and constructing a new iterator with NewIter. The long-lived
 // iterator and the new iterator should surface identical iterator states.
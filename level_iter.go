@@ -134,7 +134,14 @@ type levelIter struct {
 	// advanced beyond the file's bounds. See
 	// levelIterBoundaryContext.isIgnorableBoundaryKey.
 	filteredIter filteredIter
-	newIters     tableNewIters
+	// prefetchIter is set if the iterator of the current file prefetches data
+	// blocks (see IterOptions.PrefetchBlocks) and the next file has not been
+	// opened yet. See maybeOpenNextFile.
+	prefetchIter prefetchingIter
+	// nextFile holds the next file and its iterators if they were opened by
+	// maybeOpenNextFile before the iteration reached the file.
+	nextFile openedFile
+	newIters tableNewIters
 	// When rangeDelIterPtr != nil, the caller requires that *rangeDelIterPtr must
 	// point to a range del iterator corresponding to the current file. When this
 	// iterator returns nil, *rangeDelIterPtr should also be set to nil. Whenever
@@ -235,6 +242,38 @@ type filteredIter interface {
 	MaybeFilteredKeys() bool
 }
 
+// openedFile is a file whose iterators were opened ahead of time.
+type openedFile struct {
+	file         *fileMetadata
+	iter         internalIterator
+	rangeDelIter keyspan.FragmentIterator
+}
+
+// close closes the file's iterators, if any.
+func (f *openedFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.iter.Close()
+	if f.rangeDelIter != nil {
+		err = firstError(err, f.rangeDelIter.Close())
+	}
+	*f = openedFile{}
+	return err
+}
+
+// prefetchingIter is an additional interface implemented by iterators that
+// asynchronously prefetch the data blocks that lie ahead of forward iteration.
+// The sstable.Iterator implements this interface.
+type prefetchingIter interface {
+	// PrefetchFirstBlocks starts prefetching the first data blocks of the
+	// iterator before it is positioned.
+	PrefetchFirstBlocks()
+	// PrefetchedToEnd returns true once the iterator has queued the prefetch of
+	// the last data block it needs when iterating forward.
+	PrefetchedToEnd() bool
+}
+
 // levelIter implements the base.InternalIterator interface.
 var _ base.InternalIterator = (*levelIter)(nil)
 
@@ -275,6 +314,8 @@ func (l *levelIter) init(
 		l.tableOpts.PointKeyFilters = l.filtersBuf[:0:1]
 	}
 	l.tableOpts.UseL6Filters = opts.UseL6Filters
+	l.tableOpts.BlockCacheMode = opts.BlockCacheMode
+	l.tableOpts.PrefetchBlocks = opts.PrefetchBlocks
 	l.tableOpts.CategoryAndQoS = opts.CategoryAndQoS
 	l.tableOpts.level = l.level
 	l.tableOpts.snapshotForHideObsoletePoints = opts.snapshotForHideObsoletePoints
@@ -630,6 +671,16 @@ func (l *levelIter) loadFile(file *fileMetadata, dir int) loadFileReturnIndicato
 		// have changed. We handle that below.
 	}
 
+	// Hold on to the next file's iterators, if they were opened ahead of time,
+	// in case file is the next file. They're closed if they end up unused.
+	next := l.nextFile
+	l.nextFile = openedFile{}
+	if next.file != nil {
+		defer func() {
+			l.err = firstError(l.err, next.close())
+		}()
+	}
+
 	// Close both iter and rangeDelIterPtr. While mergingIter knows about
 	// rangeDelIterPtr, it can't call Close() on it because it does not know
 	// when the levelIter will switch it. Note that levelIter.Close() can be
@@ -676,11 +727,19 @@ func (l *levelIter) loadFile(file *fileMetadata, dir int) loadFileReturnIndicato
 
 		var rangeDelIter keyspan.FragmentIterator
 		var iter internalIterator
-		iter, rangeDelIter, l.err = l.newIters(l.ctx, l.iterFile, &l.tableOpts, l.internalOpts)
+		if next.file == file {
+			iter, rangeDelIter = next.iter, next.rangeDelIter
+			next = openedFile{}
+		} else {
+			iter, rangeDelIter, l.err = l.newIters(l.ctx, l.iterFile, &l.tableOpts, l.internalOpts)
+		}
 		l.iter = iter
 		if l.err != nil {
 			return noFileLoaded
 		}
+		if l.tableOpts.PrefetchBlocks > 0 {
+			l.prefetchIter, _ = iter.(prefetchingIter)
+		}
 		if rangeDelIter != nil {
 			if fi, ok := iter.(filteredIter); ok {
 				l.filteredIter = fi
@@ -882,6 +941,9 @@ func (l *levelIter) Next() (*InternalKey, base.LazyValue) {
 	if l.err != nil || l.iter == nil {
 		return nil, base.LazyValue{}
 	}
+	if l.prefetchIter != nil {
+		l.maybeOpenNextFile()
+	}
 	if l.boundaryContext != nil {
 		l.boundaryContext.isSyntheticIterBoundsKey = false
 		l.boundaryContext.isIgnorableBoundaryKey = false
@@ -923,6 +985,9 @@ func (l *levelIter) NextPrefix(succKey []byte) (*InternalKey, base.LazyValue) {
 	if l.err != nil || l.iter == nil {
 		return nil, base.LazyValue{}
 	}
+	if l.prefetchIter != nil {
+		l.maybeOpenNextFile()
+	}
 	if l.boundaryContext != nil {
 		l.boundaryContext.isSyntheticIterBoundsKey = false
 		l.boundaryContext.isIgnorableBoundaryKey = false
@@ -1210,7 +1275,48 @@ func (l *levelIter) Error() error {
 	return l.iter.Error()
 }
 
+// maybeOpenNextFile opens the iterators of the file that follows the current
+// file once the current file's iterator has queued the prefetch of its last
+// data block, and starts prefetching the next file's first data blocks. This
+// avoids waiting for these blocks when a forward scan reaches the next file.
+func (l *levelIter) maybeOpenNextFile() {
+	if !l.prefetchIter.PrefetchedToEnd() {
+		return
+	}
+	l.prefetchIter = nil
+	if l.nextFile.file != nil {
+		return
+	}
+	files := l.files.Clone()
+	file := files.Next()
+	for file != nil && !file.HasPointKeys {
+		file = files.Next()
+	}
+	if file == nil {
+		return
+	}
+	// initTableBounds overwrites the current file's bounds in l.tableOpts.
+	lower, upper := l.tableOpts.LowerBound, l.tableOpts.UpperBound
+	defer func() {
+		l.tableOpts.LowerBound, l.tableOpts.UpperBound = lower, upper
+	}()
+	if l.initTableBounds(file) != 0 {
+		return
+	}
+	iter, rangeDelIter, err := l.newIters(l.ctx, file, &l.tableOpts, l.internalOpts)
+	if err != nil {
+		// The error will be encountered again if the iteration reaches the
+		// file.
+		return
+	}
+	if pi, ok := iter.(prefetchingIter); ok {
+		pi.PrefetchFirstBlocks()
+	}
+	l.nextFile = openedFile{file: file, iter: iter, rangeDelIter: rangeDelIter}
+}
+
 func (l *levelIter) Close() error {
+	l.prefetchIter = nil
 	if l.iter != nil {
 		l.err = l.iter.Close()
 		l.iter = nil
@@ -1222,12 +1328,15 @@ func (l *levelIter) Close() error {
 		*l.rangeDelIterPtr = nil
 		l.rangeDelIterCopy = nil
 	}
+	l.err = firstError(l.err, l.nextFile.close())
 	return l.err
 }
 
 func (l *levelIter) SetBounds(lower, upper []byte) {
 	l.lower = lower
 	l.upper = upper
+	// The next file's iterators were opened with the previous bounds.
+	l.err = firstError(l.err, l.nextFile.close())
 
 	if l.iter == nil {
 		return
@@ -1252,6 +1361,9 @@ func (l *levelIter) SetContext(ctx context.Context) {
 		// manifest.LevelToInt(opts.level)) that happens in table_cache.go.
 		l.iter.SetContext(ctx)
 	}
+	if l.nextFile.iter != nil {
+		l.nextFile.iter.SetContext(ctx)
+	}
 }
 
 func (l *levelIter) String() string {
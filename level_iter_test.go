@@ -163,7 +163,8 @@ func (lt *levelIterTest) newIters(
 	lt.itersCreated++
 	iter, err := lt.readers[file.FileNum].NewIterWithBlockPropertyFiltersAndContextEtc(
 		ctx, opts.LowerBound, opts.UpperBound, nil, false, true, iio.stats, sstable.CategoryAndQoS{},
-		nil, sstable.TrivialReaderProvider{Reader: lt.readers[file.FileNum]})
+		nil, sstable.TrivialReaderProvider{Reader: lt.readers[file.FileNum]}, sstable.BlockCacheDefault,
+		0 /* prefetchBlocks */)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -544,6 +544,14 @@ func RandomOptions(
 		opts.Experimental.MaxWriterConcurrency = 2
 		opts.Experimental.ForceWriterParallelism = true
 	}
+	if rng.Intn(4) == 0 {
+		// Split compactions into subcompactions for 25% of the random options.
+		opts.Experimental.MaxSubcompactions = 2 + rng.Intn(3) // 2 - 4
+	}
+	if rng.Intn(4) == 0 {
+		// Periodically rewrite old tables for 25% of the random options.
+		opts.PeriodicCompactionInterval = time.Duration(1+rng.Intn(5)) * time.Second // 1s - 5s
+	}
 	if rng.Intn(2) == 0 {
 		opts.Experimental.DisableIngestAsFlushable = func() bool { return true }
 	}
@@ -570,6 +578,8 @@ func RandomOptions(
 	lopts.BlockSizeThreshold = 50 + rng.Intn(50)   // 50 - 100
 	lopts.IndexBlockSize = 1 << uint(rng.Intn(24)) // 1 - 16MB
 	lopts.TargetFileSize = 1 << uint(rng.Intn(28)) // 1 - 256MB
+	lopts.DataBlockHashIndex = rng.Intn(2) == 0
+	lopts.ColumnarDataBlocks = rng.Intn(4) == 0
 
 	// We either use no bloom filter, the default filter, or a filter with
 	// randomized bits-per-key setting. We zero out the Filters map. It'll get
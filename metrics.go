@@ -22,6 +22,9 @@ import (
 // CacheMetrics holds metrics for the block and table cache.
 type CacheMetrics = cache.Metrics
 
+// CacheTierMetrics holds metrics for the secondary tier of the block cache.
+type CacheTierMetrics = cache.TierMetrics
+
 // FilterMetrics holds metrics for the filter policy
 type FilterMetrics = sstable.FilterMetrics
 
@@ -105,9 +108,40 @@ type LevelMetrics struct {
 		// LevelMetrics.format, but are available to sophisticated clients.
 		BytesWrittenDataBlocks  uint64
 		BytesWrittenValueBlocks uint64
+		// Cumulative metrics about the uncompressed bytes of data blocks that
+		// were compressed with, or skipped compression with, each algorithm,
+		// via compactions (except move compactions) or flushes. Only populated
+		// when LevelOptions.AdaptiveCompression is enabled. Not printed by
+		// LevelMetrics.format.
+		Compression CompressionMetrics
 	}
 }
 
+// CompressionMetrics holds counters of the uncompressed bytes of data blocks
+// compressed or skipped per compression algorithm. See
+// LevelOptions.AdaptiveCompression.
+type CompressionMetrics struct {
+	SnappyCompressedBytes uint64
+	SnappySkippedBytes    uint64
+	ZstdCompressedBytes   uint64
+	ZstdSkippedBytes      uint64
+}
+
+// Add adds the counters in u to m.
+func (m *CompressionMetrics) Add(u *CompressionMetrics) {
+	m.SnappyCompressedBytes += u.SnappyCompressedBytes
+	m.SnappySkippedBytes += u.SnappySkippedBytes
+	m.ZstdCompressedBytes += u.ZstdCompressedBytes
+	m.ZstdSkippedBytes += u.ZstdSkippedBytes
+}
+
+func (m *CompressionMetrics) addProperties(p *sstable.Properties) {
+	m.SnappyCompressedBytes += p.SnappyCompressedBytes
+	m.SnappySkippedBytes += p.SnappySkippedBytes
+	m.ZstdCompressedBytes += p.ZstdCompressedBytes
+	m.ZstdSkippedBytes += p.ZstdSkippedBytes
+}
+
 // Add updates the counter metrics for the level.
 func (m *LevelMetrics) Add(u *LevelMetrics) {
 	m.NumFiles += u.NumFiles
@@ -130,6 +164,7 @@ func (m *LevelMetrics) Add(u *LevelMetrics) {
 	m.Additional.BytesWrittenDataBlocks += u.Additional.BytesWrittenDataBlocks
 	m.Additional.BytesWrittenValueBlocks += u.Additional.BytesWrittenValueBlocks
 	m.Additional.ValueBlocksSize += u.Additional.ValueBlocksSize
+	m.Additional.Compression.Add(&u.Additional.Compression)
 }
 
 // WriteAmp computes the write amplification for compactions at this
@@ -149,6 +184,26 @@ func (m *LevelMetrics) WriteAmp() float64 {
 // metrics reflect those operations.
 type Metrics struct {
 	BlockCache CacheMetrics
+	// BlockCacheTier holds metrics for the secondary tier of the block cache
+	// on local storage. It is the zero value if the cache was not created with
+	// a secondary tier (see NewTieredCache).
+	BlockCacheTier CacheTierMetrics
+	// BlockCacheWarmup holds metrics for the warm-up of the block cache from
+	// the blocks that were resident in the cache when the DB was last closed
+	// (see Options.BlockCacheWarmup).
+	BlockCacheWarmup struct {
+		// The number of blocks listed for warm-up when the DB was opened.
+		Blocks int64
+		// The number of listed blocks that have been processed so far. This
+		// includes blocks that were skipped because they were already in the
+		// cache or because their sstable is no longer live.
+		BlocksProcessed int64
+		// The number of blocks, and their total size, read into the cache.
+		BlocksLoaded int64
+		BytesLoaded  int64
+		// InProgress is true while the warm-up is running.
+		InProgress bool
+	}
 
 	Compact struct {
 		// The total number of compactions, and per-compaction type counts.
@@ -159,6 +214,7 @@ type Metrics struct {
 		MoveCount         int64
 		ReadCount         int64
 		RewriteCount      int64
+		PeriodicCount     int64
 		MultiLevelCount   int64
 		CounterLevelCount int64
 		// An estimate of the number of bytes that need to be compacted for the LSM
@@ -177,6 +233,9 @@ type Metrics struct {
 		// Duration records the cumulative duration of all compactions since the
 		// database was opened.
 		Duration time.Duration
+		// Strategy describes the strategy used to pick automatic compactions;
+		// see Options.CompactionPicker.
+		Strategy string
 	}
 
 	Ingest struct {
@@ -544,13 +603,14 @@ func (m *Metrics) SafeFormat(w redact.SafePrinter, _ rune) {
 		redact.Safe(m.Compact.NumInProgress),
 		humanize.Bytes.Int64(m.Compact.InProgressBytes))
 
-	w.Printf("             default: %d  delete: %d  elision: %d  move: %d  read: %d  rewrite: %d  multi-level: %d\n",
+	w.Printf("             default: %d  delete: %d  elision: %d  move: %d  read: %d  rewrite: %d  periodic: %d  multi-level: %d\n",
 		redact.Safe(m.Compact.DefaultCount),
 		redact.Safe(m.Compact.DeleteOnlyCount),
 		redact.Safe(m.Compact.ElisionOnlyCount),
 		redact.Safe(m.Compact.MoveCount),
 		redact.Safe(m.Compact.ReadCount),
 		redact.Safe(m.Compact.RewriteCount),
+		redact.Safe(m.Compact.PeriodicCount),
 		redact.Safe(m.Compact.MultiLevelCount))
 
 	w.Printf("MemTables: %d (%s)  zombie: %d (%s)\n",
@@ -578,6 +638,21 @@ func (m *Metrics) SafeFormat(w redact.SafePrinter, _ rune) {
 			redact.Safe(hitRate(m.Hits, m.Misses)))
 	}
 	formatCacheMetrics(&m.BlockCache, "Block cache")
+	if m.BlockCacheTier.Capacity > 0 {
+		w.Printf("Block cache tier: %s entries (%s of %s)  hit rate: %.1f%%  dropped writes: %d\n",
+			humanize.Count.Int64(m.BlockCacheTier.Count),
+			humanize.Bytes.Int64(m.BlockCacheTier.Size),
+			humanize.Bytes.Int64(m.BlockCacheTier.Capacity),
+			redact.Safe(hitRate(m.BlockCacheTier.Hits, m.BlockCacheTier.Misses)),
+			redact.Safe(m.BlockCacheTier.DroppedWrites))
+	}
+	if m.BlockCacheWarmup.Blocks > 0 {
+		w.Printf("Block cache warm-up: %s of %s blocks processed  loaded: %s blocks (%s)\n",
+			humanize.Count.Int64(m.BlockCacheWarmup.BlocksProcessed),
+			humanize.Count.Int64(m.BlockCacheWarmup.Blocks),
+			humanize.Count.Int64(m.BlockCacheWarmup.BlocksLoaded),
+			humanize.Bytes.Int64(m.BlockCacheWarmup.BytesLoaded))
+	}
 	formatCacheMetrics(&m.TableCache, "Table cache")
 
 	formatSharedCacheMetrics := func(w redact.SafePrinter, m *SecondaryCacheMetrics, name redact.SafeString) {
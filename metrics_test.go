@@ -36,6 +36,7 @@ func exampleMetrics() Metrics {
 	m.Compact.ReadCount = 31
 	m.Compact.RewriteCount = 32
 	m.Compact.MultiLevelCount = 33
+	m.Compact.PeriodicCount = 34
 	m.Compact.EstimatedDebt = 6
 	m.Compact.InProgressBytes = 7
 	m.Compact.NumInProgress = 2
@@ -379,3 +380,44 @@ func TestMetricsWAmpDisableWAL(t *testing.T) {
 	require.Greater(t, tot.WriteAmp(), 1.0)
 	require.NoError(t, d.Close())
 }
+
+func TestMetricsBlockCacheTier(t *testing.T) {
+	fs := vfs.NewMem()
+	c, err := NewTieredCache(1<<20, &CacheTierOptions{FS: fs, Dir: "cache-tier", Size: 16 << 20})
+	require.NoError(t, err)
+	defer c.Unref()
+
+	// NB: The memtable size is reserved in the cache.
+	d, err := Open("db", &Options{FS: fs, Cache: c, MemTableSize: 256 << 10})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	value := bytes.Repeat([]byte("v"), 1024)
+	for i := 0; i < 4000; i++ {
+		require.NoError(t, d.Set([]byte(fmt.Sprintf("key%06d", i)), value, nil))
+	}
+	require.NoError(t, d.Flush())
+
+	// Scan the table twice. The first scan evicts blocks from the small
+	// in-memory cache into the tier, and the second scan reads them back.
+	for j := 0; j < 2; j++ {
+		iter, _ := d.NewIter(nil)
+		n := 0
+		for valid := iter.First(); valid; valid = iter.Next() {
+			require.Equal(t, value, iter.Value())
+			n++
+		}
+		require.NoError(t, iter.Close())
+		require.Equal(t, 4000, n)
+		// Wait for the evicted blocks to be written to the tier.
+		c.WaitForTierWritesForTesting()
+	}
+
+	m := d.Metrics()
+	require.NotZero(t, m.BlockCacheTier.Writes)
+	require.NotZero(t, m.BlockCacheTier.Hits)
+	require.Equal(t, int64(16<<20), m.BlockCacheTier.Capacity)
+	require.Contains(t, m.String(), "Block cache tier: ")
+	em := exampleMetrics()
+	require.NotContains(t, em.String(), "Block cache tier")
+}
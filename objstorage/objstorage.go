@@ -275,6 +275,10 @@ type Provider interface {
 	// Cannot be called if shared storage is not configured for the provider.
 	SetCreatorID(creatorID CreatorID) error
 
+	// CreatorID returns the CreatorID set with SetCreatorID, or zero if it
+	// hasn't been set.
+	CreatorID() CreatorID
+
 	// IsSharedForeign returns whether this object is owned by a different node.
 	IsSharedForeign(meta ObjectMetadata) bool
 
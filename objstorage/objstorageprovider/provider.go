@@ -29,6 +29,9 @@ type provider struct {
 	st Settings
 
 	fsDir vfs.File
+	// localFS is used to create and open local objects. It is st.FS, wrapped
+	// with vfs.WithDirectIO if st.DirectIO is set.
+	localFS vfs.FS
 
 	tracer *objiotracing.Tracer
 
@@ -93,6 +96,13 @@ type Settings struct {
 	// out a large chunk of dirty filesystem buffers.
 	BytesPerSync int
 
+	// DirectIO enables direct I/O for local objects, which bypasses the OS
+	// page cache when reading and writing them (see vfs.WithDirectIO). Since
+	// OS-level readahead is not available in this case, readahead is performed
+	// by read handles themselves. If the filesystem does not support direct
+	// I/O, objects are read and written through the page cache.
+	DirectIO bool
+
 	// Fields here are set only if the provider is to support remote objects
 	// (experimental).
 	Remote struct {
@@ -167,8 +177,12 @@ func open(settings Settings) (p *provider, _ error) {
 	}()
 
 	p = &provider{
-		st:    settings,
-		fsDir: fsDir,
+		st:      settings,
+		fsDir:   fsDir,
+		localFS: settings.FS,
+	}
+	if settings.DirectIO {
+		p.localFS = vfs.WithDirectIO(settings.FS)
 	}
 	p.mu.knownObjects = make(map[base.DiskFileNum]objstorage.ObjectMetadata)
 	p.mu.protectedObjects = make(map[base.DiskFileNum]int)
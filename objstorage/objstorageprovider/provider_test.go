@@ -599,3 +599,53 @@ func TestParallelSync(t *testing.T) {
 		})
 	}
 }
+
+func TestDirectIO(t *testing.T) {
+	st := DefaultSettings(vfs.Default, t.TempDir())
+	st.DirectIO = true
+	p, err := Open(st)
+	require.NoError(t, err)
+	defer p.Close()
+
+	ctx := context.Background()
+	data := make([]byte, 2<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	w, _, err := p.Create(ctx, base.FileTypeTable, base.DiskFileNum(1), objstorage.CreateOptions{})
+	require.NoError(t, err)
+	for rest := data; len(rest) > 0; rest = rest[min(len(rest), 10000):] {
+		require.NoError(t, w.Write(append([]byte(nil), rest[:min(len(rest), 10000)]...)))
+	}
+	require.NoError(t, w.Finish())
+
+	r, err := p.OpenForReading(ctx, base.FileTypeTable, base.DiskFileNum(1), objstorage.OpenOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+	require.Equal(t, int64(len(data)), r.Size())
+	if !r.(*fileReadable).directIO {
+		t.Skipf("direct I/O is not supported in %s", st.FSDirName)
+	}
+
+	// Sequential reads eventually use the maximum readahead size, and reads
+	// are served from the readahead buffer.
+	rh := r.NewReadHandle(ctx)
+	defer rh.Close()
+	buf := make([]byte, 5000)
+	for off := 0; off+len(buf) <= len(data); off += len(buf) {
+		require.NoError(t, rh.ReadAt(ctx, buf, int64(off)))
+		require.Equal(t, data[off:off+len(buf)], buf)
+	}
+	require.True(t, TestingCheckMaxReadahead(rh))
+
+	// Compactions use the maximum readahead size right away.
+	rh2 := r.NewReadHandle(ctx)
+	defer rh2.Close()
+	require.False(t, TestingCheckMaxReadahead(rh2))
+	rh2.SetupForCompaction()
+	require.True(t, TestingCheckMaxReadahead(rh2))
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 100; i++ {
+		off := rng.Intn(len(data) - len(buf))
+		require.NoError(t, rh2.ReadAt(ctx, buf, int64(off)))
+		require.Equal(t, data[off:off+len(buf)], buf)
+	}
+}
@@ -155,6 +155,14 @@ func (p *provider) SetCreatorID(creatorID objstorage.CreatorID) error {
 	return nil
 }
 
+// CreatorID is part of the objstorage.Provider interface.
+func (p *provider) CreatorID() objstorage.CreatorID {
+	if !p.remote.shared.initialized.Load() {
+		return 0
+	}
+	return p.remote.shared.creatorID
+}
+
 // IsSharedForeign is part of the objstorage.Provider interface.
 func (p *provider) IsSharedForeign(meta objstorage.ObjectMetadata) bool {
 	if !p.remote.shared.initialized.Load() {
@@ -24,24 +24,25 @@ func (p *provider) vfsOpenForReading(
 	opts objstorage.OpenOptions,
 ) (objstorage.Readable, error) {
 	filename := p.vfsPath(fileType, fileNum)
-	file, err := p.st.FS.Open(filename, vfs.RandomReadsOption)
+	file, err := p.localFS.Open(filename, vfs.RandomReadsOption)
 	if err != nil {
 		if opts.MustExist {
 			base.MustExist(p.st.FS, filename, p.st.Logger, err)
 		}
 		return nil, err
 	}
-	return newFileReadable(file, p.st.FS, filename)
+	return newFileReadable(file, p.localFS, filename)
 }
 
 func (p *provider) vfsCreate(
 	_ context.Context, fileType base.FileType, fileNum base.DiskFileNum,
 ) (objstorage.Writable, objstorage.ObjectMetadata, error) {
 	filename := p.vfsPath(fileType, fileNum)
-	file, err := p.st.FS.Create(filename)
+	file, err := p.localFS.Create(filename)
 	if err != nil {
 		return nil, objstorage.ObjectMetadata{}, err
 	}
+	directIO := vfs.IsDirectIO(file)
 	file = vfs.NewSyncingFile(file, vfs.SyncingFileOptions{
 		NoSyncOnClose: p.st.NoSyncOnClose,
 		BytesPerSync:  p.st.BytesPerSync,
@@ -50,7 +51,7 @@ func (p *provider) vfsCreate(
 		DiskFileNum: fileNum,
 		FileType:    fileType,
 	}
-	return newFileBufferedWritable(file), meta, nil
+	return newFileBufferedWritable(file, directIO), meta, nil
 }
 
 func (p *provider) vfsRemove(fileType base.FileType, fileNum base.DiskFileNum) error {
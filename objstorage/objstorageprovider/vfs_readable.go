@@ -29,6 +29,10 @@ type fileReadable struct {
 	// sequential reads option (see vfsReadHandle).
 	filename string
 	fs       vfs.FS
+
+	// directIO is set if the file uses direct I/O (see vfs.WithDirectIO), in
+	// which case the OS does not cache the file's data or read ahead.
+	directIO bool
 }
 
 var _ objstorage.Readable = (*fileReadable)(nil)
@@ -43,6 +47,7 @@ func newFileReadable(file vfs.File, fs vfs.FS, filename string) (*fileReadable,
 		size:     info.Size(),
 		filename: filename,
 		fs:       fs,
+		directIO: vfs.IsDirectIO(file),
 	}
 	invariants.SetFinalizer(r, func(obj interface{}) {
 		if obj.(*fileReadable).file != nil {
@@ -90,6 +95,16 @@ type vfsReadHandle struct {
 	// OS-level readahead. Once this is non-nil, the other variables in
 	// readaheadState don't matter much as we defer to OS-level readahead.
 	sequentialFile vfs.File
+
+	// The following fields are used when the file uses direct I/O, which
+	// precludes OS-level readahead. Readahead is instead performed by reading
+	// into readaheadBuf, which holds the file's data at readaheadOffset.
+	// maxReadahead is set once readahead reaches fileMaxReadaheadSize, or if
+	// the handle is used for a compaction; the readahead size then remains at
+	// the maximum.
+	readaheadBuf    []byte
+	readaheadOffset int64
+	maxReadahead    bool
 }
 
 var _ objstorage.ReadHandle = (*vfsReadHandle)(nil)
@@ -121,6 +136,9 @@ func (rh *vfsReadHandle) Close() error {
 
 // ReadAt is part of the objstorage.ReadHandle interface.
 func (rh *vfsReadHandle) ReadAt(_ context.Context, p []byte, offset int64) error {
+	if rh.r.directIO {
+		return rh.readAtDirect(p, offset)
+	}
 	var n int
 	var err error
 	if rh.sequentialFile != nil {
@@ -144,12 +162,53 @@ func (rh *vfsReadHandle) ReadAt(_ context.Context, p []byte, offset int64) error
 	return err
 }
 
+// readAtDirect implements ReadAt for files that use direct I/O, performing
+// readahead into readaheadBuf.
+func (rh *vfsReadHandle) readAtDirect(p []byte, offset int64) error {
+	end := offset + int64(len(p))
+	if offset >= rh.readaheadOffset && end <= rh.readaheadOffset+int64(len(rh.readaheadBuf)) {
+		copy(p, rh.readaheadBuf[offset-rh.readaheadOffset:])
+		return nil
+	}
+	readaheadSize := int64(fileMaxReadaheadSize)
+	if !rh.maxReadahead {
+		readaheadSize = rh.rs.maybeReadahead(offset, int64(len(p)))
+		rh.maxReadahead = readaheadSize >= fileMaxReadaheadSize
+	}
+	readaheadSize = min(readaheadSize, rh.r.size-offset)
+	if readaheadSize <= int64(len(p)) {
+		n, err := rh.r.file.ReadAt(p, offset)
+		if invariants.Enabled && err == nil && n != len(p) {
+			panic("short read")
+		}
+		return err
+	}
+	if cap(rh.readaheadBuf) < int(readaheadSize) {
+		rh.readaheadBuf = make([]byte, 0, fileMaxReadaheadSize)
+	}
+	buf := rh.readaheadBuf[:readaheadSize]
+	if _, err := rh.r.file.ReadAt(buf, offset); err != nil {
+		rh.readaheadBuf = buf[:0]
+		return err
+	}
+	rh.readaheadBuf = buf
+	rh.readaheadOffset = offset
+	copy(p, buf)
+	return nil
+}
+
 // SetupForCompaction is part of the objstorage.ReadHandle interface.
 func (rh *vfsReadHandle) SetupForCompaction() {
 	rh.switchToOSReadahead()
 }
 
 func (rh *vfsReadHandle) switchToOSReadahead() {
+	if rh.r.directIO {
+		// OS-level readahead is not available; use the maximum readahead size
+		// instead.
+		rh.maxReadahead = true
+		return
+	}
 	if rh.sequentialFile != nil {
 		return
 	}
@@ -164,21 +223,23 @@ func (rh *vfsReadHandle) switchToOSReadahead() {
 
 // RecordCacheHit is part of the objstorage.ReadHandle interface.
 func (rh *vfsReadHandle) RecordCacheHit(_ context.Context, offset, size int64) {
-	if rh.sequentialFile != nil {
-		// Using OS-level readahead, so do nothing.
+	if rh.sequentialFile != nil || rh.maxReadahead {
+		// Using OS-level readahead or the maximum readahead size, so do
+		// nothing.
 		return
 	}
 	rh.rs.recordCacheHit(offset, size)
 }
 
 // TestingCheckMaxReadahead returns true if the ReadHandle has switched to
-// OS-level read-ahead.
+// OS-level read-ahead, or to the maximum readahead size for files that use
+// direct I/O.
 func TestingCheckMaxReadahead(rh objstorage.ReadHandle) bool {
 	switch rh := rh.(type) {
 	case *vfsReadHandle:
-		return rh.sequentialFile != nil
+		return rh.sequentialFile != nil || rh.maxReadahead
 	case *PreallocatedReadHandle:
-		return rh.sequentialFile != nil
+		return rh.sequentialFile != nil || rh.maxReadahead
 	default:
 		panic("unknown ReadHandle type")
 	}
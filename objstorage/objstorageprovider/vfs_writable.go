@@ -13,25 +13,34 @@ import (
 
 // NewFileWritable returns a Writable that uses a file as underlying storage.
 func NewFileWritable(file vfs.File) objstorage.Writable {
-	return newFileBufferedWritable(file)
+	return newFileBufferedWritable(file, vfs.IsDirectIO(file))
 }
 
 type fileBufferedWritable struct {
 	file vfs.File
-	bw   *bufio.Writer
+	// bw buffers the writes to file. It is nil if the file uses direct I/O, in
+	// which case the file buffers writes itself (in aligned memory).
+	bw *bufio.Writer
 }
 
 var _ objstorage.Writable = (*fileBufferedWritable)(nil)
 
-func newFileBufferedWritable(file vfs.File) *fileBufferedWritable {
-	return &fileBufferedWritable{
-		file: file,
-		bw:   bufio.NewWriter(file),
+// newFileBufferedWritable returns a fileBufferedWritable. directIO indicates
+// whether file (or the file it wraps) uses direct I/O.
+func newFileBufferedWritable(file vfs.File, directIO bool) *fileBufferedWritable {
+	w := &fileBufferedWritable{file: file}
+	if !directIO {
+		w.bw = bufio.NewWriter(file)
 	}
+	return w
 }
 
 // Write is part of the objstorage.Writable interface.
 func (w *fileBufferedWritable) Write(p []byte) error {
+	if w.bw == nil {
+		_, err := w.file.Write(p)
+		return err
+	}
 	// Ignoring the length written since bufio.Writer.Write is guaranteed to
 	// return an error if the length written is < len(p).
 	_, err := w.bw.Write(p)
@@ -40,7 +49,10 @@ func (w *fileBufferedWritable) Write(p []byte) error {
 
 // Finish is part of the objstorage.Writable interface.
 func (w *fileBufferedWritable) Finish() error {
-	err := w.bw.Flush()
+	var err error
+	if w.bw != nil {
+		err = w.bw.Flush()
+	}
 	if err == nil {
 		err = w.file.Sync()
 	}
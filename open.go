@@ -342,6 +342,7 @@ func Open(dirname string, opts *Options) (db *DB, err error) {
 		FSCleaner:           opts.Cleaner,
 		NoSyncOnClose:       opts.NoSyncOnClose,
 		BytesPerSync:        opts.BytesPerSync,
+		DirectIO:            opts.DirectIO,
 	}
 	providerSettings.Remote.StorageFactory = opts.Experimental.RemoteStorage
 	providerSettings.Remote.CreateOnShared = opts.Experimental.CreateOnShared
@@ -511,6 +512,7 @@ func Open(dirname string, opts *Options) (db *DB, err error) {
 			WALMinSyncInterval: d.opts.WALMinSyncInterval,
 			WALFsyncLatency:    d.mu.log.metrics.fsyncLatency,
 			QueueSemChan:       d.commit.logSyncQSem,
+			Compression:        d.walCompression(),
 		}
 		d.mu.log.LogWriter = record.NewLogWriter(logFile, newLogNum, logWriterConfig)
 		d.mu.versions.metrics.WAL.Files++
@@ -587,6 +589,13 @@ func Open(dirname string, opts *Options) (db *DB, err error) {
 
 	d.maybeScheduleFlush()
 	d.maybeScheduleCompaction()
+	d.startCompactionTimer()
+	if p := d.opts.BlockCacheEvictionPressure; p.Threshold > 0 {
+		d.removeEvictionPressureListener = d.opts.Cache.SetEvictionPressureListener(
+			p.Threshold, p.Interval, d.opts.EventListener.BlockCacheEvictionPressure)
+	}
+	d.claimCacheTierNamespace(!manifestExists)
+	d.startBlockCacheWarmup()
 
 	// Note: this is a no-op if invariants are disabled or race is enabled.
 	//
@@ -193,7 +193,7 @@ func TestNewDBFilenames(t *testing.T) {
 			"LOCK",
 			"MANIFEST-000001",
 			"OPTIONS-000003",
-			"marker.format-version.000004.017",
+			"marker.format-version.000007.020",
 			"marker.manifest.000001.MANIFEST-000001",
 		},
 	}
@@ -606,6 +606,48 @@ func TestOpenWALReplay(t *testing.T) {
 	}
 }
 
+func TestOpenWALReplayCompressed(t *testing.T) {
+	value := []byte(strings.Repeat("compressible", 10<<10))
+	for _, c := range []Compression{SnappyCompression, ZstdCompression} {
+		t.Run(c.String(), func(t *testing.T) {
+			mem := vfs.NewMem()
+			opts := &Options{
+				FS:                 mem,
+				FormatMajorVersion: FormatWALCompression,
+				MemTableSize:       32 << 20,
+				WALCompression:     c,
+			}
+			d, err := Open("", opts)
+			require.NoError(t, err)
+			// The DB was created at a lower format major version and ratcheted
+			// during Open, so force a new WAL which uses compression.
+			require.NoError(t, d.Flush())
+			for i := 0; i < 10; i++ {
+				require.NoError(t, d.Set([]byte(fmt.Sprint(i)), value, nil))
+			}
+			d.mu.Lock()
+			logNum := d.mu.log.queue[len(d.mu.log.queue)-1].fileNum
+			d.mu.Unlock()
+			require.NoError(t, d.Close())
+
+			// The WAL is considerably smaller than the values written to it.
+			fi, err := mem.Stat(base.MakeFilepath(mem, "", fileTypeLog, logNum))
+			require.NoError(t, err)
+			require.Less(t, fi.Size(), int64(len(value)))
+
+			d, err = Open("", opts)
+			require.NoError(t, err)
+			for i := 0; i < 10; i++ {
+				v, closer, err := d.Get([]byte(fmt.Sprint(i)))
+				require.NoError(t, err)
+				require.Equal(t, value, v)
+				require.NoError(t, closer.Close())
+			}
+			require.NoError(t, d.Close())
+		})
+	}
+}
+
 // Reproduction for https://github.com/cockroachdb/pebble/issues/2234.
 func TestWALReplaySequenceNumBug(t *testing.T) {
 	mem := vfs.NewMem()
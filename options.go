@@ -27,8 +27,9 @@ import (
 )
 
 const (
-	cacheDefaultSize       = 8 << 20 // 8 MB
-	defaultLevelMultiplier = 10
+	cacheDefaultSize                  = 8 << 20 // 8 MB
+	defaultLevelMultiplier            = 10
+	defaultCompactionProgressInterval = 10 * time.Second
 )
 
 // Compression exports the base.Compression type.
@@ -184,6 +185,24 @@ type IterOptions struct {
 	// existing is not low or if we just expect a one-time Seek (where loading the
 	// data block directly is better).
 	UseL6Filters bool
+	// BlockCacheMode controls how the data blocks read by the iterator are added
+	// to the block cache. Large scans that are not expected to revisit the
+	// blocks they read may use sstable.BlockCacheLowPriority or
+	// sstable.BlockCacheBypass to avoid evicting blocks needed by other reads.
+	// Index and filter blocks are always added to the block cache.
+	BlockCacheMode sstable.BlockCacheMode
+	// PrefetchBlocks, if positive, enables a scan mode for long forward scans
+	// in which data blocks are read asynchronously before the iterator needs
+	// them, so that the iterator doesn't wait for each block to be read in
+	// turn. Each sstable iterator in the iterator tree reads up to
+	// PrefetchBlocks data blocks ahead of its position, and the iterator of
+	// each level starts reading the first data blocks of the level's next
+	// sstable before it reaches the end of the current one. The memory used by
+	// prefetched blocks is therefore bounded by about 2*PrefetchBlocks blocks
+	// per level. Prefetching stops at the iterator's upper bound and restarts
+	// after seeks; it does not apply to reverse iteration. The prefetch
+	// statistics in IteratorStats report how effective it is.
+	PrefetchBlocks int
 	// CategoryAndQoS is used for categorized iterator stats. This should not be
 	// changed by calling SetOptions.
 	sstable.CategoryAndQoS
@@ -215,6 +234,23 @@ func (o *IterOptions) GetLowerBound() []byte {
 	return o.LowerBound
 }
 
+// getBlockCacheMode returns the BlockCacheMode, or the default mode if the
+// receiver is nil.
+func (o *IterOptions) getBlockCacheMode() sstable.BlockCacheMode {
+	if o == nil {
+		return sstable.BlockCacheDefault
+	}
+	return o.BlockCacheMode
+}
+
+// getPrefetchBlocks returns PrefetchBlocks, or zero if the receiver is nil.
+func (o *IterOptions) getPrefetchBlocks() int {
+	if o == nil {
+		return 0
+	}
+	return o.PrefetchBlocks
+}
+
 // GetUpperBound returns the UpperBound or nil if the receiver is nil.
 func (o *IterOptions) GetUpperBound() []byte {
 	if o == nil {
@@ -406,6 +442,37 @@ type LevelOptions struct {
 	// The default value (DefaultCompression) uses snappy compression.
 	Compression Compression
 
+	// AdaptiveCompression configures adaptive per-block selection of the
+	// compression algorithm. When enabled, sstable writers sample the
+	// compression ratio achieved by Compression and fall back to a cheaper
+	// algorithm (by default, no compression) for blocks written while the
+	// savings are below a threshold.
+	//
+	// The default value disables adaptive compression.
+	AdaptiveCompression sstable.AdaptiveCompressionOptions
+
+	// DataBlockHashIndex enables a hash index in each data block that maps the
+	// prefixes (as defined by Comparer.Split) of the block's keys to the
+	// block's restart intervals. The hash index speeds up point lookups (Get
+	// and SeekPrefixGE) within a data block, at the cost of a few bytes per
+	// distinct prefix. It requires a FormatMajorVersion of at least
+	// FormatDataBlockHashIndex, and is ignored for older format major versions.
+	//
+	// The default value disables the hash index.
+	DataBlockHashIndex bool
+
+	// ColumnarDataBlocks enables columnar data blocks, which store the
+	// prefixes (as defined by Comparer.Split) of the block's keys once per
+	// distinct prefix, and the keys' suffixes and values in separate columns.
+	// Columnar blocks speed up NextPrefix and improve the compression of
+	// MVCC data with many versions per key. It requires a FormatMajorVersion of
+	// at least FormatColumnarDataBlocks, and is ignored for older format major
+	// versions. Columnar data blocks do not use a hash index, so this option
+	// takes precedence over DataBlockHashIndex.
+	//
+	// The default value uses row-oriented data blocks.
+	ColumnarDataBlocks bool
+
 	// FilterPolicy defines a filter algorithm (such as a Bloom filter) that can
 	// reduce disk reads for Get calls.
 	//
@@ -458,6 +525,9 @@ func (o *LevelOptions) EnsureDefaults() *LevelOptions {
 	if o.Compression <= DefaultCompression || o.Compression >= sstable.NCompression {
 		o.Compression = SnappyCompression
 	}
+	if o.AdaptiveCompression.Enabled {
+		o.AdaptiveCompression = o.AdaptiveCompression.EnsureDefaults()
+	}
 	if o.IndexBlockSize <= 0 {
 		o.IndexBlockSize = o.BlockSize
 	}
@@ -471,6 +541,39 @@ func (o *LevelOptions) EnsureDefaults() *LevelOptions {
 // apply to the DB at large; per-query options are defined by the IterOptions
 // and WriteOptions types.
 type Options struct {
+	// BlockCacheEvictionPressure configures the
+	// EventListener.BlockCacheEvictionPressure event, which is invoked when the
+	// eviction pressure of the block cache crosses Threshold (see
+	// CacheEvictionPressureInfo). A cache has a single listener, so if the
+	// cache is shared by multiple DBs, only the DB opened last with a
+	// threshold receives the event.
+	BlockCacheEvictionPressure struct {
+		// Threshold is the eviction pressure, as the fraction of the cache's
+		// capacity evicted per second, that triggers the event. The zero value
+		// disables the event.
+		Threshold float64
+		// Interval is the duration over which the pressure is measured. The
+		// default value is 1 second.
+		Interval time.Duration
+	}
+
+	// BlockCacheWarmup configures the warm-up of the block cache after a
+	// restart. While the DB is open, it periodically writes the keys (sstable
+	// file number and block offset) of the blocks resident in the block cache
+	// to a file in the data directory, and writes the file once more when the
+	// DB is closed. When the DB is opened, the listed blocks belonging to
+	// sstables that are still live are read back into the block cache in the
+	// background. Progress is reported in Metrics.BlockCacheWarmup.
+	BlockCacheWarmup struct {
+		// PersistInterval is the interval at which the keys of the resident
+		// blocks are written. The zero value disables both the persistence of
+		// the keys and the warm-up.
+		PersistInterval time.Duration
+		// BytesPerSecond limits the rate at which blocks are read during
+		// warm-up. The default value is 32 MB/s.
+		BytesPerSecond int64
+	}
+
 	// Sync sstables periodically in order to smooth out writes to disk. This
 	// option does not provide any persistency guarantee, but is used to avoid
 	// latency spikes if the OS automatically decides to write out a large chunk
@@ -490,6 +593,60 @@ type Options struct {
 	// The default cleaner uses the DeleteCleaner.
 	Cleaner Cleaner
 
+	// CompactionOutputSplitter, if non-nil, partitions the keyspace into
+	// contiguous ranges that no sstable written by a flush or compaction will
+	// span. It is called with a user key and must return the user key at which
+	// the partition following the key's partition begins, or nil if the key is
+	// in the last partition. The returned key must be greater than the provided
+	// key according to Comparer, and the function must be deterministic.
+	//
+	// For example, a DB whose keys are prefixed by a tenant ID may return the
+	// smallest key of the next tenant to ensure that sstables never contain
+	// keys of two tenants, keeping excises and per-tenant deletions cheap.
+	// Output files are still split by size and grandparent overlap within a
+	// partition. Files that span a partition boundary (eg, ingested files) are
+	// rewritten rather than moved when compacted.
+	//
+	// The default value does not partition the keyspace.
+	CompactionOutputSplitter func(userKey []byte) []byte
+
+	// CompactionPicker determines the strategy used to pick automatic
+	// compactions. LeveledCompactionPicker minimizes space and read
+	// amplification, while TieredCompactionPicker trades them for lower write
+	// amplification on write-heavy workloads. FIFOCompactionPicker keeps all
+	// data in L0 and drops the oldest tables, for append-only data that is
+	// only retained up to a size or age.
+	//
+	// The default value uses LeveledCompactionPicker.
+	CompactionPicker CompactionPicker
+
+	// CompactionRateLimiter, if set, limits the rate at which compactions
+	// write sstables. See NewCompactionRateLimiter and
+	// NewAutoTunedCompactionRateLimiter.
+	//
+	// The default value does not limit compactions.
+	CompactionRateLimiter *CompactionRateLimiter
+
+	// PeriodicCompactionInterval, if positive, is the age past which tables are
+	// rewritten by periodic compactions, so that tombstones in key ranges that
+	// aren't otherwise compacted are eventually dropped. A table's age is
+	// measured from its creation time, which isn't known for tables written by
+	// old versions of Pebble; such tables are never considered. Periodic
+	// compactions have the lowest priority of automatic compactions, and at
+	// most one of them runs at a time. They're rate limited so that they
+	// rewrite at most all of the tables below L0 once per interval, spread
+	// evenly across it. They're also scheduled while the DB is idle. Only
+	// LeveledCompactionPicker picks them.
+	//
+	// The default value disables periodic compactions.
+	PeriodicCompactionInterval time.Duration
+
+	// CompactionProgressInterval is the interval at which the progress of a
+	// running compaction is reported to EventListener.CompactionProgress.
+	//
+	// The default value is 10s.
+	CompactionProgressInterval time.Duration
+
 	// Comparer defines a total ordering over the space of []byte keys: a 'less
 	// than' relationship. The same comparison algorithm must be used for reads
 	// and writes over the lifetime of the DB.
@@ -502,6 +659,18 @@ type Options struct {
 	// or tools only, to check invariants over all the data in the database.
 	DebugCheck func(*DB) error
 
+	// DirectIO enables direct I/O (O_DIRECT) for sstables on Linux, so that
+	// sstable reads and writes (notably those of compactions) bypass the OS
+	// page cache instead of evicting pages other processes need. The block
+	// cache should be sized accordingly, since sstable data is no longer cached
+	// by the OS. Readahead, which the OS no longer performs, is performed by
+	// Pebble. If the platform or filesystem does not support direct I/O,
+	// sstables are read and written through the page cache. The WAL and other
+	// files are not affected. See vfs.WithDirectIO.
+	//
+	// The default value is false.
+	DirectIO bool
+
 	// Disable the write-ahead log (WAL). Disabling the write-ahead log prohibits
 	// crash recovery, but can improve performance if crash recovery is not
 	// needed (e.g. when only temporary state is being stored in the database).
@@ -640,6 +809,18 @@ type Options struct {
 		// is enough CPU available, and this option bypasses that.
 		ForceWriterParallelism bool
 
+		// MaxSubcompactions is the maximum number of subcompactions a single
+		// compaction may be split into. Subcompactions compact disjoint key
+		// ranges of the compaction's inputs in parallel, each writing its own
+		// output tables, which shortens large compactions into lower levels at
+		// the cost of additional concurrency. A compaction is only split if
+		// fewer than MaxConcurrentCompactions compactions are running, into at
+		// most as many subcompactions as there are idle compaction slots plus
+		// one. Intra-L0 compactions and flushes are never split.
+		//
+		// By default, or if MaxSubcompactions <= 1, compactions are not split.
+		MaxSubcompactions int
+
 		// CPUWorkPermissionGranter should be set if Pebble should be given the
 		// ability to optionally schedule additional CPU. See the documentation
 		// for CPUWorkPermissionGranter for more details.
@@ -700,6 +881,13 @@ type Options struct {
 		CreateOnShared        remote.CreateOnSharedStrategy
 		CreateOnSharedLocator remote.Locator
 
+		// CompactionExecutor, if set, runs the compactions whose input tables
+		// are all on remote storage and whose output tables would be created on
+		// shared storage, instead of running them in the DB's process. See
+		// CompactionExecutor for details. The DB must have a creator ID (see
+		// DB.SetCreatorID).
+		CompactionExecutor CompactionExecutor
+
 		// CacheSizeBytesBytes is the size of the on-disk block cache for objects
 		// on shared storage in bytes. If it is 0, no cache is used.
 		SecondaryCacheSizeBytes int64
@@ -982,6 +1170,15 @@ type Options struct {
 	// default behaviour in RocksDB.
 	WALBytesPerSync int
 
+	// WALCompression specifies the algorithm used to compress the batches
+	// written to the WAL. Records that do not shrink when compressed are
+	// written uncompressed. WAL compression requires a format major version of
+	// at least FormatWALCompression; WALs are written uncompressed while the
+	// database's format major version is lower.
+	//
+	// The default value (DefaultCompression) uses no compression.
+	WALCompression Compression
+
 	// WALDir specifies the directory to store write-ahead logs (WALs) in. If
 	// empty (the default), WALs will be stored in the same directory as sstables
 	// (i.e. the directory passed to pebble.Open).
@@ -1077,6 +1274,12 @@ func (o *Options) EnsureDefaults() *Options {
 	if o == nil {
 		o = &Options{}
 	}
+	if o.BlockCacheEvictionPressure.Interval <= 0 {
+		o.BlockCacheEvictionPressure.Interval = time.Second
+	}
+	if o.BlockCacheWarmup.BytesPerSecond <= 0 {
+		o.BlockCacheWarmup.BytesPerSecond = 32 << 20 // 32 MB/s
+	}
 	if o.BytesPerSync <= 0 {
 		o.BytesPerSync = 512 << 10 // 512 KB
 	}
@@ -1086,6 +1289,9 @@ func (o *Options) EnsureDefaults() *Options {
 	if o.Comparer == nil {
 		o.Comparer = DefaultComparer
 	}
+	if o.CompactionProgressInterval <= 0 {
+		o.CompactionProgressInterval = defaultCompactionProgressInterval
+	}
 	if o.Experimental.DisableIngestAsFlushable == nil {
 		o.Experimental.DisableIngestAsFlushable = func() bool { return false }
 	}
@@ -1308,11 +1514,28 @@ func (o *Options) String() string {
 	fmt.Fprintf(&buf, "  pebble_version=0.1\n")
 	fmt.Fprintf(&buf, "\n")
 	fmt.Fprintf(&buf, "[Options]\n")
+	if o.BlockCacheEvictionPressure.Threshold != 0 {
+		fmt.Fprintf(&buf, "  block_cache_eviction_pressure_interval=%s\n", o.BlockCacheEvictionPressure.Interval)
+		fmt.Fprintf(&buf, "  block_cache_eviction_pressure_threshold=%g\n", o.BlockCacheEvictionPressure.Threshold)
+	}
+	if o.BlockCacheWarmup.PersistInterval != 0 {
+		fmt.Fprintf(&buf, "  block_cache_warmup_bytes_per_second=%d\n", o.BlockCacheWarmup.BytesPerSecond)
+		fmt.Fprintf(&buf, "  block_cache_warmup_persist_interval=%s\n", o.BlockCacheWarmup.PersistInterval)
+	}
 	fmt.Fprintf(&buf, "  bytes_per_sync=%d\n", o.BytesPerSync)
 	fmt.Fprintf(&buf, "  cache_size=%d\n", cacheSize)
 	fmt.Fprintf(&buf, "  cleaner=%s\n", o.Cleaner)
 	fmt.Fprintf(&buf, "  compaction_debt_concurrency=%d\n", o.Experimental.CompactionDebtConcurrency)
+	if o.CompactionPicker != nil {
+		fmt.Fprintf(&buf, "  compaction_picker=%s\n", o.CompactionPicker)
+	}
+	if o.CompactionProgressInterval != defaultCompactionProgressInterval {
+		fmt.Fprintf(&buf, "  compaction_progress_interval=%s\n", o.CompactionProgressInterval)
+	}
 	fmt.Fprintf(&buf, "  comparer=%s\n", o.Comparer.Name)
+	if o.DirectIO {
+		fmt.Fprintf(&buf, "  direct_io=%t\n", o.DirectIO)
+	}
 	fmt.Fprintf(&buf, "  disable_wal=%t\n", o.DisableWAL)
 	if o.Experimental.DisableIngestAsFlushable != nil && o.Experimental.DisableIngestAsFlushable() {
 		fmt.Fprintf(&buf, "  disable_ingest_as_flushable=%t\n", true)
@@ -1339,6 +1562,9 @@ func (o *Options) String() string {
 	if o.Experimental.MultiLevelCompactionHeuristic != nil {
 		fmt.Fprintf(&buf, "  multilevel_compaction_heuristic=%s\n", o.Experimental.MultiLevelCompactionHeuristic.String())
 	}
+	if o.PeriodicCompactionInterval != 0 {
+		fmt.Fprintf(&buf, "  periodic_compaction_interval=%s\n", o.PeriodicCompactionInterval)
+	}
 	fmt.Fprintf(&buf, "  read_compaction_rate=%d\n", o.Experimental.ReadCompactionRate)
 	fmt.Fprintf(&buf, "  read_sampling_multiplier=%d\n", o.Experimental.ReadSamplingMultiplier)
 	fmt.Fprintf(&buf, "  strict_wal_tail=%t\n", o.private.strictWALTail)
@@ -1346,8 +1572,14 @@ func (o *Options) String() string {
 	fmt.Fprintf(&buf, "  validate_on_ingest=%t\n", o.Experimental.ValidateOnIngest)
 	fmt.Fprintf(&buf, "  wal_dir=%s\n", o.WALDir)
 	fmt.Fprintf(&buf, "  wal_bytes_per_sync=%d\n", o.WALBytesPerSync)
+	if o.WALCompression != DefaultCompression {
+		fmt.Fprintf(&buf, "  wal_compression=%s\n", o.WALCompression)
+	}
 	fmt.Fprintf(&buf, "  max_writer_concurrency=%d\n", o.Experimental.MaxWriterConcurrency)
 	fmt.Fprintf(&buf, "  force_writer_parallelism=%t\n", o.Experimental.ForceWriterParallelism)
+	if o.Experimental.MaxSubcompactions > 1 {
+		fmt.Fprintf(&buf, "  max_subcompactions=%d\n", o.Experimental.MaxSubcompactions)
+	}
 	fmt.Fprintf(&buf, "  secondary_cache_size_bytes=%d\n", o.Experimental.SecondaryCacheSizeBytes)
 	fmt.Fprintf(&buf, "  create_on_shared=%d\n", o.Experimental.CreateOnShared)
 
@@ -1375,6 +1607,18 @@ func (o *Options) String() string {
 		fmt.Fprintf(&buf, "  block_size=%d\n", l.BlockSize)
 		fmt.Fprintf(&buf, "  block_size_threshold=%d\n", l.BlockSizeThreshold)
 		fmt.Fprintf(&buf, "  compression=%s\n", l.Compression)
+		if a := l.AdaptiveCompression; a.Enabled {
+			fmt.Fprintln(&buf, "  adaptive_compression=true")
+			fmt.Fprintf(&buf, "  adaptive_compression_fallback=%s\n", a.Fallback)
+			fmt.Fprintf(&buf, "  adaptive_compression_min_savings_percent=%d\n", a.MinSavingsPercent)
+			fmt.Fprintf(&buf, "  adaptive_compression_sample_interval=%d\n", a.SampleInterval)
+		}
+		if l.DataBlockHashIndex {
+			fmt.Fprintln(&buf, "  data_block_hash_index=true")
+		}
+		if l.ColumnarDataBlocks {
+			fmt.Fprintln(&buf, "  columnar_data_blocks=true")
+		}
 		fmt.Fprintf(&buf, "  filter_policy=%s\n", filterPolicyName(l.FilterPolicy))
 		fmt.Fprintf(&buf, "  filter_type=%s\n", l.FilterType)
 		fmt.Fprintf(&buf, "  index_block_size=%d\n", l.IndexBlockSize)
@@ -1384,6 +1628,21 @@ func (o *Options) String() string {
 	return buf.String()
 }
 
+func parseCompression(value string) (Compression, error) {
+	switch value {
+	case "Default":
+		return DefaultCompression, nil
+	case "NoCompression":
+		return NoCompression, nil
+	case "Snappy":
+		return SnappyCompression, nil
+	case "ZSTD":
+		return ZstdCompression, nil
+	default:
+		return DefaultCompression, errors.Errorf("pebble: unknown compression: %q", errors.Safe(value))
+	}
+}
+
 func parseOptions(s string, fn func(section, key, value string) error) error {
 	var section string
 	for _, line := range strings.Split(s, "\n") {
@@ -1473,6 +1732,14 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 		case section == "Options":
 			var err error
 			switch key {
+			case "block_cache_eviction_pressure_interval":
+				o.BlockCacheEvictionPressure.Interval, err = time.ParseDuration(value)
+			case "block_cache_eviction_pressure_threshold":
+				o.BlockCacheEvictionPressure.Threshold, err = strconv.ParseFloat(value, 64)
+			case "block_cache_warmup_bytes_per_second":
+				o.BlockCacheWarmup.BytesPerSecond, err = strconv.ParseInt(value, 10, 64)
+			case "block_cache_warmup_persist_interval":
+				o.BlockCacheWarmup.PersistInterval, err = time.ParseDuration(value)
 			case "bytes_per_sync":
 				o.BytesPerSync, err = strconv.Atoi(value)
 			case "cache_size":
@@ -1497,6 +1764,56 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 						o.Cleaner, err = hooks.NewCleaner(value)
 					}
 				}
+			case "compaction_picker":
+				switch {
+				case value == "leveled":
+					o.CompactionPicker = LeveledCompactionPicker{}
+				case strings.HasPrefix(value, "tiered"):
+					fields := strings.FieldsFunc(strings.TrimPrefix(value, "tiered"), func(r rune) bool {
+						return unicode.IsSpace(r) || r == ',' || r == '(' || r == ')'
+					})
+					if len(fields) != 4 {
+						err = errors.Newf("require 4 arguments")
+					}
+					var t TieredCompactionPicker
+					for i, v := range []*int{&t.SizeRatio, &t.MinMergeWidth, &t.MaxMergeWidth, &t.MaxSizeAmplificationPercent} {
+						if err == nil {
+							*v, err = strconv.Atoi(fields[i])
+						}
+					}
+					if err == nil {
+						o.CompactionPicker = t
+					} else {
+						err = errors.Wrapf(err, "unexpected tiered compaction picker arguments: %s", value)
+					}
+				case strings.HasPrefix(value, "fifo"):
+					fields := strings.FieldsFunc(strings.TrimPrefix(value, "fifo"), func(r rune) bool {
+						return unicode.IsSpace(r) || r == ',' || r == '(' || r == ')'
+					})
+					if len(fields) != 4 {
+						err = errors.Newf("require 4 arguments")
+					}
+					var f FIFOCompactionPicker
+					if err == nil {
+						f.MaxTableFilesSize, err = strconv.ParseUint(fields[0], 10, 64)
+					}
+					if err == nil {
+						f.TTL, err = time.ParseDuration(fields[1])
+					}
+					if err == nil {
+						f.MinMergeWidth, err = strconv.Atoi(fields[2])
+					}
+					if err == nil {
+						f.MaxMergeBytes, err = strconv.ParseUint(fields[3], 10, 64)
+					}
+					if err == nil {
+						o.CompactionPicker = f
+					} else {
+						err = errors.Wrapf(err, "unexpected fifo compaction picker arguments: %s", value)
+					}
+				default:
+					err = errors.Newf("unrecognized compaction picker: %s", value)
+				}
 			case "comparer":
 				switch value {
 				case "leveldb.BytewiseComparator":
@@ -1508,6 +1825,8 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 				}
 			case "compaction_debt_concurrency":
 				o.Experimental.CompactionDebtConcurrency, err = strconv.ParseUint(value, 10, 64)
+			case "compaction_progress_interval":
+				o.CompactionProgressInterval, err = time.ParseDuration(value)
 			case "delete_range_flush_delay":
 				// NB: This is a deprecated serialization of the
 				// `flush_delay_delete_range`.
@@ -1524,6 +1843,8 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 				}
 			case "disable_lazy_combined_iteration":
 				o.private.disableLazyCombinedIteration, err = strconv.ParseBool(value)
+			case "direct_io":
+				o.DirectIO, err = strconv.ParseBool(value)
 			case "disable_wal":
 				o.DisableWAL, err = strconv.ParseBool(value)
 			case "flush_delay_delete_range":
@@ -1609,6 +1930,8 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 				default:
 					err = errors.Newf("unrecognized multilevel compaction heuristic: %s", value)
 				}
+			case "periodic_compaction_interval":
+				o.PeriodicCompactionInterval, err = time.ParseDuration(value)
 			case "point_tombstone_weight":
 				// Do nothing; deprecated.
 			case "strict_wal_tail":
@@ -1645,10 +1968,14 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 				o.WALDir = value
 			case "wal_bytes_per_sync":
 				o.WALBytesPerSync, err = strconv.Atoi(value)
+			case "wal_compression":
+				o.WALCompression, err = parseCompression(value)
 			case "max_writer_concurrency":
 				o.Experimental.MaxWriterConcurrency, err = strconv.Atoi(value)
 			case "force_writer_parallelism":
 				o.Experimental.ForceWriterParallelism, err = strconv.ParseBool(value)
+			case "max_subcompactions":
+				o.Experimental.MaxSubcompactions, err = strconv.Atoi(value)
 			case "secondary_cache_size_bytes":
 				o.Experimental.SecondaryCacheSizeBytes, err = strconv.ParseInt(value, 10, 64)
 			case "create_on_shared":
@@ -1691,18 +2018,19 @@ func (o *Options) Parse(s string, hooks *ParseHooks) error {
 			case "block_size_threshold":
 				l.BlockSizeThreshold, err = strconv.Atoi(value)
 			case "compression":
-				switch value {
-				case "Default":
-					l.Compression = DefaultCompression
-				case "NoCompression":
-					l.Compression = NoCompression
-				case "Snappy":
-					l.Compression = SnappyCompression
-				case "ZSTD":
-					l.Compression = ZstdCompression
-				default:
-					return errors.Errorf("pebble: unknown compression: %q", errors.Safe(value))
-				}
+				l.Compression, err = parseCompression(value)
+			case "adaptive_compression":
+				l.AdaptiveCompression.Enabled, err = strconv.ParseBool(value)
+			case "adaptive_compression_fallback":
+				l.AdaptiveCompression.Fallback, err = parseCompression(value)
+			case "adaptive_compression_min_savings_percent":
+				l.AdaptiveCompression.MinSavingsPercent, err = strconv.Atoi(value)
+			case "adaptive_compression_sample_interval":
+				l.AdaptiveCompression.SampleInterval, err = strconv.Atoi(value)
+			case "data_block_hash_index":
+				l.DataBlockHashIndex, err = strconv.ParseBool(value)
+			case "columnar_data_blocks":
+				l.ColumnarDataBlocks, err = strconv.ParseBool(value)
 			case "filter_policy":
 				if hooks != nil && hooks.NewFilterPolicy != nil {
 					l.FilterPolicy, err = hooks.NewFilterPolicy(value)
@@ -1851,6 +2179,9 @@ func (o *Options) MakeWriterOptions(level int, format sstable.TableFormat) sstab
 	writerOpts.BlockSize = levelOpts.BlockSize
 	writerOpts.BlockSizeThreshold = levelOpts.BlockSizeThreshold
 	writerOpts.Compression = levelOpts.Compression
+	writerOpts.AdaptiveCompression = levelOpts.AdaptiveCompression
+	writerOpts.DataBlockHashIndex = levelOpts.DataBlockHashIndex
+	writerOpts.ColumnarDataBlocks = levelOpts.ColumnarDataBlocks
 	writerOpts.FilterPolicy = levelOpts.FilterPolicy
 	writerOpts.FilterType = levelOpts.FilterType
 	writerOpts.IndexBlockSize = levelOpts.IndexBlockSize
@@ -229,7 +229,14 @@ func TestOptionsParse(t *testing.T) {
 			opts.Levels[0].BlockSize = 1024
 			opts.Levels[1].BlockSize = 2048
 			opts.Levels[2].BlockSize = 4096
+			opts.Levels[2].AdaptiveCompression.Enabled = true
+			opts.Levels[2].AdaptiveCompression.Fallback = SnappyCompression
+			opts.Levels[1].DataBlockHashIndex = true
+			opts.Levels[2].ColumnarDataBlocks = true
+			opts.DirectIO = true
+			opts.CompactionPicker = TieredCompactionPicker{SizeRatio: 10, MaxSizeAmplificationPercent: 150}
 			opts.Experimental.CompactionDebtConcurrency = 100
+			opts.CompactionProgressInterval = 30 * time.Second
 			opts.FlushDelayDeleteRange = 10 * time.Second
 			opts.FlushDelayRangeKey = 11 * time.Second
 			opts.Experimental.LevelMultiplier = 5
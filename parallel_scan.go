@@ -0,0 +1,140 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// ParallelScanFunc is invoked by ParallelScan for every key within the scanned
+// range. The partition identifies which of the concurrent scans produced the
+// key. The key and value are only valid for the duration of the call.
+type ParallelScanFunc func(partition int, key, value []byte) error
+
+// ParallelScan scans the point keys within [start, end) using up to
+// parallelism concurrent iterators, invoking fn for every key. A nil start or
+// end leaves the corresponding side of the range unbounded.
+//
+// The range is divided into partitions of roughly equal size using the
+// boundaries and sizes of the sstables that overlap it, and every partition is
+// read at the same sequence number through an implicit snapshot. Keys are
+// passed to fn in order within a partition, but calls for different partitions
+// are made concurrently and in no particular order, so fn must be safe for
+// concurrent use. Fewer than parallelism partitions are used if the sstables
+// don't provide enough distinct boundaries.
+//
+// If fn returns an error or ctx is canceled, the remaining scans are stopped
+// and the first error is returned.
+func (d *DB) ParallelScan(
+	ctx context.Context, start, end []byte, parallelism int, fn ParallelScanFunc,
+) error {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	s := d.NewSnapshot()
+	defer s.Close()
+	return s.ParallelScan(ctx, start, end, parallelism, fn)
+}
+
+// ParallelScan is like DB.ParallelScan, but reads the keys visible to the
+// snapshot.
+func (s *Snapshot) ParallelScan(
+	ctx context.Context, start, end []byte, parallelism int, fn ParallelScanFunc,
+) error {
+	if s.db == nil {
+		panic(ErrClosed)
+	}
+	return s.db.parallelScan(ctx, snapshotIterOpts{seqNum: s.seqNum}, start, end, parallelism, fn)
+}
+
+func (d *DB) parallelScan(
+	ctx context.Context,
+	snapshot snapshotIterOpts,
+	start, end []byte,
+	parallelism int,
+	fn ParallelScanFunc,
+) error {
+	if parallelism < 1 {
+		return errors.Errorf("pebble: invalid parallelism %d", parallelism)
+	}
+	if start != nil && end != nil && d.cmp(start, end) >= 0 {
+		return errors.New("pebble: invalid key range specified (start >= end)")
+	}
+
+	splits := d.parallelScanSplitKeys(start, end, parallelism)
+	g, ctx := errgroup.WithContext(ctx)
+	for i := 0; i <= len(splits); i++ {
+		opts := IterOptions{LowerBound: start, UpperBound: end}
+		if i > 0 {
+			opts.LowerBound = splits[i-1]
+		}
+		if i < len(splits) {
+			opts.UpperBound = splits[i]
+		}
+		partition := i
+		g.Go(func() error {
+			return d.scanPartition(ctx, snapshot, &opts, partition, fn)
+		})
+	}
+	return g.Wait()
+}
+
+// parallelScanCheckInterval is the number of keys a partition scan visits
+// between checks for cancellation.
+const parallelScanCheckInterval = 1024
+
+func (d *DB) scanPartition(
+	ctx context.Context,
+	snapshot snapshotIterOpts,
+	opts *IterOptions,
+	partition int,
+	fn ParallelScanFunc,
+) (err error) {
+	iter := d.newIter(ctx, nil /* batch */, newIterOpts{snapshot: snapshot}, opts)
+	defer func() {
+		err = errors.CombineErrors(err, iter.Close())
+	}()
+	for n, valid := 0, iter.First(); valid; n, valid = n+1, iter.Next() {
+		if n%parallelScanCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		value, err := iter.ValueAndErr()
+		if err != nil {
+			return err
+		}
+		if err := fn(partition, iter.Key(), value); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// parallelScanSplitKeys returns up to parallelism-1 increasing user keys that
+// divide [start, end) into partitions holding roughly equal amounts of sstable
+// data. The candidate split keys are the largest user keys of the sstables
+// overlapping the range, each weighted by the size of its file. Data in the
+// memtables is not taken into account.
+func (d *DB) parallelScanSplitKeys(start, end []byte, parallelism int) [][]byte {
+	if parallelism <= 1 {
+		return nil
+	}
+	readState := d.loadReadState()
+	defer readState.unref()
+	var candidates []splitCandidate
+	for level := range readState.current.Levels {
+		iter := overlappingFiles(readState.current, level, d.cmp, start, end)
+		for f := iter.First(); f != nil; f = iter.Next() {
+			if fileOverlapsRange(d.cmp, f, start, end) {
+				candidates = append(candidates, splitCandidate{key: f.Largest.UserKey, size: f.Size})
+			}
+		}
+	}
+	return pickSplitKeys(d.cmp, candidates, start, end, parallelism)
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallelScan(t *testing.T) {
+	d, err := Open("", &Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	const numKeys = 2000
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key%05d", i)) }
+	for i := 0; i < numKeys; i++ {
+		require.NoError(t, d.Set(key(i), []byte(fmt.Sprint(i)), nil))
+		if i%200 == 199 {
+			require.NoError(t, d.Flush())
+		}
+	}
+	require.NoError(t, d.Compact(key(0), key(numKeys/2), false /* parallelize */))
+
+	// Writes after the snapshot aren't visible to its scans.
+	snap := d.NewSnapshot()
+	defer func() { require.NoError(t, snap.Close()) }()
+	for i := 0; i < numKeys; i += 2 {
+		require.NoError(t, d.Delete(key(i), nil))
+	}
+
+	scan := func(
+		r interface {
+			ParallelScan(context.Context, []byte, []byte, int, ParallelScanFunc) error
+		},
+		start, end []byte,
+		parallelism int,
+	) (keys []string, partitions int) {
+		var mu sync.Mutex
+		lastKey := map[int][]byte{}
+		err := r.ParallelScan(context.Background(), start, end, parallelism, func(partition int, k, v []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			// Keys are ordered within a partition.
+			if last, ok := lastKey[partition]; ok {
+				require.Less(t, string(last), string(k))
+			}
+			lastKey[partition] = slices.Clone(k)
+			keys = append(keys, string(k))
+			return nil
+		})
+		require.NoError(t, err)
+		slices.Sort(keys)
+		return keys, len(lastKey)
+	}
+	expected := func(start, end int, step int) []string {
+		var keys []string
+		for i := start; i < end; i += step {
+			keys = append(keys, string(key(i)))
+		}
+		return keys
+	}
+
+	for _, parallelism := range []int{1, 2, 3, 8, 64} {
+		t.Run(fmt.Sprint(parallelism), func(t *testing.T) {
+			keys, partitions := scan(snap, nil, nil, parallelism)
+			require.Equal(t, expected(0, numKeys, 1), keys)
+			require.LessOrEqual(t, partitions, parallelism)
+			if parallelism > 1 {
+				require.Greater(t, partitions, 1)
+			}
+
+			keys, _ = scan(snap, key(150), key(1234), parallelism)
+			require.Equal(t, expected(150, 1234, 1), keys)
+
+			keys, _ = scan(d, key(150), key(1234), parallelism)
+			require.Equal(t, expected(151, 1234, 2), keys)
+		})
+	}
+
+	// The split keys are increasing and strictly within the range.
+	splits := d.parallelScanSplitKeys(key(100), key(1900), 8)
+	require.NotEmpty(t, splits)
+	for i, k := range splits {
+		require.Greater(t, string(k), string(key(100)))
+		require.Less(t, string(k), string(key(1900)))
+		if i > 0 {
+			require.Negative(t, bytes.Compare(splits[i-1], k))
+		}
+	}
+
+	// An error returned by the callback stops the scan.
+	errTest := errors.New("test error")
+	err = d.ParallelScan(context.Background(), nil, nil, 4, func(int, []byte, []byte) error {
+		return errTest
+	})
+	require.True(t, errors.Is(err, errTest))
+
+	// So does a canceled context.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = d.ParallelScan(ctx, nil, nil, 4, func(int, []byte, []byte) error { return nil })
+	require.True(t, errors.Is(err, context.Canceled))
+
+	require.Error(t, d.ParallelScan(context.Background(), nil, nil, 0, nil))
+	require.Error(t, d.ParallelScan(context.Background(), key(5), key(5), 1, nil))
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package record
+
+import (
+	"encoding/binary"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/constants"
+	"github.com/golang/snappy"
+)
+
+// Compression is the algorithm used by a LogWriter to compress the payload of
+// records. The value is part of the wire format of compressed records and
+// should not be changed.
+type Compression uint8
+
+// The available record compression algorithms.
+const (
+	NoCompression Compression = iota
+	SnappyCompression
+	ZstdCompression
+)
+
+// String implements fmt.Stringer.
+func (c Compression) String() string {
+	switch c {
+	case NoCompression:
+		return "NoCompression"
+	case SnappyCompression:
+		return "Snappy"
+	case ZstdCompression:
+		return "ZSTD"
+	default:
+		return "Unknown"
+	}
+}
+
+// minCompressedRecordSize is the minimum size of a record for which a
+// LogWriter attempts compression. Smaller records are unlikely to compress
+// well enough to offset the CPU spent.
+const minCompressedRecordSize = 64
+
+// compressRecord compresses the record payload p with the given algorithm,
+// using buf as the desired destination. The compressed payload is prefixed
+// with a byte identifying the algorithm. It returns false if the record should
+// be written uncompressed because compression would not shrink it.
+func compressRecord(c Compression, p []byte, buf []byte) ([]byte, bool, error) {
+	if c == NoCompression || len(p) < minCompressedRecordSize {
+		return buf, false, nil
+	}
+	buf = append(buf[:0], byte(c))
+	switch c {
+	case SnappyCompression:
+		n := snappy.MaxEncodedLen(len(p))
+		if cap(buf) < 1+n {
+			buf = append(make([]byte, 0, 1+n), buf...)
+		}
+		buf = buf[:1+len(snappy.Encode(buf[1:1+n], p))]
+	case ZstdCompression:
+		buf = binary.AppendUvarint(buf, uint64(len(p)))
+		encoded, err := encodeZstd(buf, p)
+		if err != nil {
+			return buf, false, err
+		}
+		buf = encoded
+	default:
+		return buf, false, nil
+	}
+	return buf, len(buf) < len(p), nil
+}
+
+// maxCompressionRatio bounds the ratio between the decompressed and compressed
+// sizes of a record. Zstandard can encode each 128 KiB block of repeated bytes
+// in 4 bytes, which is the largest ratio achievable by the supported
+// algorithms.
+const maxCompressionRatio = 1 << 15
+
+// decompressRecord decompresses the payload of a compressed record, using buf
+// as the desired destination. The decompressed length recorded in a corrupt
+// record may be arbitrary, so it's validated before the destination is
+// allocated.
+func decompressRecord(b []byte, buf []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, base.CorruptionErrorf("pebble/record: empty compressed record")
+	}
+	c, b := Compression(b[0]), b[1:]
+	var decodedLen int
+	switch c {
+	case SnappyCompression:
+		n, err := snappy.DecodedLen(b)
+		if err != nil {
+			return nil, base.MarkCorruptionError(err)
+		}
+		decodedLen = n
+	case ZstdCompression:
+		n, varIntLen := binary.Uvarint(b)
+		if varIntLen <= 0 || n > constants.MaxUint32OrInt {
+			return nil, base.CorruptionErrorf("pebble/record: compressed record has invalid length")
+		}
+		decodedLen, b = int(n), b[varIntLen:]
+	default:
+		return nil, base.CorruptionErrorf("pebble/record: unknown record compression: %d", errors.Safe(c))
+	}
+	if decodedLen < 0 || uint64(decodedLen) > uint64(len(b))*maxCompressionRatio {
+		return nil, base.CorruptionErrorf("pebble/record: compressed record of %d bytes has invalid length %d",
+			errors.Safe(len(b)), errors.Safe(decodedLen))
+	}
+	if cap(buf) < decodedLen {
+		buf = make([]byte, decodedLen)
+	}
+	buf = buf[:decodedLen]
+
+	var result []byte
+	var err error
+	switch c {
+	case SnappyCompression:
+		result, err = snappy.Decode(buf, b)
+	case ZstdCompression:
+		result, err = decodeZstd(buf, b)
+	}
+	if err != nil {
+		return nil, base.MarkCorruptionError(err)
+	}
+	if len(result) != decodedLen {
+		return nil, base.CorruptionErrorf("pebble/record: decompressed record has unexpected length: %d != %d",
+			errors.Safe(len(result)), errors.Safe(decodedLen))
+	}
+	return result, nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+//go:build cgo
+// +build cgo
+
+package record
+
+import "github.com/DataDog/zstd"
+
+// decodeZstd decompresses b with the Zstandard algorithm. It reuses the
+// preallocated capacity of decodedBuf if it is sufficient. Like encodeZstd, it
+// uses the single-shot API rather than setting up a stream per record.
+func decodeZstd(decodedBuf, b []byte) ([]byte, error) {
+	return zstd.Decompress(decodedBuf, b)
+}
+
+// encodeZstd compresses b with the Zstandard algorithm at default compression
+// level (level 3), appending the result to dst.
+func encodeZstd(dst []byte, b []byte) ([]byte, error) {
+	// CompressLevel writes to the start of the buffer it's given, and only
+	// reuses it if its capacity is at least the compression bound.
+	bound := zstd.CompressBound(len(b))
+	if cap(dst)-len(dst) < bound {
+		grown := make([]byte, len(dst), len(dst)+bound)
+		copy(grown, dst)
+		dst = grown
+	}
+	compressed, err := zstd.CompressLevel(dst[len(dst):len(dst)+bound], b, 3)
+	if err != nil {
+		return nil, err
+	}
+	return dst[:len(dst)+len(compressed)], nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+//go:build !cgo
+// +build !cgo
+
+package record
+
+import (
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdDecoder decompresses the records of all readers. DecodeAll may be
+// called concurrently.
+var zstdDecoder, zstdDecoderErr = zstd.NewReader(nil)
+
+// zstdEncoderPool holds the *zstd.Encoders used to compress records.
+var zstdEncoderPool sync.Pool
+
+// decodeZstd decompresses b with the Zstandard algorithm. It reuses the
+// preallocated capacity of decodedBuf if it is sufficient.
+func decodeZstd(decodedBuf, b []byte) ([]byte, error) {
+	if zstdDecoderErr != nil {
+		return nil, zstdDecoderErr
+	}
+	return zstdDecoder.DecodeAll(b, decodedBuf[:0])
+}
+
+// encodeZstd compresses b with the Zstandard algorithm at default compression
+// level (level 3), appending the result to dst.
+func encodeZstd(dst []byte, b []byte) ([]byte, error) {
+	encoder, _ := zstdEncoderPool.Get().(*zstd.Encoder)
+	if encoder == nil {
+		var err error
+		encoder, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer zstdEncoderPool.Put(encoder)
+	return encoder.EncodeAll(b, dst), nil
+}
@@ -297,6 +297,13 @@ type LogWriter struct {
 
 	// See the comment for LogWriterConfig.QueueSemChan.
 	queueSemChan chan struct{}
+
+	// compression is the algorithm used to compress records. See
+	// LogWriterConfig.Compression.
+	compression Compression
+	// compressedBuf is the buffer records are compressed into. External
+	// synchronisation provided by commitPipeline.mu.
+	compressedBuf []byte
 }
 
 // LogWriterConfig is a struct used for configuring new LogWriters
@@ -308,6 +315,11 @@ type LogWriterConfig struct {
 	// the syncQueue from overflowing (which will cause a panic). All production
 	// code ensures this is non-nil.
 	QueueSemChan chan struct{}
+	// Compression is the algorithm used to compress the payload of records.
+	// Records that do not shrink when compressed are written uncompressed.
+	// Compressed records cannot be read by readers that predate record
+	// compression.
+	Compression Compression
 }
 
 // initialAllocatedBlocksCap is the initial capacity of the various slices
@@ -341,6 +353,7 @@ func NewLogWriter(
 			return time.AfterFunc(d, f)
 		},
 		queueSemChan: logWriterConfig.QueueSemChan,
+		compression:  logWriterConfig.Compression,
 	}
 	r.free.blocks = make([]*block, 0, initialAllocatedBlocksCap)
 	r.block = blockPool.Get().(*block)
@@ -665,12 +678,24 @@ func (w *LogWriter) SyncRecord(
 		return -1, w.err
 	}
 
+	var compressed bool
+	if w.compression != NoCompression {
+		c, ok, compressErr := compressRecord(w.compression, p, w.compressedBuf)
+		if compressErr != nil {
+			return -1, compressErr
+		}
+		w.compressedBuf, compressed = c, ok
+		if compressed {
+			p = c
+		}
+	}
+
 	// The `i == 0` condition ensures we handle empty records. Such records can
 	// possibly be generated for VersionEdits stored in the MANIFEST. While the
 	// MANIFEST is currently written using Writer, it is good to support the same
 	// semantics with LogWriter.
 	for i := 0; i == 0 || len(p) > 0; i++ {
-		p = w.emitFragment(i, p)
+		p = w.emitFragment(i, p, compressed)
 	}
 
 	if wg != nil {
@@ -710,7 +735,7 @@ func (w *LogWriter) emitEOFTrailer() {
 	b.written.Store(i + int32(recyclableHeaderSize))
 }
 
-func (w *LogWriter) emitFragment(n int, p []byte) (remainingP []byte) {
+func (w *LogWriter) emitFragment(n int, p []byte, compressed bool) (remainingP []byte) {
 	b := w.block
 	i := b.written.Load()
 	first := n == 0
@@ -729,6 +754,9 @@ func (w *LogWriter) emitFragment(n int, p []byte) (remainingP []byte) {
 			b.buf[i+6] = recyclableMiddleChunkType
 		}
 	}
+	if compressed {
+		b.buf[i+6] += recyclableCompressedFullChunkType - recyclableFullChunkType
+	}
 
 	binary.LittleEndian.PutUint32(b.buf[i+7:i+11], w.logNum)
 
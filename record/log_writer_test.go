@@ -6,7 +6,10 @@ package record
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
 	"sort"
 	"sync"
@@ -15,6 +18,7 @@ import (
 	"time"
 
 	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
 	"github.com/cockroachdb/pebble/internal/humanize"
 	"github.com/cockroachdb/pebble/vfs"
 	"github.com/cockroachdb/pebble/vfs/errorfs"
@@ -591,3 +595,85 @@ func BenchmarkQueueWALBlocks(b *testing.B) {
 		})
 	}
 }
+
+func TestLogWriterCompression(t *testing.T) {
+	for _, c := range []Compression{SnappyCompression, ZstdCompression} {
+		t.Run(c.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewLogWriter(&buf, 1, LogWriterConfig{
+				WALFsyncLatency: prometheus.NewHistogram(prometheus.HistogramOpts{}),
+				Compression:     c,
+			})
+			// Records of various sizes, including records that are too small to
+			// be compressed, records that do not compress, and compressible
+			// records that span multiple blocks.
+			records := [][]byte{
+				[]byte("small"),
+				bytes.Repeat([]byte("compressible "), 1000),
+				func() []byte {
+					var b []byte
+					for i := 0; i < 8; i++ {
+						sum := sha256.Sum256([]byte{byte(i)})
+						b = append(b, sum[:]...)
+					}
+					return b
+				}(),
+				bytes.Repeat([]byte("x"), 3*blockSize),
+				nil,
+			}
+			for _, r := range records {
+				_, err := w.WriteRecord(r)
+				require.NoError(t, err)
+			}
+			require.NoError(t, w.Close())
+			// The compressible records shrink the log.
+			require.Less(t, buf.Len(), 3*blockSize)
+
+			r := NewReader(&buf, 1)
+			for _, want := range records {
+				rr, err := r.Next()
+				require.NoError(t, err)
+				got, err := io.ReadAll(rr)
+				require.NoError(t, err)
+				require.Equal(t, len(want), len(got))
+				require.True(t, bytes.Equal(want, got))
+			}
+			_, err := r.Next()
+			require.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestCompressedRecordCorruption(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewLogWriter(&buf, 1, LogWriterConfig{
+		WALFsyncLatency: prometheus.NewHistogram(prometheus.HistogramOpts{}),
+		Compression:     SnappyCompression,
+	})
+	_, err := w.WriteRecord(bytes.Repeat([]byte("compressible "), 1000))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// Truncate the log in the middle of the compressed record.
+	data := buf.Bytes()[:recyclableHeaderSize+10]
+	r := NewReader(bytes.NewReader(data), 1)
+	_, err = r.Next()
+	require.True(t, IsInvalidRecord(err), "unexpected error: %v", err)
+}
+
+func TestDecompressRecordInvalidLength(t *testing.T) {
+	// A corrupt record may claim a decompressed length far larger than its
+	// compressed payload could produce. It's reported as corruption without
+	// allocating a buffer of that length.
+	for _, n := range []uint64{1 << 40, 1 << 30, math.MaxUint64} {
+		b := binary.AppendUvarint([]byte{byte(ZstdCompression)}, n)
+		b = append(b, bytes.Repeat([]byte{0}, 16)...)
+		_, err := decompressRecord(b, nil)
+		require.True(t, errors.Is(err, base.ErrCorruption), "length %d: unexpected error: %v", n, err)
+	}
+	b := []byte{byte(SnappyCompression)}
+	b = binary.AppendUvarint(b, 1<<30)
+	b = append(b, bytes.Repeat([]byte{0}, 16)...)
+	_, err := decompressRecord(b, nil)
+	require.True(t, errors.Is(err, base.ErrCorruption), "unexpected error: %v", err)
+}
@@ -0,0 +1,220 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"slices"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/manifest"
+	"github.com/cockroachdb/pebble/sstable"
+)
+
+// splitCandidate is a user key at which a key range may be split, weighted by
+// the approximate number of bytes that precede it since the previous
+// candidate from the same source.
+type splitCandidate struct {
+	key  []byte
+	size uint64
+}
+
+// pickSplitKeys sorts the candidates and returns up to n-1 strictly increasing
+// keys within (start, end) that divide the candidates' cumulative size into n
+// roughly equal parts. A nil start or end leaves the corresponding side of the
+// range unbounded. The returned keys don't alias the candidates.
+func pickSplitKeys(cmp Compare, candidates []splitCandidate, start, end []byte, n int) [][]byte {
+	if n <= 1 {
+		return nil
+	}
+	var total uint64
+	for _, c := range candidates {
+		total += c.size
+	}
+	if total == 0 {
+		return nil
+	}
+	slices.SortFunc(candidates, func(a, b splitCandidate) int {
+		return cmp(a.key, b.key)
+	})
+
+	// Walk the candidates in key order, emitting a split key each time the
+	// cumulative size crosses the next multiple of total/n.
+	splits := make([][]byte, 0, n-1)
+	var cumulative uint64
+	for _, c := range candidates {
+		cumulative += c.size
+		if len(splits) == n-1 {
+			break
+		}
+		if cumulative*uint64(n) < total*uint64(len(splits)+1) {
+			continue
+		}
+		// Split keys must lie strictly within the range and strictly increase.
+		if (start != nil && cmp(c.key, start) <= 0) || (end != nil && cmp(c.key, end) >= 0) {
+			continue
+		}
+		if len(splits) > 0 && cmp(splits[len(splits)-1], c.key) >= 0 {
+			continue
+		}
+		splits = append(splits, append([]byte(nil), c.key...))
+	}
+	return splits
+}
+
+// overlappingFiles returns an iterator over the files in the given level of
+// the version that may overlap [start, end). A nil start or end leaves the
+// corresponding side of the range unbounded.
+func overlappingFiles(
+	v *version, level int, cmp Compare, start, end []byte,
+) manifest.LevelIterator {
+	if start == nil || end == nil {
+		return v.Levels[level].Iter()
+	}
+	overlaps := v.Overlaps(level, cmp, start, end, true /* exclusiveEnd */)
+	return overlaps.Iter()
+}
+
+// fileOverlapsRange returns true if the file's bounds overlap [start, end).
+func fileOverlapsRange(cmp Compare, f *fileMetadata, start, end []byte) bool {
+	return (end == nil || cmp(f.Smallest.UserKey, end) < 0) &&
+		(start == nil || cmp(f.Largest.UserKey, start) >= 0)
+}
+
+// splitKeysFileGranularity controls when ApproximateSplitKeys consults the
+// index blocks of a sstable. A sstable that lies entirely within the range
+// and is smaller than 1/splitKeysFileGranularity of the target partition size
+// contributes a single candidate at its largest key, sized by its
+// fileMetadata, without being opened.
+const splitKeysFileGranularity = 8
+
+// splitKeysMemTableChunkSize is the approximate number of memtable bytes
+// between consecutive split candidates taken from a memtable.
+const splitKeysMemTableChunkSize = 32 << 10
+
+// ApproximateSplitKeys returns up to n-1 increasing user keys that divide
+// [start, end) into n spans holding approximately equal numbers of bytes. A
+// nil start or end leaves the corresponding side of the range unbounded.
+//
+// The estimate is computed from the sizes recorded in the fileMetadata of the
+// sstables overlapping the range across all levels, the index block entries of
+// the sstables that are large or only partially overlap the range, and the
+// keys and values in the memtables. No data blocks are read. Memtable sizes
+// are uncompressed, so ranges with unflushed writes are weighted more heavily
+// than the same data on disk. Fewer than n-1 keys are returned if the range
+// doesn't contain enough distinct boundaries.
+func (d *DB) ApproximateSplitKeys(start, end []byte, n int) ([][]byte, error) {
+	if err := d.closed.Load(); err != nil {
+		panic(err)
+	}
+	if n < 1 {
+		return nil, errors.Errorf("pebble: invalid number of spans %d", n)
+	}
+	if start != nil && end != nil && d.cmp(start, end) >= 0 {
+		return nil, errors.New("pebble: invalid key range specified (start >= end)")
+	}
+	if n == 1 {
+		return nil, nil
+	}
+
+	// Grab and reference the current readState. This prevents the underlying
+	// files in the associated version from being deleted if there is a
+	// concurrent compaction.
+	readState := d.loadReadState()
+	defer readState.unref()
+
+	var total uint64
+	for level := range readState.current.Levels {
+		iter := overlappingFiles(readState.current, level, d.cmp, start, end)
+		for f := iter.First(); f != nil; f = iter.Next() {
+			if fileOverlapsRange(d.cmp, f, start, end) {
+				total += f.Size
+			}
+		}
+	}
+	smallFileSize := total / uint64(n*splitKeysFileGranularity)
+
+	var candidates []splitCandidate
+	for level := range readState.current.Levels {
+		iter := overlappingFiles(readState.current, level, d.cmp, start, end)
+		for f := iter.First(); f != nil; f = iter.Next() {
+			if !fileOverlapsRange(d.cmp, f, start, end) {
+				continue
+			}
+			contained := (start == nil || d.cmp(start, f.Smallest.UserKey) <= 0) &&
+				(end == nil || d.cmp(f.Largest.UserKey, end) < 0)
+			if contained && f.Size <= smallFileSize {
+				candidates = append(candidates, splitCandidate{key: f.Largest.UserKey, size: f.Size})
+				continue
+			}
+			var seps []sstable.DataBlockSeparator
+			var err error
+			if f.Virtual {
+				err = d.tableCache.withVirtualReader(
+					f.VirtualMeta(),
+					func(r sstable.VirtualReader) (err error) {
+						seps, err = r.DataBlockSeparators(start, end)
+						return err
+					},
+				)
+			} else {
+				err = d.tableCache.withReader(
+					f.PhysicalMeta(),
+					func(r *sstable.Reader) (err error) {
+						seps, err = r.DataBlockSeparators(start, end)
+						return err
+					},
+				)
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, sep := range seps {
+				candidates = append(candidates, splitCandidate{key: sep.Key, size: sep.Size})
+			}
+		}
+	}
+
+	for _, mem := range readState.memtables {
+		m, ok := mem.flushable.(*memTable)
+		if !ok {
+			// Ingested flushables are sstables that will soon be added to the
+			// LSM; their contents are ignored rather than read.
+			continue
+		}
+		candidates = m.appendSplitCandidates(candidates, start, end)
+	}
+
+	return pickSplitKeys(d.cmp, candidates, start, end, n), nil
+}
+
+// appendSplitCandidates appends split candidates for the memtable's keys
+// within [start, end), roughly one per splitKeysMemTableChunkSize bytes of
+// keys and values.
+func (m *memTable) appendSplitCandidates(
+	candidates []splitCandidate, start, end []byte,
+) []splitCandidate {
+	iter := m.newIter(&IterOptions{LowerBound: start, UpperBound: end})
+	defer iter.Close()
+	var size uint64
+	var lastKey []byte
+	for key, val := iter.First(); key != nil; key, val = iter.Next() {
+		lastKey = key.UserKey
+		size += uint64(key.Size() + val.Len())
+		if size >= splitKeysMemTableChunkSize {
+			candidates = append(candidates, splitCandidate{
+				key:  append([]byte(nil), key.UserKey...),
+				size: size,
+			})
+			size = 0
+		}
+	}
+	if size > 0 {
+		candidates = append(candidates, splitCandidate{
+			key:  append([]byte(nil), lastKey...),
+			size: size,
+		})
+	}
+	return candidates
+}
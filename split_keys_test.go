@@ -0,0 +1,82 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApproximateSplitKeys(t *testing.T) {
+	d, err := Open("", &Options{
+		FS:     vfs.NewMem(),
+		Levels: []LevelOptions{{BlockSize: 1 << 10}},
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, d.Close()) }()
+
+	const numKeys = 20000
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key%06d", i)) }
+	value := bytes.Repeat([]byte("x"), 100)
+	write := func(from, to int) {
+		for i := from; i < to; i++ {
+			require.NoError(t, d.Set(key(i), value, nil))
+		}
+	}
+
+	// checkBalanced verifies that the split keys are increasing, lie within
+	// [from, to) and divide the keys written there into spans of similar size.
+	checkBalanced := func(splits [][]byte, from, to, n int) {
+		require.Len(t, splits, n-1)
+		counts := make([]int, n)
+		for i := from; i < to; i++ {
+			j := 0
+			for j < len(splits) && bytes.Compare(key(i), splits[j]) >= 0 {
+				j++
+			}
+			counts[j]++
+		}
+		expected := (to - from) / n
+		for i, c := range counts {
+			require.InDelta(t, expected, c, float64(expected)/2, "span %d: %v", i, counts)
+		}
+	}
+
+	// Only memtable data.
+	write(0, numKeys/2)
+	splits, err := d.ApproximateSplitKeys(nil, nil, 4)
+	require.NoError(t, err)
+	checkBalanced(splits, 0, numKeys/2, 4)
+
+	// Data in sstables across levels and in the memtable.
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Compact(key(0), key(numKeys/4), false /* parallelize */))
+	write(numKeys/2, numKeys)
+	require.NoError(t, d.Flush())
+	write(numKeys/2, 3*numKeys/4)
+	for _, n := range []int{2, 5, 10} {
+		splits, err = d.ApproximateSplitKeys(key(1000), key(numKeys/2-1000), n)
+		require.NoError(t, err)
+		checkBalanced(splits, 1000, numKeys/2-1000, n)
+	}
+
+	splits, err = d.ApproximateSplitKeys(nil, nil, 1)
+	require.NoError(t, err)
+	require.Empty(t, splits)
+
+	// A range without data has no split keys.
+	splits, err = d.ApproximateSplitKeys([]byte("z"), nil, 4)
+	require.NoError(t, err)
+	require.Empty(t, splits)
+
+	_, err = d.ApproximateSplitKeys(nil, nil, 0)
+	require.Error(t, err)
+	_, err = d.ApproximateSplitKeys(key(5), key(1), 2)
+	require.Error(t, err)
+}
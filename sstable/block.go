@@ -62,15 +62,29 @@ type blockWriter struct {
 	// will optimize by stepping through restarts only within the same block.
 	// Note that the first restart is the first key in the block.
 	setHasSameKeyPrefixSinceLastRestart bool
+	// hashIndexSplit, if non-nil, enables the data block hash index (see
+	// block_hash_index.go), which indexes the prefixes of the keys as
+	// determined by hashIndexSplit. hashIndexEntries holds the prefixes added
+	// to the current block.
+	hashIndexSplit   Split
+	hashIndexEntries []hashIndexEntry
+	// columnarSplit, if non-nil, causes the block to be written as a columnar
+	// data block (see columnar_block.go) whose keys are split into prefixes and
+	// suffixes by columnarSplit. The columns are accumulated in col, and the
+	// restart interval and hash index are ignored.
+	columnarSplit Split
+	col           columnarBlockWriter
 }
 
 func (w *blockWriter) clear() {
 	*w = blockWriter{
-		buf:      w.buf[:0],
-		restarts: w.restarts[:0],
-		curKey:   w.curKey[:0],
-		curValue: w.curValue[:0],
-		prevKey:  w.prevKey[:0],
+		buf:              w.buf[:0],
+		restarts:         w.restarts[:0],
+		curKey:           w.curKey[:0],
+		curValue:         w.curValue[:0],
+		prevKey:          w.prevKey[:0],
+		hashIndexEntries: w.hashIndexEntries[:0],
+		col:              w.col.cleared(),
 	}
 }
 
@@ -241,11 +255,25 @@ func (w *blockWriter) addWithOptionalValuePrefix(
 	}
 	key.Encode(w.curKey)
 
+	if w.columnarSplit != nil {
+		w.col.add(w.columnarSplit, key, value, addValuePrefix, valuePrefix)
+		w.curValue = w.col.values[len(w.col.values)-len(value):]
+		w.nEntries++
+		return
+	}
 	w.storeWithOptionalValuePrefix(
 		size, value, maxSharedKeyLen, addValuePrefix, valuePrefix, setHasSameKeyPrefix)
+	if w.hashIndexSplit != nil {
+		w.addToHashIndex(key.UserKey)
+	}
 }
 
 func (w *blockWriter) finish() []byte {
+	if w.columnarSplit != nil {
+		w.buf = w.col.finish(w.buf)
+		w.nEntries = 0
+		return w.buf
+	}
 	// Write the restart points to the buffer.
 	if w.nEntries == 0 {
 		// Every block must have at least one restart point.
@@ -261,7 +289,12 @@ func (w *blockWriter) finish() []byte {
 		binary.LittleEndian.PutUint32(tmp4, x)
 		w.buf = append(w.buf, tmp4...)
 	}
-	binary.LittleEndian.PutUint32(tmp4, uint32(len(w.restarts)))
+	numRestarts := uint32(len(w.restarts))
+	if w.hashIndexSize() > 0 {
+		w.buf = appendHashIndex(w.buf, w.hashIndexEntries)
+		numRestarts |= hashIndexRestartsMask
+	}
+	binary.LittleEndian.PutUint32(tmp4, numRestarts)
 	w.buf = append(w.buf, tmp4...)
 	result := w.buf
 
@@ -270,6 +303,7 @@ func (w *blockWriter) finish() []byte {
 	w.nextRestart = 0
 	w.buf = w.buf[:0]
 	w.restarts = w.restarts[:0]
+	w.hashIndexEntries = w.hashIndexEntries[:0]
 	return result
 }
 
@@ -279,7 +313,10 @@ func (w *blockWriter) finish() []byte {
 const emptyBlockSize = 4
 
 func (w *blockWriter) estimatedSize() int {
-	return len(w.buf) + 4*len(w.restarts) + emptyBlockSize
+	if w.columnarSplit != nil {
+		return w.col.estimatedSize()
+	}
+	return len(w.buf) + 4*len(w.restarts) + w.hashIndexSize() + emptyBlockSize
 }
 
 type blockEntry struct {
@@ -410,6 +447,16 @@ type blockIter struct {
 		hasValuePrefix bool
 	}
 	hideObsoletePoints bool
+	// hashBuckets holds the buckets of the block's hash index, if the block
+	// has one. The hash index is only used if split is also set. See
+	// block_hash_index.go.
+	hashBuckets []byte
+	split       Split
+	// columnar is true if the block is a columnar data block, in which case
+	// col provides access to its columns, offset holds the index of the
+	// current row and restarts the number of rows. See columnar_block.go.
+	columnar bool
+	col      columnarBlock
 }
 
 // blockIter implements the base.InternalIterator interface.
@@ -427,13 +474,18 @@ func (i *blockIter) String() string {
 func (i *blockIter) init(
 	cmp Compare, block block, globalSeqNum uint64, hideObsoletePoints bool,
 ) error {
-	numRestarts := int32(binary.LittleEndian.Uint32(block[len(block)-4:]))
-	if numRestarts == 0 {
+	if isColumnarBlock(block) {
+		return i.initColumnar(cmp, block, globalSeqNum, hideObsoletePoints)
+	}
+	i.columnar = false
+	restarts, numRestarts, hashBuckets, ok := decodeBlockTrailer(block)
+	if !ok {
 		return base.CorruptionErrorf("pebble/table: invalid table (block has no restart points)")
 	}
 	i.cmp = cmp
-	i.restarts = int32(len(block)) - 4*(1+numRestarts)
+	i.restarts = restarts
 	i.numRestarts = numRestarts
+	i.hashBuckets = hashBuckets
 	i.globalSeqNum = globalSeqNum
 	i.ptr = unsafe.Pointer(&block[0])
 	i.data = block
@@ -470,6 +522,8 @@ func (i *blockIter) invalidate() {
 	i.nextOffset = 0
 	i.restarts = 0
 	i.numRestarts = 0
+	i.hashBuckets = nil
+	i.columnar = false
 	i.data = nil
 }
 
@@ -486,6 +540,7 @@ func (i *blockIter) resetForReuse() blockIter {
 		cached:    i.cached[:0],
 		cachedBuf: i.cachedBuf[:0],
 		data:      nil,
+		col:       columnarBlock{firstKey: i.col.firstKey[:0]},
 	}
 }
 
@@ -692,14 +747,19 @@ func (i *blockIter) SeekGE(key []byte, flags base.SeekGEFlags) (*InternalKey, ba
 	if invariants.Enabled && i.isDataInvalidated() {
 		panic(errors.AssertionFailedf("invalidated blockIter used"))
 	}
+	if i.columnar {
+		return i.colSeekGE(key)
+	}
 
 	i.clearCache()
 	// Find the index of the smallest restart point whose key is > the key
-	// sought; index will be numRestarts if there is no such restart point.
+	// sought; index will be numRestarts if there is no such restart point. The
+	// hash index, if present, avoids the binary search when the key's prefix
+	// is in the block.
 	i.offset = 0
-	var index int32
+	index, ok := i.hashIndexSeek(key)
 
-	{
+	if !ok {
 		// NB: manually inlined sort.Seach is ~5% faster.
 		//
 		// Define f(-1) == false and f(n) == true.
@@ -825,6 +885,9 @@ func (i *blockIter) SeekLT(key []byte, flags base.SeekLTFlags) (*InternalKey, ba
 	if invariants.Enabled && i.isDataInvalidated() {
 		panic(errors.AssertionFailedf("invalidated blockIter used"))
 	}
+	if i.columnar {
+		return i.colSeekLT(key)
+	}
 
 	i.clearCache()
 	// Find the index of the smallest restart point whose key is >= the key
@@ -996,6 +1059,9 @@ func (i *blockIter) First() (*InternalKey, base.LazyValue) {
 	if invariants.Enabled && i.isDataInvalidated() {
 		panic(errors.AssertionFailedf("invalidated blockIter used"))
 	}
+	if i.columnar {
+		return i.colFirst()
+	}
 
 	i.offset = 0
 	if !i.valid() {
@@ -1029,6 +1095,9 @@ func (i *blockIter) Last() (*InternalKey, base.LazyValue) {
 	if invariants.Enabled && i.isDataInvalidated() {
 		panic(errors.AssertionFailedf("invalidated blockIter used"))
 	}
+	if i.columnar {
+		return i.colLast()
+	}
 
 	// Seek forward from the last restart point.
 	i.offset = decodeRestart(i.data[i.restarts+4*(i.numRestarts-1):])
@@ -1063,6 +1132,9 @@ func (i *blockIter) Last() (*InternalKey, base.LazyValue) {
 // Next implements internalIterator.Next, as documented in the pebble
 // package.
 func (i *blockIter) Next() (*InternalKey, base.LazyValue) {
+	if i.columnar {
+		return i.colNext()
+	}
 	if len(i.cachedBuf) > 0 {
 		// We're switching from reverse iteration to forward iteration. We need to
 		// populate i.fullKey with the current key we're positioned at so that
@@ -1114,6 +1186,9 @@ start:
 
 // NextPrefix implements (base.InternalIterator).NextPrefix.
 func (i *blockIter) NextPrefix(succKey []byte) (*InternalKey, base.LazyValue) {
+	if i.columnar {
+		return i.colNextPrefix()
+	}
 	if i.lazyValueHandling.hasValuePrefix {
 		return i.nextPrefixV3(succKey)
 	}
@@ -1402,6 +1477,9 @@ func (i *blockIter) nextPrefixV3(succKey []byte) (*InternalKey, base.LazyValue)
 // Prev implements internalIterator.Prev, as documented in the pebble
 // package.
 func (i *blockIter) Prev() (*InternalKey, base.LazyValue) {
+	if i.columnar {
+		return i.colPrev()
+	}
 start:
 	for n := len(i.cached) - 1; n >= 0; n-- {
 		i.nextOffset = i.offset
@@ -0,0 +1,210 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"encoding/binary"
+	"unsafe"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/cockroachdb/pebble/internal/base"
+)
+
+// Data block hash index
+//
+// A data block written with TableFormatPebblev5 or later may contain a hash
+// index, enabled by WriterOptions.DataBlockHashIndex, that maps the prefixes
+// (as defined by Comparer.Split) of the block's keys to the restart interval in
+// which each prefix first appears. A SeekGE within the block consults the hash
+// index before resorting to a binary search over the restart points, and when
+// the sought key's prefix is present it can position the iterator at the
+// right restart interval after examining just two restart keys. Since
+// SeekPrefixGE on an sstable iterator seeks the data block using SeekGE, both
+// exact-match seeks and prefix seeks benefit.
+//
+// The hash index is stored between the restart points and the trailing
+// restart count:
+//
+//	+---------+----------+-------------+------------------+-----------------+
+//	| entries | restarts | buckets     | num buckets (u16)| num restarts    |
+//	|         | (4B each)| (1B each)   |                  | (u32, high bit) |
+//	+---------+----------+-------------+------------------+-----------------+
+//
+// The presence of a hash index is signaled by the high bit of the restart
+// count, which is otherwise always clear since blocks are limited to
+// MaximumBlockSize. Each bucket holds the index of a restart interval, or one
+// of the sentinels hashIndexBucketEmpty and hashIndexBucketCollision. Since
+// bucket values are a single byte, a hash index is only written for blocks
+// with at most hashIndexMaxRestarts restart points.
+//
+// A bucket may hold the restart interval of a different prefix than the one
+// sought, either because the sought prefix is not present in the block or
+// because of a hash collision that wasn't detected at write time (only
+// prefixes in the same block are compared). The reader therefore verifies that
+// the sought prefix could begin in the restart interval by comparing it with
+// the prefixes of the keys at the interval's restart point and at the next
+// restart point, and falls back to the binary search if it cannot.
+
+const (
+	// hashIndexRestartsMask is the bit of the restart count that signals the
+	// presence of a hash index.
+	hashIndexRestartsMask uint32 = 1 << 31
+	// hashIndexBucketEmpty marks a bucket to which no prefix hashes.
+	hashIndexBucketEmpty = 0xff
+	// hashIndexBucketCollision marks a bucket to which prefixes that first
+	// appear in different restart intervals hash.
+	hashIndexBucketCollision = 0xfe
+	// hashIndexMaxRestarts is the maximum number of restart points of a block
+	// with a hash index.
+	hashIndexMaxRestarts = hashIndexBucketCollision
+	// hashIndexUtilization is the target ratio of the number of distinct
+	// prefixes to the number of buckets.
+	hashIndexUtilization = 0.75
+	// hashIndexMaxBuckets is the maximum number of buckets, bounded by the
+	// encoding of the bucket count.
+	hashIndexMaxBuckets = 1<<16 - 1
+)
+
+type hashIndexEntry struct {
+	hash    uint64
+	restart uint8
+}
+
+func hashIndexHash(prefix []byte) uint64 {
+	return xxhash.Sum64(prefix)
+}
+
+// hashIndexNumBuckets returns the number of buckets of a hash index for the
+// given number of distinct prefixes.
+func hashIndexNumBuckets(numPrefixes int) int {
+	n := int(float64(numPrefixes)/hashIndexUtilization) + 1
+	if n > hashIndexMaxBuckets {
+		n = hashIndexMaxBuckets
+	}
+	return n
+}
+
+// appendHashIndex appends the buckets and bucket count of a hash index for the
+// given entries to buf.
+func appendHashIndex(buf []byte, entries []hashIndexEntry) []byte {
+	numBuckets := hashIndexNumBuckets(len(entries))
+	start := len(buf)
+	for j := 0; j < numBuckets; j++ {
+		buf = append(buf, hashIndexBucketEmpty)
+	}
+	buckets := buf[start:]
+	for _, e := range entries {
+		b := &buckets[e.hash%uint64(numBuckets)]
+		switch *b {
+		case hashIndexBucketEmpty:
+			*b = e.restart
+		case e.restart, hashIndexBucketCollision:
+		default:
+			*b = hashIndexBucketCollision
+		}
+	}
+	return binary.LittleEndian.AppendUint16(buf, uint16(numBuckets))
+}
+
+// addToHashIndex records the prefix of the key most recently added to the
+// block writer in the hash index, if it differs from the prefix of the
+// preceding key in the block.
+func (w *blockWriter) addToHashIndex(userKey []byte) {
+	prefix := userKey[:w.hashIndexSplit(userKey)]
+	if w.nEntries > 1 {
+		prevUserKey := w.prevKey[:len(w.prevKey)-8]
+		if string(prefix) == string(prevUserKey[:w.hashIndexSplit(prevUserKey)]) {
+			return
+		}
+	}
+	w.hashIndexEntries = append(w.hashIndexEntries, hashIndexEntry{
+		hash:    hashIndexHash(prefix),
+		restart: uint8(len(w.restarts) - 1),
+	})
+}
+
+// hashIndexSize returns the size of the hash index that finish would append
+// to the block, or zero if it would not append one.
+func (w *blockWriter) hashIndexSize() int {
+	if w.hashIndexSplit == nil || len(w.hashIndexEntries) == 0 || len(w.restarts) > hashIndexMaxRestarts {
+		return 0
+	}
+	return hashIndexNumBuckets(len(w.hashIndexEntries)) + 2
+}
+
+// decodeBlockTrailer decodes the restart count stored at the end of a block,
+// returning the offset at which the restart points begin, the number of
+// restart points, and the buckets of the block's hash index, if it has one.
+func decodeBlockTrailer(block []byte) (restarts, numRestarts int32, hashBuckets []byte, ok bool) {
+	if len(block) < 4 {
+		return 0, 0, nil, false
+	}
+	end := len(block) - 4
+	n := binary.LittleEndian.Uint32(block[end:])
+	if n&hashIndexRestartsMask != 0 {
+		n &^= hashIndexRestartsMask
+		if end < 2 {
+			return 0, 0, nil, false
+		}
+		numBuckets := int(binary.LittleEndian.Uint16(block[end-2:]))
+		end -= 2 + numBuckets
+		if end < 0 || numBuckets == 0 {
+			return 0, 0, nil, false
+		}
+		hashBuckets = block[end : end+numBuckets : end+numBuckets]
+	}
+	if n == 0 || int64(n)*4 > int64(end) {
+		return 0, 0, nil, false
+	}
+	return int32(end) - 4*int32(n), int32(n), hashBuckets, true
+}
+
+// restartUserKey returns the user key at the specified restart point.
+func (i *blockIter) restartUserKey(index int32) []byte {
+	offset := decodeRestart(i.data[i.restarts+4*index:])
+	// For a restart point, there are 0 bytes shared with the previous key. The
+	// varint encoding of 0 occupies 1 byte.
+	ptr := unsafe.Pointer(uintptr(i.ptr) + uintptr(offset+1))
+	unshared, ptr := decodeVarint(ptr)
+	_, ptr = decodeVarint(ptr)
+	if unshared < base.InternalTrailerLen {
+		return nil
+	}
+	return getBytes(ptr, int(unshared)-base.InternalTrailerLen)
+}
+
+// hashIndexSeek consults the block's hash index for the prefix of key. If the
+// prefix first appears in restart interval r, it returns r+1, i.e. the index
+// that the binary search in SeekGE would compute for a key at the start of the
+// prefix, and true. It returns false if the block has no hash index or the
+// restart interval of the prefix cannot be determined from it.
+func (i *blockIter) hashIndexSeek(key []byte) (int32, bool) {
+	if i.hashBuckets == nil || i.split == nil {
+		return 0, false
+	}
+	prefix := key[:i.split(key)]
+	r := int32(i.hashBuckets[hashIndexHash(prefix)%uint64(len(i.hashBuckets))])
+	if r >= hashIndexMaxRestarts || r >= i.numRestarts {
+		return 0, false
+	}
+	// The keys before restart interval r must all have smaller prefixes, which
+	// is the case if the prefix of the restart key isn't larger than the
+	// sought prefix. If the prefixes are equal, the bucket holds the first
+	// restart interval of the prefix (the bucket of a prefix present in the
+	// block can't hold another prefix's restart interval).
+	k := i.restartUserKey(r)
+	if i.cmp(k[:i.split(k)], prefix) > 0 {
+		return 0, false
+	}
+	// If the next restart key has a smaller prefix, the sought prefix is not
+	// present in restart interval r.
+	if r+1 < i.numRestarts {
+		k = i.restartUserKey(r + 1)
+		if i.cmp(k[:i.split(k)], prefix) < 0 {
+			return 0, false
+		}
+	}
+	return r + 1, true
+}
@@ -0,0 +1,234 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/testkeys"
+	"github.com/cockroachdb/pebble/objstorage/objstorageprovider"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/rand"
+)
+
+// hashIndexTestKeys returns sorted keys with a random number of versions per
+// prefix, along with keys that are not present: versions of present prefixes
+// and versions of absent prefixes.
+func hashIndexTestKeys(rng *rand.Rand, numPrefixes int) (keys, absent [][]byte) {
+	ks := testkeys.Alpha(3)
+	stride := ks.Count() / int64(numPrefixes+1)
+	for i := int64(0); i < int64(numPrefixes); i++ {
+		idx := i * stride
+		// Timestamps are sorted in decreasing order.
+		for ts := int64(2*rng.Intn(8) + 2); ts > 0; ts -= 2 {
+			keys = append(keys, testkeys.KeyAt(ks, idx, ts))
+		}
+		absent = append(absent,
+			testkeys.KeyAt(ks, idx, 1),
+			testkeys.KeyAt(ks, idx, 100),
+			testkeys.KeyAt(ks, idx+1, 1),
+			testkeys.Key(ks, idx+1))
+	}
+	return keys, absent
+}
+
+func TestBlockHashIndex(t *testing.T) {
+	seed := uint64(time.Now().UnixNano())
+	t.Logf("seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+
+	for _, numPrefixes := range []int{1, 10, 100, 500} {
+		for _, restartInterval := range []int{1, 4, 16} {
+			t.Run(fmt.Sprintf("prefixes=%d,restarts=%d", numPrefixes, restartInterval), func(t *testing.T) {
+				keys, absent := hashIndexTestKeys(rng, numPrefixes)
+				plain := &blockWriter{restartInterval: restartInterval}
+				hashed := &blockWriter{restartInterval: restartInterval, hashIndexSplit: testkeys.Comparer.Split}
+				for _, k := range keys {
+					plain.add(base.MakeInternalKey(k, 1, InternalKeyKindSet), nil)
+					hashed.add(base.MakeInternalKey(k, 1, InternalKeyKindSet), nil)
+				}
+				estimatedSize := hashed.estimatedSize()
+				numRestarts := len(hashed.restarts)
+				plainBlock := plain.finish()
+				hashedBlock := hashed.finish()
+				require.Equal(t, estimatedSize, len(hashedBlock))
+
+				plainIter, err := newBlockIter(testkeys.Comparer.Compare, plainBlock)
+				require.NoError(t, err)
+				hashedIter, err := newBlockIter(testkeys.Comparer.Compare, hashedBlock)
+				require.NoError(t, err)
+				hashedIter.split = testkeys.Comparer.Split
+				if numRestarts > hashIndexMaxRestarts {
+					require.Nil(t, hashedIter.hashBuckets)
+					require.Equal(t, len(plainBlock), len(hashedBlock))
+				} else {
+					require.NotNil(t, hashedIter.hashBuckets)
+				}
+
+				// Seeks with and without the hash index find the same keys.
+				var hits int
+				check := func(key []byte) {
+					if _, ok := hashedIter.hashIndexSeek(key); ok {
+						hits++
+					}
+					want, _ := plainIter.SeekGE(key, base.SeekGEFlagsNone)
+					got, _ := hashedIter.SeekGE(key, base.SeekGEFlagsNone)
+					if want == nil {
+						require.Nil(t, got, "SeekGE(%s)", key)
+						return
+					}
+					require.NotNil(t, got, "SeekGE(%s)", key)
+					require.Equal(t, string(want.UserKey), string(got.UserKey), "SeekGE(%s)", key)
+					// The iterator is positioned correctly for subsequent steps.
+					want, _ = plainIter.Next()
+					got, _ = hashedIter.Next()
+					require.Equal(t, want == nil, got == nil)
+					if want != nil {
+						require.Equal(t, string(want.UserKey), string(got.UserKey))
+					}
+					want, _ = plainIter.Prev()
+					got, _ = hashedIter.Prev()
+					require.Equal(t, string(want.UserKey), string(got.UserKey))
+				}
+				for _, k := range keys {
+					check(k)
+				}
+				for _, k := range absent {
+					check(k)
+				}
+				// The hash index is used for most present keys. Collisions
+				// account for the rest.
+				if hashedIter.hashBuckets != nil {
+					require.Greater(t, hits, len(keys)/2)
+				} else {
+					require.Zero(t, hits)
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeBlockTrailer(t *testing.T) {
+	w := &blockWriter{restartInterval: 2, hashIndexSplit: testkeys.Comparer.Split}
+	for _, k := range []string{"a@2", "a@1", "b@3", "c@1", "c"} {
+		w.add(base.MakeInternalKey([]byte(k), 1, InternalKeyKindSet), nil)
+	}
+	block := w.finish()
+	restarts, numRestarts, hashBuckets, ok := decodeBlockTrailer(block)
+	require.True(t, ok)
+	require.Equal(t, int32(3), numRestarts)
+	// Three distinct prefixes hash into five buckets.
+	require.Len(t, hashBuckets, 5)
+	require.Equal(t, int32(len(block)-4-2-5-4*3), restarts)
+
+	// Truncated blocks and implausible counts are detected.
+	for i := 0; i < 4; i++ {
+		_, _, _, ok = decodeBlockTrailer(block[:i])
+		require.False(t, ok)
+	}
+	for _, tc := range []struct {
+		off int
+		val byte
+	}{
+		{len(block) - 6, 0xff}, // too many buckets
+		{len(block) - 6, 0x00}, // no buckets
+		{len(block) - 2, 0x01}, // too many restarts
+	} {
+		corrupt := append([]byte(nil), block...)
+		corrupt[tc.off] = tc.val
+		if tc.off == len(block)-6 {
+			corrupt[tc.off+1] = tc.val
+		}
+		_, _, _, ok = decodeBlockTrailer(corrupt)
+		require.False(t, ok)
+	}
+}
+
+func TestWriterDataBlockHashIndex(t *testing.T) {
+	seed := uint64(time.Now().UnixNano())
+	t.Logf("seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+	keys, absent := hashIndexTestKeys(rng, 2000)
+
+	mem := vfs.NewMem()
+	build := func(name string, format TableFormat, hashIndex bool) *Reader {
+		f, err := mem.Create(name)
+		require.NoError(t, err)
+		w := NewWriter(objstorageprovider.NewFileWritable(f), WriterOptions{
+			BlockSize:          1024,
+			Comparer:           testkeys.Comparer,
+			TableFormat:        format,
+			DataBlockHashIndex: hashIndex,
+		})
+		for _, k := range keys {
+			require.NoError(t, w.Set(k, []byte(k)))
+		}
+		require.NoError(t, w.Close())
+		f, err = mem.Open(name)
+		require.NoError(t, err)
+		readable, err := NewSimpleReadable(f)
+		require.NoError(t, err)
+		r, err := NewReader(readable, ReaderOptions{Comparer: testkeys.Comparer})
+		require.NoError(t, err)
+		return r
+	}
+	plain := build("plain", TableFormatPebblev5, false)
+	defer plain.Close()
+	hashed := build("hashed", TableFormatPebblev5, true)
+	defer hashed.Close()
+	// The option is ignored by older table formats.
+	old := build("old", TableFormatPebblev4, true)
+	defer old.Close()
+
+	hasHashIndex := func(r *Reader) bool {
+		iter, err := r.NewIter(nil, nil)
+		require.NoError(t, err)
+		defer iter.Close()
+		key, _ := iter.SeekGE(keys[0], base.SeekGEFlagsNone)
+		require.NotNil(t, key)
+		switch i := iter.(type) {
+		case *singleLevelIterator:
+			return i.data.hashBuckets != nil
+		case *twoLevelIterator:
+			return i.data.hashBuckets != nil
+		default:
+			t.Fatalf("unexpected iterator type %T", iter)
+			return false
+		}
+	}
+	require.False(t, hasHashIndex(plain))
+	require.True(t, hasHashIndex(hashed))
+	require.False(t, hasHashIndex(old))
+
+	plainIter, err := plain.NewIter(nil, nil)
+	require.NoError(t, err)
+	defer plainIter.Close()
+	hashedIter, err := hashed.NewIter(nil, nil)
+	require.NoError(t, err)
+	defer hashedIter.Close()
+
+	toString := func(kv *base.InternalKey, v base.LazyValue) string {
+		if kv == nil {
+			return "<nil>"
+		}
+		val, _, err := v.Value(nil)
+		require.NoError(t, err)
+		return fmt.Sprintf("%s:%s", kv.UserKey, val)
+	}
+	for _, k := range append(append([][]byte(nil), keys...), absent...) {
+		want := toString(plainIter.SeekGE(k, base.SeekGEFlagsNone))
+		got := toString(hashedIter.SeekGE(k, base.SeekGEFlagsNone))
+		require.Equal(t, want, got, "SeekGE(%s)", k)
+
+		prefix := k[:testkeys.Comparer.Split(k)]
+		want = toString(plainIter.SeekPrefixGE(prefix, k, base.SeekGEFlagsNone))
+		got = toString(hashedIter.SeekPrefixGE(prefix, k, base.SeekGEFlagsNone))
+		require.Equal(t, want, got, "SeekPrefixGE(%s)", k)
+	}
+}
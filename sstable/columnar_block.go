@@ -0,0 +1,468 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unsafe"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/invariants"
+)
+
+// Columnar data blocks
+//
+// A data block written with TableFormatPebblev6 or later may be columnar,
+// enabled by WriterOptions.ColumnarDataBlocks. Instead of a sequence of
+// prefix-compressed key-value entries, a columnar block stores the keys split
+// by Comparer.Split into a column of distinct prefixes and a column of
+// suffixes, and stores the key trailers and values in columns of their own:
+//
+//	+----------+----------+--------+
+//	| prefixes | suffixes | values |     (byte columns)
+//	+----------+----------+--------+----------------+-----------------+
+//	| prefix offsets (u32 * (P+1)) | prefix rows (u32 * (P+1))        |
+//	+------------------------------+----------------------------------+
+//	| suffix offsets (u32 * (N+1)) | trailers (u64 * N)               |
+//	+------------------------------+----------------------------------+
+//	| value offsets (u32 * (N+1))  | P (u32) | N | columnar bit (u32) |
+//	+------------------------------+----------------------------------+
+//
+// where N is the number of keys (rows) in the block and P is the number of
+// distinct prefixes. The offsets are byte offsets from the start of the block:
+// prefix p occupies [prefixOffsets[p], prefixOffsets[p+1]), and the rows with
+// prefix p are [prefixRows[p], prefixRows[p+1]). The suffix and value of row r
+// are located analogously. Values include the value prefix of
+// TableFormatPebblev3 (see value_block.go).
+//
+// A columnar block is signaled by columnarBlockMask in the trailing row count,
+// which is otherwise the restart count of a row-oriented block and always has
+// the bit clear. The block format is independent of the table format, so a
+// table may contain both row-oriented and columnar data blocks.
+//
+// Storing each distinct prefix once saves space when keys have many versions,
+// and keeps the repeated suffixes and the trailers together, where they
+// compress well. The prefix column allows NextPrefix to step to the next
+// prefix without examining the remaining versions of the current one, and
+// seeks to binary search the prefixes and then compare suffixes, never
+// assembling the full keys. Since the keys are stored separately from the
+// values, they can be scanned without touching the values.
+
+// columnarBlockMask is the bit of the trailing row count that signals a
+// columnar block.
+const columnarBlockMask uint32 = 1 << 30
+
+// columnarBlockFooterLen is the length of the footer of a columnar block,
+// holding the number of prefixes and the number of rows.
+const columnarBlockFooterLen = 8
+
+// isColumnarBlock returns true if the block is a columnar data block.
+func isColumnarBlock(block []byte) bool {
+	return len(block) >= columnarBlockFooterLen &&
+		binary.LittleEndian.Uint32(block[len(block)-4:])&columnarBlockMask != 0
+}
+
+// columnarBlockWriter accumulates the columns of a columnar data block. It is
+// used by blockWriter when blockWriter.columnarSplit is set.
+type columnarBlockWriter struct {
+	prefixes      []byte
+	suffixes      []byte
+	values        []byte
+	prefixOffsets []uint32
+	prefixRows    []uint32
+	suffixOffsets []uint32
+	valueOffsets  []uint32
+	trailers      []uint64
+}
+
+func (w *columnarBlockWriter) cleared() columnarBlockWriter {
+	return columnarBlockWriter{
+		prefixes:      w.prefixes[:0],
+		suffixes:      w.suffixes[:0],
+		values:        w.values[:0],
+		prefixOffsets: w.prefixOffsets[:0],
+		prefixRows:    w.prefixRows[:0],
+		suffixOffsets: w.suffixOffsets[:0],
+		valueOffsets:  w.valueOffsets[:0],
+		trailers:      w.trailers[:0],
+	}
+}
+
+// add appends a row to the block. The key must sort after the previously
+// added keys. The value prefix, if any, is stored with the value.
+func (w *columnarBlockWriter) add(
+	split Split, key InternalKey, value []byte, addValuePrefix bool, valuePrefix valuePrefix,
+) {
+	row := uint32(len(w.trailers))
+	prefix := key.UserKey[:split(key.UserKey)]
+	if n := len(w.prefixOffsets); n == 0 || !bytes.Equal(w.prefixes[w.prefixOffsets[n-1]:], prefix) {
+		w.prefixOffsets = append(w.prefixOffsets, uint32(len(w.prefixes)))
+		w.prefixRows = append(w.prefixRows, row)
+		w.prefixes = append(w.prefixes, prefix...)
+	}
+	w.suffixOffsets = append(w.suffixOffsets, uint32(len(w.suffixes)))
+	w.suffixes = append(w.suffixes, key.UserKey[len(prefix):]...)
+	w.valueOffsets = append(w.valueOffsets, uint32(len(w.values)))
+	if addValuePrefix {
+		w.values = append(w.values, byte(valuePrefix))
+	}
+	w.values = append(w.values, value...)
+	w.trailers = append(w.trailers, key.Trailer)
+}
+
+// estimatedSize returns the size of the block that finish would produce.
+func (w *columnarBlockWriter) estimatedSize() int {
+	numPrefixes, numRows := len(w.prefixOffsets), len(w.trailers)
+	return len(w.prefixes) + len(w.suffixes) + len(w.values) +
+		8*(numPrefixes+1) + 8*(numRows+1) + 8*numRows + columnarBlockFooterLen
+}
+
+// finish appends the encoded block to buf[:0], and resets the writer.
+func (w *columnarBlockWriter) finish(buf []byte) []byte {
+	numPrefixes, numRows := len(w.prefixOffsets), len(w.trailers)
+	buf = buf[:0]
+	if cap(buf) < w.estimatedSize() {
+		buf = make([]byte, 0, w.estimatedSize())
+	}
+	buf = append(buf, w.prefixes...)
+	buf = append(buf, w.suffixes...)
+	buf = append(buf, w.values...)
+
+	appendOffsets := func(buf []byte, offsets []uint32, base, end int) []byte {
+		for _, o := range offsets {
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(base)+o)
+		}
+		return binary.LittleEndian.AppendUint32(buf, uint32(base+end))
+	}
+	buf = appendOffsets(buf, w.prefixOffsets, 0, len(w.prefixes))
+	buf = appendOffsets(buf, w.prefixRows, 0, numRows)
+	buf = appendOffsets(buf, w.suffixOffsets, len(w.prefixes), len(w.suffixes))
+	for _, t := range w.trailers {
+		buf = binary.LittleEndian.AppendUint64(buf, t)
+	}
+	buf = appendOffsets(buf, w.valueOffsets, len(w.prefixes)+len(w.suffixes), len(w.values))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(numPrefixes))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(numRows)|columnarBlockMask)
+	*w = w.cleared()
+	return buf
+}
+
+// columnarBlock provides access to the columns of a columnar data block, along
+// with the state of a blockIter positioned within it.
+type columnarBlock struct {
+	data          []byte
+	numPrefixes   int32
+	numRows       int32
+	prefixOffsets []byte
+	prefixRows    []byte
+	suffixOffsets []byte
+	trailers      []byte
+	valueOffsets  []byte
+
+	// prefixIdx is the index of the prefix of the row the iterator is
+	// positioned at, or, if the iterator is exhausted, of the first or last
+	// prefix.
+	prefixIdx int32
+	// keyPrefixIdx is the index of the prefix stored in blockIter.fullKey, or
+	// -1. keyPrefixLen is the length of that prefix.
+	keyPrefixIdx int32
+	keyPrefixLen int
+	// firstKey is the buffer backing blockIter.firstUserKey.
+	firstKey []byte
+}
+
+func readUint32(b []byte, i int32) uint32 {
+	return binary.LittleEndian.Uint32(b[4*i:])
+}
+
+// decode decodes the columns of block, returning false if the block is
+// malformed.
+func (c *columnarBlock) decode(block []byte) bool {
+	n := len(block) - columnarBlockFooterLen
+	if n < 0 {
+		return false
+	}
+	numPrefixes := int64(binary.LittleEndian.Uint32(block[n:]))
+	numRows := int64(binary.LittleEndian.Uint32(block[n+4:]) &^ columnarBlockMask)
+	metaLen := 8*(numPrefixes+1) + 8*(numRows+1) + 8*numRows
+	start := int64(n) - metaLen
+	if start < 0 || numPrefixes > numRows || (numRows > 0) != (numPrefixes > 0) {
+		return false
+	}
+	meta := block[start:n]
+	c.data = block
+	c.numPrefixes = int32(numPrefixes)
+	c.numRows = int32(numRows)
+	c.prefixOffsets, meta = meta[:4*(numPrefixes+1)], meta[4*(numPrefixes+1):]
+	c.prefixRows, meta = meta[:4*(numPrefixes+1)], meta[4*(numPrefixes+1):]
+	c.suffixOffsets, meta = meta[:4*(numRows+1)], meta[4*(numRows+1):]
+	c.trailers, meta = meta[:8*numRows], meta[8*numRows:]
+	c.valueOffsets = meta
+	// The columns must be contiguous and end where the offsets begin. The
+	// offsets within each column are validated lazily by the slice bounds
+	// checks when they're accessed.
+	return readUint32(c.prefixOffsets, 0) == 0 &&
+		readUint32(c.prefixOffsets, c.numPrefixes) == readUint32(c.suffixOffsets, 0) &&
+		readUint32(c.suffixOffsets, c.numRows) == readUint32(c.valueOffsets, 0) &&
+		int64(readUint32(c.valueOffsets, c.numRows)) == start &&
+		readUint32(c.prefixRows, 0) == 0 &&
+		int64(readUint32(c.prefixRows, c.numPrefixes)) == numRows
+}
+
+func (c *columnarBlock) prefix(p int32) []byte {
+	return c.data[readUint32(c.prefixOffsets, p):readUint32(c.prefixOffsets, p+1)]
+}
+
+// prefixStart returns the first row of prefix p. The first row past the last
+// prefix is numRows.
+func (c *columnarBlock) prefixStart(p int32) int32 {
+	return int32(readUint32(c.prefixRows, p))
+}
+
+func (c *columnarBlock) suffix(row int32) []byte {
+	return c.data[readUint32(c.suffixOffsets, row):readUint32(c.suffixOffsets, row+1)]
+}
+
+func (c *columnarBlock) value(row int32) []byte {
+	return c.data[readUint32(c.valueOffsets, row):readUint32(c.valueOffsets, row+1)]
+}
+
+func (c *columnarBlock) trailer(row int32) uint64 {
+	return binary.LittleEndian.Uint64(c.trailers[8*row:])
+}
+
+// prefixOfRow returns the index of the prefix of the given row.
+func (c *columnarBlock) prefixOfRow(row int32) int32 {
+	// Find the first prefix that starts after row; row belongs to the prefix
+	// preceding it.
+	lo, hi := int32(0), c.numPrefixes
+	for lo < hi {
+		h := int32(uint(lo+hi) >> 1)
+		if c.prefixStart(h) <= row {
+			lo = h + 1
+		} else {
+			hi = h
+		}
+	}
+	return lo - 1
+}
+
+// initColumnar initializes the blockIter for a columnar data block.
+func (i *blockIter) initColumnar(
+	cmp Compare, block block, globalSeqNum uint64, hideObsoletePoints bool,
+) error {
+	if !i.col.decode(block) {
+		return base.CorruptionErrorf("pebble/table: invalid table (malformed columnar block)")
+	}
+	i.cmp = cmp
+	i.columnar = true
+	i.restarts = i.col.numRows
+	i.numRestarts = 0
+	i.hashBuckets = nil
+	i.globalSeqNum = globalSeqNum
+	i.ptr = unsafe.Pointer(&block[0])
+	i.data = block
+	i.fullKey = i.fullKey[:0]
+	i.val = nil
+	i.hideObsoletePoints = hideObsoletePoints
+	i.clearCache()
+	i.col.keyPrefixIdx = -1
+	i.col.prefixIdx = 0
+	i.offset = 0
+	if i.col.numRows > 0 {
+		i.col.firstKey = append(append(i.col.firstKey[:0], i.col.prefix(0)...), i.col.suffix(0)...)
+		i.firstUserKey = i.col.firstKey
+	} else {
+		i.firstUserKey = nil
+	}
+	return nil
+}
+
+// colLoad positions the iterator at the given row, which must belong to
+// i.col.prefixIdx, and returns true if the row is an obsolete point that
+// should be hidden.
+func (i *blockIter) colLoad(row int32) (hiddenPoint bool) {
+	c := &i.col
+	if invariants.Enabled && c.prefixOfRow(row) != c.prefixIdx {
+		panic(errors.AssertionFailedf("row %d does not belong to prefix %d", row, c.prefixIdx))
+	}
+	if c.keyPrefixIdx != c.prefixIdx {
+		i.fullKey = append(i.fullKey[:0], c.prefix(c.prefixIdx)...)
+		c.keyPrefixIdx = c.prefixIdx
+		c.keyPrefixLen = len(i.fullKey)
+	}
+	i.fullKey = append(i.fullKey[:c.keyPrefixLen], c.suffix(row)...)
+	i.key = i.fullKey
+	trailer := c.trailer(row)
+	hiddenPoint = i.hideObsoletePoints && trailer&trailerObsoleteBit != 0
+	i.ikey.Trailer = trailer & trailerObsoleteMask
+	i.ikey.UserKey = i.fullKey
+	if i.globalSeqNum != 0 {
+		i.ikey.SetSeqNum(i.globalSeqNum)
+	}
+	i.val = c.value(row)
+	i.offset = row
+	// nextOffset is only used to estimate the position of the iterator within
+	// the block, for which the offset of the end of the value suffices.
+	i.nextOffset = int32(readUint32(c.valueOffsets, row+1))
+	return hiddenPoint
+}
+
+func (i *blockIter) colLazyValue() base.LazyValue {
+	if !i.lazyValueHandling.hasValuePrefix ||
+		base.TrailerKind(i.ikey.Trailer) != InternalKeyKindSet {
+		i.lazyValue = base.MakeInPlaceValue(i.val)
+	} else if i.lazyValueHandling.vbr == nil || !isValueHandle(valuePrefix(i.val[0])) {
+		i.lazyValue = base.MakeInPlaceValue(i.val[1:])
+	} else {
+		i.lazyValue = i.lazyValueHandling.vbr.getLazyValueForPrefixAndValueHandle(i.val)
+	}
+	return i.lazyValue
+}
+
+// colForward positions the iterator at the first visible row at or after the
+// given row, which must belong to i.col.prefixIdx if it is a valid row.
+func (i *blockIter) colForward(row int32) (*InternalKey, base.LazyValue) {
+	c := &i.col
+	for ; row < c.numRows; row++ {
+		for c.prefixIdx+1 < c.numPrefixes && row >= c.prefixStart(c.prefixIdx+1) {
+			c.prefixIdx++
+		}
+		if !i.colLoad(row) {
+			return &i.ikey, i.colLazyValue()
+		}
+	}
+	i.offset = c.numRows
+	i.val = nil
+	return nil, base.LazyValue{}
+}
+
+// colBackward positions the iterator at the last visible row at or before the
+// given row.
+func (i *blockIter) colBackward(row int32) (*InternalKey, base.LazyValue) {
+	c := &i.col
+	for ; row >= 0; row-- {
+		for c.prefixIdx > 0 && row < c.prefixStart(c.prefixIdx) {
+			c.prefixIdx--
+		}
+		if !i.colLoad(row) {
+			return &i.ikey, i.colLazyValue()
+		}
+	}
+	i.offset = -1
+	i.val = nil
+	return nil, base.LazyValue{}
+}
+
+// colSearch returns the first row with a key >= key, or numRows if there is
+// no such row, and sets i.col.prefixIdx to the prefix of that row.
+func (i *blockIter) colSearch(key []byte) int32 {
+	c := &i.col
+	if i.split == nil {
+		// Without a Split function, binary search the rows comparing whole
+		// keys.
+		lo, hi := int32(0), c.numRows
+		for lo < hi {
+			h := int32(uint(lo+hi) >> 1)
+			p := c.prefixOfRow(h)
+			i.cachedBuf = append(append(i.cachedBuf[:0], c.prefix(p)...), c.suffix(h)...)
+			if i.cmp(i.cachedBuf, key) < 0 {
+				lo = h + 1
+			} else {
+				hi = h
+			}
+		}
+		i.cachedBuf = i.cachedBuf[:0]
+		if lo < c.numRows {
+			c.prefixIdx = c.prefixOfRow(lo)
+		} else {
+			c.prefixIdx = c.numPrefixes - 1
+		}
+		return lo
+	}
+
+	keyPrefix := key[:i.split(key)]
+	// Find the first prefix >= the key's prefix.
+	lo, hi := int32(0), c.numPrefixes
+	for lo < hi {
+		h := int32(uint(lo+hi) >> 1)
+		if i.cmp(c.prefix(h), keyPrefix) < 0 {
+			lo = h + 1
+		} else {
+			hi = h
+		}
+	}
+	if lo == c.numPrefixes {
+		c.prefixIdx = c.numPrefixes - 1
+		return c.numRows
+	}
+	c.prefixIdx = lo
+	start := c.prefixStart(lo)
+	if i.cmp(c.prefix(lo), keyPrefix) != 0 {
+		return start
+	}
+	// The prefixes are equal, so the keys are ordered by their suffixes.
+	keySuffix := key[len(keyPrefix):]
+	rlo, rhi := start, c.prefixStart(lo+1)
+	for rlo < rhi {
+		h := int32(uint(rlo+rhi) >> 1)
+		if i.cmp(c.suffix(h), keySuffix) < 0 {
+			rlo = h + 1
+		} else {
+			rhi = h
+		}
+	}
+	return rlo
+}
+
+func (i *blockIter) colSeekGE(key []byte) (*InternalKey, base.LazyValue) {
+	return i.colForward(i.colSearch(key))
+}
+
+func (i *blockIter) colSeekLT(key []byte) (*InternalKey, base.LazyValue) {
+	return i.colBackward(i.colSearch(key) - 1)
+}
+
+func (i *blockIter) colFirst() (*InternalKey, base.LazyValue) {
+	i.col.prefixIdx = 0
+	return i.colForward(0)
+}
+
+func (i *blockIter) colLast() (*InternalKey, base.LazyValue) {
+	i.col.prefixIdx = max(i.col.numPrefixes-1, 0)
+	return i.colBackward(i.col.numRows - 1)
+}
+
+func (i *blockIter) colNext() (*InternalKey, base.LazyValue) {
+	if i.offset >= i.col.numRows {
+		return nil, base.LazyValue{}
+	}
+	return i.colForward(i.offset + 1)
+}
+
+func (i *blockIter) colPrev() (*InternalKey, base.LazyValue) {
+	if i.offset < 0 {
+		return nil, base.LazyValue{}
+	}
+	return i.colBackward(i.offset - 1)
+}
+
+// colNextPrefix steps to the first row of the next prefix. All of its keys
+// are >= succKey, the immediate successor of the current key's prefix.
+func (i *blockIter) colNextPrefix() (*InternalKey, base.LazyValue) {
+	c := &i.col
+	if i.offset < 0 || i.offset >= c.numRows {
+		return i.colNext()
+	}
+	if c.prefixIdx+1 >= c.numPrefixes {
+		i.offset = c.numRows
+		i.val = nil
+		return nil, base.LazyValue{}
+	}
+	c.prefixIdx++
+	return i.colForward(c.prefixStart(c.prefixIdx))
+}
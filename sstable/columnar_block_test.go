@@ -0,0 +1,269 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/testkeys"
+	"github.com/cockroachdb/pebble/objstorage/objstorageprovider"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/rand"
+)
+
+// compareBlockIters checks that a and b return the same keys and values for a
+// random sequence of operations.
+func compareBlockIters(
+	t *testing.T, rng *rand.Rand, a, b base.InternalIterator, keys, absent [][]byte,
+) {
+	format := func(k *InternalKey, v base.LazyValue) string {
+		if k == nil {
+			return "<nil>"
+		}
+		return fmt.Sprintf("%s:%s", k, v.InPlaceValue())
+	}
+	seekKeys := append(append([][]byte(nil), keys...), absent...)
+	ops := []string{"first"}
+	ka, va := a.First()
+	kb, vb := b.First()
+	require.Equal(t, format(ka, va), format(kb, vb))
+	for j := 0; j < 2000; j++ {
+		var op string
+		switch rng.Intn(8) {
+		case 0:
+			op = "first"
+			ka, va = a.First()
+			kb, vb = b.First()
+		case 1:
+			op = "last"
+			ka, va = a.Last()
+			kb, vb = b.Last()
+		case 2, 3:
+			op = "next"
+			ka, va = a.Next()
+			kb, vb = b.Next()
+		case 4:
+			op = "prev"
+			ka, va = a.Prev()
+			kb, vb = b.Prev()
+		case 5:
+			k := seekKeys[rng.Intn(len(seekKeys))]
+			op = fmt.Sprintf("seek-ge %s", k)
+			ka, va = a.SeekGE(k, base.SeekGEFlagsNone)
+			kb, vb = b.SeekGE(k, base.SeekGEFlagsNone)
+		case 6:
+			k := seekKeys[rng.Intn(len(seekKeys))]
+			op = fmt.Sprintf("seek-lt %s", k)
+			ka, va = a.SeekLT(k, base.SeekLTFlagsNone)
+			kb, vb = b.SeekLT(k, base.SeekLTFlagsNone)
+		case 7:
+			// NextPrefix requires the iterator to be positioned at a key.
+			k := keys[rng.Intn(len(keys))]
+			ka, _ = a.SeekGE(k, base.SeekGEFlagsNone)
+			kb, _ = b.SeekGE(k, base.SeekGEFlagsNone)
+			if ka == nil || kb == nil {
+				require.Equal(t, ka == nil, kb == nil)
+				continue
+			}
+			succ := testkeys.Comparer.ImmediateSuccessor(nil, ka.UserKey[:testkeys.Comparer.Split(ka.UserKey)])
+			op = fmt.Sprintf("seek-ge %s; next-prefix", k)
+			ka, va = a.NextPrefix(succ)
+			kb, vb = b.NextPrefix(succ)
+		}
+		ops = append(ops, op)
+		want, got := format(ka, va), format(kb, vb)
+		if want != got {
+			t.Fatalf("mismatch after:\n%s\nwant %s\ngot  %s", strings.Join(ops, "\n"), want, got)
+		}
+	}
+}
+
+func TestColumnarBlock(t *testing.T) {
+	seed := uint64(time.Now().UnixNano())
+	t.Logf("seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+
+	for _, numPrefixes := range []int{0, 1, 10, 200} {
+		for _, hideObsolete := range []bool{false, true} {
+			t.Run(fmt.Sprintf("prefixes=%d,hide-obsolete=%t", numPrefixes, hideObsolete), func(t *testing.T) {
+				keys, absent := hashIndexTestKeys(rng, numPrefixes)
+				if len(absent) == 0 {
+					absent = [][]byte{[]byte("a")}
+				}
+				rowWriter := &blockWriter{restartInterval: 16}
+				colWriter := &blockWriter{restartInterval: 16, columnarSplit: testkeys.Comparer.Split}
+				for j, k := range keys {
+					ik := base.MakeInternalKey(k, uint64(j), InternalKeyKindSet)
+					isObsolete := rng.Intn(3) == 0
+					value := []byte(fmt.Sprintf("v%d", j))
+					rowWriter.addWithOptionalValuePrefix(ik, isObsolete, value, len(k), false, 0, false)
+					colWriter.addWithOptionalValuePrefix(ik, isObsolete, value, len(k), false, 0, false)
+					require.Equal(t, rowWriter.getCurKey(), colWriter.getCurKey())
+					require.Equal(t, value, colWriter.curValue)
+				}
+				estimatedSize := colWriter.estimatedSize()
+				rowBlock := rowWriter.finish()
+				colBlock := colWriter.finish()
+				require.Equal(t, estimatedSize, len(colBlock))
+				require.True(t, isColumnarBlock(colBlock))
+				require.False(t, isColumnarBlock(rowBlock))
+
+				rowIter := &blockIter{}
+				require.NoError(t, rowIter.init(testkeys.Comparer.Compare, rowBlock, 0, hideObsolete))
+				for _, split := range []Split{nil, testkeys.Comparer.Split} {
+					colIter := &blockIter{split: split}
+					require.NoError(t, colIter.init(testkeys.Comparer.Compare, colBlock, 0, hideObsolete))
+					require.True(t, colIter.columnar)
+					require.Equal(t, string(rowIter.getFirstUserKey()), string(colIter.getFirstUserKey()))
+					if len(keys) == 0 {
+						k, _ := colIter.First()
+						require.Nil(t, k)
+						continue
+					}
+					compareBlockIters(t, rng, rowIter, colIter, keys, absent)
+				}
+
+				// The writer is reset by finish and can be reused.
+				empty := &blockWriter{columnarSplit: testkeys.Comparer.Split}
+				require.Equal(t, empty.estimatedSize(), colWriter.estimatedSize())
+			})
+		}
+	}
+}
+
+func TestColumnarBlockCorruption(t *testing.T) {
+	w := &blockWriter{columnarSplit: testkeys.Comparer.Split}
+	for _, k := range []string{"a@2", "a@1", "b@3", "c"} {
+		w.add(base.MakeInternalKey([]byte(k), 1, InternalKeyKindSet), []byte(k))
+	}
+	block := w.finish()
+	var c columnarBlock
+	require.True(t, c.decode(block))
+	require.Equal(t, int32(4), c.numRows)
+	require.Equal(t, int32(3), c.numPrefixes)
+	require.Equal(t, "a", string(c.prefix(0)))
+	require.Equal(t, "@1", string(c.suffix(1)))
+	require.Equal(t, "", string(c.suffix(3)))
+	require.Equal(t, "b@3", string(c.value(2)))
+	require.Equal(t, int32(2), c.prefixOfRow(3))
+
+	// Truncating the block or changing its counts is detected.
+	for n := 0; n < len(block); n++ {
+		truncated := append([]byte(nil), block[n+1:]...)
+		if isColumnarBlock(truncated) {
+			require.False(t, c.decode(truncated), "truncated at %d", n)
+		}
+	}
+	for _, off := range []int{len(block) - 8, len(block) - 4} {
+		corrupt := append([]byte(nil), block...)
+		corrupt[off]++
+		i := &blockIter{}
+		require.Error(t, i.init(testkeys.Comparer.Compare, corrupt, 0, false))
+	}
+}
+
+func TestWriterColumnarDataBlocks(t *testing.T) {
+	seed := uint64(time.Now().UnixNano())
+	t.Logf("seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+	keys, absent := hashIndexTestKeys(rng, 2000)
+	values := make([][]byte, len(keys))
+	for j := range values {
+		values[j] = bytes.Repeat([]byte{byte('a' + j%26)}, 1+rng.Intn(200))
+	}
+
+	mem := vfs.NewMem()
+	build := func(name string, format TableFormat, columnar bool) *Reader {
+		f, err := mem.Create(name)
+		require.NoError(t, err)
+		w := NewWriter(objstorageprovider.NewFileWritable(f), WriterOptions{
+			BlockSize:          2048,
+			Comparer:           testkeys.Comparer,
+			TableFormat:        format,
+			ColumnarDataBlocks: columnar,
+			Compression:        NoCompression,
+		})
+		for j, k := range keys {
+			// Values of older versions are stored in value blocks.
+			require.NoError(t, w.Set(k, values[j]))
+		}
+		require.NoError(t, w.Close())
+		f, err = mem.Open(name)
+		require.NoError(t, err)
+		readable, err := NewSimpleReadable(f)
+		require.NoError(t, err)
+		r, err := NewReader(readable, ReaderOptions{Comparer: testkeys.Comparer})
+		require.NoError(t, err)
+		return r
+	}
+	row := build("row", TableFormatPebblev6, false)
+	defer row.Close()
+	col := build("col", TableFormatPebblev6, true)
+	defer col.Close()
+	// The option is ignored by older table formats.
+	old := build("old", TableFormatPebblev5, true)
+	defer old.Close()
+
+	isColumnar := func(r *Reader) bool {
+		l, err := r.Layout()
+		require.NoError(t, err)
+		var buf strings.Builder
+		l.Describe(&buf, true /* verbose */, r, nil /* fmtRecord */)
+		return strings.Contains(buf.String(), "[columns:")
+	}
+	require.False(t, isColumnar(row))
+	require.True(t, isColumnar(col))
+	require.False(t, isColumnar(old))
+	require.NotZero(t, col.Properties.NumValueBlocks)
+
+	rowIter, err := row.NewIter(nil, nil)
+	require.NoError(t, err)
+	defer rowIter.Close()
+	colIter, err := col.NewIter(nil, nil)
+	require.NoError(t, err)
+	defer colIter.Close()
+
+	toString := func(kv *base.InternalKey, v base.LazyValue) string {
+		if kv == nil {
+			return "<nil>"
+		}
+		val, _, err := v.Value(nil)
+		require.NoError(t, err)
+		return fmt.Sprintf("%s:%s", kv.UserKey, val)
+	}
+	var n int
+	for k, v := colIter.First(); k != nil; k, v = colIter.Next() {
+		require.Equal(t, string(keys[n]), toString(k, v)[:len(keys[n])])
+		n++
+	}
+	require.Equal(t, len(keys), n)
+	for _, k := range append(append([][]byte(nil), keys...), absent...) {
+		rowKey, rowValue := rowIter.SeekGE(k, base.SeekGEFlagsNone)
+		want := toString(rowKey, rowValue)
+		got := toString(colIter.SeekGE(k, base.SeekGEFlagsNone))
+		require.Equal(t, want, got, "SeekGE(%s)", k)
+		if rowKey != nil {
+			succ := testkeys.Comparer.ImmediateSuccessor(nil, rowKey.UserKey[:testkeys.Comparer.Split(rowKey.UserKey)])
+			want = toString(rowIter.NextPrefix(succ))
+			got = toString(colIter.NextPrefix(succ))
+			require.Equal(t, want, got, "SeekGE(%s); NextPrefix", k)
+		}
+
+		prefix := k[:testkeys.Comparer.Split(k)]
+		want = toString(rowIter.SeekPrefixGE(prefix, k, base.SeekGEFlagsNone))
+		got = toString(colIter.SeekPrefixGE(prefix, k, base.SeekGEFlagsNone))
+		require.Equal(t, want, got, "SeekPrefixGE(%s)", k)
+
+		want = toString(rowIter.SeekLT(k, base.SeekLTFlagsNone))
+		got = toString(colIter.SeekLT(k, base.SeekLTFlagsNone))
+		require.Equal(t, want, got, "SeekLT(%s)", k)
+	}
+}
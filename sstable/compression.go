@@ -97,3 +97,76 @@ func compressBlock(
 		return noCompressionBlockType, b
 	}
 }
+
+// adaptiveCompressor selects the compression algorithm for each data block
+// written by a Writer configured with AdaptiveCompressionOptions. It tracks
+// whether the configured algorithm is currently profitable, and while it is
+// not, uses the fallback algorithm for all but every SampleInterval'th block.
+// The sampled blocks are compressed with the configured algorithm in order to
+// detect when the data becomes compressible again.
+type adaptiveCompressor struct {
+	opts AdaptiveCompressionOptions
+	// compression is the configured (preferred) compression algorithm.
+	compression Compression
+	// fallingBack is true when the most recent sample indicated that the
+	// configured algorithm does not yield sufficient savings.
+	fallingBack bool
+	// blocksSinceSample is the number of blocks that have been written using
+	// the fallback algorithm since the last sample.
+	blocksSinceSample int
+}
+
+// next returns the compression algorithm to use for the next data block.
+func (a *adaptiveCompressor) next() Compression {
+	if !a.fallingBack || a.blocksSinceSample >= a.opts.SampleInterval {
+		return a.compression
+	}
+	return a.opts.Fallback
+}
+
+// record updates the adaptive state and the compression counters in props
+// after a data block of uncompressedLen bytes was compressed using the
+// algorithm c (as returned by next), resulting in a block of compressedLen
+// bytes with the given block type.
+func (a *adaptiveCompressor) record(
+	props *Properties, c Compression, bt blockType, uncompressedLen, compressedLen int,
+) {
+	n := uint64(uncompressedLen)
+	if bt == noCompressionBlockType {
+		addCompressionSkipped(props, c, n)
+	} else {
+		addCompressionCompressed(props, c, n)
+	}
+	if c != a.compression {
+		// A block written with the fallback algorithm. The configured algorithm
+		// was skipped for this block.
+		addCompressionSkipped(props, a.compression, n)
+		a.blocksSinceSample++
+		return
+	}
+	// A block written with the configured algorithm serves as a sample.
+	a.blocksSinceSample = 0
+	savings := 0
+	if bt != noCompressionBlockType && uncompressedLen > 0 {
+		savings = 100 * (uncompressedLen - compressedLen) / uncompressedLen
+	}
+	a.fallingBack = savings < a.opts.MinSavingsPercent
+}
+
+func addCompressionCompressed(props *Properties, c Compression, n uint64) {
+	switch c {
+	case SnappyCompression:
+		props.SnappyCompressedBytes += n
+	case ZstdCompression:
+		props.ZstdCompressedBytes += n
+	}
+}
+
+func addCompressionSkipped(props *Properties, c Compression, n uint64) {
+	switch c {
+	case SnappyCompression:
+		props.SnappySkippedBytes += n
+	case ZstdCompression:
+		props.ZstdSkippedBytes += n
+	}
+}
@@ -25,6 +25,8 @@ const (
 	TableFormatPebblev2 // Range keys.
 	TableFormatPebblev3 // Value blocks.
 	TableFormatPebblev4 // DELSIZED tombstones.
+	TableFormatPebblev5 // Data block hash index.
+	TableFormatPebblev6 // Columnar data blocks.
 	NumTableFormats
 
 	TableFormatMax = NumTableFormats - 1
@@ -231,6 +233,10 @@ func ParseTableFormat(magic []byte, version uint32) (TableFormat, error) {
 			return TableFormatPebblev3, nil
 		case 4:
 			return TableFormatPebblev4, nil
+		case 5:
+			return TableFormatPebblev5, nil
+		case 6:
+			return TableFormatPebblev6, nil
 		default:
 			return TableFormatUnspecified, base.CorruptionErrorf(
 				"pebble/table: unsupported pebble format version %d", errors.Safe(version),
@@ -258,6 +264,10 @@ func (f TableFormat) AsTuple() (string, uint32) {
 		return pebbleDBMagic, 3
 	case TableFormatPebblev4:
 		return pebbleDBMagic, 4
+	case TableFormatPebblev5:
+		return pebbleDBMagic, 5
+	case TableFormatPebblev6:
+		return pebbleDBMagic, 6
 	default:
 		panic("sstable: unknown table format version tuple")
 	}
@@ -278,6 +288,10 @@ func (f TableFormat) String() string {
 		return "(Pebble,v3)"
 	case TableFormatPebblev4:
 		return "(Pebble,v4)"
+	case TableFormatPebblev5:
+		return "(Pebble,v5)"
+	case TableFormatPebblev6:
+		return "(Pebble,v6)"
 	default:
 		panic("sstable: unknown table format version tuple")
 	}
@@ -145,7 +145,7 @@ func (l *Layout) Describe(
 		}
 
 		h, err := r.readBlock(
-			context.Background(), b.BlockHandle, nil /* transform */, nil /* readHandle */, nil /* stats */, nil /* iterStats */, nil /* buffer pool */)
+			context.Background(), b.BlockHandle, nil /* transform */, nil /* readHandle */, nil /* stats */, nil /* iterStats */, nil /* buffer pool */, cacheNormalPriority)
 		if err != nil {
 			fmt.Fprintf(w, "  [err: %s]\n", err)
 			continue
@@ -188,26 +188,40 @@ func (l *Layout) Describe(
 		case "data", "range-del", "range-key":
 			iter, _ := newBlockIter(r.Compare, h.Get())
 			for key, value := iter.First(); key != nil; key, value = iter.Next() {
-				ptr := unsafe.Pointer(uintptr(iter.ptr) + uintptr(iter.offset))
-				shared, ptr := decodeVarint(ptr)
-				unshared, ptr := decodeVarint(ptr)
-				value2, _ := decodeVarint(ptr)
+				if iter.columnar {
+					// The offset of a row is that of its suffix. The format of the
+					// numbers in the row line is:
+					//
+					//   (<prefix>, <suffix>, <value>)
+					//
+					// <prefix> is the index of the row's prefix in the prefix column.
+					// <suffix> is the number of suffix bytes.
+					// <value>  is the number of value bytes.
+					fmt.Fprintf(w, "%10d    row %d (%d, %d, %d)\n",
+						b.Offset+uint64(readUint32(iter.col.suffixOffsets, iter.offset)), iter.offset,
+						iter.col.prefixIdx, len(iter.col.suffix(iter.offset)), len(iter.val))
+				} else {
+					ptr := unsafe.Pointer(uintptr(iter.ptr) + uintptr(iter.offset))
+					shared, ptr := decodeVarint(ptr)
+					unshared, ptr := decodeVarint(ptr)
+					value2, _ := decodeVarint(ptr)
 
-				total := iter.nextOffset - iter.offset
-				// The format of the numbers in the record line is:
-				//
-				//   (<total> = <length> [<shared>] + <unshared> + <value>)
-				//
-				// <total>    is the total number of bytes for the record.
-				// <length>   is the size of the 3 varint encoded integers for <shared>,
-				//            <unshared>, and <value>.
-				// <shared>   is the number of key bytes shared with the previous key.
-				// <unshared> is the number of unshared key bytes.
-				// <value>    is the number of value bytes.
-				fmt.Fprintf(w, "%10d    record (%d = %d [%d] + %d + %d)",
-					b.Offset+uint64(iter.offset), total,
-					total-int32(unshared+value2), shared, unshared, value2)
-				formatIsRestart(iter.data, iter.restarts, iter.numRestarts, iter.offset)
+					total := iter.nextOffset - iter.offset
+					// The format of the numbers in the record line is:
+					//
+					//   (<total> = <length> [<shared>] + <unshared> + <value>)
+					//
+					// <total>    is the total number of bytes for the record.
+					// <length>   is the size of the 3 varint encoded integers for <shared>,
+					//            <unshared>, and <value>.
+					// <shared>   is the number of key bytes shared with the previous key.
+					// <unshared> is the number of unshared key bytes.
+					// <value>    is the number of value bytes.
+					fmt.Fprintf(w, "%10d    record (%d = %d [%d] + %d + %d)",
+						b.Offset+uint64(iter.offset), total,
+						total-int32(unshared+value2), shared, unshared, value2)
+					formatIsRestart(iter.data, iter.restarts, iter.numRestarts, iter.offset)
+				}
 				if fmtRecord != nil {
 					fmt.Fprintf(w, "              ")
 					if l.Format < TableFormatPebblev3 {
@@ -235,7 +249,15 @@ func (l *Layout) Describe(
 				lastKey.Trailer = key.Trailer
 				lastKey.UserKey = append(lastKey.UserKey[:0], key.UserKey...)
 			}
-			formatRestarts(iter.data, iter.restarts, iter.numRestarts)
+			if iter.columnar {
+				c := &iter.col
+				fmt.Fprintf(w, "%10d    [columns: %d rows, %d prefixes (%d bytes), suffixes (%d bytes), values (%d bytes)]\n",
+					b.Offset+uint64(readUint32(c.valueOffsets, c.numRows)), c.numRows, c.numPrefixes,
+					readUint32(c.prefixOffsets, c.numPrefixes), readUint32(c.suffixOffsets, c.numRows)-readUint32(c.suffixOffsets, 0),
+					readUint32(c.valueOffsets, c.numRows)-readUint32(c.valueOffsets, 0))
+			} else {
+				formatRestarts(iter.data, iter.restarts, iter.numRestarts)
+			}
 			formatTrailer()
 		case "index", "top-index":
 			iter, _ := newBlockIter(r.Compare, h.Get())
@@ -5,6 +5,8 @@
 package sstable
 
 import (
+	"fmt"
+
 	"github.com/cockroachdb/pebble/internal/base"
 	"github.com/cockroachdb/pebble/internal/cache"
 )
@@ -47,6 +49,53 @@ func (c Compression) String() string {
 	}
 }
 
+// AdaptiveCompressionOptions configures adaptive selection of the compression
+// algorithm used for data blocks. When enabled, the Writer samples the
+// compression ratio achieved by the configured algorithm and, when the savings
+// fall below MinSavingsPercent, falls back to a cheaper algorithm for
+// subsequent blocks until a later sample indicates that compression is
+// worthwhile again. This avoids spending CPU compressing incompressible data.
+type AdaptiveCompressionOptions struct {
+	// Enabled enables adaptive compression.
+	Enabled bool
+
+	// MinSavingsPercent is the minimum percentage by which a sampled block must
+	// shrink for the configured compression algorithm to be considered
+	// profitable.
+	//
+	// The default value is 12.
+	MinSavingsPercent int
+
+	// SampleInterval is the number of data blocks written with the fallback
+	// algorithm between two samples of the configured algorithm.
+	//
+	// The default value is 16.
+	SampleInterval int
+
+	// Fallback is the compression algorithm used while the configured algorithm
+	// is deemed unprofitable. It should be cheaper than the configured
+	// algorithm.
+	//
+	// The default value (DefaultCompression) uses no compression.
+	Fallback Compression
+}
+
+// EnsureDefaults ensures that the default values for all of the options have
+// been initialized. It is valid to call EnsureDefaults on a zero value, in
+// which case adaptive compression remains disabled.
+func (o AdaptiveCompressionOptions) EnsureDefaults() AdaptiveCompressionOptions {
+	if o.MinSavingsPercent <= 0 {
+		o.MinSavingsPercent = 12
+	}
+	if o.SampleInterval <= 0 {
+		o.SampleInterval = 16
+	}
+	if o.Fallback <= DefaultCompression || o.Fallback >= NCompression {
+		o.Fallback = NoCompression
+	}
+	return o
+}
+
 // FilterType exports the base.FilterType type.
 type FilterType = base.FilterType
 
@@ -61,6 +110,51 @@ type FilterWriter = base.FilterWriter
 // FilterPolicy exports the base.FilterPolicy type.
 type FilterPolicy = base.FilterPolicy
 
+// BlockCacheMode controls how the data blocks read by an iterator are added
+// to the block cache. Index and filter blocks are unaffected, and are always
+// added to the block cache at high priority.
+type BlockCacheMode int8
+
+const (
+	// BlockCacheDefault adds data blocks to the block cache at normal
+	// priority.
+	BlockCacheDefault BlockCacheMode = iota
+	// BlockCacheLowPriority adds data blocks to the block cache at low
+	// priority, making them the first candidates for eviction. It is intended
+	// for large scans that are not expected to revisit the blocks they read,
+	// and would otherwise evict blocks needed by other reads.
+	BlockCacheLowPriority
+	// BlockCacheBypass does not add data blocks to the block cache. Data blocks
+	// that are already in the block cache are still used.
+	BlockCacheBypass
+)
+
+// String implements fmt.Stringer.
+func (m BlockCacheMode) String() string {
+	switch m {
+	case BlockCacheDefault:
+		return "default"
+	case BlockCacheLowPriority:
+		return "low-priority"
+	case BlockCacheBypass:
+		return "bypass"
+	default:
+		return fmt.Sprintf("BlockCacheMode(%d)", m)
+	}
+}
+
+// policy returns the cachePolicy used for data blocks.
+func (m BlockCacheMode) policy() cachePolicy {
+	switch m {
+	case BlockCacheLowPriority:
+		return cacheLowPriority
+	case BlockCacheBypass:
+		return cacheBypass
+	default:
+		return cacheNormalPriority
+	}
+}
+
 // ReaderOptions holds the parameters needed for reading an sstable.
 type ReaderOptions struct {
 	// Cache is used to cache uncompressed blocks from sstables.
@@ -152,6 +246,11 @@ type WriterOptions struct {
 	// The default value (DefaultCompression) uses snappy compression.
 	Compression Compression
 
+	// AdaptiveCompression configures adaptive selection of the compression
+	// algorithm for data blocks. When enabled, the bytes compressed and skipped
+	// per algorithm are recorded in the sstable properties.
+	AdaptiveCompression AdaptiveCompressionOptions
+
 	// FilterPolicy defines a filter algorithm (such as a Bloom filter) that can
 	// reduce disk reads for Get calls.
 	//
@@ -199,6 +298,22 @@ type WriterOptions struct {
 	// youngest for a userkey.
 	WritingToLowestLevel bool
 
+	// DataBlockHashIndex, if true, appends a hash index to each data block that
+	// maps the prefixes (as defined by Comparer.Split) of the block's keys to
+	// the block's restart intervals, speeding up point lookups within the block
+	// at the cost of a few bytes per distinct prefix. It is only relevant for
+	// >= TableFormatPebblev5, and is ignored for older formats.
+	DataBlockHashIndex bool
+
+	// ColumnarDataBlocks, if true, writes columnar data blocks that store the
+	// prefixes (as defined by Comparer.Split) and suffixes of the keys, the key
+	// trailers and the values in separate columns. Columnar blocks are more
+	// compact for keys with many versions per prefix and speed up NextPrefix.
+	// It is only relevant for >= TableFormatPebblev6 and a Comparer with a
+	// Split function, and is ignored otherwise. Columnar data blocks have no
+	// hash index, so DataBlockHashIndex is ignored if ColumnarDataBlocks is set.
+	ColumnarDataBlocks bool
+
 	// BlockPropertyCollectors is a list of BlockPropertyCollector creation
 	// functions. A new BlockPropertyCollector is created for each sstable
 	// built and lives for the lifetime of writing that table.
@@ -237,6 +352,9 @@ func (o WriterOptions) ensureDefaults() WriterOptions {
 	if o.Compression <= DefaultCompression || o.Compression >= NCompression {
 		o.Compression = SnappyCompression
 	}
+	if o.AdaptiveCompression.Enabled {
+		o.AdaptiveCompression = o.AdaptiveCompression.EnsureDefaults()
+	}
 	if o.IndexBlockSize <= 0 {
 		o.IndexBlockSize = o.BlockSize
 	}
@@ -179,6 +179,15 @@ type Properties struct {
 	RawRangeKeyKeySize uint64 `prop:"pebble.raw.range-key.key.size"`
 	// Total raw rangekey value size.
 	RawRangeKeyValueSize uint64 `prop:"pebble.raw.range-key.value.size"`
+	// The uncompressed size of the data blocks in this table that were
+	// compressed with Snappy. Only populated if adaptive compression is
+	// enabled, and only serialized if > 0.
+	SnappyCompressedBytes uint64 `prop:"pebble.compression.snappy.compressed-bytes"`
+	// The uncompressed size of the data blocks in this table for which Snappy
+	// compression was skipped, either because it did not yield sufficient
+	// savings or because adaptive compression fell back to a cheaper
+	// algorithm. Only serialized if > 0.
+	SnappySkippedBytes uint64 `prop:"pebble.compression.snappy.skipped-bytes"`
 	// The total number of keys in this table that were pinned by open snapshots.
 	SnapshotPinnedKeys uint64 `prop:"pebble.num.snapshot-pinned-keys"`
 	// The cumulative bytes of keys in this table that were pinned by
@@ -193,6 +202,13 @@ type Properties struct {
 	UserProperties map[string]string
 	// If filtering is enabled, was the filter created on the whole key.
 	WholeKeyFiltering bool `prop:"rocksdb.block.based.table.whole.key.filtering"`
+	// The uncompressed size of the data blocks in this table that were
+	// compressed with Zstd. Only populated if adaptive compression is enabled,
+	// and only serialized if > 0.
+	ZstdCompressedBytes uint64 `prop:"pebble.compression.zstd.compressed-bytes"`
+	// The uncompressed size of the data blocks in this table for which Zstd
+	// compression was skipped. Only serialized if > 0.
+	ZstdSkippedBytes uint64 `prop:"pebble.compression.zstd.skipped-bytes"`
 
 	// Loaded set indicating which fields have been loaded from disk. Indexed by
 	// the field's byte offset within the struct
@@ -417,6 +433,12 @@ func (p *Properties) save(tblFormat TableFormat, w *rawBlockWriter) {
 	if p.PropertyCollectorNames != "" {
 		p.saveString(m, unsafe.Offsetof(p.PropertyCollectorNames), p.PropertyCollectorNames)
 	}
+	if p.SnappyCompressedBytes > 0 {
+		p.saveUvarint(m, unsafe.Offsetof(p.SnappyCompressedBytes), p.SnappyCompressedBytes)
+	}
+	if p.SnappySkippedBytes > 0 {
+		p.saveUvarint(m, unsafe.Offsetof(p.SnappySkippedBytes), p.SnappySkippedBytes)
+	}
 	if p.SnapshotPinnedKeys > 0 {
 		p.saveUvarint(m, unsafe.Offsetof(p.SnapshotPinnedKeys), p.SnapshotPinnedKeys)
 		p.saveUvarint(m, unsafe.Offsetof(p.SnapshotPinnedKeySize), p.SnapshotPinnedKeySize)
@@ -428,6 +450,12 @@ func (p *Properties) save(tblFormat TableFormat, w *rawBlockWriter) {
 		p.saveUvarint(m, unsafe.Offsetof(p.ValueBlocksSize), p.ValueBlocksSize)
 	}
 	p.saveBool(m, unsafe.Offsetof(p.WholeKeyFiltering), p.WholeKeyFiltering)
+	if p.ZstdCompressedBytes > 0 {
+		p.saveUvarint(m, unsafe.Offsetof(p.ZstdCompressedBytes), p.ZstdCompressedBytes)
+	}
+	if p.ZstdSkippedBytes > 0 {
+		p.saveUvarint(m, unsafe.Offsetof(p.ZstdSkippedBytes), p.ZstdSkippedBytes)
+	}
 
 	if tblFormat < TableFormatPebblev1 {
 		m["rocksdb.column.family.id"] = binary.AppendUvarint([]byte(nil), math.MaxInt32)
@@ -56,12 +56,12 @@ func newRawBlockIter(cmp Compare, block block) (*rawBlockIter, error) {
 }
 
 func (i *rawBlockIter) init(cmp Compare, block block) error {
-	numRestarts := int32(binary.LittleEndian.Uint32(block[len(block)-4:]))
-	if numRestarts == 0 {
+	restarts, numRestarts, _, ok := decodeBlockTrailer(block)
+	if !ok {
 		return base.CorruptionErrorf("pebble/table: invalid table (block has no restart points)")
 	}
 	i.cmp = cmp
-	i.restarts = int32(len(block)) - 4*(1+numRestarts)
+	i.restarts = restarts
 	i.numRestarts = numRestarts
 	i.ptr = unsafe.Pointer(&block[0])
 	i.data = block
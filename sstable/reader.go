@@ -190,6 +190,8 @@ type CommonReader interface {
 		categoryAndQoS CategoryAndQoS,
 		statsCollector *CategoryStatsCollector,
 		rp ReaderProvider,
+		cacheMode BlockCacheMode,
+		prefetchBlocks int,
 	) (Iterator, error)
 	NewCompactionIter(
 		bytesIterated *uint64,
@@ -271,13 +273,16 @@ func (r *Reader) NewIterWithBlockPropertyFilters(
 ) (Iterator, error) {
 	return r.newIterWithBlockPropertyFiltersAndContext(
 		context.Background(), lower, upper, filterer, false, useFilterBlock, stats,
-		categoryAndQoS, statsCollector, rp, nil)
+		categoryAndQoS, statsCollector, rp, BlockCacheDefault, 0 /* prefetchBlocks */, nil)
 }
 
 // NewIterWithBlockPropertyFiltersAndContextEtc is similar to
 // NewIterWithBlockPropertyFilters and additionally accepts a context for
 // tracing.
 //
+// If prefetchBlocks is positive, the iterator asynchronously reads up to
+// prefetchBlocks data blocks ahead of its position when iterating forward.
+//
 // If hideObsoletePoints, the callee assumes that filterer already includes
 // obsoleteKeyBlockPropertyFilter. The caller can satisfy this contract by
 // first calling TryAddBlockPropertyFilterForHideObsoletePoints.
@@ -290,10 +295,12 @@ func (r *Reader) NewIterWithBlockPropertyFiltersAndContextEtc(
 	categoryAndQoS CategoryAndQoS,
 	statsCollector *CategoryStatsCollector,
 	rp ReaderProvider,
+	cacheMode BlockCacheMode,
+	prefetchBlocks int,
 ) (Iterator, error) {
 	return r.newIterWithBlockPropertyFiltersAndContext(
 		ctx, lower, upper, filterer, hideObsoletePoints, useFilterBlock, stats, categoryAndQoS,
-		statsCollector, rp, nil)
+		statsCollector, rp, cacheMode, prefetchBlocks, nil)
 }
 
 // TryAddBlockPropertyFilterForHideObsoletePoints is expected to be called
@@ -322,6 +329,8 @@ func (r *Reader) newIterWithBlockPropertyFiltersAndContext(
 	categoryAndQoS CategoryAndQoS,
 	statsCollector *CategoryStatsCollector,
 	rp ReaderProvider,
+	cacheMode BlockCacheMode,
+	prefetchBlocks int,
 	v *virtualState,
 ) (Iterator, error) {
 	// NB: pebble.tableCache wraps the returned iterator with one which performs
@@ -330,7 +339,7 @@ func (r *Reader) newIterWithBlockPropertyFiltersAndContext(
 	if r.Properties.IndexType == twoLevelIndex {
 		i := twoLevelIterPool.Get().(*twoLevelIterator)
 		err := i.init(ctx, r, v, lower, upper, filterer, useFilterBlock, hideObsoletePoints, stats,
-			categoryAndQoS, statsCollector, rp, nil /* bufferPool */)
+			categoryAndQoS, statsCollector, rp, nil /* bufferPool */, cacheMode, prefetchBlocks)
 		if err != nil {
 			return nil, err
 		}
@@ -339,7 +348,7 @@ func (r *Reader) newIterWithBlockPropertyFiltersAndContext(
 
 	i := singleLevelIterPool.Get().(*singleLevelIterator)
 	err := i.init(ctx, r, v, lower, upper, filterer, useFilterBlock, hideObsoletePoints, stats,
-		categoryAndQoS, statsCollector, rp, nil /* bufferPool */)
+		categoryAndQoS, statsCollector, rp, nil /* bufferPool */, cacheMode, prefetchBlocks)
 	if err != nil {
 		return nil, err
 	}
@@ -383,7 +392,8 @@ func (r *Reader) newCompactionIter(
 			context.Background(),
 			r, v, nil /* lower */, nil /* upper */, nil,
 			false /* useFilter */, v != nil && v.isSharedIngested, /* hideObsoletePoints */
-			nil /* stats */, categoryAndQoS, statsCollector, rp, bufferPool,
+			nil /* stats */, categoryAndQoS, statsCollector, rp, bufferPool, BlockCacheDefault,
+			0, /* prefetchBlocks */
 		)
 		if err != nil {
 			return nil, err
@@ -398,7 +408,8 @@ func (r *Reader) newCompactionIter(
 	err := i.init(
 		context.Background(), r, v, nil /* lower */, nil, /* upper */
 		nil, false /* useFilter */, v != nil && v.isSharedIngested, /* hideObsoletePoints */
-		nil /* stats */, categoryAndQoS, statsCollector, rp, bufferPool,
+		nil /* stats */, categoryAndQoS, statsCollector, rp, bufferPool, BlockCacheDefault,
+		0, /* prefetchBlocks */
 	)
 	if err != nil {
 		return nil, err
@@ -482,28 +493,28 @@ func (r *Reader) readIndex(
 	ctx context.Context, stats *base.InternalIteratorStats, iterStats *iterStatsAccumulator,
 ) (bufferHandle, error) {
 	ctx = objiotracing.WithBlockType(ctx, objiotracing.MetadataBlock)
-	return r.readBlock(ctx, r.indexBH, nil, nil, stats, iterStats, nil /* buffer pool */)
+	return r.readBlock(ctx, r.indexBH, nil, nil, stats, iterStats, nil /* buffer pool */, cacheHighPriority)
 }
 
 func (r *Reader) readFilter(
 	ctx context.Context, stats *base.InternalIteratorStats, iterStats *iterStatsAccumulator,
 ) (bufferHandle, error) {
 	ctx = objiotracing.WithBlockType(ctx, objiotracing.FilterBlock)
-	return r.readBlock(ctx, r.filterBH, nil /* transform */, nil /* readHandle */, stats, iterStats, nil /* buffer pool */)
+	return r.readBlock(ctx, r.filterBH, nil /* transform */, nil /* readHandle */, stats, iterStats, nil /* buffer pool */, cacheHighPriority)
 }
 
 func (r *Reader) readRangeDel(
 	stats *base.InternalIteratorStats, iterStats *iterStatsAccumulator,
 ) (bufferHandle, error) {
 	ctx := objiotracing.WithBlockType(context.Background(), objiotracing.MetadataBlock)
-	return r.readBlock(ctx, r.rangeDelBH, r.rangeDelTransform, nil /* readHandle */, stats, iterStats, nil /* buffer pool */)
+	return r.readBlock(ctx, r.rangeDelBH, r.rangeDelTransform, nil /* readHandle */, stats, iterStats, nil /* buffer pool */, cacheNormalPriority)
 }
 
 func (r *Reader) readRangeKey(
 	stats *base.InternalIteratorStats, iterStats *iterStatsAccumulator,
 ) (bufferHandle, error) {
 	ctx := objiotracing.WithBlockType(context.Background(), objiotracing.MetadataBlock)
-	return r.readBlock(ctx, r.rangeKeyBH, nil /* transform */, nil /* readHandle */, stats, iterStats, nil /* buffer pool */)
+	return r.readBlock(ctx, r.rangeKeyBH, nil /* transform */, nil /* readHandle */, stats, iterStats, nil /* buffer pool */, cacheNormalPriority)
 }
 
 func checkChecksum(
@@ -571,6 +582,30 @@ func DeterministicReadBlockDurationForTesting() func() {
 
 var deterministicReadBlockDurationForTesting = false
 
+// cachePolicy determines how a block read by readBlock is added to the block
+// cache.
+type cachePolicy int8
+
+const (
+	cacheNormalPriority cachePolicy = iota
+	cacheHighPriority
+	cacheLowPriority
+	// cacheBypass reads the block into memory that is released along with the
+	// returned handle, without adding it to the block cache.
+	cacheBypass
+)
+
+func (p cachePolicy) priority() cache.Priority {
+	switch p {
+	case cacheHighPriority:
+		return cache.HighPriority
+	case cacheLowPriority:
+		return cache.LowPriority
+	default:
+		return cache.NormalPriority
+	}
+}
+
 func (r *Reader) readBlock(
 	ctx context.Context,
 	bh BlockHandle,
@@ -579,6 +614,7 @@ func (r *Reader) readBlock(
 	stats *base.InternalIteratorStats,
 	iterStats *iterStatsAccumulator,
 	bufferPool *BufferPool,
+	policy cachePolicy,
 ) (handle bufferHandle, _ error) {
 	if h := r.opts.Cache.Get(r.cacheID, r.fileNum, bh.Offset); h.Get() != nil {
 		// Cache hit.
@@ -696,7 +732,10 @@ func (r *Reader) readBlock(
 	if decompressed.buf.Valid() {
 		return bufferHandle{b: decompressed.buf}, nil
 	}
-	h := r.opts.Cache.Set(r.cacheID, r.fileNum, bh.Offset, decompressed.v)
+	if policy == cacheBypass {
+		return bufferHandle{h: cache.NewUncachedHandle(decompressed.v)}, nil
+	}
+	h := r.opts.Cache.SetWithPriority(r.cacheID, r.fileNum, bh.Offset, decompressed.v, policy.priority())
 	return bufferHandle{h: h}, nil
 }
 
@@ -759,7 +798,7 @@ func (r *Reader) readMetaindex(metaindexBH BlockHandle) error {
 
 	b, err := r.readBlock(
 		context.Background(), metaindexBH, nil /* transform */, nil /* readHandle */, nil, /* stats */
-		nil /* iterStats */, &r.metaBufferPool)
+		nil /* iterStats */, &r.metaBufferPool, cacheNormalPriority)
 	if err != nil {
 		return err
 	}
@@ -803,7 +842,7 @@ func (r *Reader) readMetaindex(metaindexBH BlockHandle) error {
 	if bh, ok := meta[metaPropertiesName]; ok {
 		b, err = r.readBlock(
 			context.Background(), bh, nil /* transform */, nil /* readHandle */, nil, /* stats */
-			nil /* iterStats */, nil /* buffer pool */)
+			nil /* iterStats */, nil /* buffer pool */, cacheNormalPriority)
 		if err != nil {
 			return err
 		}
@@ -909,7 +948,8 @@ func (r *Reader) Layout() (*Layout, error) {
 			l.Index = append(l.Index, indexBH.BlockHandle)
 
 			subIndex, err := r.readBlock(context.Background(), indexBH.BlockHandle,
-				nil /* transform */, nil /* readHandle */, nil /* stats */, nil /* iterStats */, nil /* buffer pool */)
+				nil /* transform */, nil /* readHandle */, nil /* stats */, nil, /* iterStats */
+				nil /* buffer pool */, cacheNormalPriority)
 			if err != nil {
 				return nil, err
 			}
@@ -932,7 +972,7 @@ func (r *Reader) Layout() (*Layout, error) {
 		}
 	}
 	if r.valueBIH.h.Length != 0 {
-		vbiH, err := r.readBlock(context.Background(), r.valueBIH.h, nil, nil, nil, nil, nil /* buffer pool */)
+		vbiH, err := r.readBlock(context.Background(), r.valueBIH.h, nil, nil, nil, nil, nil /* buffer pool */, cacheNormalPriority)
 		if err != nil {
 			return nil, err
 		}
@@ -968,6 +1008,78 @@ func (r *Reader) Layout() (*Layout, error) {
 	return l, nil
 }
 
+// WarmBlocks reads the blocks that begin at the specified offsets into the
+// block cache, unless they are already present. Offsets at which no block of
+// the table begins are ignored, which allows the offsets to have been recorded
+// by a cache whose contents may no longer correspond to the table. If wait is
+// non-nil, it is called with the length of each block before the block is
+// read, which allows the caller to limit the rate of reads; an error returned
+// by wait stops the warm-up and is returned. WarmBlocks returns the number of
+// blocks read and their total length.
+func (r *Reader) WarmBlocks(
+	ctx context.Context, offsets []uint64, wait func(length uint64) error,
+) (blocks int, bytes uint64, _ error) {
+	l, err := r.Layout()
+	if err != nil {
+		return 0, 0, err
+	}
+	handles := make(map[uint64]BlockHandle, len(l.Data)+len(l.Index)+len(l.ValueBlock))
+	for i := range l.Data {
+		handles[l.Data[i].Offset] = l.Data[i].BlockHandle
+	}
+	for _, bh := range l.Index {
+		handles[bh.Offset] = bh
+	}
+	for _, bh := range l.ValueBlock {
+		handles[bh.Offset] = bh
+	}
+
+	for _, offset := range offsets {
+		var bh BlockHandle
+		var read func() (bufferHandle, error)
+		switch {
+		case offset == r.indexBH.Offset:
+			bh = r.indexBH
+			read = func() (bufferHandle, error) { return r.readIndex(ctx, nil, nil) }
+		case r.filterBH.Length > 0 && offset == r.filterBH.Offset:
+			bh = r.filterBH
+			read = func() (bufferHandle, error) { return r.readFilter(ctx, nil, nil) }
+		case r.rangeDelBH.Length > 0 && offset == r.rangeDelBH.Offset:
+			bh = r.rangeDelBH
+			read = func() (bufferHandle, error) { return r.readRangeDel(nil, nil) }
+		case r.rangeKeyBH.Length > 0 && offset == r.rangeKeyBH.Offset:
+			bh = r.rangeKeyBH
+			read = func() (bufferHandle, error) { return r.readRangeKey(nil, nil) }
+		default:
+			var ok bool
+			if bh, ok = handles[offset]; !ok {
+				continue
+			}
+			read = func() (bufferHandle, error) {
+				return r.readBlock(ctx, bh, nil /* transform */, nil /* readHandle */, nil, /* stats */
+					nil /* iterStats */, nil /* buffer pool */, cacheNormalPriority)
+			}
+		}
+		if h := r.opts.Cache.Get(r.cacheID, r.fileNum, offset); h.Get() != nil {
+			h.Release()
+			continue
+		}
+		if wait != nil {
+			if err := wait(bh.Length); err != nil {
+				return blocks, bytes, err
+			}
+		}
+		h, err := read()
+		if err != nil {
+			return blocks, bytes, err
+		}
+		h.Release()
+		blocks++
+		bytes += bh.Length
+	}
+	return blocks, bytes, nil
+}
+
 // ValidateBlockChecksums validates the checksums for each block in the SSTable.
 func (r *Reader) ValidateBlockChecksums() error {
 	// Pre-compute the BlockHandles for the underlying file.
@@ -1003,7 +1115,7 @@ func (r *Reader) ValidateBlockChecksums() error {
 		}
 
 		// Read the block, which validates the checksum.
-		h, err := r.readBlock(context.Background(), bh, nil, rh, nil, nil /* iterStats */, nil /* buffer pool */)
+		h, err := r.readBlock(context.Background(), bh, nil, rh, nil, nil /* iterStats */, nil /* buffer pool */, cacheNormalPriority)
 		if err != nil {
 			return err
 		}
@@ -1071,7 +1183,8 @@ func (r *Reader) EstimateDiskUsage(start, end []byte) (uint64, error) {
 			return 0, errCorruptIndexEntry
 		}
 		startIdxBlock, err := r.readBlock(context.Background(), startIdxBH.BlockHandle,
-			nil /* transform */, nil /* readHandle */, nil /* stats */, nil /* iterStats */, nil /* buffer pool */)
+			nil /* transform */, nil /* readHandle */, nil /* stats */, nil, /* iterStats */
+			nil /* buffer pool */, cacheHighPriority)
 		if err != nil {
 			return 0, err
 		}
@@ -1092,7 +1205,8 @@ func (r *Reader) EstimateDiskUsage(start, end []byte) (uint64, error) {
 				return 0, errCorruptIndexEntry
 			}
 			endIdxBlock, err := r.readBlock(context.Background(),
-				endIdxBH.BlockHandle, nil /* transform */, nil /* readHandle */, nil /* stats */, nil /* iterStats */, nil /* buffer pool */)
+				endIdxBH.BlockHandle, nil /* transform */, nil /* readHandle */, nil /* stats */, nil, /* iterStats */
+				nil /* buffer pool */, cacheHighPriority)
 			if err != nil {
 				return 0, err
 			}
@@ -1150,6 +1264,96 @@ func (r *Reader) EstimateDiskUsage(start, end []byte) (uint64, error) {
 		endBH.Offset + endBH.Length + blockTrailerLen - startBH.Offset), nil
 }
 
+// DataBlockSeparator describes a data block using only its index block entry.
+type DataBlockSeparator struct {
+	// Key is the block's index separator: a user key greater than or equal to
+	// every key in the block and less than every key in the following block.
+	Key []byte
+	// Size is the on-disk size of the block, plus the share of the table's
+	// value blocks linearly interpolated for it.
+	Size uint64
+}
+
+// DataBlockSeparators returns the separators and sizes of the data blocks that
+// may contain keys in [start, end), in key order. A nil start or end leaves the
+// corresponding side of the range unbounded. Only index blocks are read, so the
+// result is suitable for cheaply estimating how data is distributed within the
+// table.
+func (r *Reader) DataBlockSeparators(start, end []byte) ([]DataBlockSeparator, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	indexH, err := r.readIndex(context.Background(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer indexH.Release()
+
+	seek := func(iter *blockIter) (*InternalKey, base.LazyValue) {
+		if start == nil {
+			return iter.First()
+		}
+		return iter.SeekGE(start, base.SeekGEFlagsNone)
+	}
+	var seps []DataBlockSeparator
+	// appendBlocks appends the data blocks referenced by the index block
+	// iterated by iter, returning false once a separator at or past end is
+	// reached.
+	appendBlocks := func(iter *blockIter) (bool, error) {
+		for key, val := seek(iter); key != nil; key, val = iter.Next() {
+			bh, err := decodeBlockHandleWithProperties(val.InPlaceValue())
+			if err != nil {
+				return false, errCorruptIndexEntry
+			}
+			size := bh.Length + blockTrailerLen
+			if r.Properties.DataSize > 0 {
+				size += uint64((float64(size) / float64(r.Properties.DataSize)) *
+					float64(r.Properties.ValueBlocksSize))
+			}
+			seps = append(seps, DataBlockSeparator{
+				Key:  append([]byte(nil), key.UserKey...),
+				Size: size,
+			})
+			if end != nil && r.Compare(key.UserKey, end) >= 0 {
+				return false, nil
+			}
+		}
+		return true, iter.Error()
+	}
+
+	iter, err := newBlockIter(r.Compare, indexH.Get())
+	if err != nil {
+		return nil, err
+	}
+	if r.Properties.IndexPartitions == 0 {
+		_, err := appendBlocks(iter)
+		return seps, err
+	}
+	for key, val := seek(iter); key != nil; key, val = iter.Next() {
+		bh, err := decodeBlockHandleWithProperties(val.InPlaceValue())
+		if err != nil {
+			return nil, errCorruptIndexEntry
+		}
+		partitionH, err := r.readBlock(context.Background(), bh.BlockHandle,
+			nil /* transform */, nil /* readHandle */, nil /* stats */, nil, /* iterStats */
+			nil /* buffer pool */, cacheHighPriority)
+		if err != nil {
+			return nil, err
+		}
+		partitionIter, err := newBlockIter(r.Compare, partitionH.Get())
+		if err != nil {
+			partitionH.Release()
+			return nil, err
+		}
+		more, err := appendBlocks(partitionIter)
+		partitionH.Release()
+		if err != nil || !more {
+			return seps, err
+		}
+	}
+	return seps, iter.Error()
+}
+
 // TableFormat returns the format version for the table.
 func (r *Reader) TableFormat() (TableFormat, error) {
 	if r.err != nil {
@@ -200,10 +200,17 @@ func (i *compactionIterator) String() string {
 	return i.reader.fileNum.String()
 }
 
+// SeekGE implements internalIterator.SeekGE, as documented in the pebble
+// package. It's used to start compacting a table from a key other than its
+// first, which is then only iterated forward. The bytes before the key aren't
+// counted as iterated.
 func (i *compactionIterator) SeekGE(
 	key []byte, flags base.SeekGEFlags,
 ) (*InternalKey, base.LazyValue) {
-	panic("pebble: SeekGE unimplemented")
+	i.err = nil // clear cached iteration error
+	k, v := i.singleLevelIterator.SeekGE(key, flags)
+	i.prevOffset = i.recordOffset()
+	return i.skipForward(k, v)
 }
 
 func (i *compactionIterator) SeekPrefixGE(
@@ -65,6 +65,12 @@ type singleLevelIterator struct {
 	stats      *base.InternalIteratorStats
 	iterStats  iterStatsAccumulator
 	bufferPool *BufferPool
+	// dataCachePolicy determines how data blocks read by the iterator are
+	// added to the block cache.
+	dataCachePolicy cachePolicy
+	// prefetcher is set if the iterator prefetches the data blocks that lie
+	// ahead of forward iteration.
+	prefetcher *dataBlockPrefetcher
 
 	// boundsCmp and positionedUsingLatestBounds are for optimizing iteration
 	// that uses multiple adjacent bounds. The seek after setting a new bound
@@ -189,6 +195,8 @@ func (i *singleLevelIterator) init(
 	statsCollector *CategoryStatsCollector,
 	rp ReaderProvider,
 	bufferPool *BufferPool,
+	cacheMode BlockCacheMode,
+	prefetchBlocks int,
 ) error {
 	if r.err != nil {
 		return r.err
@@ -210,15 +218,25 @@ func (i *singleLevelIterator) init(
 	i.useFilter = useFilter
 	i.reader = r
 	i.cmp = r.Compare
+	i.data.split = r.Split
 	i.stats = stats
 	i.hideObsoletePoints = hideObsoletePoints
 	i.bufferPool = bufferPool
+	i.dataCachePolicy = cacheMode.policy()
 	err = i.index.initHandle(i.cmp, indexH, r.Properties.GlobalSeqNum, false)
 	if err != nil {
 		// blockIter.Close releases indexH and always returns a nil error
 		_ = i.index.Close()
 		return err
 	}
+	// Prefetching is not supported with a BufferPool, which is not
+	// thread-safe.
+	if prefetchBlocks > 0 && bufferPool == nil {
+		if err := i.initPrefetcher(prefetchBlocks, nil /* topLevelIndex */); err != nil {
+			_ = i.index.Close()
+			return err
+		}
+	}
 	i.dataRH = objstorageprovider.UsePreallocatedReadHandle(ctx, r.readable, &i.dataRHPrealloc)
 	if r.tableFormat >= TableFormatPebblev3 {
 		if r.Properties.NumValueBlocks > 0 {
@@ -428,9 +446,16 @@ func (i *singleLevelIterator) loadBlock(dir int8) loadBlockResult {
 		}
 		// blockIntersects
 	}
-	ctx := objiotracing.WithBlockType(i.ctx, objiotracing.DataBlock)
-	block, err := i.reader.readBlock(
-		ctx, i.dataBH, nil /* transform */, i.dataRH, i.stats, &i.iterStats, i.bufferPool)
+	var block bufferHandle
+	prefetched := false
+	if i.prefetcher != nil {
+		block, prefetched, err = i.takePrefetchedBlock(dir)
+	}
+	if !prefetched && err == nil {
+		ctx := objiotracing.WithBlockType(i.ctx, objiotracing.DataBlock)
+		block, err = i.reader.readBlock(
+			ctx, i.dataBH, nil /* transform */, i.dataRH, i.stats, &i.iterStats, i.bufferPool, i.dataCachePolicy)
+	}
 	if err != nil {
 		i.err = err
 		return loadBlockFailed
@@ -442,6 +467,9 @@ func (i *singleLevelIterator) loadBlock(dir int8) loadBlockResult {
 		return loadBlockFailed
 	}
 	i.initBounds()
+	if i.prefetcher != nil && dir > 0 {
+		i.schedulePrefetch()
+	}
 	return loadBlockOK
 }
 
@@ -451,7 +479,7 @@ func (i *singleLevelIterator) readBlockForVBR(
 	h BlockHandle, stats *base.InternalIteratorStats,
 ) (bufferHandle, error) {
 	ctx := objiotracing.WithBlockType(i.ctx, objiotracing.ValueBlock)
-	return i.reader.readBlock(ctx, h, nil, i.vbRH, stats, &i.iterStats, i.bufferPool)
+	return i.reader.readBlock(ctx, h, nil, i.vbRH, stats, &i.iterStats, i.bufferPool, i.dataCachePolicy)
 }
 
 // resolveMaybeExcluded is invoked when the block-property filterer has found
@@ -1440,6 +1468,9 @@ func firstError(err0, err1 error) error {
 // Close implements internalIterator.Close, as documented in the pebble
 // package.
 func (i *singleLevelIterator) Close() error {
+	if i.prefetcher != nil {
+		i.prefetcher.close()
+	}
 	i.iterStats.close()
 	var err error
 	if i.closeHook != nil {
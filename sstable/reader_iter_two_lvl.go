@@ -62,7 +62,8 @@ func (i *twoLevelIterator) loadIndex(dir int8) loadBlockResult {
 	}
 	ctx := objiotracing.WithBlockType(i.ctx, objiotracing.MetadataBlock)
 	indexBlock, err := i.reader.readBlock(
-		ctx, bhp.BlockHandle, nil /* transform */, nil /* readHandle */, i.stats, &i.iterStats, i.bufferPool)
+		ctx, bhp.BlockHandle, nil /* transform */, nil /* readHandle */, i.stats, &i.iterStats, i.bufferPool,
+		cacheHighPriority)
 	if err != nil {
 		i.err = err
 		return loadBlockFailed
@@ -150,6 +151,8 @@ func (i *twoLevelIterator) init(
 	statsCollector *CategoryStatsCollector,
 	rp ReaderProvider,
 	bufferPool *BufferPool,
+	cacheMode BlockCacheMode,
+	prefetchBlocks int,
 ) error {
 	if r.err != nil {
 		return r.err
@@ -172,15 +175,25 @@ func (i *twoLevelIterator) init(
 	i.useFilter = useFilter
 	i.reader = r
 	i.cmp = r.Compare
+	i.data.split = r.Split
 	i.stats = stats
 	i.hideObsoletePoints = hideObsoletePoints
 	i.bufferPool = bufferPool
+	i.dataCachePolicy = cacheMode.policy()
 	err = i.topLevelIndex.initHandle(i.cmp, topLevelIndexH, r.Properties.GlobalSeqNum, false)
 	if err != nil {
 		// blockIter.Close releases topLevelIndexH and always returns a nil error
 		_ = i.topLevelIndex.Close()
 		return err
 	}
+	// Prefetching is not supported with a BufferPool, which is not
+	// thread-safe.
+	if prefetchBlocks > 0 && bufferPool == nil {
+		if err := i.initPrefetcher(prefetchBlocks, &i.topLevelIndex); err != nil {
+			_ = i.topLevelIndex.Close()
+			return err
+		}
+	}
 	i.dataRH = r.readable.NewReadHandle(ctx)
 	if r.tableFormat >= TableFormatPebblev3 {
 		if r.Properties.NumValueBlocks > 0 {
@@ -979,6 +992,9 @@ func (i *twoLevelIterator) skipBackward() (*InternalKey, base.LazyValue) {
 // Close implements internalIterator.Close, as documented in the pebble
 // package.
 func (i *twoLevelIterator) Close() error {
+	if i.prefetcher != nil {
+		i.prefetcher.close()
+	}
 	i.iterStats.close()
 	var err error
 	if i.closeHook != nil {
@@ -1025,10 +1041,17 @@ func (i *twoLevelCompactionIterator) Close() error {
 	return i.twoLevelIterator.Close()
 }
 
+// SeekGE implements internalIterator.SeekGE, as documented in the pebble
+// package. It's used to start compacting a table from a key other than its
+// first, which is then only iterated forward. The bytes before the key aren't
+// counted as iterated.
 func (i *twoLevelCompactionIterator) SeekGE(
 	key []byte, flags base.SeekGEFlags,
 ) (*InternalKey, base.LazyValue) {
-	panic("pebble: SeekGE unimplemented")
+	i.err = nil // clear cached iteration error
+	k, v := i.twoLevelIterator.SeekGE(key, flags)
+	i.prevOffset = i.recordOffset()
+	return i.skipForward(k, v)
 }
 
 func (i *twoLevelCompactionIterator) SeekPrefixGE(
@@ -0,0 +1,362 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/objstorage/objstorageprovider/objiotracing"
+)
+
+// The states of a prefetchedBlock.
+const (
+	prefetchPending int32 = iota
+	prefetchDone
+	prefetchAbandoned
+)
+
+// prefetchedBlock is a data block that is read asynchronously by a
+// dataBlockPrefetcher.
+type prefetchedBlock struct {
+	bh BlockHandle
+	// state transitions from prefetchPending to either prefetchDone, once the
+	// read completes, or prefetchAbandoned, if the iterator no longer needs the
+	// block. Whoever loses the transition releases buf.
+	state atomic.Int32
+	// done is closed once the read completed or was skipped because the block
+	// was abandoned.
+	done chan struct{}
+	// buf, err and stats are set by the reading goroutine before done is
+	// closed.
+	buf   bufferHandle
+	err   error
+	stats base.InternalIteratorStats
+}
+
+// dataBlockPrefetcher asynchronously reads the data blocks that lie ahead of
+// an iterator that iterates forward, so that the iterator doesn't have to wait
+// for each block to be read in turn.
+//
+// The prefetcher maintains a queue of up to depth blocks that follow the
+// iterator's current data block, in the order in which they appear in the
+// table. The blocks are read in batches, each by its own goroutine using its
+// own read handle. When the iterator loads the block at the head of the queue,
+// the block is handed over to the iterator and the queue is replenished once
+// it is half empty. When the iterator loads any other block (eg, after a seek
+// or when iterating backward), the queued blocks are abandoned and the
+// prefetcher restarts at the iterator's new position. The memory held by a
+// prefetcher is therefore bounded by depth blocks.
+//
+// The prefetcher walks the index independently of the iterator, using its own
+// index block iterators, so prefetching proceeds across the index blocks of a
+// two-level index.
+type dataBlockPrefetcher struct {
+	depth int
+	// iterTopLevelIndex is the top-level index of the iterator that owns the
+	// prefetcher, if the table has a two-level index.
+	iterTopLevelIndex *blockIter
+	// topLevelIndex and index are positioned at the index entries of the last
+	// queued block. topLevelIndex is only used for two-level indexes, in which
+	// case index holds a handle to the index block it iterates over.
+	// Otherwise, index iterates over the iterator's index block.
+	topLevelIndex blockIter
+	index         blockIter
+	// positioned is true if index is positioned at the last queued block, or
+	// before the first data block if atStart is also true.
+	positioned bool
+	atStart    bool
+	// exhausted is true if the blocks that follow the last queued block don't
+	// need to be prefetched, because they're past the end of the table or the
+	// iterator's upper bound.
+	exhausted bool
+	queue     []*prefetchedBlock
+	// blockHandles is scratch space for the handles of a batch.
+	blockHandles []BlockHandle
+	// wg tracks the goroutines reading batches of blocks. They must complete
+	// before the iterator closes, since they use its Reader.
+	wg sync.WaitGroup
+}
+
+// initPrefetcher enables prefetching of up to depth data blocks for the
+// iterator. It must be called after the iterator's index (and top-level index
+// for two-level tables) is initialized.
+func (i *singleLevelIterator) initPrefetcher(depth int, topLevelIndex *blockIter) error {
+	p := &dataBlockPrefetcher{depth: depth, iterTopLevelIndex: topLevelIndex}
+	var err error
+	if topLevelIndex != nil {
+		err = p.topLevelIndex.init(i.cmp, topLevelIndex.handle.Get(), i.reader.Properties.GlobalSeqNum, false)
+	} else {
+		err = p.index.init(i.cmp, i.index.handle.Get(), i.reader.Properties.GlobalSeqNum, false)
+	}
+	if err != nil {
+		return err
+	}
+	i.prefetcher = p
+	return nil
+}
+
+// PrefetchFirstBlocks starts prefetching the first data blocks of an iterator
+// that was configured to prefetch data blocks, before it is positioned. It's
+// used to avoid waiting for the first blocks of the next table when a forward
+// scan reaches the end of the current table. It is a no-op if prefetching is
+// not enabled.
+func (i *singleLevelIterator) PrefetchFirstBlocks() {
+	p := i.prefetcher
+	if p == nil {
+		return
+	}
+	p.abandon()
+	p.positioned = true
+	p.atStart = true
+	p.exhausted = false
+	i.schedulePrefetch()
+}
+
+// PrefetchedToEnd returns true if the iterator is configured to prefetch data
+// blocks and has queued the prefetch of the last data block it will need when
+// iterating forward.
+func (i *singleLevelIterator) PrefetchedToEnd() bool {
+	p := i.prefetcher
+	return p != nil && p.positioned && p.exhausted
+}
+
+// takePrefetchedBlock returns the block i.dataBH if it was prefetched. It
+// returns ok=false if the block was not prefetched, after abandoning the
+// queued blocks, which no longer follow the iterator's position.
+func (i *singleLevelIterator) takePrefetchedBlock(dir int8) (_ bufferHandle, ok bool, _ error) {
+	p := i.prefetcher
+	if dir > 0 {
+		// Skip over any blocks that the iterator skipped, eg, because they
+		// were excluded by block-property filters.
+		n := 0
+		for n < len(p.queue) && p.queue[n].bh.Offset < i.dataBH.Offset {
+			p.queue[n].abandon()
+			n++
+		}
+		p.queue = p.queue[:copy(p.queue, p.queue[n:])]
+	}
+	if dir < 0 || len(p.queue) == 0 || p.queue[0].bh != i.dataBH {
+		p.abandon()
+		return bufferHandle{}, false, nil
+	}
+	b := p.queue[0]
+	p.queue = p.queue[:copy(p.queue, p.queue[1:])]
+	waited := false
+	select {
+	case <-b.done:
+	default:
+		waited = true
+		<-b.done
+	}
+	if b.err != nil {
+		return bufferHandle{}, false, b.err
+	}
+	if i.stats != nil {
+		i.stats.Merge(b.stats)
+		if waited {
+			i.stats.PrefetchWaits++
+		} else {
+			i.stats.PrefetchHits++
+		}
+	}
+	i.iterStats.reportStats(b.stats.BlockBytes, b.stats.BlockBytesInCache, b.stats.BlockReadDuration)
+	return b.buf, true, nil
+}
+
+// schedulePrefetch replenishes the queue of prefetched blocks after the
+// iterator loaded the block i.dataBH while iterating forward.
+func (i *singleLevelIterator) schedulePrefetch() {
+	p := i.prefetcher
+	if !p.positioned && !i.positionPrefetcher() {
+		p.positioned = true
+		p.exhausted = true
+		return
+	}
+	if p.exhausted || len(p.queue) > p.depth/2 {
+		return
+	}
+	p.blockHandles = p.blockHandles[:0]
+	for len(p.queue)+len(p.blockHandles) < p.depth {
+		key, bhp, ok := i.nextPrefetchEntry()
+		if !ok {
+			p.exhausted = true
+			break
+		}
+		if i.bpfs != nil {
+			if intersects, err := i.bpfs.intersects(bhp.Props); err != nil || intersects == blockExcluded {
+				continue
+			}
+		}
+		p.blockHandles = append(p.blockHandles, bhp.BlockHandle)
+		// The separator's user key is greater than or equal to the user keys
+		// of the block, and less than or equal to the user keys of the
+		// following blocks.
+		if i.upper != nil {
+			if c := i.cmp(key.UserKey, i.upper); c > 0 || (c == 0 && !i.endKeyInclusive) {
+				p.exhausted = true
+				break
+			}
+		}
+	}
+	if len(p.blockHandles) == 0 {
+		return
+	}
+	blocks := make([]prefetchedBlock, len(p.blockHandles))
+	for j := range blocks {
+		blocks[j].bh = p.blockHandles[j]
+		blocks[j].done = make(chan struct{})
+		p.queue = append(p.queue, &blocks[j])
+	}
+	if i.stats != nil {
+		i.stats.PrefetchedBlocks += uint64(len(blocks))
+	}
+	p.wg.Add(1)
+	go p.read(i.ctx, i.reader, i.dataCachePolicy, blocks)
+}
+
+// positionPrefetcher positions the prefetcher's index iterators at the entry
+// of the iterator's current data block. It returns false if the entry could
+// not be found.
+func (i *singleLevelIterator) positionPrefetcher() bool {
+	p := i.prefetcher
+	p.atStart = false
+	p.exhausted = false
+	if p.iterTopLevelIndex != nil {
+		indexBH, err := decodeBlockHandleWithProperties(p.iterTopLevelIndex.lazyValue.InPlaceValue())
+		if err != nil || !seekIndexEntry(&p.topLevelIndex, p.iterTopLevelIndex.Key(), indexBH.Offset) {
+			return false
+		}
+		if !i.loadPrefetcherIndex() {
+			return false
+		}
+	}
+	if !seekIndexEntry(&p.index, i.index.Key(), i.dataBH.Offset) {
+		return false
+	}
+	p.positioned = true
+	return true
+}
+
+// seekIndexEntry positions the index block iterator at the entry with the
+// given key that points to the block at the given offset.
+func seekIndexEntry(index *blockIter, key *InternalKey, offset uint64) bool {
+	for k, v := index.SeekGE(key.UserKey, base.SeekGEFlagsNone); k != nil; k, v = index.Next() {
+		bh, err := decodeBlockHandleWithProperties(v.InPlaceValue())
+		if err != nil || bh.Offset > offset {
+			return false
+		}
+		if bh.Offset == offset {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPrefetcherIndex loads the index block at the current position of the
+// prefetcher's top-level index iterator.
+func (i *singleLevelIterator) loadPrefetcherIndex() bool {
+	p := i.prefetcher
+	bhp, err := decodeBlockHandleWithProperties(p.topLevelIndex.lazyValue.InPlaceValue())
+	if err != nil {
+		return false
+	}
+	ctx := objiotracing.WithBlockType(i.ctx, objiotracing.MetadataBlock)
+	indexBlock, err := i.reader.readBlock(
+		ctx, bhp.BlockHandle, nil /* transform */, nil /* readHandle */, i.stats, &i.iterStats,
+		nil /* bufferPool */, cacheHighPriority)
+	if err != nil {
+		return false
+	}
+	return p.index.initHandle(i.cmp, indexBlock, i.reader.Properties.GlobalSeqNum, false) == nil
+}
+
+// nextPrefetchEntry advances the prefetcher's index iterators to the entry of
+// the next data block, returning the entry's separator key and block handle.
+func (i *singleLevelIterator) nextPrefetchEntry() (*InternalKey, BlockHandleWithProperties, bool) {
+	p := i.prefetcher
+	var key *InternalKey
+	var val base.LazyValue
+	if p.atStart {
+		p.atStart = false
+		if p.iterTopLevelIndex != nil {
+			if k, _ := p.topLevelIndex.First(); k == nil || !i.loadPrefetcherIndex() {
+				return nil, BlockHandleWithProperties{}, false
+			}
+		}
+		key, val = p.index.First()
+	} else {
+		key, val = p.index.Next()
+	}
+	for key == nil {
+		if p.iterTopLevelIndex == nil {
+			return nil, BlockHandleWithProperties{}, false
+		}
+		if k, _ := p.topLevelIndex.Next(); k == nil || !i.loadPrefetcherIndex() {
+			return nil, BlockHandleWithProperties{}, false
+		}
+		key, val = p.index.First()
+	}
+	bhp, err := decodeBlockHandleWithProperties(val.InPlaceValue())
+	if err != nil {
+		return nil, BlockHandleWithProperties{}, false
+	}
+	return key, bhp, true
+}
+
+// read reads the given blocks, in order, skipping the blocks that were
+// abandoned.
+func (p *dataBlockPrefetcher) read(
+	ctx context.Context, r *Reader, policy cachePolicy, blocks []prefetchedBlock,
+) {
+	defer p.wg.Done()
+	ctx = objiotracing.WithBlockType(ctx, objiotracing.DataBlock)
+	rh := r.readable.NewReadHandle(ctx)
+	defer func() { _ = rh.Close() }()
+	for j := range blocks {
+		b := &blocks[j]
+		if b.state.Load() == prefetchPending {
+			b.buf, b.err = r.readBlock(
+				ctx, b.bh, nil /* transform */, rh, &b.stats, nil /* iterStats */, nil /* bufferPool */, policy)
+			if !b.state.CompareAndSwap(prefetchPending, prefetchDone) {
+				// The block was abandoned while it was read.
+				b.buf.Release()
+				b.buf = bufferHandle{}
+			}
+		}
+		close(b.done)
+	}
+}
+
+// abandon releases the block, or arranges for the goroutine reading it to
+// release it.
+func (b *prefetchedBlock) abandon() {
+	if !b.state.CompareAndSwap(prefetchPending, prefetchAbandoned) {
+		// The read completed.
+		b.buf.Release()
+		b.buf = bufferHandle{}
+	}
+}
+
+// abandon abandons all the queued blocks. The prefetcher must be positioned
+// again before blocks are queued.
+func (p *dataBlockPrefetcher) abandon() {
+	for _, b := range p.queue {
+		b.abandon()
+	}
+	p.queue = p.queue[:0]
+	p.positioned = false
+}
+
+// close abandons the queued blocks and waits for the goroutines reading blocks
+// to complete.
+func (p *dataBlockPrefetcher) close() {
+	p.abandon()
+	p.wg.Wait()
+	_ = p.index.Close()
+	_ = p.topLevelIndex.Close()
+}
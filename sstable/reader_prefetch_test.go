@@ -0,0 +1,124 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package sstable
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/testkeys"
+	"github.com/cockroachdb/pebble/objstorage/objstorageprovider"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/rand"
+)
+
+func TestDataBlockPrefetch(t *testing.T) {
+	seed := uint64(time.Now().UnixNano())
+	t.Logf("seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+	keys, absent := hashIndexTestKeys(rng, 500)
+
+	mem := vfs.NewMem()
+	build := func(name string, indexBlockSize int) *Reader {
+		f, err := mem.Create(name)
+		require.NoError(t, err)
+		w := NewWriter(objstorageprovider.NewFileWritable(f), WriterOptions{
+			BlockSize:      256,
+			IndexBlockSize: indexBlockSize,
+			Comparer:       testkeys.Comparer,
+			TableFormat:    TableFormatPebblev2,
+		})
+		for j, k := range keys {
+			require.NoError(t, w.Set(k, []byte(fmt.Sprint(j))))
+		}
+		require.NoError(t, w.Close())
+		f, err = mem.Open(name)
+		require.NoError(t, err)
+		readable, err := NewSimpleReadable(f)
+		require.NoError(t, err)
+		r, err := NewReader(readable, ReaderOptions{Comparer: testkeys.Comparer})
+		require.NoError(t, err)
+		return r
+	}
+
+	for _, twoLevel := range []bool{false, true} {
+		indexBlockSize := 64 << 10
+		if twoLevel {
+			indexBlockSize = 256
+		}
+		r := build(fmt.Sprintf("table-%t", twoLevel), indexBlockSize)
+		require.Equal(t, twoLevel, r.Properties.IndexPartitions > 0)
+		numBlocks := int(r.Properties.NumDataBlocks)
+		require.Greater(t, numBlocks, 20)
+
+		newIter := func(upper []byte, prefetchBlocks int, stats *base.InternalIteratorStats) Iterator {
+			iter, err := r.NewIterWithBlockPropertyFiltersAndContextEtc(
+				context.Background(), nil /* lower */, upper, nil /* filterer */, false, /* hideObsoletePoints */
+				true /* useFilterBlock */, stats, CategoryAndQoS{}, nil, /* statsCollector */
+				TrivialReaderProvider{Reader: r}, BlockCacheDefault, prefetchBlocks)
+			require.NoError(t, err)
+			return iter
+		}
+
+		for _, depth := range []int{1, 2, 7, 32} {
+			t.Run(fmt.Sprintf("two-level=%t,depth=%d", twoLevel, depth), func(t *testing.T) {
+				// A full scan uses a prefetched block for every data block but the
+				// first one.
+				var stats base.InternalIteratorStats
+				iter := newIter(nil, depth, &stats)
+				n := 0
+				for k, _ := iter.First(); k != nil; k, _ = iter.Next() {
+					require.Equal(t, string(keys[n]), string(k.UserKey))
+					n++
+				}
+				require.Equal(t, len(keys), n)
+				require.True(t, iter.(prefetchingIterForTesting).PrefetchedToEnd())
+				require.NoError(t, iter.Close())
+				require.Equal(t, uint64(numBlocks-1), stats.PrefetchedBlocks)
+				require.Equal(t, uint64(numBlocks-1), stats.PrefetchHits+stats.PrefetchWaits)
+
+				// Prefetching the first blocks of an unpositioned iterator.
+				stats = base.InternalIteratorStats{}
+				iter = newIter(nil, depth, &stats)
+				iter.(prefetchingIterForTesting).PrefetchFirstBlocks()
+				for k, _ := iter.First(); k != nil; k, _ = iter.Next() {
+				}
+				require.NoError(t, iter.Close())
+				require.Equal(t, uint64(numBlocks), stats.PrefetchHits+stats.PrefetchWaits)
+
+				// Prefetching stops at the upper bound.
+				upper := keys[len(keys)/4]
+				stats = base.InternalIteratorStats{}
+				iter = newIter(upper, depth, &stats)
+				n = 0
+				for k, _ := iter.First(); k != nil; k, _ = iter.Next() {
+					n++
+				}
+				require.NoError(t, iter.Close())
+				require.Equal(t, len(keys)/4, n)
+				usedBlocks := stats.PrefetchHits + stats.PrefetchWaits
+				require.LessOrEqual(t, stats.PrefetchedBlocks, usedBlocks+1)
+
+				// Random operations return the same results as an iterator that
+				// doesn't prefetch, and don't leak blocks.
+				plain := newIter(nil, 0, nil)
+				prefetching := newIter(nil, depth, nil)
+				compareBlockIters(t, rng, plain, prefetching, keys, absent)
+				require.NoError(t, plain.Close())
+				require.NoError(t, prefetching.Close())
+			})
+		}
+		require.NoError(t, r.Close())
+	}
+}
+
+type prefetchingIterForTesting interface {
+	PrefetchFirstBlocks()
+	PrefetchedToEnd() bool
+}
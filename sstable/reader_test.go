@@ -444,7 +444,7 @@ func TestVirtualReader(t *testing.T) {
 			var stats base.InternalIteratorStats
 			iter, err := v.NewIterWithBlockPropertyFiltersAndContextEtc(
 				context.Background(), lower, upper, nil, false, false,
-				&stats, CategoryAndQoS{}, nil, TrivialReaderProvider{Reader: r})
+				&stats, CategoryAndQoS{}, nil, TrivialReaderProvider{Reader: r}, BlockCacheDefault, 0 /* prefetchBlocks */)
 			if err != nil {
 				return err.Error()
 			}
@@ -580,6 +580,8 @@ func TestReaderStats(t *testing.T) {
 			TableFormatPebblev2:    "testdata/readerstats_LevelDB",
 			TableFormatPebblev3:    "testdata/readerstats_Pebblev3",
 			TableFormatPebblev4:    "testdata/readerstats_Pebblev3",
+			TableFormatPebblev5:    "testdata/readerstats_Pebblev3",
+			TableFormatPebblev6:    "testdata/readerstats_Pebblev3",
 		}, func(t *testing.T, format TableFormat, dir string) {
 			if dir == "" {
 				t.Skip()
@@ -612,6 +614,8 @@ func TestReaderWithBlockPropertyFilter(t *testing.T) {
 			TableFormatPebblev2:    "testdata/reader_bpf/Pebblev2",
 			TableFormatPebblev3:    "testdata/reader_bpf/Pebblev3",
 			TableFormatPebblev4:    "testdata/reader_bpf/Pebblev3",
+			TableFormatPebblev5:    "testdata/reader_bpf/Pebblev3",
+			TableFormatPebblev6:    "testdata/reader_bpf/Pebblev3",
 		}, func(t *testing.T, format TableFormat, dir string) {
 			if dir == "" {
 				t.Skip("Block-properties unsupported")
@@ -718,7 +722,7 @@ func indexLayoutString(t *testing.T, r *Reader) string {
 		fmt.Fprintf(&buf, " %s: size %d\n", string(key.UserKey), bh.Length)
 		if twoLevelIndex {
 			b, err := r.readBlock(
-				context.Background(), bh.BlockHandle, nil, nil, nil, nil, nil)
+				context.Background(), bh.BlockHandle, nil, nil, nil, nil, nil, cacheNormalPriority)
 			require.NoError(t, err)
 			defer b.Release()
 			iter2, err := newBlockIter(r.Compare, b.Get())
@@ -815,6 +819,8 @@ func runTestReader(t *testing.T, o WriterOptions, dir string, r *Reader, printVa
 					CategoryAndQoS{},
 					nil,
 					TrivialReaderProvider{Reader: r},
+					BlockCacheDefault,
+					0, /* prefetchBlocks */
 				)
 				if err != nil {
 					return err.Error()
@@ -997,6 +1003,46 @@ func TestBytesIterated(t *testing.T) {
 	})
 }
 
+func TestCompactionIteratorSeekGE(t *testing.T) {
+	for _, blockSize := range []int{100, 4096} {
+		for _, indexBlockSize := range []int{100, math.MaxInt32} {
+			r := buildTestTable(t, 1e4, blockSize, indexBlockSize, NoCompression, nil)
+			var pool BufferPool
+			pool.Init(5)
+			newIter := func(bytesIterated *uint64) Iterator {
+				citer, err := r.NewCompactionIter(
+					bytesIterated, CategoryAndQoS{}, nil, TrivialReaderProvider{Reader: r}, &pool)
+				require.NoError(t, err)
+				return citer
+			}
+			var keys [][]byte
+			var bytesIterated uint64
+			citer := newIter(&bytesIterated)
+			for key, _ := citer.First(); key != nil; key, _ = citer.Next() {
+				keys = append(keys, append([]byte(nil), key.UserKey...))
+			}
+			require.NoError(t, citer.Close())
+
+			// Seeking to the middle of the table yields the second half of the
+			// keys, and only the bytes from the seek key onward are counted as
+			// iterated.
+			var seekBytesIterated uint64
+			citer = newIter(&seekBytesIterated)
+			var seekKeys [][]byte
+			for key, _ := citer.SeekGE(keys[len(keys)/2], base.SeekGEFlagsNone); key != nil; key, _ = citer.Next() {
+				seekKeys = append(seekKeys, append([]byte(nil), key.UserKey...))
+			}
+			require.NoError(t, citer.Close())
+			require.Equal(t, keys[len(keys)/2:], seekKeys)
+			require.Less(t, seekBytesIterated, bytesIterated*6/10)
+			require.Greater(t, seekBytesIterated, bytesIterated*4/10)
+
+			require.NoError(t, r.Close())
+			pool.Release()
+		}
+	}
+}
+
 func TestCompactionIteratorSetupForCompaction(t *testing.T) {
 	tmpDir := path.Join(t.TempDir())
 	provider, err := objstorageprovider.Open(objstorageprovider.DefaultSettings(vfs.Default, tmpDir))
@@ -2063,7 +2109,7 @@ func BenchmarkIteratorScanObsolete(b *testing.B) {
 								iter, err := r.NewIterWithBlockPropertyFiltersAndContextEtc(
 									context.Background(), nil, nil, filterer, hideObsoletePoints,
 									true, nil, CategoryAndQoS{}, nil,
-									TrivialReaderProvider{Reader: r})
+									TrivialReaderProvider{Reader: r}, BlockCacheDefault, 0 /* prefetchBlocks */)
 								require.NoError(b, err)
 								b.ResetTimer()
 								for i := 0; i < b.N; i++ {
@@ -2098,3 +2144,135 @@ func newReader(r ReadableFile, o ReaderOptions, extraOpts ...ReaderOption) (*Rea
 	}
 	return NewReader(readable, o, extraOpts...)
 }
+
+func TestReaderBlockCacheMode(t *testing.T) {
+	for _, indexBlockSize := range []int{1024, math.MaxInt32} {
+		for _, mode := range []BlockCacheMode{BlockCacheDefault, BlockCacheLowPriority, BlockCacheBypass} {
+			t.Run(fmt.Sprintf("index=%d/mode=%s", indexBlockSize, mode), func(t *testing.T) {
+				const numEntries = 5000
+				r := buildTestTable(t, numEntries, 1024, indexBlockSize, DefaultCompression, nil)
+				defer r.Close()
+
+				iter, err := r.NewIterWithBlockPropertyFiltersAndContextEtc(
+					context.Background(), nil /* lower */, nil /* upper */, nil, /* filterer */
+					false /* hideObsoletePoints */, true, /* useFilterBlock */
+					nil /* stats */, CategoryAndQoS{}, nil, /* statsCollector */
+					TrivialReaderProvider{Reader: r}, mode, 0 /* prefetchBlocks */)
+				require.NoError(t, err)
+				var n int
+				for key, _ := iter.First(); key != nil; key, _ = iter.Next() {
+					require.Equal(t, uint64(n), binary.BigEndian.Uint64(key.UserKey))
+					n++
+				}
+				require.NoError(t, iter.Close())
+				require.Equal(t, numEntries, n)
+
+				// The first data block is at offset 0. It is in the cache unless
+				// the cache was bypassed.
+				h := r.opts.Cache.Get(r.cacheID, r.fileNum, 0)
+				defer h.Release()
+				if mode == BlockCacheBypass {
+					require.Nil(t, h.Get())
+				} else {
+					require.NotNil(t, h.Get())
+				}
+				// The index block is always in the cache.
+				ih := r.opts.Cache.Get(r.cacheID, r.fileNum, r.indexBH.Offset)
+				defer ih.Release()
+				require.NotNil(t, ih.Get())
+			})
+		}
+	}
+}
+
+func TestReaderWarmBlocks(t *testing.T) {
+	for _, indexBlockSize := range []int{1024, math.MaxInt32} {
+		t.Run(fmt.Sprintf("index=%d", indexBlockSize), func(t *testing.T) {
+			r := buildTestTable(t, 5000, 1024, indexBlockSize, DefaultCompression, nil)
+			defer r.Close()
+
+			l, err := r.Layout()
+			require.NoError(t, err)
+			r.opts.Cache.EvictFile(r.cacheID, r.fileNum)
+
+			// Warm every other data block. The offset 1 does not correspond to a
+			// block and is ignored.
+			var offsets []uint64
+			var wantBytes uint64
+			for i := 0; i < len(l.Data); i += 2 {
+				offsets = append(offsets, l.Data[i].Offset)
+				wantBytes += l.Data[i].Length
+			}
+			offsets = append(offsets, 1)
+			var waited uint64
+			blocks, bytes, err := r.WarmBlocks(context.Background(), offsets, func(length uint64) error {
+				waited += length
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, (len(l.Data)+1)/2, blocks)
+			require.Equal(t, wantBytes, bytes)
+			require.Equal(t, wantBytes, waited)
+			for i := range l.Data {
+				h := r.opts.Cache.Get(r.cacheID, r.fileNum, l.Data[i].Offset)
+				require.Equal(t, i%2 == 0, h.Get() != nil, "block %d", i)
+				h.Release()
+			}
+
+			// Blocks already in the cache are not read again.
+			blocks, _, err = r.WarmBlocks(context.Background(), offsets, nil /* wait */)
+			require.NoError(t, err)
+			require.Zero(t, blocks)
+
+			// An error returned by wait stops the warm-up.
+			r.opts.Cache.EvictFile(r.cacheID, r.fileNum)
+			errStop := errors.New("stop")
+			blocks, _, err = r.WarmBlocks(context.Background(), offsets, func(uint64) error {
+				return errStop
+			})
+			require.ErrorIs(t, err, errStop)
+			require.Zero(t, blocks)
+		})
+	}
+}
+
+func TestReaderDataBlockSeparators(t *testing.T) {
+	keyAt := func(i uint64) []byte { return binary.BigEndian.AppendUint64(nil, i) }
+	for _, indexBlockSize := range []int{1024, math.MaxInt32} {
+		t.Run(fmt.Sprintf("index=%d", indexBlockSize), func(t *testing.T) {
+			r := buildTestTable(t, 5000, 1024, indexBlockSize, DefaultCompression, nil)
+			defer r.Close()
+			l, err := r.Layout()
+			require.NoError(t, err)
+
+			// Without bounds, every data block is described.
+			seps, err := r.DataBlockSeparators(nil, nil)
+			require.NoError(t, err)
+			require.Len(t, seps, len(l.Data))
+			var total uint64
+			for i := range seps {
+				require.Equal(t, l.Data[i].Length+blockTrailerLen, seps[i].Size)
+				if i > 0 {
+					require.Negative(t, r.Compare(seps[i-1].Key, seps[i].Key))
+				}
+				total += seps[i].Size
+			}
+			require.Equal(t, r.Properties.DataSize, total)
+
+			// With bounds, the blocks that may contain keys in the range are
+			// described.
+			start, end := keyAt(1000), keyAt(2000)
+			bounded, err := r.DataBlockSeparators(start, end)
+			require.NoError(t, err)
+			first := 0
+			for r.Compare(seps[first].Key, start) < 0 {
+				first++
+			}
+			last := first
+			for r.Compare(seps[last].Key, end) < 0 {
+				last++
+			}
+			require.Equal(t, seps[first:last+1], bounded)
+		})
+	}
+}
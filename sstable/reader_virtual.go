@@ -109,10 +109,12 @@ func (v *VirtualReader) NewIterWithBlockPropertyFiltersAndContextEtc(
 	categoryAndQoS CategoryAndQoS,
 	statsCollector *CategoryStatsCollector,
 	rp ReaderProvider,
+	cacheMode BlockCacheMode,
+	prefetchBlocks int,
 ) (Iterator, error) {
 	i, err := v.reader.newIterWithBlockPropertyFiltersAndContext(
 		ctx, lower, upper, filterer, hideObsoletePoints, useFilterBlock, stats,
-		categoryAndQoS, statsCollector, rp, &v.vState)
+		categoryAndQoS, statsCollector, rp, cacheMode, prefetchBlocks, &v.vState)
 	if err == nil && v.vState.prefixChange != nil {
 		i = newPrefixReplacingIterator(i, v.vState.prefixChange.ContentPrefix, v.vState.prefixChange.SyntheticPrefix, v.reader.Compare)
 	}
@@ -275,6 +277,33 @@ func (v *VirtualReader) EstimateDiskUsage(start, end []byte) (uint64, error) {
 	return v.reader.EstimateDiskUsage(f, l)
 }
 
+// DataBlockSeparators calls VirtualReader.reader.DataBlockSeparators after
+// enforcing the virtual sstable bounds.
+func (v *VirtualReader) DataBlockSeparators(start, end []byte) ([]DataBlockSeparator, error) {
+	_, f, l := v.vState.constrainBounds(start, end, true /* endInclusive */)
+	prefixChange := v.vState.prefixChange
+	if prefixChange == nil {
+		return v.reader.DataBlockSeparators(f, l)
+	}
+	if !bytes.HasPrefix(f, prefixChange.SyntheticPrefix) || !bytes.HasPrefix(l, prefixChange.SyntheticPrefix) {
+		return nil, errInputPrefixMismatch
+	}
+	seps, err := v.reader.DataBlockSeparators(prefixChange.ReplaceArg(f), prefixChange.ReplaceArg(l))
+	if err != nil {
+		return nil, err
+	}
+	for i := range seps {
+		if bytes.HasPrefix(seps[i].Key, prefixChange.ContentPrefix) {
+			seps[i].Key = prefixChange.ReplaceResult(seps[i].Key)
+		} else {
+			// A shortened separator past the last key may have dropped the
+			// prefix; the virtual sstable's upper bound separates it instead.
+			seps[i].Key = append([]byte(nil), l...)
+		}
+	}
+	return seps, nil
+}
+
 // CommonProperties implements the CommonReader interface.
 func (v *VirtualReader) CommonProperties() *CommonProperties {
 	return &v.Properties
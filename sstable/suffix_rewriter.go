@@ -167,6 +167,7 @@ type blockWithSpan struct {
 func rewriteBlocks(
 	r *Reader,
 	restartInterval int,
+	hashIndexSplit, columnarSplit Split,
 	checksumType ChecksumType,
 	compression Compression,
 	input []BlockHandleWithProperties,
@@ -175,8 +176,13 @@ func rewriteBlocks(
 	from, to []byte,
 	split Split,
 ) error {
+	// Rewriting the suffixes of the keys doesn't change their prefixes, so the
+	// rewritten blocks can be given a hash index or be columnar regardless of
+	// the format of the input blocks.
 	bw := blockWriter{
 		restartInterval: restartInterval,
+		hashIndexSplit:  hashIndexSplit,
+		columnarSplit:   columnarSplit,
 	}
 	buf := blockBuf{checksummer: checksummer{checksumType: checksumType}}
 	if checksumType == ChecksumTypeXXHash {
@@ -305,6 +311,8 @@ func rewriteDataBlocksToWriter(
 			err := rewriteBlocks(
 				r,
 				w.dataBlockBuf.dataBlock.restartInterval,
+				w.dataBlockHashIndexSplit,
+				w.dataBlockColumnarSplit,
 				w.blockBuf.checksummer.checksumType,
 				w.compression,
 				data,
@@ -181,6 +181,17 @@ Data blocks have some additional features:
     in the context of that sstable (for a reader that reads at a higher seqnum
     than the highest seqnum in the sstable). For details, see the comment in
     format.go.
+
+- For TableFormatPebblev5 onwards:
+  - Data blocks may contain a hash index mapping the prefixes of the block's
+    keys to restart intervals, signaled by the most significant bit of the
+    block's restart count. See the comment in block_hash_index.go.
+
+- For TableFormatPebblev6 onwards:
+  - Data blocks may be columnar, storing the prefixes and suffixes of the keys,
+    the key trailers and the values in separate columns, signaled by the
+    second most significant bit of the block's trailing count. See the comment
+    in columnar_block.go.
 */
 
 const (
@@ -443,7 +454,8 @@ func supportsTwoLevelIndex(format TableFormat) bool {
 	switch format {
 	case TableFormatLevelDB:
 		return false
-	case TableFormatRocksDBv2, TableFormatPebblev1, TableFormatPebblev2, TableFormatPebblev3, TableFormatPebblev4:
+	case TableFormatRocksDBv2, TableFormatPebblev1, TableFormatPebblev2, TableFormatPebblev3, TableFormatPebblev4, TableFormatPebblev5,
+		TableFormatPebblev6:
 		return true
 	default:
 		panic("sstable: unspecified table format version")
@@ -127,8 +127,15 @@ type Writer struct {
 	indexBlockSizeThreshold int
 	compare                 Compare
 	split                   Split
+	// dataBlockHashIndexSplit is set to split if data blocks are written with
+	// a hash index (see WriterOptions.DataBlockHashIndex), and
+	// dataBlockColumnarSplit if data blocks are columnar (see
+	// WriterOptions.ColumnarDataBlocks).
+	dataBlockHashIndexSplit Split
+	dataBlockColumnarSplit  Split
 	formatKey               base.FormatKey
 	compression             Compression
+	adaptiveCompression     *adaptiveCompressor
 	separator               Separator
 	successor               Successor
 	tableFormat             TableFormat
@@ -635,9 +642,13 @@ var dataBlockBufPool = sync.Pool{
 	},
 }
 
-func newDataBlockBuf(restartInterval int, checksumType ChecksumType) *dataBlockBuf {
+func newDataBlockBuf(
+	restartInterval int, hashIndexSplit, columnarSplit Split, checksumType ChecksumType,
+) *dataBlockBuf {
 	d := dataBlockBufPool.Get().(*dataBlockBuf)
 	d.dataBlock.restartInterval = restartInterval
+	d.dataBlock.hashIndexSplit = hashIndexSplit
+	d.dataBlock.columnarSplit = columnarSplit
 	d.checksummer.checksumType = checksumType
 	return d
 }
@@ -650,6 +661,20 @@ func (d *dataBlockBuf) compressAndChecksum(c Compression) {
 	d.compressed = compressAndChecksum(d.uncompressed, c, &d.blockBuf)
 }
 
+// compressDataBlock compresses and checksums the finished data block in d,
+// selecting the compression algorithm adaptively if adaptive compression is
+// enabled.
+func (w *Writer) compressDataBlock(d *dataBlockBuf) {
+	if w.adaptiveCompression == nil {
+		d.compressAndChecksum(w.compression)
+		return
+	}
+	c := w.adaptiveCompression.next()
+	d.compressAndChecksum(c)
+	w.adaptiveCompression.record(
+		&w.props, c, blockType(d.blockBuf.tmp[0]), len(d.uncompressed), len(d.compressed))
+}
+
 func (d *dataBlockBuf) shouldFlush(
 	key InternalKey, valueLen, targetBlockSize, sizeThreshold int,
 ) bool {
@@ -1384,7 +1409,7 @@ func (w *Writer) flush(key InternalKey) error {
 		return err
 	}
 	w.dataBlockBuf.finish()
-	w.dataBlockBuf.compressAndChecksum(w.compression)
+	w.compressDataBlock(w.dataBlockBuf)
 	// Since dataBlockEstimates.addInflightDataBlock was never called, the
 	// inflightSize is set to 0.
 	w.coordination.sizeEstimate.dataBlockCompressed(len(w.dataBlockBuf.compressed), 0)
@@ -1448,7 +1473,8 @@ func (w *Writer) flush(key InternalKey) error {
 	} else {
 		err = w.coordination.writeQueue.addSync(writeTask)
 	}
-	w.dataBlockBuf = newDataBlockBuf(w.restartInterval, w.checksumType)
+	w.dataBlockBuf = newDataBlockBuf(
+		w.restartInterval, w.dataBlockHashIndexSplit, w.dataBlockColumnarSplit, w.checksumType)
 
 	return err
 }
@@ -1895,7 +1921,9 @@ func (w *Writer) Close() (err error) {
 	// Finish the last data block, or force an empty data block if there
 	// aren't any data blocks at all.
 	if w.dataBlockBuf.dataBlock.nEntries > 0 || w.indexBlock.block.nEntries == 0 {
-		bh, err := w.writeBlock(w.dataBlockBuf.dataBlock.finish(), w.compression, &w.dataBlockBuf.blockBuf)
+		w.dataBlockBuf.finish()
+		w.compressDataBlock(w.dataBlockBuf)
+		bh, err := w.writeCompressedBlock(w.dataBlockBuf.compressed, w.dataBlockBuf.tmp[:])
 		if err != nil {
 			return err
 		}
@@ -2224,7 +2252,19 @@ func NewWriter(writable objstorage.Writable, o WriterOptions, extraOpts ...Write
 			})
 	}
 
-	w.dataBlockBuf = newDataBlockBuf(w.restartInterval, w.checksumType)
+	if o.ColumnarDataBlocks && w.tableFormat >= TableFormatPebblev6 {
+		w.dataBlockColumnarSplit = w.split
+	} else if o.DataBlockHashIndex && w.tableFormat >= TableFormatPebblev5 {
+		w.dataBlockHashIndexSplit = w.split
+	}
+	w.dataBlockBuf = newDataBlockBuf(
+		w.restartInterval, w.dataBlockHashIndexSplit, w.dataBlockColumnarSplit, w.checksumType)
+	if o.AdaptiveCompression.Enabled && w.compression != NoCompression {
+		w.adaptiveCompression = &adaptiveCompressor{
+			opts:        o.AdaptiveCompression,
+			compression: w.compression,
+		}
+	}
 
 	w.blockBuf = blockBuf{
 		checksummer: checksummer{checksumType: o.Checksum},
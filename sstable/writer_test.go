@@ -421,7 +421,7 @@ func TestBlockBufClear(t *testing.T) {
 }
 
 func TestClearDataBlockBuf(t *testing.T) {
-	d := newDataBlockBuf(1, ChecksumTypeCRC32c)
+	d := newDataBlockBuf(1, nil /* hashIndexSplit */, nil /* columnarSplit */, ChecksumTypeCRC32c)
 	d.blockBuf.compressedBuf = make([]byte, 1)
 	d.dataBlock.add(ikey("apple"), nil)
 	d.dataBlock.add(ikey("banana"), nil)
@@ -1073,3 +1073,99 @@ var test4bSuffixComparer = &base.Comparer{
 	},
 	Name: "comparer-split-4b-suffix",
 }
+
+func TestWriterAdaptiveCompression(t *testing.T) {
+	rng := rand.New(rand.NewSource(1 /* fixed seed */))
+	const numKeys = 2000
+	keys := make([][]byte, numKeys)
+	vals := make([][]byte, numKeys)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("%08d", i))
+		vals[i] = make([]byte, 100)
+		if i < numKeys/2 {
+			// Incompressible values.
+			rng.Read(vals[i])
+		} else {
+			// Highly compressible values.
+			for j := range vals[i] {
+				vals[i][j] = 'a'
+			}
+		}
+	}
+
+	write := func(adaptive AdaptiveCompressionOptions) (*memFile, Properties) {
+		f := &memFile{}
+		w := NewWriter(f, WriterOptions{
+			BlockSize:           1 << 10,
+			Compression:         SnappyCompression,
+			AdaptiveCompression: adaptive,
+			TableFormat:         TableFormatPebblev2,
+		})
+		for i := range keys {
+			require.NoError(t, w.Set(keys[i], vals[i]))
+		}
+		require.NoError(t, w.Close())
+		meta, err := w.Metadata()
+		require.NoError(t, err)
+		return f, meta.Properties
+	}
+
+	// Without adaptive compression, the counters are not populated.
+	_, props := write(AdaptiveCompressionOptions{})
+	require.Zero(t, props.SnappyCompressedBytes)
+	require.Zero(t, props.SnappySkippedBytes)
+
+	f, props := write(AdaptiveCompressionOptions{Enabled: true, SampleInterval: 4})
+	require.NotZero(t, props.SnappyCompressedBytes)
+	require.NotZero(t, props.SnappySkippedBytes)
+	// The incompressible half of the table is skipped, while the compressible
+	// half is compressed (modulo the blocks at the transition between the two,
+	// which are written before the next sample).
+	require.Greater(t, props.SnappyCompressedBytes, props.DataSize/2)
+	require.Less(t, props.SnappyCompressedBytes+props.SnappySkippedBytes, 2*props.DataSize+(1<<10))
+
+	// The properties round trip and the table is readable.
+	r, err := NewMemReader(f.Data(), ReaderOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+	require.Equal(t, props.SnappyCompressedBytes, r.Properties.SnappyCompressedBytes)
+	require.Equal(t, props.SnappySkippedBytes, r.Properties.SnappySkippedBytes)
+	it, err := r.NewIter(nil, nil)
+	require.NoError(t, err)
+	defer it.Close()
+	i := 0
+	for k, v := it.First(); k != nil; k, v = it.Next() {
+		require.Equal(t, keys[i], k.UserKey)
+		vBytes, _, err := v.Value(nil)
+		require.NoError(t, err)
+		require.Equal(t, vals[i], vBytes)
+		i++
+	}
+	require.Equal(t, numKeys, i)
+}
+
+func TestAdaptiveCompressor(t *testing.T) {
+	a := adaptiveCompressor{
+		opts: AdaptiveCompressionOptions{
+			Enabled: true,
+		}.EnsureDefaults(),
+		compression: ZstdCompression,
+	}
+	a.opts.SampleInterval = 2
+	var props Properties
+
+	// An unprofitable sample causes a fallback for the next SampleInterval
+	// blocks.
+	require.Equal(t, ZstdCompression, a.next())
+	a.record(&props, ZstdCompression, noCompressionBlockType, 100, 100)
+	for i := 0; i < 2; i++ {
+		require.Equal(t, NoCompression, a.next())
+		a.record(&props, NoCompression, noCompressionBlockType, 100, 100)
+	}
+	require.Equal(t, uint64(300), props.ZstdSkippedBytes)
+	// A profitable sample re-enables the configured algorithm.
+	require.Equal(t, ZstdCompression, a.next())
+	a.record(&props, ZstdCompression, zstdCompressionBlockType, 100, 50)
+	require.Equal(t, ZstdCompression, a.next())
+	require.Equal(t, uint64(100), props.ZstdCompressedBytes)
+}
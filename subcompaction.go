@@ -0,0 +1,246 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/internal/keyspan"
+	"github.com/cockroachdb/pebble/internal/manifest"
+	"github.com/cockroachdb/pebble/sstable"
+	"golang.org/x/sync/errgroup"
+)
+
+// subcompactionMinSizeFactor is the minimum size of the inputs of a
+// subcompaction, as a multiple of the compaction's maximum output file size.
+// Smaller subcompactions would produce small output tables at their bounds
+// without saving much time.
+const subcompactionMinSizeFactor = 2
+
+// pickSubcompactions returns the subcompactions that the compaction c should be
+// split into, or nil if it shouldn't be split. See
+// Options.Experimental.MaxSubcompactions.
+//
+// The subcompactions partition the key range of c at boundaries of its input
+// tables, so that each of them has roughly the same amount of input. The
+// compaction itself holds one compaction slot; a slot is reserved for each of
+// the other subcompactions, so that the compactions scheduled while they run
+// don't exceed Options.MaxConcurrentCompactions. runSubcompactions releases
+// the reserved slots.
+//
+// d.mu must be held when calling this.
+func (d *DB) pickSubcompactions(c *compaction) []*compaction {
+	if c.kind != compactionKindDefault || len(c.flushing) != 0 || c.outputLevel.level == 0 {
+		return nil
+	}
+	// The compaction itself holds one of the compaction slots.
+	n := min(d.opts.Experimental.MaxSubcompactions,
+		1+d.opts.MaxConcurrentCompactions()-d.mu.compact.compactingCount)
+	if n <= 1 {
+		return nil
+	}
+
+	var candidates []splitCandidate
+	var size uint64
+	for _, cl := range c.inputs {
+		iter := cl.files.Iter()
+		for f := iter.First(); f != nil; f = iter.Next() {
+			candidates = append(candidates, splitCandidate{key: f.Largest.UserKey, size: f.Size})
+			size += f.Size
+		}
+	}
+	if c.maxOutputFileSize > 0 {
+		n = min(n, int(size/(subcompactionMinSizeFactor*c.maxOutputFileSize)))
+	}
+	splitKeys := pickSplitKeys(c.cmp, candidates, c.smallest.UserKey, c.largest.UserKey, n)
+	if len(splitKeys) == 0 {
+		return nil
+	}
+
+	subcompactions := make([]*compaction, 0, len(splitKeys)+1)
+	var lower []byte
+	for _, upper := range splitKeys {
+		subcompactions = append(subcompactions, c.newSubcompaction(lower, upper))
+		lower = upper
+	}
+	d.mu.compact.compactingCount += len(splitKeys)
+	d.mu.compact.subcompactionSlots += len(splitKeys)
+	return append(subcompactions, c.newSubcompaction(lower, nil))
+}
+
+// newSubcompaction returns a subcompaction of c that compacts the keys of c
+// within [lower, upper).
+func (c *compaction) newSubcompaction(lower, upper []byte) *compaction {
+	return &compaction{
+		kind:               c.kind,
+		cmp:                c.cmp,
+		equal:              c.equal,
+		comparer:           c.comparer,
+		formatKey:          c.formatKey,
+		logger:             c.logger,
+		version:            c.version,
+		beganAt:            c.beganAt,
+		startLevel:         c.startLevel,
+		outputLevel:        c.outputLevel,
+		extraLevels:        c.extraLevels,
+		inputs:             c.inputs,
+		maxOutputFileSize:  c.maxOutputFileSize,
+		maxOverlapBytes:    c.maxOverlapBytes,
+		disableSpanElision: c.disableSpanElision,
+		smallest:           c.smallest,
+		largest:            c.largest,
+		grandparents:       c.grandparents,
+		inuseKeyRanges:     c.inuseKeyRanges,
+		inuseEntireRange:   c.inuseEntireRange,
+		allowedZeroSeqNum:  c.allowedZeroSeqNum,
+		outputCreationTime: c.outputCreationTime,
+		parent:             c,
+		lower:              lower,
+		upper:              upper,
+	}
+}
+
+// setCancelled cancels the compaction, along with the context of the
+// CompactionExecutor running it, if any.
+func (c *compaction) setCancelled() {
+	c.cancel.Store(true)
+	c.cancelMu.Lock()
+	defer c.cancelMu.Unlock()
+	if c.cancelMu.cancelCtx != nil {
+		c.cancelMu.cancelCtx()
+	}
+}
+
+// cancelled returns true if the compaction, or the compaction it is a
+// subcompaction of, has been cancelled.
+func (c *compaction) cancelled() bool {
+	return c.cancel.Load() || (c.parent != nil && c.parent.cancel.Load())
+}
+
+// boundedFiles returns the files of the given slice that overlap the bounds of
+// the subcompaction c. The slice must be sorted by key.
+func (c *compaction) boundedFiles(files manifest.LevelSlice) manifest.LevelSlice {
+	var bounded []*fileMetadata
+	iter := files.Iter()
+	for f := iter.First(); f != nil; f = iter.Next() {
+		if c.lower != nil && c.cmp(f.Largest.UserKey, c.lower) < 0 {
+			continue
+		}
+		if c.upper != nil && c.cmp(f.Smallest.UserKey, c.upper) >= 0 {
+			break
+		}
+		bounded = append(bounded, f)
+	}
+	return manifest.NewLevelSliceKeySorted(c.cmp, bounded)
+}
+
+// truncateSpans returns an iterator over the spans of iter truncated to the
+// bounds of the subcompaction c.
+func (c *compaction) truncateSpans(iter keyspan.FragmentIterator) keyspan.FragmentIterator {
+	return keyspan.Filter(iter, func(in *keyspan.Span, out *keyspan.Span) bool {
+		out.Start, out.End = in.Start, in.End
+		out.Keys = append(out.Keys[:0], in.Keys...)
+		if c.lower != nil && c.cmp(out.Start, c.lower) < 0 {
+			out.Start = c.lower
+		}
+		if c.upper != nil && c.cmp(out.End, c.upper) > 0 {
+			out.End = c.upper
+		}
+		return c.cmp(out.Start, out.End) < 0
+	}, c.cmp)
+}
+
+// boundedIter wraps the point iterator of a level of a subcompaction, omitting
+// the keys outside the subcompaction's bounds. The iterator is positioned at
+// the lower bound with SeekGE, so that the blocks of the level's first table
+// before the bound aren't read.
+type boundedIter struct {
+	internalIterator
+	cmp          Compare
+	lower, upper []byte
+}
+
+// First implements internalIterator.First.
+func (i *boundedIter) First() (*InternalKey, base.LazyValue) {
+	if i.lower != nil {
+		return i.checkUpper(i.internalIterator.SeekGE(i.lower, base.SeekGEFlagsNone))
+	}
+	return i.checkUpper(i.internalIterator.First())
+}
+
+// Next implements internalIterator.Next.
+func (i *boundedIter) Next() (*InternalKey, base.LazyValue) {
+	return i.checkUpper(i.internalIterator.Next())
+}
+
+func (i *boundedIter) checkUpper(
+	key *InternalKey, val base.LazyValue,
+) (*InternalKey, base.LazyValue) {
+	if key != nil && i.upper != nil && i.cmp(key.UserKey, i.upper) >= 0 {
+		return nil, base.LazyValue{}
+	}
+	return key, val
+}
+
+// runSubcompactions runs the subcompactions of c in parallel, returning their
+// outputs in key order, and releases the compaction slots reserved for them by
+// pickSubcompactions. The outputs are returned even if an error is returned,
+// so that the caller may remove the tables that were created.
+//
+// d.mu must not be held when calling this.
+func (d *DB) runSubcompactions(
+	jobID int,
+	c *compaction,
+	subcompactions []*compaction,
+	snapshots []uint64,
+	writerOpts sstable.WriterOptions,
+) ([]*compactionOutput, error) {
+	outputs := make([]*compactionOutput, len(subcompactions))
+	infos := make([]SubcompactionInfo, len(subcompactions))
+	var g errgroup.Group
+	for i := range subcompactions {
+		i := i
+		outputs[i] = &compactionOutput{}
+		g.Go(func() error {
+			startTime := d.timeNow()
+			err := d.compactAndWrite(jobID, subcompactions[i], snapshots, writerOpts, outputs[i])
+			infos[i].Duration = d.timeNow().Sub(startTime)
+			return err
+		})
+	}
+	err := g.Wait()
+
+	d.mu.Lock()
+	d.mu.compact.compactingCount -= len(subcompactions) - 1
+	d.mu.compact.subcompactionSlots -= len(subcompactions) - 1
+	d.maybeScheduleCompaction()
+	d.mu.Unlock()
+
+	for _, sc := range subcompactions {
+		c.bytesIterated += sc.bytesIterated
+		c.bytesWritten += sc.bytesWritten
+	}
+	if err != nil {
+		return outputs, err
+	}
+
+	// Each subcompaction verifies that its own tables don't split a user key.
+	// Verify the same at the boundaries between subcompactions.
+	ve := &versionEdit{}
+	for i, o := range outputs {
+		for j, nf := range o.newFiles {
+			ve.NewFiles = append(ve.NewFiles, nf)
+			if j == 0 {
+				if err := c.errorOnUserKeyOverlap(ve); err != nil {
+					return outputs, err
+				}
+			}
+			infos[i].Tables = append(infos[i].Tables, nf.Meta.TableInfo())
+		}
+		infos[i].Start = subcompactions[i].lower
+		infos[i].End = subcompactions[i].upper
+	}
+	c.subcompactions = infos
+	return outputs, nil
+}
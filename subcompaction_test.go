@@ -0,0 +1,122 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package pebble
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/rand"
+)
+
+func TestSubcompactions(t *testing.T) {
+	var mu sync.Mutex
+	var infos []CompactionInfo
+	opts := &Options{
+		FS:                          vfs.NewMem(),
+		DebugCheck:                  DebugCheckLevels,
+		DisableAutomaticCompactions: true,
+		MaxConcurrentCompactions:    func() int { return 4 },
+		EventListener: &EventListener{
+			CompactionEnd: func(info CompactionInfo) {
+				mu.Lock()
+				defer mu.Unlock()
+				infos = append(infos, info)
+			},
+		},
+	}
+	opts.Experimental.MaxSubcompactions = 4
+	opts.Levels = make([]LevelOptions, numLevels)
+	for i := range opts.Levels {
+		opts.Levels[i].TargetFileSize = 16 << 10
+	}
+	d, err := Open("", opts)
+	require.NoError(t, err)
+
+	// Write keys in several flushes, so that the compactions below have many
+	// input tables, and delete some of them with point and range deletions.
+	const numKeys = 10000
+	key := func(i int) []byte { return []byte(fmt.Sprintf("%08d", i)) }
+	rng := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
+	expected := map[string][]byte{}
+	for i := 0; i < numKeys; i++ {
+		value := make([]byte, 100)
+		rng.Read(value)
+		require.NoError(t, d.Set(key(i), value, nil))
+		expected[string(key(i))] = value
+		if i%2000 == 1999 {
+			require.NoError(t, d.Flush())
+		}
+	}
+	for i := 0; i < numKeys; i += 7 {
+		require.NoError(t, d.Delete(key(i), nil))
+		delete(expected, string(key(i)))
+	}
+	require.NoError(t, d.DeleteRange(key(5000), key(5500), nil))
+	for i := 5000; i < 5500; i++ {
+		delete(expected, string(key(i)))
+	}
+	require.NoError(t, d.Flush())
+	require.NoError(t, d.Compact(key(0), key(numKeys), false /* parallelize */))
+
+	// At least one compaction was split, and the tables of each subcompaction
+	// lie within its bounds. No snapshots are open, so the split compactions
+	// into the bottommost level zeroed the sequence numbers of their outputs.
+	mu.Lock()
+	var split, bottommost int
+	for _, info := range infos {
+		if len(info.Subcompactions) == 0 {
+			continue
+		}
+		split++
+		if info.Output.Level == numLevels-1 {
+			bottommost++
+			for _, table := range info.Output.Tables {
+				require.Equal(t, uint64(0), table.LargestSeqNum, "table %s", table.FileNum)
+			}
+		}
+		var tables []TableInfo
+		for _, sc := range info.Subcompactions {
+			for _, table := range sc.Tables {
+				if sc.Start != nil {
+					require.LessOrEqual(t, string(sc.Start), string(table.Smallest.UserKey))
+				}
+				if sc.End != nil {
+					require.LessOrEqual(t, string(table.Largest.UserKey), string(sc.End))
+				}
+			}
+			tables = append(tables, sc.Tables...)
+		}
+		require.Equal(t, info.Output.Tables, tables)
+		require.Contains(t, info.String(), fmt.Sprintf("%d subcompactions", len(info.Subcompactions)))
+	}
+	mu.Unlock()
+	require.Greater(t, split, 0)
+	require.Greater(t, bottommost, 0)
+
+	// The slots reserved for the subcompactions were released.
+	d.mu.Lock()
+	require.Equal(t, 0, d.mu.compact.compactingCount)
+	require.Equal(t, 0, d.mu.compact.subcompactionSlots)
+	d.mu.Unlock()
+
+	// The split compactions preserved the contents of the DB.
+	iter, _ := d.NewIter(nil)
+	var n int
+	for valid := iter.First(); valid; valid = iter.Next() {
+		value, ok := expected[string(iter.Key())]
+		require.True(t, ok, "unexpected key %s", iter.Key())
+		require.True(t, bytes.Equal(value, iter.Value()), "unexpected value for key %s", iter.Key())
+		n++
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, len(expected), n)
+	require.NoError(t, d.Close())
+}
@@ -245,6 +245,20 @@ func (c *tableCacheContainer) withReader(meta physicalMeta, fn func(*sstable.Rea
 	return fn(v.reader)
 }
 
+// withBackingReader fetches the Reader for the physical sstable that backs the
+// file, which may be a virtual sstable.
+func (c *tableCacheContainer) withBackingReader(
+	meta *fileMetadata, fn func(*sstable.Reader) error,
+) error {
+	s := c.tableCache.getShard(meta.FileBacking.DiskFileNum)
+	v := s.findNode(meta, &c.dbOpts)
+	defer s.unrefValue(v)
+	if v.err != nil {
+		return v.err
+	}
+	return fn(v.reader)
+}
+
 // withVirtualReader fetches a VirtualReader associated with a virtual sstable.
 func (c *tableCacheContainer) withVirtualReader(
 	meta virtualMeta, fn func(sstable.VirtualReader) error,
@@ -563,7 +577,8 @@ func (c *tableCacheShard) newIters(
 	} else {
 		iter, err = cr.NewIterWithBlockPropertyFiltersAndContextEtc(
 			ctx, opts.GetLowerBound(), opts.GetUpperBound(), filterer, hideObsoletePoints, useFilter,
-			internalOpts.stats, categoryAndQoS, dbOpts.sstStatsCollector, rp)
+			internalOpts.stats, categoryAndQoS, dbOpts.sstStatsCollector, rp, opts.getBlockCacheMode(),
+			opts.getPrefetchBlocks())
 	}
 	if err != nil {
 		if rangeDelIter != nil {
@@ -1016,7 +1031,13 @@ func (c *tableCacheShard) evict(fileNum base.DiskFileNum, dbOpts *tableCacheOpts
 		v.release(c)
 	}
 
-	dbOpts.opts.Cache.EvictFile(dbOpts.cacheID, fileNum)
+	if allowLeak {
+		// The DB is being closed rather than the file deleted, so the file's
+		// blocks in the cache's secondary tier remain valid.
+		dbOpts.opts.Cache.EvictFileFromMemory(dbOpts.cacheID, fileNum)
+	} else {
+		dbOpts.opts.Cache.EvictFile(dbOpts.cacheID, fileNum)
+	}
 }
 
 // removeDB evicts any nodes which have a reference to the DB
@@ -0,0 +1,116 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/objstorage/remote"
+	"github.com/cockroachdb/pebble/sstable"
+	"github.com/spf13/cobra"
+)
+
+// compactWorkerT implements a worker that runs the compaction jobs of a
+// pebble.CompactionExecutor.
+type compactWorkerT struct {
+	Root *cobra.Command
+
+	opts      *pebble.Options
+	comparers sstable.Comparers
+	mergers   sstable.Mergers
+
+	remoteDir string
+}
+
+func newCompactWorker(
+	opts *pebble.Options, comparers sstable.Comparers, mergers sstable.Mergers,
+) *compactWorkerT {
+	w := &compactWorkerT{
+		opts:      opts,
+		comparers: comparers,
+		mergers:   mergers,
+	}
+
+	w.Root = &cobra.Command{
+		Use:   "compact-worker [<job>]",
+		Short: "run a remote compaction job",
+		Long: `
+Run a compaction job of a DB that offloads compactions of tables on remote
+storage to a CompactionExecutor. The job is read as JSON from the given file,
+or from stdin, and the tables it produced are written as JSON to stdout, to be
+installed by the DB.
+
+The remote storage of the job's tables is the one configured for the tool, or
+the local directory given with --remote-dir, which holds the objects of all
+of the job's locators.
+`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: w.run,
+	}
+	w.Root.Flags().StringVar(
+		&w.remoteDir, "remote-dir", "", "local directory used as the remote storage of all of the job's locators")
+	return w
+}
+
+func (w *compactWorkerT) run(cmd *cobra.Command, args []string) error {
+	var in io.Reader = cmd.InOrStdin()
+	if len(args) == 1 {
+		f, err := w.opts.FS.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	var job pebble.CompactionJob
+	if err := json.NewDecoder(in).Decode(&job); err != nil {
+		return errors.Wrap(err, "decoding compaction job")
+	}
+
+	opts := w.opts.Clone()
+	opts.Comparer = w.comparers[job.Comparer]
+	if opts.Comparer == nil {
+		return errors.Errorf("unknown comparer %q", errors.Safe(job.Comparer))
+	}
+	opts.Merger = w.mergers[job.Merger]
+	if opts.Merger == nil {
+		return errors.Errorf("unknown merger %q", errors.Safe(job.Merger))
+	}
+	if w.remoteDir != "" {
+		opts.Experimental.RemoteStorage = localDirFactory{
+			storage: remote.NewLocalFS(w.remoteDir, opts.FS),
+		}
+	}
+
+	result, err := pebble.RunCompactionJob(context.Background(), &job, opts)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%s\n", data)
+	return nil
+}
+
+// localDirFactory is the remote storage of a job run with --remote-dir. The
+// input tables and the output tables of the job may have different locators,
+// which all refer to the local directory.
+type localDirFactory struct {
+	storage remote.Storage
+}
+
+var _ remote.StorageFactory = localDirFactory{}
+
+// CreateStorage is part of the remote.StorageFactory interface.
+func (f localDirFactory) CreateStorage(locator remote.Locator) (remote.Storage, error) {
+	return f.storage, nil
+}
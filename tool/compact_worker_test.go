@@ -0,0 +1,101 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/objstorage/remote"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+// commandExecutor is a pebble.CompactionExecutor that runs the compaction jobs
+// with the compact-worker command.
+type commandExecutor struct {
+	fs        vfs.FS
+	remoteDir string
+	jobs      int
+}
+
+func (e *commandExecutor) ExecuteCompaction(
+	_ context.Context, job *pebble.CompactionJob,
+) (*pebble.CompactionJobResult, error) {
+	e.jobs++
+	data, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	f, err := e.fs.Create("job.json")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	c := &cobra.Command{}
+	c.AddCommand(New(FS(e.fs)).Commands...)
+	c.SetArgs([]string{"compact-worker", "--remote-dir", e.remoteDir, "job.json"})
+	c.SetOut(&buf)
+	c.SetErr(&buf)
+	if err := c.Execute(); err != nil {
+		return nil, err
+	}
+	var result pebble.CompactionJobResult
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func TestCompactWorker(t *testing.T) {
+	fs := vfs.NewMem()
+	require.NoError(t, fs.MkdirAll("remote", 0755))
+	executor := &commandExecutor{fs: fs, remoteDir: "remote"}
+
+	opts := &pebble.Options{
+		FS:            fs,
+		LBaseMaxBytes: 1,
+	}
+	opts.Experimental.RemoteStorage = remote.MakeSimpleFactory(map[remote.Locator]remote.Storage{
+		"": remote.NewLocalFS("remote", fs),
+	})
+	opts.Experimental.CreateOnShared = remote.CreateOnSharedAll
+	opts.Experimental.CompactionExecutor = executor
+	d, err := pebble.Open("db", opts)
+	require.NoError(t, err)
+	require.NoError(t, d.SetCreatorID(1))
+
+	key := func(i int) []byte { return []byte(fmt.Sprintf("%04d", i)) }
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 1000; i++ {
+			require.NoError(t, d.Set(key(i), []byte(fmt.Sprint(round)), nil))
+		}
+		require.NoError(t, d.Flush())
+		require.NoError(t, d.Compact(key(0), key(1000), false /* parallelize */))
+	}
+	require.Greater(t, executor.jobs, 0)
+
+	iter, _ := d.NewIter(nil)
+	var n int
+	for valid := iter.First(); valid; valid = iter.Next() {
+		require.Equal(t, "1", string(iter.Value()))
+		n++
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, 1000, n)
+	require.NoError(t, d.Close())
+}
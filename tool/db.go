@@ -30,7 +30,10 @@ type dbT struct {
 	Root       *cobra.Command
 	Check      *cobra.Command
 	Checkpoint *cobra.Command
+	Dump       *cobra.Command
+	Export     *cobra.Command
 	Get        *cobra.Command
+	Load       *cobra.Command
 	Logs       *cobra.Command
 	LSM        *cobra.Command
 	Properties *cobra.Command
@@ -58,6 +61,8 @@ type dbT struct {
 	ioParallelism int
 	ioSizes       string
 	verbose       bool
+	targetSize    int64
+	resolveMerges bool
 }
 
 func newDB(
@@ -100,6 +105,30 @@ database not be in use by another process.
 		Args: cobra.ExactArgs(2),
 		Run:  d.runCheckpoint,
 	}
+	d.Dump = &cobra.Command{
+		Use:   "dump <dir> <file>",
+		Short: "dump the DB to a file",
+		Long: `
+Dump the point keys, range deletions, range keys and merge operands of the DB to
+a file, which may be loaded with the load command by a DB with a different
+format or comparer. Requires that the specified database not be in use by
+another process.
+`,
+		Args: cobra.ExactArgs(2),
+		Run:  d.runDump,
+	}
+	d.Export = &cobra.Command{
+		Use:   "export <dir> <export-dir>",
+		Short: "export a key range to sstables",
+		Long: `
+Export the keys within the range specified by --start and --end to sstables in
+the export directory, which may be ingested by another DB. Ingesting them
+replaces the keys within the range of that DB. Requires that the specified
+database not be in use by another process.
+`,
+		Args: cobra.ExactArgs(2),
+		Run:  d.runExport,
+	}
 	d.Get = &cobra.Command{
 		Use:   "get <dir> <key>",
 		Short: "get value for a key",
@@ -111,6 +140,17 @@ process.
 		Args: cobra.ExactArgs(2),
 		Run:  d.runGet,
 	}
+	d.Load = &cobra.Command{
+		Use:   "load <dir> <file>",
+		Short: "load a dump into the DB",
+		Long: `
+Load a file written by the dump command into the DB, which is created if it
+doesn't exist. The loaded keys replace the keys of the DB. Requires that the
+specified database not be in use by another process.
+`,
+		Args: cobra.ExactArgs(2),
+		Run:  d.runLoad,
+	}
 	d.Logs = logs.NewCmd()
 	d.LSM = &cobra.Command{
 		Use:   "lsm <dir>",
@@ -173,17 +213,17 @@ specified database.
 		Run:  d.runIOBench,
 	}
 
-	d.Root.AddCommand(d.Check, d.Checkpoint, d.Get, d.Logs, d.LSM, d.Properties, d.Scan, d.Set, d.Space, d.IOBench)
+	d.Root.AddCommand(d.Check, d.Checkpoint, d.Dump, d.Export, d.Get, d.Load, d.Logs, d.LSM, d.Properties, d.Scan, d.Set, d.Space, d.IOBench)
 	d.Root.PersistentFlags().BoolVarP(&d.verbose, "verbose", "v", false, "verbose output")
 
-	for _, cmd := range []*cobra.Command{d.Check, d.Checkpoint, d.Get, d.LSM, d.Properties, d.Scan, d.Set, d.Space} {
+	for _, cmd := range []*cobra.Command{d.Check, d.Checkpoint, d.Dump, d.Export, d.Get, d.Load, d.LSM, d.Properties, d.Scan, d.Set, d.Space} {
 		cmd.Flags().StringVar(
 			&d.comparerName, "comparer", "", "comparer name (use default if empty)")
 		cmd.Flags().StringVar(
 			&d.mergerName, "merger", "", "merger name (use default if empty)")
 	}
 
-	for _, cmd := range []*cobra.Command{d.Scan, d.Space} {
+	for _, cmd := range []*cobra.Command{d.Export, d.Scan, d.Space} {
 		cmd.Flags().Var(
 			&d.start, "start", "start key for the range")
 		cmd.Flags().Var(
@@ -200,6 +240,12 @@ specified database.
 	d.Scan.Flags().Int64Var(
 		&d.count, "count", 0, "key count for scan (0 is unlimited)")
 
+	d.Dump.Flags().BoolVar(
+		&d.resolveMerges, "resolve-merges", false, "dump the merged values instead of the merge operands")
+
+	d.Export.Flags().Int64Var(
+		&d.targetSize, "target-file-size", 0, "target size of the exported sstables (0 is the DB's default)")
+
 	d.IOBench.Flags().BoolVar(
 		&d.allLevels, "all-levels", false, "if set, benchmark all levels (default is only L5/L6)")
 	d.IOBench.Flags().IntVar(
@@ -370,6 +416,93 @@ func (d *dbT) runCheckpoint(cmd *cobra.Command, args []string) {
 	}
 }
 
+func (d *dbT) runDump(cmd *cobra.Command, args []string) {
+	stdout, stderr := cmd.OutOrStdout(), cmd.ErrOrStderr()
+	db, err := d.openDB(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return
+	}
+	defer d.closeDB(stderr, db)
+
+	f, err := d.opts.FS.Create(args[1])
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return
+	}
+	snap := db.NewSnapshot()
+	defer snap.Close()
+	stats, err := snap.Dump(f, pebble.DumpOptions{ResolveMerges: d.resolveMerges})
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return
+	}
+	d.printDumpStats(stdout, stats)
+}
+
+func (d *dbT) runLoad(cmd *cobra.Command, args []string) {
+	stdout, stderr := cmd.OutOrStdout(), cmd.ErrOrStderr()
+	f, err := d.opts.FS.Open(args[1])
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return
+	}
+	defer f.Close()
+	db, err := d.openDB(args[0], nonReadOnly{})
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return
+	}
+	defer d.closeDB(stderr, db)
+
+	stats, err := db.Load(f, pebble.BulkLoaderOptions{})
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return
+	}
+	d.printDumpStats(stdout, stats)
+}
+
+func (d *dbT) printDumpStats(stdout io.Writer, stats pebble.DumpStats) {
+	fmt.Fprintf(stdout, "sets: %d\n", stats.Sets)
+	fmt.Fprintf(stdout, "merges: %d\n", stats.Merges)
+	fmt.Fprintf(stdout, "range deletes: %d\n", stats.RangeDeletes)
+	fmt.Fprintf(stdout, "range key sets: %d\n", stats.RangeKeySets)
+}
+
+func (d *dbT) runExport(cmd *cobra.Command, args []string) {
+	stdout, stderr := cmd.OutOrStdout(), cmd.ErrOrStderr()
+	if d.end == nil {
+		fmt.Fprintf(stderr, "--end must be specified\n")
+		return
+	}
+	db, err := d.openDB(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return
+	}
+	defer d.closeDB(stderr, db)
+
+	snap := db.NewSnapshot()
+	defer snap.Close()
+	paths, err := snap.Export(d.start, d.end, args[1], pebble.ExportOptions{
+		TargetFileSize: d.targetSize,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return
+	}
+	for _, path := range paths {
+		fmt.Fprintf(stdout, "%s\n", path)
+	}
+}
+
 func (d *dbT) runGet(cmd *cobra.Command, args []string) {
 	stdout, stderr := cmd.OutOrStdout(), cmd.ErrOrStderr()
 	db, err := d.openDB(args[0])
@@ -391,7 +391,6 @@ func (s *sstableT) runScan(cmd *cobra.Command, args []string) {
 			fmt.Fprintf(stderr, "%s%s\n", prefix, err)
 			return
 		}
-		defer iter.Close()
 		key, value := iter.SeekGE(s.start, base.SeekGEFlagsNone)
 
 		// We configured sstable.Reader to return raw tombstones which requires a
@@ -427,6 +426,7 @@ func (s *sstableT) runScan(cmd *cobra.Command, args []string) {
 		}()
 		if err != nil {
 			fmt.Fprintf(stdout, "%s%s\n", prefix, err)
+			_ = iter.Close()
 			return
 		}
 
@@ -447,6 +447,7 @@ func (s *sstableT) runScan(cmd *cobra.Command, args []string) {
 					v, _, err := value.Value(nil)
 					if err != nil {
 						fmt.Fprintf(stdout, "%s%s\n", prefix, err)
+						_ = iter.Close()
 						return
 					}
 					formatKeyValue(stdout, s.fmtKey, s.fmtValue, key, v)
@@ -487,6 +488,10 @@ func (s *sstableT) runScan(cmd *cobra.Command, args []string) {
 			}
 		}
 
+		if err := iter.Close(); err != nil {
+			fmt.Fprintf(stdout, "%s\n", err)
+		}
+
 		// Handle range keys.
 		rkIter, err := r.NewRawRangeKeyIter()
 		if err != nil {
@@ -514,10 +519,6 @@ func (s *sstableT) runScan(cmd *cobra.Command, args []string) {
 				}
 			}
 		}
-
-		if err := iter.Close(); err != nil {
-			fmt.Fprintf(stdout, "%s\n", err)
-		}
 	})
 }
 
@@ -26,6 +26,7 @@ type Merger = base.Merger
 // T is the container for all of the introspection tools.
 type T struct {
 	Commands        []*cobra.Command
+	compactWorker   *compactWorkerT
 	db              *dbT
 	find            *findT
 	lsm             *lsmT
@@ -121,6 +122,7 @@ func New(opts ...Option) *T {
 		opt(t)
 	}
 
+	t.compactWorker = newCompactWorker(&t.opts, t.comparers, t.mergers)
 	t.db = newDB(&t.opts, t.comparers, t.mergers, t.openErrEnhancer)
 	t.find = newFind(&t.opts, t.comparers, t.defaultComparer, t.mergers)
 	t.lsm = newLSM(&t.opts, t.comparers)
@@ -129,6 +131,7 @@ func New(opts ...Option) *T {
 	t.sstable = newSSTable(&t.opts, t.comparers, t.mergers)
 	t.wal = newWAL(&t.opts, t.comparers, t.defaultComparer)
 	t.Commands = []*cobra.Command{
+		t.compactWorker.Root,
 		t.db.Root,
 		t.find.Root,
 		t.lsm.Root,
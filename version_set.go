@@ -84,6 +84,11 @@ type versionSet struct {
 	// still referenced by an inuse iterator.
 	zombieTables map[base.DiskFileNum]uint64 // filenum -> size
 
+	// tableDeleteReasons holds the reasons to report in the TableDeleteInfo of
+	// tables dropped by delete-only compactions that have a deleteReason, until
+	// the tables are deleted.
+	tableDeleteReasons map[base.DiskFileNum]string
+
 	// backingState is protected by the versionSet.logLock. It's populated
 	// during Open in versionSet.load, but it's not used concurrently during
 	// load.
@@ -140,6 +145,7 @@ func (vs *versionSet) init(
 	vs.versions.Init(mu)
 	vs.obsoleteFn = vs.addObsoleteLocked
 	vs.zombieTables = make(map[base.DiskFileNum]uint64)
+	vs.tableDeleteReasons = make(map[base.DiskFileNum]string)
 	vs.backingState.fileBackingMap = make(map[base.DiskFileNum]*fileBacking)
 	vs.backingState.fileBackingSize = 0
 	vs.nextFileNum = 1
@@ -677,6 +683,10 @@ func (vs *versionSet) incrementCompactions(
 	case compactionKindRewrite:
 		vs.metrics.Compact.Count++
 		vs.metrics.Compact.RewriteCount++
+
+	case compactionKindPeriodic:
+		vs.metrics.Compact.Count++
+		vs.metrics.Compact.PeriodicCount++
 	}
 	if len(extraLevels) > 0 {
 		vs.metrics.Compact.MultiLevelCount++
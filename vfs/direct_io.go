@@ -0,0 +1,265 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package vfs
+
+import (
+	"io"
+	"sync"
+	"unsafe"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// directIOAlignment is the alignment of the offsets, lengths and memory
+	// buffers of direct I/O reads and writes. 4KB is a multiple of the logical
+	// block size of all common devices.
+	directIOAlignment = 4 << 10
+	// directIOWriteBufferSize is the size of the aligned buffer that
+	// accumulates writes to a file opened for direct I/O.
+	directIOWriteBufferSize = 256 << 10
+)
+
+// WithDirectIO wraps an FS so that the files it creates or opens for reading
+// bypass the OS page cache using direct I/O (O_DIRECT on Linux). This avoids
+// polluting the page cache with large sequential reads and writes, such as
+// those of compactions, at the cost of losing OS-level caching and readahead
+// for these files.
+//
+// Direct I/O requires the offsets, lengths and memory buffers of I/O
+// operations to be aligned. The returned files take care of this: reads are
+// performed through aligned bounce buffers, and writes are accumulated in an
+// aligned buffer and issued in aligned chunks. The unaligned tail of a written
+// file is written without direct I/O when the file is synced or closed, after
+// which the file must not be written to using direct I/O anymore; further
+// writes go through the page cache.
+//
+// Direct I/O is enabled on a file after it is opened by the wrapped FS, so the
+// wrapped FS's own wrappers (eg, disk-health checking) keep functioning. Files
+// for which direct I/O is not supported, either because of the platform, the
+// filesystem (eg, tmpfs) or because they're not backed by a file descriptor
+// (eg, MemFS), are returned unchanged. See IsDirectIO.
+//
+// Files opened with OpenReadWrite or ReuseForWrite do not use direct I/O.
+func WithDirectIO(fs FS) FS {
+	return &directIOFS{FS: fs}
+}
+
+type directIOFS struct {
+	FS
+}
+
+var _ FS = (*directIOFS)(nil)
+
+// Unwrap returns the underlying FS.
+func (fs *directIOFS) Unwrap() FS {
+	return fs.FS
+}
+
+// Create implements FS.Create.
+func (fs *directIOFS) Create(name string) (File, error) {
+	f, err := fs.FS.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return newDirectIOFile(f), nil
+}
+
+// Open implements FS.Open.
+func (fs *directIOFS) Open(name string, opts ...OpenOption) (File, error) {
+	f, err := fs.FS.Open(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newDirectIOFile(f), nil
+}
+
+// IsDirectIO returns true if the file was opened by an FS returned by
+// WithDirectIO and uses direct I/O.
+func IsDirectIO(f File) bool {
+	_, ok := f.(*directIOFile)
+	return ok
+}
+
+// directIOFile is a File that uses direct I/O. See WithDirectIO.
+type directIOFile struct {
+	File
+	fd uintptr
+
+	// writeBuf is an aligned buffer that holds the written data that has not
+	// been written to the file yet. It is allocated by the first Write.
+	writeBuf []byte
+	// directWrites is false once the unaligned tail of the written data was
+	// written to the file without direct I/O.
+	directWrites bool
+	// written is the number of bytes written to the file.
+	written int64
+	// readOffset is the offset of the next Read.
+	readOffset int64
+}
+
+func newDirectIOFile(f File) File {
+	fd := f.Fd()
+	if fd == InvalidFd {
+		return f
+	}
+	if err := setDirectIO(fd, true); err != nil {
+		return f
+	}
+	return &directIOFile{File: f, fd: fd, directWrites: true}
+}
+
+// alignedBuffer returns a buffer of length n whose memory is aligned to
+// directIOAlignment.
+func alignedBuffer(n int) []byte {
+	b := make([]byte, n+directIOAlignment)
+	off := int(uintptr(unsafe.Pointer(&b[0])) & (directIOAlignment - 1))
+	if off != 0 {
+		off = directIOAlignment - off
+	}
+	return b[off : off+n : off+n]
+}
+
+func isAligned(b []byte) bool {
+	return len(b)%directIOAlignment == 0 &&
+		uintptr(unsafe.Pointer(&b[0]))&(directIOAlignment-1) == 0
+}
+
+// readBufferPool holds aligned bounce buffers for unaligned reads.
+var readBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := alignedBuffer(directIOAlignment)
+		return &b
+	},
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *directIOFile) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	start := off &^ (directIOAlignment - 1)
+	end := (off + int64(len(p)) + directIOAlignment - 1) &^ (directIOAlignment - 1)
+	if start == off && isAligned(p) {
+		return f.File.ReadAt(p, off)
+	}
+
+	bp := readBufferPool.Get().(*[]byte)
+	defer readBufferPool.Put(bp)
+	if int64(cap(*bp)) < end-start {
+		*bp = alignedBuffer(int(end - start))
+	}
+	buf := (*bp)[:end-start]
+	n, err := f.File.ReadAt(buf, start)
+	if n -= int(off - start); n < 0 {
+		n = 0
+	}
+	n = copy(p, buf[off-start:off-start+int64(n)])
+	if n == len(p) {
+		// A short read of the aligned range that covers p is expected at the
+		// end of the file.
+		return n, nil
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Read implements io.Reader.
+func (f *directIOFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.readOffset)
+	f.readOffset += int64(n)
+	return n, err
+}
+
+// Write implements io.Writer.
+func (f *directIOFile) Write(p []byte) (int, error) {
+	if !f.directWrites {
+		n, err := f.File.Write(p)
+		f.written += int64(n)
+		return n, err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		if f.writeBuf == nil {
+			f.writeBuf = alignedBuffer(directIOWriteBufferSize)[:0]
+		}
+		c := copy(f.writeBuf[len(f.writeBuf):cap(f.writeBuf)], p)
+		f.writeBuf = f.writeBuf[:len(f.writeBuf)+c]
+		p = p[c:]
+		if len(f.writeBuf) == cap(f.writeBuf) {
+			if err := f.flush(false /* tail */); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// WriteAt is not supported: files that use direct I/O are only written
+// sequentially.
+func (f *directIOFile) WriteAt(p []byte, off int64) (int, error) {
+	return 0, errors.WithStack(ErrUnsupported)
+}
+
+// flush writes the aligned prefix of the buffered data to the file. If tail
+// is true, the unaligned remainder is also written, without direct I/O.
+func (f *directIOFile) flush(tail bool) error {
+	if aligned := len(f.writeBuf) &^ (directIOAlignment - 1); aligned > 0 {
+		n, err := f.File.Write(f.writeBuf[:aligned])
+		f.written += int64(n)
+		if err != nil {
+			return err
+		}
+		f.writeBuf = f.writeBuf[:copy(f.writeBuf, f.writeBuf[aligned:])]
+	}
+	if !tail || len(f.writeBuf) == 0 {
+		return nil
+	}
+	if err := setDirectIO(f.fd, false); err != nil {
+		return err
+	}
+	f.directWrites = false
+	n, err := f.File.Write(f.writeBuf)
+	f.written += int64(n)
+	f.writeBuf = nil
+	return err
+}
+
+// Prefetch is a no-op: files that use direct I/O bypass the OS page cache.
+func (f *directIOFile) Prefetch(offset, length int64) error {
+	return nil
+}
+
+// Sync implements File.Sync.
+func (f *directIOFile) Sync() error {
+	if err := f.flush(true /* tail */); err != nil {
+		return err
+	}
+	return f.File.Sync()
+}
+
+// SyncData implements File.SyncData.
+func (f *directIOFile) SyncData() error {
+	if err := f.flush(true /* tail */); err != nil {
+		return err
+	}
+	return f.File.SyncData()
+}
+
+// SyncTo implements File.SyncTo. Only data that was written to the file is
+// synced; buffered data is not.
+func (f *directIOFile) SyncTo(length int64) (fullSync bool, err error) {
+	fullSync, err = f.File.SyncTo(min(length, f.written))
+	// The buffered data is not synced.
+	return fullSync && len(f.writeBuf) == 0, err
+}
+
+// Close implements io.Closer.
+func (f *directIOFile) Close() error {
+	err := f.flush(true /* tail */)
+	return errors.CombineErrors(err, f.File.Close())
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/rand"
+)
+
+func TestDirectIO(t *testing.T) {
+	seed := uint64(time.Now().UnixNano())
+	t.Logf("seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+
+	fs := WithDirectIO(Default)
+	dir := t.TempDir()
+	f, err := fs.Create(fs.PathJoin(dir, "probe"))
+	require.NoError(t, err)
+	directIOSupported := IsDirectIO(f)
+	require.NoError(t, f.Close())
+	if !directIOSupported {
+		t.Skipf("direct I/O is not supported in %s", dir)
+	}
+
+	for _, size := range []int{0, 1, directIOAlignment - 1, directIOAlignment, 3*directIOWriteBufferSize + 17} {
+		for _, syncMidway := range []bool{false, true} {
+			data := make([]byte, size)
+			_, _ = rng.Read(data)
+			name := fs.PathJoin(dir, "file")
+			f, err := fs.Create(name)
+			require.NoError(t, err)
+			require.True(t, IsDirectIO(f))
+			for rest := data; len(rest) > 0; {
+				n := min(len(rest), 1+rng.Intn(64<<10))
+				_, err := f.Write(rest[:n])
+				require.NoError(t, err)
+				rest = rest[n:]
+				if syncMidway && len(rest) > 0 && rng.Intn(10) == 0 {
+					// Writes after the file is synced don't use direct I/O.
+					require.NoError(t, f.Sync())
+				}
+				_, err = f.SyncTo(int64(len(data) - len(rest)))
+				require.NoError(t, err)
+			}
+			require.NoError(t, f.Close())
+
+			f, err = fs.Open(name)
+			require.NoError(t, err)
+			require.True(t, IsDirectIO(f))
+			stat, err := f.Stat()
+			require.NoError(t, err)
+			require.Equal(t, int64(size), stat.Size())
+
+			// Sequential reads.
+			got, err := io.ReadAll(f)
+			require.NoError(t, err)
+			require.True(t, bytes.Equal(data, got))
+
+			// Random reads, including reads that extend past the end of the file.
+			for i := 0; i < 100; i++ {
+				off := rng.Int63n(int64(size) + 1)
+				p := make([]byte, rng.Intn(3*directIOAlignment))
+				n, err := f.ReadAt(p, off)
+				want := data[off:min(int(off)+len(p), size)]
+				require.Equal(t, len(want), n)
+				require.True(t, bytes.Equal(want, p[:n]))
+				if n < len(p) {
+					require.Equal(t, io.EOF, err)
+				} else {
+					require.NoError(t, err)
+				}
+			}
+			// Aligned reads into aligned buffers don't need a bounce buffer.
+			if size >= directIOAlignment {
+				p := alignedBuffer(directIOAlignment)
+				_, err = f.ReadAt(p, 0)
+				require.NoError(t, err)
+				require.True(t, bytes.Equal(data[:directIOAlignment], p))
+			}
+			require.NoError(t, f.Close())
+		}
+	}
+}
+
+func TestDirectIOUnsupported(t *testing.T) {
+	// Files not backed by a file descriptor are returned unchanged.
+	mem := NewMem()
+	fs := WithDirectIO(mem)
+	require.Equal(t, FS(mem), Root(fs))
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+	require.False(t, IsDirectIO(f))
+	_, err = f.Write([]byte("bar"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	f, err = fs.Open("foo")
+	require.NoError(t, err)
+	require.False(t, IsDirectIO(f))
+	require.NoError(t, f.Close())
+}
+
+func TestAlignedBuffer(t *testing.T) {
+	for _, n := range []int{1, directIOAlignment, 3*directIOAlignment + 5} {
+		b := alignedBuffer(n)
+		require.Len(t, b, n)
+		require.Equal(t, n, cap(b))
+		require.Equal(t, n%directIOAlignment == 0, isAligned(b))
+	}
+}
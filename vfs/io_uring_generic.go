@@ -0,0 +1,13 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package vfs
+
+// WithIOUring returns fs: io_uring is only available on Linux.
+func WithIOUring(fs FS) FS {
+	return fs
+}
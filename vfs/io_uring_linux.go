@@ -0,0 +1,527 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package vfs
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/sys/unix"
+)
+
+// WithIOUring wraps an FS so that reads from the files it opens for reading
+// are performed through io_uring. Reads issued concurrently by different
+// goroutines (eg, parallel iterators and compactions) are submitted to the
+// kernel in batches by a single goroutine, which amortizes the cost of system
+// calls across reads, instead of each read being a blocking pread system call
+// on its own goroutine. The handoff to the submitting goroutine adds latency
+// to reads that are served from the OS page cache, so WithIOUring is most
+// useful for reads that reach the device, eg when combined with WithDirectIO.
+//
+// Files that are not backed by a file descriptor (eg, MemFS files) are
+// returned unchanged. If io_uring is not available (eg, on kernels older than
+// 5.1, or because it is disabled by a seccomp policy), WithIOUring returns fs
+// itself.
+//
+// The io_uring instance is shared by all files opened by the returned FS, and
+// is released once all of them are closed.
+func WithIOUring(fs FS) FS {
+	r, err := newIOUring()
+	if err != nil {
+		return fs
+	}
+	r.stop()
+	return &ioUringFS{FS: fs}
+}
+
+type ioUringFS struct {
+	FS
+
+	mu   sync.Mutex
+	ring *ioUring
+	// refs is the number of open files that use ring.
+	refs int
+}
+
+var _ FS = (*ioUringFS)(nil)
+
+// Unwrap returns the underlying FS.
+func (fs *ioUringFS) Unwrap() FS {
+	return fs.FS
+}
+
+// Open implements FS.Open.
+func (fs *ioUringFS) Open(name string, opts ...OpenOption) (File, error) {
+	f, err := fs.FS.Open(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	fd := f.Fd()
+	if fd == InvalidFd {
+		return f, nil
+	}
+	r, err := fs.acquire()
+	if err != nil {
+		// Fall back to regular reads.
+		return f, nil
+	}
+	return &ioUringFile{File: f, fs: fs, ring: r, fd: int32(fd), name: name}, nil
+}
+
+func (fs *ioUringFS) acquire() (*ioUring, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.ring == nil {
+		r, err := newIOUring()
+		if err != nil {
+			return nil, err
+		}
+		fs.ring = r
+	}
+	fs.refs++
+	return fs.ring, nil
+}
+
+func (fs *ioUringFS) release() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.refs--; fs.refs == 0 {
+		fs.ring.stop()
+		fs.ring = nil
+	}
+}
+
+// ioUringFile is a File whose reads are performed through io_uring.
+type ioUringFile struct {
+	File
+	fs   *ioUringFS
+	ring *ioUring
+	fd   int32
+	name string
+	// readOffset is the offset of the next Read.
+	readOffset int64
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *ioUringFile) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	for n < len(p) {
+		m, err := f.ring.read(f.fd, p[n:], off+int64(n))
+		if err == errIOUringFailed {
+			m, err = f.File.ReadAt(p[n:], off+int64(n))
+			return n + m, err
+		}
+		if err != nil {
+			return n, &os.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		if m == 0 {
+			return n, io.EOF
+		}
+		n += m
+	}
+	return n, nil
+}
+
+// Read implements io.Reader.
+func (f *ioUringFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.readOffset)
+	f.readOffset += int64(n)
+	return n, err
+}
+
+// Close implements io.Closer.
+func (f *ioUringFile) Close() error {
+	err := f.File.Close()
+	f.fs.release()
+	f.ring = nil
+	return err
+}
+
+const (
+	// ioUringEntries is the size of the submission queue, which bounds the
+	// number of reads that are in flight at any time.
+	ioUringEntries = 256
+
+	ioringOpReadv        = 1
+	ioringEnterGetEvents = 1 << 0
+	ioringFeatSingleMmap = 1 << 0
+	ioringOffSQRing      = 0
+	ioringOffCQRing      = 0x8000000
+	ioringOffSQEs        = 0x10000000
+
+	// ioUringMinBackoff and ioUringMaxBackoff bound the time waited before
+	// retrying io_uring_enter after it failed.
+	ioUringMinBackoff = 10 * time.Microsecond
+	ioUringMaxBackoff = 10 * time.Millisecond
+
+	ioUringSQESize    = 64
+	ioUringCQESize    = 16
+	ioUringParamsSize = 120
+)
+
+// The following types mirror the kernel's io_uring ABI (see
+// include/uapi/linux/io_uring.h).
+
+type ioSQRingOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	flags       uint32
+	dropped     uint32
+	array       uint32
+	resv1       uint32
+	userAddr    uint64
+}
+
+type ioCQRingOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	overflow    uint32
+	cqes        uint32
+	flags       uint32
+	resv1       uint32
+	userAddr    uint64
+}
+
+type ioUringParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFd         uint32
+	resv         [3]uint32
+	sqOff        ioSQRingOffsets
+	cqOff        ioCQRingOffsets
+}
+
+type ioUringSQE struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	rwFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	pad         [2]uint64
+}
+
+type ioUringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// Assert that the types have the sizes defined by the ABI.
+var (
+	_ [ioUringSQESize - unsafe.Sizeof(ioUringSQE{})]struct{}
+	_ [unsafe.Sizeof(ioUringSQE{}) - ioUringSQESize]struct{}
+	_ [ioUringCQESize - unsafe.Sizeof(ioUringCQE{})]struct{}
+	_ [unsafe.Sizeof(ioUringCQE{}) - ioUringCQESize]struct{}
+	_ [ioUringParamsSize - unsafe.Sizeof(ioUringParams{})]struct{}
+	_ [unsafe.Sizeof(ioUringParams{}) - ioUringParamsSize]struct{}
+)
+
+// errIOUringFailed is returned for reads that could not be performed because
+// the io_uring instance failed. Such reads are retried with pread.
+var errIOUringFailed = errors.New("pebble: io_uring failed")
+
+// ioUringRequest is a read submitted to an ioUring.
+type ioUringRequest struct {
+	fd  int32
+	off int64
+	// iov describes the buffer read into. The buffer is referenced by the
+	// caller of ioUring.read for the duration of the read.
+	iov unix.Iovec
+	res int32
+	err error
+	// done is signaled when the read completes.
+	done chan struct{}
+}
+
+var ioUringRequestPool = sync.Pool{
+	New: func() interface{} {
+		return &ioUringRequest{done: make(chan struct{}, 1)}
+	},
+}
+
+// ioUring is an io_uring instance. Reads are sent to a goroutine (see run)
+// which owns the submission and completion queues.
+type ioUring struct {
+	fd int
+
+	sqRing, cqRing, sqesMem []byte
+
+	sqHead, sqTail *atomic.Uint32
+	sqMask         uint32
+	sqEntries      uint32
+	sqArray        []uint32
+	sqes           []ioUringSQE
+
+	cqHead, cqTail *atomic.Uint32
+	cqMask         uint32
+	cqes           []ioUringCQE
+
+	reqs    chan *ioUringRequest
+	stopped chan struct{}
+}
+
+func newIOUring() (_ *ioUring, err error) {
+	var p ioUringParams
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, ioUringEntries, uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	r := &ioUring{fd: int(fd)}
+	defer func() {
+		if err != nil {
+			r.unmap()
+		}
+	}()
+
+	mmap := func(offset int64, size uint32) ([]byte, error) {
+		return unix.Mmap(r.fd, offset, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	}
+	sqSize := p.sqOff.array + p.sqEntries*4
+	cqSize := p.cqOff.cqes + p.cqEntries*ioUringCQESize
+	if p.features&ioringFeatSingleMmap != 0 {
+		if r.sqRing, err = mmap(ioringOffSQRing, max(sqSize, cqSize)); err != nil {
+			return nil, err
+		}
+		r.cqRing = r.sqRing
+	} else {
+		if r.sqRing, err = mmap(ioringOffSQRing, sqSize); err != nil {
+			return nil, err
+		}
+		if r.cqRing, err = mmap(ioringOffCQRing, cqSize); err != nil {
+			return nil, err
+		}
+	}
+	if r.sqesMem, err = mmap(ioringOffSQEs, p.sqEntries*ioUringSQESize); err != nil {
+		return nil, err
+	}
+
+	r.sqHead = (*atomic.Uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.head]))
+	r.sqTail = (*atomic.Uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.tail]))
+	r.sqMask = *(*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.ringMask]))
+	r.sqEntries = p.sqEntries
+	r.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.array])), p.sqEntries)
+	r.sqes = unsafe.Slice((*ioUringSQE)(unsafe.Pointer(&r.sqesMem[0])), p.sqEntries)
+	r.cqHead = (*atomic.Uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.head]))
+	r.cqTail = (*atomic.Uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.tail]))
+	r.cqMask = *(*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.ringMask]))
+	r.cqes = unsafe.Slice((*ioUringCQE)(unsafe.Pointer(&r.cqRing[p.cqOff.cqes])), p.cqEntries)
+
+	r.reqs = make(chan *ioUringRequest, ioUringEntries)
+	r.stopped = make(chan struct{})
+	go r.run()
+	return r, nil
+}
+
+func (r *ioUring) unmap() {
+	if r.sqesMem != nil {
+		_ = unix.Munmap(r.sqesMem)
+	}
+	if r.cqRing != nil && (r.sqRing == nil || &r.cqRing[0] != &r.sqRing[0]) {
+		_ = unix.Munmap(r.cqRing)
+	}
+	if r.sqRing != nil {
+		_ = unix.Munmap(r.sqRing)
+	}
+	_ = unix.Close(r.fd)
+}
+
+// stop stops the ioUring once all submitted reads have completed, and
+// releases its resources. No reads may be submitted after stop is called.
+func (r *ioUring) stop() {
+	close(r.reqs)
+	<-r.stopped
+	r.unmap()
+}
+
+// read reads into p at offset off of the file with the given descriptor,
+// returning the number of bytes read. A short read is not an error; 0 bytes
+// are read at the end of the file.
+func (r *ioUring) read(fd int32, p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	req := ioUringRequestPool.Get().(*ioUringRequest)
+	defer ioUringRequestPool.Put(req)
+	for {
+		req.fd = fd
+		req.off = off
+		req.iov.Base = &p[0]
+		req.iov.SetLen(len(p))
+		r.reqs <- req
+		<-req.done
+		res, err := req.res, req.err
+		*req = ioUringRequest{done: req.done}
+		switch {
+		case err != nil:
+			return 0, err
+		case res >= 0:
+			return int(res), nil
+		case syscall.Errno(-res) == unix.EINTR || syscall.Errno(-res) == unix.EAGAIN:
+			continue
+		default:
+			return 0, syscall.Errno(-res)
+		}
+	}
+}
+
+// run submits the reads sent to r.reqs and signals their completion. Reads
+// that are sent while previously submitted reads are in flight are submitted
+// together, with a single system call.
+func (r *ioUring) run() {
+	defer close(r.stopped)
+
+	// slots holds the in-flight requests, indexed by the user data of their
+	// submission queue entries.
+	slots := make([]*ioUringRequest, r.sqEntries)
+	freeSlots := make([]uint64, r.sqEntries)
+	for i := range freeSlots {
+		freeSlots[i] = uint64(i)
+	}
+	var pending []*ioUringRequest
+	var failed error
+	// backoff is the time to wait before the next io_uring_enter, after it
+	// failed.
+	var backoff time.Duration
+	reqs := r.reqs
+	for {
+		inflight := len(slots) - len(freeSlots)
+		if inflight == 0 && len(pending) == 0 {
+			// Nothing to do but wait for a request.
+			req, ok := <-reqs
+			if !ok {
+				return
+			}
+			pending = append(pending, req)
+		}
+		// Collect the other requests that are ready without blocking.
+	collect:
+		for reqs != nil {
+			select {
+			case req, ok := <-reqs:
+				if !ok {
+					reqs = nil
+					break collect
+				}
+				pending = append(pending, req)
+			default:
+				break collect
+			}
+		}
+		if failed != nil {
+			for _, req := range pending {
+				req.err = failed
+				req.done <- struct{}{}
+			}
+			pending = pending[:0]
+		}
+
+		// Queue as many pending requests as there are free slots. The
+		// completion queue is at least as large as the submission queue, so it
+		// can't overflow.
+		tail := r.sqTail.Load()
+		for len(pending) > 0 && len(freeSlots) > 0 {
+			req := pending[0]
+			pending[0] = nil
+			pending = pending[1:]
+			slot := freeSlots[len(freeSlots)-1]
+			freeSlots = freeSlots[:len(freeSlots)-1]
+			slots[slot] = req
+
+			idx := tail & r.sqMask
+			r.sqes[idx] = ioUringSQE{
+				opcode:   ioringOpReadv,
+				fd:       req.fd,
+				off:      uint64(req.off),
+				addr:     uint64(uintptr(unsafe.Pointer(&req.iov))),
+				len:      1,
+				userData: slot,
+			}
+			r.sqArray[idx] = idx
+			tail++
+		}
+		r.sqTail.Store(tail)
+		if len(pending) == 0 {
+			pending = nil
+		}
+
+		// Submit the queued requests and wait for at least one completion.
+		toSubmit := tail - r.sqHead.Load()
+		if len(slots)-len(freeSlots) > 0 {
+			_, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd),
+				uintptr(toSubmit), 1 /* minComplete */, ioringEnterGetEvents, 0, 0)
+			switch errno {
+			case 0, unix.EINTR:
+				backoff = 0
+			case unix.EAGAIN, unix.EBUSY, unix.ENOMEM:
+				// The kernel is temporarily out of resources. The requests
+				// that were not submitted remain queued, and are submitted
+				// again after backing off.
+				backoff = min(max(2*backoff, ioUringMinBackoff), ioUringMaxBackoff)
+				time.Sleep(backoff)
+			default:
+				// Fail the requests that were not submitted, and any further
+				// requests; these are retried with pread.
+				failed = errIOUringFailed
+				head := r.sqHead.Load()
+				for ; tail != head; tail-- {
+					slot := r.sqes[(tail-1)&r.sqMask].userData
+					req := slots[slot]
+					slots[slot] = nil
+					freeSlots = append(freeSlots, slot)
+					req.err = failed
+					req.done <- struct{}{}
+				}
+				r.sqTail.Store(tail)
+				// The requests already in flight can't be failed, since the
+				// kernel may still write to their buffers. Their completions
+				// are polled for until they have all completed.
+				if len(slots)-len(freeSlots) > 0 {
+					backoff = min(max(2*backoff, ioUringMinBackoff), ioUringMaxBackoff)
+					time.Sleep(backoff)
+				}
+			}
+		}
+
+		// Reap the completions.
+		head := r.cqHead.Load()
+		for cqTail := r.cqTail.Load(); head != cqTail; head++ {
+			cqe := &r.cqes[head&r.cqMask]
+			req := slots[cqe.userData]
+			slots[cqe.userData] = nil
+			freeSlots = append(freeSlots, cqe.userData)
+			req.res = cqe.res
+			req.done <- struct{}{}
+		}
+		r.cqHead.Store(head)
+
+		if reqs == nil && len(pending) == 0 && len(slots) == len(freeSlots) {
+			return
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/rand"
+)
+
+func TestIOUring(t *testing.T) {
+	fs := WithIOUring(Default)
+	uringFS, ok := fs.(*ioUringFS)
+	if !ok {
+		t.Skip("io_uring is not available")
+	}
+	seed := uint64(time.Now().UnixNano())
+	t.Logf("seed: %d", seed)
+
+	data := make([]byte, 1<<20+123)
+	_, _ = rand.New(rand.NewSource(seed)).Read(data)
+	name := fs.PathJoin(t.TempDir(), "file")
+	f, err := fs.Create(name)
+	require.NoError(t, err)
+	_, err = f.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	f, err = fs.Open(name, RandomReadsOption)
+	require.NoError(t, err)
+	require.IsType(t, (*ioUringFile)(nil), f)
+	// A second file shares the io_uring instance.
+	f2, err := fs.Open(name)
+	require.NoError(t, err)
+	require.Equal(t, 2, uringFS.refs)
+
+	// Sequential reads.
+	got, err := io.ReadAll(f2)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(data, got))
+	require.NoError(t, f2.Close())
+
+	// Concurrent random reads, including reads that extend past the end of
+	// the file.
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(seed uint64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < 500; i++ {
+				off := rng.Intn(len(data) + 1)
+				p := make([]byte, rng.Intn(64<<10))
+				n, err := f.ReadAt(p, int64(off))
+				want := data[off:min(off+len(p), len(data))]
+				if n != len(want) || !bytes.Equal(want, p[:n]) {
+					t.Errorf("ReadAt(%d, %d) = %d, %v", len(p), off, n, err)
+					return
+				}
+				if (n < len(p)) != (err == io.EOF) || (err != nil && err != io.EOF) {
+					t.Errorf("ReadAt(%d, %d): unexpected error %v", len(p), off, err)
+					return
+				}
+			}
+		}(seed + uint64(g))
+	}
+	wg.Wait()
+
+	// The io_uring instance is released once all files are closed.
+	require.NoError(t, f.Close())
+	require.Zero(t, uringFS.refs)
+	require.Nil(t, uringFS.ring)
+}
+
+func TestIOUringFallback(t *testing.T) {
+	if _, ok := WithIOUring(Default).(*ioUringFS); !ok {
+		t.Skip("io_uring is not available")
+	}
+	// Files not backed by a file descriptor are returned unchanged.
+	mem := NewMem()
+	fs := WithIOUring(mem)
+	require.Equal(t, FS(mem), Root(fs))
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	f, err = fs.Open("foo")
+	require.NoError(t, err)
+	_, ok := f.(*ioUringFile)
+	require.False(t, ok)
+	require.NoError(t, f.Close())
+}
+
+func BenchmarkIOUringReadAt(b *testing.B) {
+	name := Default.PathJoin(b.TempDir(), "file")
+	f, err := Default.Create(name)
+	require.NoError(b, err)
+	data := make([]byte, 64<<20)
+	_, _ = rand.New(rand.NewSource(1)).Read(data)
+	_, err = f.Write(data)
+	require.NoError(b, err)
+	require.NoError(b, f.Close())
+
+	for _, tc := range []struct {
+		name string
+		fs   FS
+	}{{"pread", Default}, {"io_uring", WithIOUring(Default)}} {
+		b.Run(tc.name, func(b *testing.B) {
+			f, err := tc.fs.Open(name, RandomReadsOption)
+			require.NoError(b, err)
+			defer f.Close()
+			b.SetBytes(4096)
+			b.RunParallel(func(pb *testing.PB) {
+				rng := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
+				p := make([]byte, 4096)
+				for pb.Next() {
+					if _, err := f.ReadAt(p, rng.Int63n(int64(len(data)-len(p)))); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
@@ -275,6 +275,14 @@ func TestVFS(t *testing.T) {
 			}()
 			runTestVFS(t, Default, dir)
 		})
+		t.Run("io_uring", func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "test-vfs")
+			require.NoError(t, err)
+			defer func() {
+				_ = os.RemoveAll(dir)
+			}()
+			runTestVFS(t, WithIOUring(Default), dir)
+		})
 	}
 }
 